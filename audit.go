@@ -0,0 +1,34 @@
+package protojson
+
+import "google.golang.org/protobuf/proto"
+
+// MaskReport describes the masking applied while encoding a message.
+type MaskReport struct {
+	// Paths holds the path of each masked value in output order, e.g.
+	// users[0].email, including values left out by MaskOmit.
+	Paths []string
+
+	// Redactions is the number of matches replaced by Redactors.
+	Redactions int
+}
+
+// Masked returns the number of masked values.
+func (r MaskReport) Masked() int {
+	return len(r.Paths)
+}
+
+// auditMask records that the value at the current path was masked
+func (e *encoder) auditMask() {
+	e.masked++
+	if e.audit {
+		e.report.Paths = append(e.report.Paths, e.path.String())
+	}
+}
+
+// reportMasks passes the masking recorded while writing m to MaskAuditFunc
+func (e *Encoder) reportMasks(m proto.Message) {
+	if e.enc.audit {
+		e.opts.MaskAuditFunc(m, e.enc.report)
+		e.enc.report = MaskReport{}
+	}
+}
@@ -0,0 +1,91 @@
+package protojson_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TestMaskAuditFunc tests reporting of the values masked in each message
+func TestMaskAuditFunc(t *testing.T) {
+	msg := &pb_basic.ComplexMessage{
+		Id: "c1",
+		Users: []*pb_basic.User{
+			{Name: "alice", Email: "alice@example.com", Role: pb_basic.Role_ROLE_ADMIN, Metadata: map[string]string{"token": "t", "region": "eu"}},
+			{Name: "bob", Permissions: []string{"mail bob@example.com"}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want protojson.MaskReport
+	}{
+		{
+			name: "NoMasking",
+			want: protojson.MaskReport{},
+		},
+		{
+			name: "Fields",
+			opts: protojson.MarshalOptions{
+				FieldMaskFunc:   func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "email" || fd.Name() == "role" },
+				MaskPolicy:      protojson.MaskOmit,
+				MaskKeyPatterns: []string{"token"},
+			},
+			want: protojson.MaskReport{Paths: []string{`users[0].email`, `users[0].role`, `users[0].metadata["token"]`}},
+		},
+		{
+			name: "Redactions",
+			opts: protojson.MarshalOptions{Redactors: []protojson.Redactor{protojson.RedactEmails}},
+			want: protojson.MaskReport{Redactions: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reports []protojson.MaskReport
+			tt.opts.MaskAuditFunc = func(m proto.Message, report protojson.MaskReport) {
+				if m != msg {
+					t.Errorf("MaskAuditFunc() message = %v, want %v", m, msg)
+				}
+				reports = append(reports, report)
+			}
+			if _, err := tt.opts.Marshal(msg); err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff([]protojson.MaskReport{tt.want}, reports); diff != "" {
+				t.Errorf("MaskAuditFunc() reports mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMaskAuditFuncPerMessage tests that each encoded message gets its own
+// report
+func TestMaskAuditFuncPerMessage(t *testing.T) {
+	var counts []int
+	opts := protojson.MarshalOptions{
+		FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "email" },
+		MaskAuditFunc: func(m proto.Message, report protojson.MaskReport) {
+			counts = append(counts, report.Masked())
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := protojson.NewEncoderWithOptions(&buf, opts)
+	msgs := []proto.Message{
+		&pb_basic.User{Name: "alice", Email: "alice@example.com"},
+		&pb_basic.User{Name: "bob"},
+	}
+	if err := enc.EncodeList(msgs); err != nil {
+		t.Fatalf("EncodeList() error = %v", err)
+	}
+	if diff := cmp.Diff([]int{1, 0}, counts); diff != "" {
+		t.Errorf("Masked() counts mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -2,6 +2,7 @@ package protojson_test
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	pb "github.com/wreulicke/protojson/gen"
@@ -9,6 +10,8 @@ import (
 	stdprotojson "google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 // Benchmark basic types
@@ -188,6 +191,36 @@ func BenchmarkMapFields_Custom(b *testing.B) {
 	}
 }
 
+func BenchmarkMapFields_Unordered(b *testing.B) {
+	msg := &pb.MapFields{
+		StringMap: map[string]string{
+			"key1": "value1",
+			"key2": "value2",
+			"key3": "value3",
+			"key4": "value4",
+			"key5": "value5",
+		},
+		IntMap: map[string]int32{
+			"key1": 100,
+			"key2": 200,
+			"key3": 300,
+			"key4": 400,
+			"key5": 500,
+		},
+	}
+
+	var buf bytes.Buffer
+	encoder := protojson.NewEncoderWithOptions(&buf, protojson.MarshalOptions{UnorderedMaps: true})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := encoder.Encode(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func BenchmarkMapFields_Standard(b *testing.B) {
 	msg := &pb.MapFields{
 		StringMap: map[string]string{
@@ -524,3 +557,177 @@ func BenchmarkLargeRepeatedFields_Standard(b *testing.B) {
 		}
 	}
 }
+
+// Benchmark Marshal into a reused buffer
+func BenchmarkMarshalAppend_Custom(b *testing.B) {
+	msg := &pb.BasicTypes{
+		StringField: "hello",
+		Int32Field:  42,
+		Int64Field:  9223372036854775807,
+		BoolField:   true,
+		DoubleField: 2.718281828,
+	}
+
+	buf := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		buf, err = protojson.MarshalAppend(buf[:0], msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalAppend_Standard(b *testing.B) {
+	msg := &pb.BasicTypes{
+		StringField: "hello",
+		Int32Field:  42,
+		Int64Field:  9223372036854775807,
+		BoolField:   true,
+		DoubleField: 2.718281828,
+	}
+
+	buf := make([]byte, 0, 1024)
+	opts := stdprotojson.MarshalOptions{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		buf, err = opts.MarshalAppend(buf[:0], msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Benchmark generated MarshalProtoJSON methods
+func BenchmarkGeneratedScalars_Custom(b *testing.B) {
+	msg := &pb.GeneratedScalars{
+		StringField:   "hello",
+		Int32Field:    42,
+		Int64Field:    9223372036854775807,
+		Uint32Field:   123,
+		Uint64Field:   456,
+		Sint32Field:   -789,
+		Sint64Field:   -1011,
+		Fixed32Field:  111,
+		Fixed64Field:  222,
+		Sfixed32Field: -333,
+		Sfixed64Field: -444,
+		BoolField:     true,
+		FloatField:    3.14,
+		DoubleField:   2.718281828,
+		BytesField:    []byte("binary data"),
+		Color:         pb.GeneratedColor_GENERATED_COLOR_RED,
+		Int64List:     []int64{1, 2, 3},
+	}
+
+	var buf bytes.Buffer
+	encoder := protojson.NewEncoder(&buf)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := encoder.Encode(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGeneratedScalars_Reflect(b *testing.B) {
+	msg := &pb.GeneratedScalars{
+		StringField:   "hello",
+		Int32Field:    42,
+		Int64Field:    9223372036854775807,
+		Uint32Field:   123,
+		Uint64Field:   456,
+		Sint32Field:   -789,
+		Sint64Field:   -1011,
+		Fixed32Field:  111,
+		Fixed64Field:  222,
+		Sfixed32Field: -333,
+		Sfixed64Field: -444,
+		BoolField:     true,
+		FloatField:    3.14,
+		DoubleField:   2.718281828,
+		BytesField:    []byte("binary data"),
+		Color:         pb.GeneratedColor_GENERATED_COLOR_RED,
+		Int64List:     []int64{1, 2, 3},
+	}
+
+	var buf bytes.Buffer
+	encoder := protojson.NewEncoder(&buf)
+	// A dynamic copy is written with protoreflect
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	dm := dynamicpb.NewMessage(msg.ProtoReflect().Descriptor())
+	if err := proto.Unmarshal(data, dm); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := encoder.EncodeReflect(dm); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Benchmark messages dominated by long strings without escapes
+func BenchmarkLongStrings_Custom(b *testing.B) {
+	msg := &pb.RepeatedFields{}
+	for i := 0; i < 100; i++ {
+		msg.Strings = append(msg.Strings, strings.Repeat("lorem ipsum dolor sit amet ", 40))
+	}
+
+	var buf bytes.Buffer
+	encoder := protojson.NewEncoder(&buf)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := encoder.Encode(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLongStrings_Standard(b *testing.B) {
+	msg := &pb.RepeatedFields{}
+	for i := 0; i < 100; i++ {
+		msg.Strings = append(msg.Strings, strings.Repeat("lorem ipsum dolor sit amet ", 40))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := stdprotojson.Marshal(msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Benchmark messages dominated by enum values
+func BenchmarkRepeatedEnums_Custom(b *testing.B) {
+	msg := &pb.RepeatedEnums{}
+	for i := 0; i < 1000; i++ {
+		msg.Statuses = append(msg.Statuses, pb.Status(i%4))
+		msg.Priorities = append(msg.Priorities, pb.Priority(i%4))
+	}
+
+	var buf bytes.Buffer
+	encoder := protojson.NewEncoder(&buf)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := encoder.Encode(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
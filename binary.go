@@ -0,0 +1,625 @@
+package protojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// binaryMember is a member of an object in the output of a binary encoder
+type binaryMember struct {
+	key string
+	val any
+}
+
+// binaryObject is an object in the output of a binary encoder, with its
+// members in the order of the JSON output
+type binaryObject []binaryMember
+
+// binaryEncoder walks messages into values typed by their fields, for
+// encoders of binary formats with the semantics of the JSON encoding:
+// fields are selected, named, masked and ordered with the options of the
+// JSON encoder. Values are nil, bool, int32, int64, uint32, uint64,
+// float32, float64, string, []byte, time.Time, time.Duration, []any and
+// binaryObject. Values that do not have the form of their field type, such
+// as masked numbers or values of custom Formatters, are converted from
+// their JSON encoding as plain JSON values.
+type binaryEncoder struct {
+	enc *Encoder     // Holds the options and state of the JSON encoder
+	rec bytes.Buffer // JSON encoding of the value being converted
+}
+
+// init sets the options of e, resetting those that only apply to JSON
+// output
+func (e *binaryEncoder) init(opts MarshalOptions) {
+	opts.Indent = ""
+	opts.Multiline = false
+	opts.Canonical = false
+	opts.Flatten = false
+	opts.TruncateOutput = false
+	opts.MaxOutputBytes = 0
+	opts.Comments = nil
+	opts.Hooks = Hooks{}
+	opts.NonFinitePolicy = NonFiniteString
+	opts.FloatFormat = FloatFormatStandard
+	opts.TimestampFormat = TimestampRFC3339
+	opts.TimestampEpochAsString = false
+	opts.DurationFormat = DurationSeconds
+	opts.BytesEncoding = BytesBase64
+	e.enc = NewEncoderWithOptions(&e.rec, opts)
+}
+
+// encode returns the typed value of m
+func (e *binaryEncoder) encode(m proto.Message) (any, error) {
+	if !e.enc.opts.AllowPartial {
+		if err := proto.CheckInitialized(m); err != nil {
+			return nil, err
+		}
+	}
+	e.enc.prepare()
+	e.enc.enc.root = true
+	v, err := e.message(m.ProtoReflect())
+	if err != nil {
+		return nil, err
+	}
+	e.enc.reportMasks(m)
+	return v, nil
+}
+
+// message returns the value of m
+func (e *binaryEncoder) message(m protoreflect.Message) (any, error) {
+	enc := &e.enc.enc
+	plan := enc.planOf(m.Descriptor())
+	typeNamed := enc.opts.EmitTypeName != TypeNameNone && enc.typeNamed()
+	if f, ok := enc.opts.Formatters[plan.name]; ok {
+		return e.plain(func() error { return enc.marshalFormatted(f, m) })
+	}
+	switch {
+	case plan.name == "google.protobuf.Timestamp":
+		seconds, nanos, err := timestampFields(m)
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(seconds, nanos).UTC(), nil
+	case plan.name == "google.protobuf.Duration":
+		return durationValue(m)
+	case plan.name == "google.protobuf.Any":
+		return e.any(m)
+	case isWrapperType(plan.name):
+		return e.wrapper(m)
+	case plan.wellKnown != nil:
+		return e.plain(func() error { return plan.wellKnown(enc, m) })
+	}
+	if f := enc.googleTypeFormatter(plan.name); f != nil {
+		return e.plain(func() error { return f(enc, m) })
+	}
+
+	var obj binaryObject
+	if typeNamed {
+		obj = append(obj, binaryMember{enc.typeNameKey(), string(plan.name)})
+	}
+	return e.fields(m, plan, obj)
+}
+
+// durationValue returns the google.protobuf.Duration m as a time.Duration,
+// or as a float64 number of seconds if it is out of its range
+func durationValue(m protoreflect.Message) (any, error) {
+	seconds, nanos, err := durationFields(m)
+	if err != nil {
+		return nil, err
+	}
+	if seconds > math.MaxInt64/int64(time.Second)-1 || seconds < math.MinInt64/int64(time.Second)+1 {
+		return float64(seconds) + float64(nanos)/1e9, nil
+	}
+	return time.Duration(seconds)*time.Second + time.Duration(nanos), nil
+}
+
+// fields appends the populated fields of m to obj, like marshalFields
+func (e *binaryEncoder) fields(m protoreflect.Message, plan *messagePlan, obj binaryObject) (binaryObject, error) {
+	enc := &e.enc.enc
+	fields := enc.fields(plan)
+	for i := range fields {
+		f := &fields[i]
+		fd := f.fd
+
+		unset := false
+		if !m.Has(fd) {
+			declared := f.declared && enc.opts.EmitDeclaredDefaults
+			if !declared && !enc.emitUnpopulated(f) {
+				continue
+			}
+			unset = f.presence && !declared
+		}
+		if f.singular && enc.omitEnum(fd, m.Get(fd)) {
+			continue
+		}
+		if enc.trackPath && enc.enterPath(fieldStep(fd)) {
+			continue
+		}
+		if f.singular && enc.omitMasked(fd) {
+			if enc.trackPath {
+				enc.leavePath()
+			}
+			continue
+		}
+
+		var val any
+		if !unset {
+			var err error
+			if val, err = e.field(fd, m.Get(fd)); err != nil {
+				return nil, err
+			}
+		}
+		member := binaryMember{plannedName(enc.plannedKey(f)), val}
+		if enc.opts.WrapOneofs && f.oneofKey != "" {
+			member = binaryMember{plannedName(enc.plannedOneofKey(f)), binaryObject{member}}
+		}
+		obj = append(obj, member)
+		if enc.trackPath {
+			enc.leavePath()
+		}
+	}
+
+	var err error
+	if plan.extensions {
+		if obj, err = e.extensions(m, obj); err != nil {
+			return nil, err
+		}
+	}
+	if enc.opts.EmitUnknownFields && len(m.GetUnknown()) > 0 {
+		ext, rest, err := enc.unknownFields(m)
+		if err != nil {
+			return nil, err
+		}
+		if ext != nil {
+			if obj, err = e.extensions(ext, obj); err != nil {
+				return nil, err
+			}
+		}
+		if len(rest) > 0 {
+			obj = append(obj, binaryMember{enc.unknownFieldsKey(), rest})
+		}
+	}
+	return obj, nil
+}
+
+// plannedName returns the name in key, a planned object key
+func plannedName(key string) string {
+	return key[1 : len(key)-len(`": `)]
+}
+
+// extensions appends the populated extension fields of m to obj, like
+// marshalExtensions
+func (e *binaryEncoder) extensions(m protoreflect.Message, obj binaryObject) (binaryObject, error) {
+	enc := &e.enc.enc
+	for _, fd := range sortedExtensions(m) {
+		if enc.trackPath && enc.enterPath(fieldStep(fd)) {
+			continue
+		}
+		if !fd.IsList() && enc.omitMasked(fd) {
+			if enc.trackPath {
+				enc.leavePath()
+			}
+			continue
+		}
+		val, err := e.field(fd, m.Get(fd))
+		if err != nil {
+			return nil, err
+		}
+		key := "[" + string(fd.FullName()) + "]"
+		if enc.opts.UseFieldNumbers {
+			key = strconv.Itoa(int(fd.Number()))
+		}
+		obj = append(obj, binaryMember{key, val})
+		if enc.trackPath {
+			enc.leavePath()
+		}
+	}
+	return obj, nil
+}
+
+// any returns the value of the google.protobuf.Any m, resolving the
+// embedded message with the configured Resolver like marshalAny
+func (e *binaryEncoder) any(m protoreflect.Message) (any, error) {
+	enc := &e.enc.enc
+	fields := m.Descriptor().Fields()
+	typeURL := m.Get(fields.ByName("type_url")).String()
+	value := m.Get(fields.ByName("value")).Bytes()
+	if typeURL == "" && len(value) == 0 {
+		return binaryObject{}, nil
+	}
+	typeURL, err := e.text(fields.ByName("type_url"), typeURL)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := binaryObject{{"@type", typeURL}}
+	msg, err := unmarshalAny(enc.resolver(), typeURL, value)
+	switch {
+	case err != nil:
+		switch enc.opts.UnresolvedAny {
+		case UnresolvedAnyBase64:
+			return append(obj, binaryMember{"value", value}), nil
+		case UnresolvedAnySkip:
+			return obj, nil
+		}
+		return nil, err
+	case enc.formatted(msg.Descriptor().FullName()):
+		val, err := e.message(msg)
+		if err != nil {
+			return nil, err
+		}
+		return append(obj, binaryMember{"value", val}), nil
+	}
+	return e.fields(msg, enc.planOf(msg.Descriptor()), obj)
+}
+
+// wrapper returns the value of a wrapper type message m
+func (e *binaryEncoder) wrapper(m protoreflect.Message) (any, error) {
+	enc := &e.enc.enc
+	fd := m.Descriptor().Fields().ByName("value")
+	if fd == nil {
+		return nil, fmt.Errorf("wrapper type missing value field")
+	}
+	val, err := e.singular(fd, m.Get(fd), enc.maskField(fd))
+	if err != nil || !enc.opts.WrapperAsObject {
+		return val, err
+	}
+	return binaryObject{{"value", val}}, nil
+}
+
+// field returns the value v of fd, handling repeated and map fields
+func (e *binaryEncoder) field(fd protoreflect.FieldDescriptor, v protoreflect.Value) (any, error) {
+	enc := &e.enc.enc
+	if enc.opts.RawJSONFunc != nil {
+		if raw, ok := enc.opts.RawJSONFunc(fd, v); ok {
+			return e.plain(func() error { return enc.writeRawJSON(fd, raw) })
+		}
+	}
+	switch {
+	case fd.IsList():
+		return e.list(fd, v.List())
+	case fd.IsMap():
+		return e.mapValue(fd, v.Map())
+	}
+	return e.singular(fd, v, enc.maskField(fd))
+}
+
+// list returns the elements of a repeated field, like marshalList
+func (e *binaryEncoder) list(fd protoreflect.FieldDescriptor, list protoreflect.List) (any, error) {
+	enc := &e.enc.enc
+	listMasked := enc.maskDescriptor(fd)
+	elems := make([]any, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		if len(elems) == enc.opts.MaxListElements && len(elems) > 0 {
+			elems = append(elems, omittedMarker(list.Len()-i, "items"))
+			break
+		}
+		v := list.Get(i)
+		if enc.omitEnum(fd, v) {
+			continue
+		}
+		if enc.trackPath && enc.enterPath(indexStep(i)) {
+			continue
+		}
+		masked := listMasked || enc.opts.FieldMaskPathFunc != nil && enc.opts.FieldMaskPathFunc(enc.path, fd)
+		if masked && enc.maskOmits(fd) {
+			enc.auditMask()
+		} else {
+			val, err := e.singular(fd, v, masked)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, val)
+		}
+		if enc.trackPath {
+			enc.leavePath()
+		}
+	}
+	return elems, nil
+}
+
+// mapValue returns the entries of a map field, like marshalMap
+func (e *binaryEncoder) mapValue(fd protoreflect.FieldDescriptor, m protoreflect.Map) (any, error) {
+	enc := &e.enc.enc
+	keys := make([]protoreflect.MapKey, 0, m.Len())
+	m.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	if !enc.opts.UnorderedMaps {
+		slices.SortFunc(keys, mapKeyCompare(fd.MapKey().Kind()))
+	}
+
+	obj := make(binaryObject, 0, len(keys))
+	for i, k := range keys {
+		if len(obj) == enc.opts.MaxMapEntries && len(obj) > 0 {
+			obj = append(obj, binaryMember{omittedMarker(len(keys)-i, "entries"), nil})
+			break
+		}
+		v := m.Get(k)
+		if enc.omitEnum(fd.MapValue(), v) {
+			continue
+		}
+		if enc.trackPath && enc.enterPath(keyStep(k)) {
+			continue
+		}
+		member, ok, err := e.mapEntry(fd, len(obj), k, v)
+		if enc.trackPath {
+			enc.leavePath()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			obj = append(obj, member)
+		}
+	}
+	return obj, nil
+}
+
+// mapEntry returns the i-th written entry of a map field, like
+// marshalMapEntry. It reports false if the entry is left out by MaskOmit.
+func (e *binaryEncoder) mapEntry(fd protoreflect.FieldDescriptor, i int, k protoreflect.MapKey, v protoreflect.Value) (binaryMember, bool, error) {
+	enc := &e.enc.enc
+	masked, err := enc.maskMapEntry(fd, k)
+	if err != nil {
+		return binaryMember{}, false, err
+	}
+	if masked && enc.maskOmits(fd.MapValue()) {
+		enc.auditMask()
+		return binaryMember{}, false, nil
+	}
+
+	key := k.String()
+	if masked && enc.opts.MaskMapKeys {
+		key = enc.maskedKey(i, k)
+	}
+	if key, err = e.text(fd, key); err != nil {
+		return binaryMember{}, false, err
+	}
+	val, err := e.singular(fd.MapValue(), v, masked)
+	return binaryMember{key, val}, true, err
+}
+
+// singular returns a singular value of fd, replaced if masked, like
+// marshalSingularValue
+func (e *binaryEncoder) singular(fd protoreflect.FieldDescriptor, v protoreflect.Value, masked bool) (any, error) {
+	enc := &e.enc.enc
+	if enc.opts.TransformValue != nil {
+		var err error
+		if v, err = enc.opts.TransformValue(fd, v); err != nil {
+			return nil, fmt.Errorf("field %s: %w", fd.FullName(), err)
+		}
+		if !v.IsValid() {
+			return nil, nil
+		}
+	}
+	if masked {
+		kind := fd.Kind()
+		if kind == protoreflect.StringKind || kind == protoreflect.BytesKind {
+			enc.auditMask()
+			return e.plain(func() error { return enc.writeMask(fd, v) })
+		}
+		if enc.opts.MaskPolicy != MaskUnchanged {
+			enc.auditMask()
+			return e.converted(fd, func() error { return enc.writeMaskPolicy(fd) })
+		}
+	}
+	if enc.opts.FieldMaskValueFunc != nil {
+		var err error
+		if v, masked, err = enc.maskValue(fd, v); err != nil {
+			return nil, err
+		}
+		if masked && !v.IsValid() {
+			return nil, nil
+		}
+	}
+
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return v.Bool(), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return int32(v.Int()), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return v.Int(), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return uint32(v.Uint()), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return v.Uint(), nil
+	case protoreflect.FloatKind:
+		return float32(v.Float()), nil
+	case protoreflect.DoubleKind:
+		return v.Float(), nil
+	case protoreflect.StringKind:
+		s := v.String()
+		if len(enc.opts.Redactors) > 0 {
+			s = enc.redact(s)
+		}
+		if n := enc.opts.TruncateLength; n > 0 && len(s) > n {
+			s = truncateString(s, n)
+		}
+		return e.text(fd, s)
+	case protoreflect.BytesKind:
+		if n := enc.opts.TruncateLength; n > 0 && len(v.Bytes()) > n {
+			// Truncated bytes are written as base64 followed by the marker
+			return e.plain(func() error { return writeBytes(enc, fd, v) })
+		}
+		return v.Bytes(), nil
+	case protoreflect.EnumKind:
+		if !enc.opts.UseEnumNumbers && !enc.opts.EnumAsObject && fd.Enum().FullName() != "google.protobuf.NullValue" {
+			if name, ok := enc.enumName(fd.Enum(), v.Enum()); ok {
+				return name[1 : len(name)-1], nil
+			}
+		}
+		return e.converted(fd, func() error { return writeEnum(enc, fd, v) })
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return e.message(v.Message())
+	}
+	return nil, fmt.Errorf("unknown field kind: %v", fd.Kind())
+}
+
+// text returns the string s of fd, checked for invalid UTF-8 like
+// marshalString
+func (e *binaryEncoder) text(fd protoreflect.FieldDescriptor, s string) (string, error) {
+	if utf8.ValidString(s) {
+		return s, nil
+	}
+	if !e.enc.opts.AllowInvalidUTF8 {
+		return "", fmt.Errorf("field %s: %w", fd.FullName(), ErrInvalidUTF8)
+	}
+	// Replace each invalid byte with U+FFFD
+	return strings.Map(func(r rune) rune { return r }, s), nil
+}
+
+// record returns the JSON value written by write
+func (e *binaryEncoder) record(write func() error) (*jsonNode, error) {
+	e.rec.Reset()
+	if err := write(); err != nil {
+		return nil, err
+	}
+	return parseJSON(e.rec.Bytes())
+}
+
+// plain returns the JSON value written by write as a plain value
+func (e *binaryEncoder) plain(write func() error) (any, error) {
+	n, err := e.record(write)
+	if err != nil {
+		return nil, err
+	}
+	return plainValue(n), nil
+}
+
+// converted returns the JSON value written by write for a singular value
+// of fd, typed by fd where it has the form of its type
+func (e *binaryEncoder) converted(fd protoreflect.FieldDescriptor, write func() error) (any, error) {
+	n, err := e.record(write)
+	if err != nil {
+		return nil, err
+	}
+	return binarySingular(fd, n), nil
+}
+
+// binarySingular converts the JSON value of a singular field value
+func binarySingular(fd protoreflect.FieldDescriptor, n *jsonNode) any {
+	if string(n.raw) == "null" {
+		return nil
+	}
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if i, ok := jsonInt(jsonScalar(n), 32); ok {
+			return int32(i)
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if i, ok := jsonInt(jsonScalar(n), 64); ok {
+			return i
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if u, ok := jsonUint(jsonScalar(n), 32); ok {
+			return uint32(u)
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if u, ok := jsonUint(jsonScalar(n), 64); ok {
+			return u
+		}
+	case protoreflect.FloatKind:
+		if f, ok := jsonFloat(jsonScalar(n), 32); ok {
+			return float32(f)
+		}
+	case protoreflect.DoubleKind:
+		if f, ok := jsonFloat(jsonScalar(n), 64); ok {
+			return f
+		}
+	case protoreflect.BytesKind:
+		if s, ok := jsonString(n); ok {
+			if b, err := decodeBase64(s); err == nil {
+				return b
+			}
+		}
+	case protoreflect.EnumKind:
+		if i, ok := jsonInt(jsonScalar(n), 32); ok && n.raw[0] != '"' {
+			return int32(i)
+		}
+	}
+	return plainValue(n)
+}
+
+// jsonScalar returns the JSON scalar n as a json.Number or string, or nil
+func jsonScalar(n *jsonNode) any {
+	if n.kind != 0 {
+		return nil
+	}
+	if s, ok := jsonString(n); ok {
+		return s
+	}
+	return json.Number(n.raw)
+}
+
+// jsonString returns the value of n if it is a JSON string
+func jsonString(n *jsonNode) (string, bool) {
+	if n.kind != 0 || len(n.raw) == 0 || n.raw[0] != '"' {
+		return "", false
+	}
+	return unquoteKey(n.raw)
+}
+
+// unquoteKey returns the value of the quoted JSON string b
+func unquoteKey(b []byte) (string, bool) {
+	if bytes.IndexByte(b, '\\') < 0 {
+		return string(b[1 : len(b)-1]), true
+	}
+	var s string
+	return s, json.Unmarshal(b, &s) == nil
+}
+
+// plainValue converts a JSON value without a field type: numbers become
+// int64 when integral, uint64 when integral but too large for int64, and
+// float64 otherwise
+func plainValue(n *jsonNode) any {
+	switch n.kind {
+	case '{':
+		obj := make(binaryObject, len(n.entries))
+		for i := range n.entries {
+			key, _ := unquoteKey(n.entries[i].key)
+			obj[i] = binaryMember{key, plainValue(n.entries[i].val)}
+		}
+		return obj
+	case '[':
+		list := make([]any, len(n.entries))
+		for i := range n.entries {
+			list[i] = plainValue(n.entries[i].val)
+		}
+		return list
+	}
+	switch s := string(n.raw); s {
+	case "null":
+		return nil
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if s, ok := jsonString(n); ok {
+		return s
+	}
+	if i, err := strconv.ParseInt(string(n.raw), 10, 64); err == nil {
+		return i
+	}
+	if u, err := strconv.ParseUint(string(n.raw), 10, 64); err == nil {
+		return u
+	}
+	f, err := strconv.ParseFloat(string(n.raw), 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
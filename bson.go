@@ -0,0 +1,187 @@
+package protojson
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// BSON element types
+const (
+	bsonDouble   = 0x01
+	bsonString   = 0x02
+	bsonDocument = 0x03
+	bsonArray    = 0x04
+	bsonBinary   = 0x05
+	bsonBool     = 0x08
+	bsonDateTime = 0x09
+	bsonNull     = 0x0a
+	bsonInt32    = 0x10
+	bsonInt64    = 0x12
+)
+
+// BSONEncoder writes messages as BSON documents with the semantics of
+// their JSON encoding, so that they can be inserted into MongoDB with
+// protojson field names without converting them to maps first. Fields are
+// named and ordered as in the JSON output, so options such as
+// UseProtoNames, EmitUnpopulated and masking apply as they do to JSON.
+// Values are typed by their fields rather than following JSON: 32-bit
+// integers are int32, 64-bit integers and uint32 are int64, floats are
+// doubles and bytes are binary. uint64 values too large for int64 are
+// written as decimal strings, as in JSON. google.protobuf.Timestamp is
+// written as a UTC datetime, truncated to milliseconds, and
+// google.protobuf.Duration as a number of seconds. Options that only
+// apply to JSON output, such as Indent, BytesEncoding, TimestampFormat or
+// MaxOutputBytes, are ignored. Messages must be written as JSON
+// objects, so well-known types such as google.protobuf.Timestamp cannot be
+// encoded at the top level.
+type BSONEncoder struct {
+	w   io.Writer
+	bin binaryEncoder
+	buf []byte
+}
+
+// NewBSONEncoder returns a new BSON encoder that writes to w using the
+// provided MarshalOptions.
+func NewBSONEncoder(w io.Writer, opts MarshalOptions) *BSONEncoder {
+	e := &BSONEncoder{w: w}
+	e.bin.init(opts)
+	return e
+}
+
+// Encode writes m as a BSON document.
+func (e *BSONEncoder) Encode(m proto.Message) error {
+	var err error
+	e.buf, err = e.bin.appendBSON(e.buf[:0], m)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(e.buf)
+	return err
+}
+
+// MarshalBSON returns the BSON encoding of m using options in o. See
+// BSONEncoder.
+func (o MarshalOptions) MarshalBSON(m proto.Message) ([]byte, error) {
+	var e binaryEncoder
+	e.init(o)
+	return e.appendBSON(nil, m)
+}
+
+// appendBSON appends the BSON document for m
+func (e *binaryEncoder) appendBSON(b []byte, m proto.Message) ([]byte, error) {
+	v, err := e.encode(m)
+	if err != nil {
+		return b, err
+	}
+	obj, ok := v.(binaryObject)
+	if !ok {
+		return b, fmt.Errorf("%s is not encoded as a JSON object", m.ProtoReflect().Descriptor().FullName())
+	}
+	return appendBSONDocument(b, obj)
+}
+
+// appendBSONDocument appends a document holding the members of obj
+func appendBSONDocument(b []byte, obj binaryObject) ([]byte, error) {
+	start := len(b)
+	b = append(b, 0, 0, 0, 0) // Length, set by endBSONDocument
+	var err error
+	for _, m := range obj {
+		if strings.IndexByte(m.key, 0) >= 0 {
+			return b, fmt.Errorf("key %q contains a NUL byte", m.key)
+		}
+		if b, err = appendBSONElement(b, m.key, m.val); err != nil {
+			return b, err
+		}
+	}
+	return endBSONDocument(b, start)
+}
+
+// appendBSONArray appends a document holding the elements of list, keyed
+// by index
+func appendBSONArray(b []byte, list []any) ([]byte, error) {
+	start := len(b)
+	b = append(b, 0, 0, 0, 0)
+	var err error
+	for i, elem := range list {
+		if b, err = appendBSONElement(b, strconv.Itoa(i), elem); err != nil {
+			return b, err
+		}
+	}
+	return endBSONDocument(b, start)
+}
+
+// endBSONDocument terminates the document starting at b[start] and sets
+// its length
+func endBSONDocument(b []byte, start int) ([]byte, error) {
+	b = append(b, 0)
+	if len(b)-start > math.MaxInt32 {
+		return b, errors.New("document exceeds the maximum BSON size")
+	}
+	binary.LittleEndian.PutUint32(b[start:], uint32(len(b)-start))
+	return b, nil
+}
+
+// appendBSONHead appends the type and key of an element
+func appendBSONHead(b []byte, typ byte, key string) []byte {
+	b = append(b, typ)
+	b = append(b, key...)
+	return append(b, 0)
+}
+
+// appendBSONElement appends the element for a value converted by
+// binaryEncoder
+func appendBSONElement(b []byte, key string, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return appendBSONHead(b, bsonNull, key), nil
+	case bool:
+		b = appendBSONHead(b, bsonBool, key)
+		if v {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case int32:
+		return binary.LittleEndian.AppendUint32(appendBSONHead(b, bsonInt32, key), uint32(v)), nil
+	case uint32:
+		return binary.LittleEndian.AppendUint64(appendBSONHead(b, bsonInt64, key), uint64(v)), nil
+	case int64:
+		return binary.LittleEndian.AppendUint64(appendBSONHead(b, bsonInt64, key), uint64(v)), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return appendBSONElement(b, key, strconv.FormatUint(v, 10))
+		}
+		return binary.LittleEndian.AppendUint64(appendBSONHead(b, bsonInt64, key), v), nil
+	case float32:
+		return appendBSONElement(b, key, float64(v))
+	case float64:
+		return binary.LittleEndian.AppendUint64(appendBSONHead(b, bsonDouble, key), math.Float64bits(v)), nil
+	case string:
+		b = binary.LittleEndian.AppendUint32(appendBSONHead(b, bsonString, key), uint32(len(v)+1))
+		b = append(b, v...)
+		return append(b, 0), nil
+	case []byte:
+		b = binary.LittleEndian.AppendUint32(appendBSONHead(b, bsonBinary, key), uint32(len(v)))
+		b = append(b, 0) // Generic binary subtype
+		return append(b, v...), nil
+	case time.Time:
+		return binary.LittleEndian.AppendUint64(appendBSONHead(b, bsonDateTime, key), uint64(v.UnixMilli())), nil
+	case time.Duration:
+		if v%time.Second == 0 {
+			return appendBSONElement(b, key, int64(v/time.Second))
+		}
+		return appendBSONElement(b, key, v.Seconds())
+	case []any:
+		return appendBSONArray(appendBSONHead(b, bsonArray, key), v)
+	case binaryObject:
+		return appendBSONDocument(appendBSONHead(b, bsonDocument, key), v)
+	}
+	return b, fmt.Errorf("unexpected value of type %T", v)
+}
@@ -0,0 +1,153 @@
+package protojson_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// bsonDoc returns the hex encoding of a BSON document holding the given
+// hex encoded elements
+func bsonDoc(elems ...string) string {
+	var body []byte
+	for _, e := range elems {
+		b, _ := hex.DecodeString(e)
+		body = append(body, b...)
+	}
+	doc := binary.LittleEndian.AppendUint32(nil, uint32(len(body)+5))
+	return hex.EncodeToString(append(append(doc, body...), 0))
+}
+
+// bsonElem returns the hex encoding of a BSON element with the given type,
+// key and hex encoded value
+func bsonElem(typ byte, key, value string) string {
+	return hex.EncodeToString(append(append([]byte{typ}, key...), 0)) + value
+}
+
+// bsonStr returns the hex encoding of a BSON string value
+func bsonStr(s string) string {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(s)+1))
+	return hex.EncodeToString(append(append(b, s...), 0))
+}
+
+// TestMarshalBSON tests the BSON encoding of messages
+func TestMarshalBSON(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Scalars",
+			msg: &pb_basic.BasicTypes{
+				StringField: "a",
+				Int32Field:  -1,
+				Int64Field:  2,
+				Uint64Field: 1 << 63,
+				BoolField:   true,
+				FloatField:  1.5,
+				BytesField:  []byte{7},
+			},
+			want: bsonDoc(
+				bsonElem(0x02, "stringField", bsonStr("a")),
+				bsonElem(0x10, "int32Field", "ffffffff"),
+				bsonElem(0x12, "int64Field", "0200000000000000"),
+				bsonElem(0x02, "uint64Field", bsonStr("9223372036854775808")),
+				bsonElem(0x08, "boolField", "01"),
+				bsonElem(0x01, "floatField", "000000000000f83f"),
+				bsonElem(0x05, "bytesField", "010000000007"),
+			),
+		},
+		{
+			name: "Nested",
+			msg: &pb_basic.User{
+				Id:          "u1",
+				Permissions: []string{"r"},
+				Profile:     &pb_basic.Profile{Bio: "b"},
+			},
+			opts: protojson.MarshalOptions{UseProtoNames: true},
+			want: bsonDoc(
+				bsonElem(0x02, "id", bsonStr("u1")),
+				bsonElem(0x04, "permissions", bsonDoc(bsonElem(0x02, "0", bsonStr("r")))),
+				bsonElem(0x03, "profile", bsonDoc(bsonElem(0x02, "bio", bsonStr("b")))),
+			),
+		},
+		{
+			name: "WellKnownTypes",
+			msg: &pb_basic.WellKnownTypes{
+				Timestamp: timestamppb.New(time.UnixMilli(1700000000123).Add(456)),
+				Duration:  durationpb.New(90 * time.Second),
+			},
+			want: bsonDoc(
+				bsonElem(0x09, "timestamp", "7b68e5cf8b010000"),
+				bsonElem(0x12, "duration", "5a00000000000000"),
+			),
+		},
+		{
+			name: "Masked",
+			msg:  &pb_basic.RedactedFields{Password: "p", Pin: 1234},
+			opts: protojson.MarshalOptions{MaskDebugRedact: true, MaskPolicy: protojson.MaskNull},
+			want: bsonDoc(
+				bsonElem(0x02, "password", bsonStr("***")),
+				bsonElem(0x0a, "pin", ""),
+			),
+		},
+		{
+			name: "Empty",
+			msg:  &pb_basic.EmptyMessage{},
+			want: "0500000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.MarshalBSON(tt.msg)
+			if err != nil {
+				t.Fatalf("MarshalBSON() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, hex.EncodeToString(got)); diff != "" {
+				t.Errorf("MarshalBSON() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestBSONEncoderErrors tests values that cannot be written as BSON
+func TestBSONEncoderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+	}{
+		{
+			name: "NotObject",
+			msg:  timestamppb.Now(),
+		},
+		{
+			name: "NULInKey",
+			msg:  &pb_basic.MapFields{StringMap: map[string]string{"a\x00b": "c"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := protojson.NewBSONEncoder(&buf, protojson.MarshalOptions{})
+			if err := enc.Encode(tt.msg); err == nil {
+				t.Error("Encode() succeeded, want error")
+			}
+			if buf.Len() != 0 {
+				t.Errorf("Encode() wrote %d bytes, want none", buf.Len())
+			}
+		})
+	}
+}
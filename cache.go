@@ -0,0 +1,263 @@
+package protojson
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// defaultMaxCacheEntries is the number of encodings a MessageCache keeps
+// unless set with SetMaxEntries
+const defaultMaxCacheEntries = 1024
+
+// MessageCache memoizes the JSON encoding of messages, keyed by message
+// pointer, so that large and rarely changing messages, such as
+// configuration embedded in every response, are encoded once instead of
+// on every Marshal call. Set it as MarshalOptions.Cache.
+//
+// Only messages of the types the cache was created for are memoized, and
+// they must not be modified after they are first encoded, unless Forget is
+// called for them. Cached messages are kept alive by the cache until they
+// are forgotten or dropped to make room, so the types should be ones whose
+// messages are long-lived rather than built per request.
+//
+// Encodings are cached separately for each set of options the cache is
+// used with. Options are compared by value, except for maps and the values
+// of pointers, which are compared by identity: options holding maps built
+// per call do not share encodings, and a map must not be modified while
+// options holding it are in use. The cache keeps the options it was used
+// with until it is reset or full. Functions cannot be compared, so the
+// cache is not used with options holding them, such as FieldMaskFunc,
+// TransformValue or Hooks. Nor is it used with Indent or Multiline, whose output depends on nesting, with an
+// EmitTypeName scope that depends on it, or with options that depend on
+// the path of a field, such as IncludePaths, FieldMaskPathFunc and
+// MaskAuditFunc.
+//
+// A MessageCache is safe for concurrent use.
+type MessageCache struct {
+	names map[protoreflect.FullName]bool
+
+	mu      sync.RWMutex
+	max     int                      // Maximum number of entries
+	options map[string]cachedOptions // Options used, by optionsKey
+	next    int                      // Last identifier given to options
+	entries map[cacheKey][]byte      // Encodings of messages
+}
+
+// cachedOptions identifies a set of options used with a MessageCache. The
+// options are kept so that the maps and pointers they hold, which their
+// key compares by address, are not freed and their addresses reused.
+type cachedOptions struct {
+	id   int
+	opts MarshalOptions
+}
+
+// cacheKey identifies the encoding of a message with a set of options
+type cacheKey struct {
+	m       proto.Message
+	options int
+}
+
+// NewMessageCache returns a cache memoizing messages of the named types.
+func NewMessageCache(names ...protoreflect.FullName) *MessageCache {
+	c := &MessageCache{
+		names:   make(map[protoreflect.FullName]bool, len(names)),
+		max:     defaultMaxCacheEntries,
+		options: make(map[string]cachedOptions),
+		entries: make(map[cacheKey][]byte),
+	}
+	for _, name := range names {
+		c.names[name] = true
+	}
+	return c
+}
+
+// SetMaxEntries sets the number of encodings the cache keeps, 1024 by
+// default. Once it is full, an arbitrary encoding is dropped for each new
+// one. n less than 1 is treated as 1.
+func (c *MessageCache) SetMaxEntries(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.max = max(n, 1)
+	for k := range c.entries {
+		if len(c.entries) <= c.max {
+			break
+		}
+		delete(c.entries, k)
+	}
+}
+
+// Forget drops the cached encodings of m, so that it is encoded again the
+// next time it is marshaled. It must be called after modifying a cached
+// message.
+func (c *MessageCache) Forget(m proto.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.entries {
+		if k.m == m {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Reset drops all cached encodings.
+func (c *MessageCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	clear(c.options)
+}
+
+// load returns the encoding cached for key
+func (c *MessageCache) load(key cacheKey) ([]byte, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	b, ok := c.entries[key]
+	return b, ok
+}
+
+// store caches b as the encoding for key, dropping an arbitrary entry if
+// the cache is full
+func (c *MessageCache) store(key cacheKey, b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.max {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = b
+}
+
+// optionsID returns the identifier of opts, whose optionsKey is key.
+// Identifiers are never reused, so that encodings cached with forgotten
+// options are not taken for others.
+func (c *MessageCache) optionsID(key string, opts *MarshalOptions) int {
+	c.mu.RLock()
+	o, ok := c.options[key]
+	c.mu.RUnlock()
+	if ok {
+		return o.id
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if o, ok := c.options[key]; ok {
+		return o.id
+	}
+	// Options built per call would otherwise grow the map without bound
+	if len(c.options) >= c.max {
+		clear(c.options)
+		clear(c.entries)
+	}
+	c.next++
+	c.options[key] = cachedOptions{id: c.next, opts: *opts}
+	return c.next
+}
+
+// cacheable reports whether the output of a message is independent of
+// where it is written, so that it can be taken from a MessageCache
+func (e *encoder) cacheable() bool {
+	if e.opts.EmitTypeName == TypeNameTopLevel || e.opts.EmitTypeName == TypeNameNested {
+		return false
+	}
+	return !e.trackPath && e.opts.Indent == "" && !e.opts.Multiline && e.opts.Comments == nil
+}
+
+// marshalCached writes m from c, encoding and storing it on a miss
+func (e *encoder) marshalCached(c *MessageCache, m protoreflect.Message, plan *messagePlan) error {
+	if e.cacheOptions == 0 {
+		e.cacheOptions = -1
+		if k, ok := optionsKey(&e.opts); ok {
+			e.cacheOptions = c.optionsID(k, &e.opts)
+		}
+	}
+	if e.cacheOptions < 0 {
+		return e.marshalPlanned(m, plan)
+	}
+	key := cacheKey{m: m.Interface(), options: e.cacheOptions}
+	if b, ok := c.load(key); ok {
+		e.w.Write(b)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	w := e.redirect(&buf)
+	err := e.marshalPlanned(m, plan)
+	e.redirect(w)
+	if err != nil {
+		return err
+	}
+
+	c.store(key, buf.Bytes())
+	e.w.Write(buf.Bytes())
+	return nil
+}
+
+// optionsKey returns a key that is equal for options that write the same
+// output, comparing maps and pointers by identity. It reports false if the
+// options hold a function, which cannot be compared.
+func optionsKey(o *MarshalOptions) (string, bool) {
+	b, ok := appendOptionsKey(nil, reflect.ValueOf(o).Elem())
+	return string(b), ok
+}
+
+// appendOptionsKey appends the key of v to b, reporting false if v holds a
+// function
+func appendOptionsKey(b []byte, v reflect.Value) ([]byte, bool) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 1), true
+		}
+		return append(b, 0), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(b, v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(b, v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return binary.AppendUvarint(b, math.Float64bits(v.Float())), true
+	case reflect.String:
+		b = binary.AppendUvarint(b, uint64(v.Len()))
+		return append(b, v.String()...), true
+	case reflect.Slice, reflect.Array:
+		b = binary.AppendUvarint(b, uint64(v.Len()))
+		for i := range v.Len() {
+			var ok bool
+			if b, ok = appendOptionsKey(b, v.Index(i)); !ok {
+				return nil, false
+			}
+		}
+		return b, true
+	case reflect.Struct:
+		for i := range v.NumField() {
+			var ok bool
+			if b, ok = appendOptionsKey(b, v.Field(i)); !ok {
+				return nil, false
+			}
+		}
+		return b, true
+	case reflect.Interface:
+		if v.IsNil() {
+			return append(b, 0), true
+		}
+		t := v.Elem().Type().String()
+		b = binary.AppendUvarint(b, uint64(len(t)))
+		b = append(b, t...)
+		return appendOptionsKey(b, v.Elem())
+	case reflect.Func:
+		if !v.IsNil() {
+			return nil, false
+		}
+		return append(b, 0), true
+	case reflect.Map, reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return binary.AppendUvarint(b, uint64(v.Pointer())), true
+	}
+	return b, true
+}
@@ -0,0 +1,238 @@
+package protojson_test
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TestMessageCache tests memoizing the encoding of sub-messages
+func TestMessageCache(t *testing.T) {
+	profile := &pb_basic.Profile{Bio: "static"}
+	cache := protojson.NewMessageCache("test.complex.Profile")
+	opts := protojson.MarshalOptions{Cache: cache}
+
+	marshal := func(opts protojson.MarshalOptions, m *pb_basic.User) string {
+		t.Helper()
+		b, err := opts.Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		return string(b)
+	}
+
+	want := `{"name":"alice","profile":{"bio":"static"}}`
+	if diff := cmp.Diff(want, marshal(opts, &pb_basic.User{Name: "alice", Profile: profile})); diff != "" {
+		t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+	}
+
+	// The cached encoding is used even though the message changed
+	profile.Bio = "changed"
+	want = `{"name":"bob","profile":{"bio":"static"}}`
+	if diff := cmp.Diff(want, marshal(opts, &pb_basic.User{Name: "bob", Profile: profile})); diff != "" {
+		t.Errorf("Marshal() cached mismatch (-want +got):\n%s", diff)
+	}
+
+	// Other messages of the type are cached separately
+	want = `{"name":"carol","profile":{"bio":"other"}}`
+	if diff := cmp.Diff(want, marshal(opts, &pb_basic.User{Name: "carol", Profile: &pb_basic.Profile{Bio: "other"}})); diff != "" {
+		t.Errorf("Marshal() other mismatch (-want +got):\n%s", diff)
+	}
+
+	// The cache is not used for indented output
+	want = "{\n  \"name\": \"dave\",\n  \"profile\": {\n    \"bio\": \"changed\"\n  }\n}"
+	indented := protojson.MarshalOptions{Cache: cache, Indent: "  "}
+	if diff := cmp.Diff(want, marshal(indented, &pb_basic.User{Name: "dave", Profile: profile})); diff != "" {
+		t.Errorf("Marshal() indented mismatch (-want +got):\n%s", diff)
+	}
+
+	cache.Forget(profile)
+	want = `{"name":"erin","profile":{"bio":"changed"}}`
+	if diff := cmp.Diff(want, marshal(opts, &pb_basic.User{Name: "erin", Profile: profile})); diff != "" {
+		t.Errorf("Marshal() after Forget mismatch (-want +got):\n%s", diff)
+	}
+
+	profile.Bio = "reset"
+	cache.Reset()
+	want = `{"name":"frank","profile":{"bio":"reset"}}`
+	if diff := cmp.Diff(want, marshal(opts, &pb_basic.User{Name: "frank", Profile: profile})); diff != "" {
+		t.Errorf("Marshal() after Reset mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestMessageCacheTypes tests that only messages of the cache's types are
+// memoized, including top-level messages
+func TestMessageCacheTypes(t *testing.T) {
+	user := &pb_basic.User{Name: "alice", Profile: &pb_basic.Profile{Bio: "bio"}}
+	opts := protojson.MarshalOptions{Cache: protojson.NewMessageCache("test.complex.User")}
+	if _, err := opts.Marshal(user); err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	user.Profile.Bio = "changed"
+	got, err := opts.Marshal(user)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"name":"alice","profile":{"bio":"bio"}}`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+	}
+
+	profile := &pb_basic.Profile{Bio: "bio"}
+	if _, err := opts.Marshal(profile); err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	profile.Bio = "changed"
+	got, err = opts.Marshal(profile)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if diff := cmp.Diff(`{"bio":"changed"}`, string(got)); diff != "" {
+		t.Errorf("Marshal() uncached type mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestMessageCacheOptions tests that encodings are cached separately for
+// each set of options
+func TestMessageCacheOptions(t *testing.T) {
+	settings := &pb_basic.Settings{NotificationsEnabled: true, Theme: "dark"}
+	msg := &pb_basic.ComplexMessage{Settings: settings}
+	cache := protojson.NewMessageCache("test.complex.Settings")
+	maskField := func(name protoreflect.Name) func(protoreflect.FieldDescriptor) bool {
+		return func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == name }
+	}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Default",
+			want: `{"settings":{"notificationsEnabled":true,"theme":"dark"}}`,
+		},
+		{
+			name: "UseProtoNames",
+			opts: protojson.MarshalOptions{UseProtoNames: true},
+			want: `{"settings":{"notifications_enabled":true,"theme":"dark"}}`,
+		},
+		{
+			name: "MaskTheme",
+			opts: protojson.MarshalOptions{FieldMaskFunc: maskField("theme")},
+			want: `{"settings":{"notificationsEnabled":true,"theme":"***"}}`,
+		},
+		{
+			name: "MaskLanguage",
+			opts: protojson.MarshalOptions{FieldMaskFunc: maskField("language")},
+			want: `{"settings":{"notificationsEnabled":true,"theme":"dark"}}`,
+		},
+	}
+
+	for range 2 {
+		for _, tt := range tests {
+			tt.opts.Cache = cache
+			got, err := tt.opts.Marshal(msg)
+			if err != nil {
+				t.Fatalf("%s: Marshal() error = %v", tt.name, err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("%s: Marshal() mismatch (-want +got):\n%s", tt.name, diff)
+			}
+		}
+	}
+}
+
+// TestMessageCacheOptionsPerCall tests that options built per call, whose
+// functions and maps may be allocated where earlier ones were, do not take
+// the encodings cached for others
+func TestMessageCacheOptionsPerCall(t *testing.T) {
+	msg := &pb_basic.ComplexMessage{Settings: &pb_basic.Settings{Theme: "dark"}}
+	cache := protojson.NewMessageCache("test.complex.Settings")
+
+	tests := []struct {
+		name string
+		opts func(theme string) protojson.MarshalOptions
+	}{
+		{
+			name: "TransformValue",
+			opts: func(theme string) protojson.MarshalOptions {
+				return protojson.MarshalOptions{
+					TransformValue: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, error) {
+						if fd.Name() == "theme" {
+							return protoreflect.ValueOfString(theme), nil
+						}
+						return v, nil
+					},
+				}
+			},
+		},
+		{
+			name: "Formatters",
+			opts: func(theme string) protojson.MarshalOptions {
+				return protojson.MarshalOptions{Formatters: map[protoreflect.FullName]protojson.Formatter{
+					"test.complex.Settings": func(protoreflect.Message, protojson.MarshalOptions) ([]byte, error) {
+						return []byte(`{"theme":"` + theme + `"}`), nil
+					},
+				}}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range 100 {
+				theme := strconv.Itoa(i)
+				opts := tt.opts(theme)
+				opts.Cache = cache
+				got, err := opts.Marshal(msg)
+				if err != nil {
+					t.Fatalf("Marshal() error = %v", err)
+				}
+				if diff := cmp.Diff(`{"settings":{"theme":"`+theme+`"}}`, string(got)); diff != "" {
+					t.Fatalf("Marshal() call %d mismatch (-want +got):\n%s", i, diff)
+				}
+				runtime.GC()
+			}
+		})
+	}
+}
+
+// TestMessageCacheMaxEntries tests that the cache drops encodings once
+// full
+func TestMessageCacheMaxEntries(t *testing.T) {
+	cache := protojson.NewMessageCache("test.complex.Profile")
+	cache.SetMaxEntries(1)
+	opts := protojson.MarshalOptions{Cache: cache}
+
+	first := &pb_basic.Profile{Bio: "first"}
+	second := &pb_basic.Profile{Bio: "second"}
+	for _, m := range []*pb_basic.Profile{first, second} {
+		if _, err := opts.Marshal(&pb_basic.User{Profile: m}); err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+	}
+
+	// Only the encoding of the last message is still cached
+	first.Bio, second.Bio = "first changed", "second changed"
+	for _, tt := range []struct {
+		msg  *pb_basic.Profile
+		want string
+	}{
+		{msg: second, want: `{"profile":{"bio":"second"}}`},
+		{msg: first, want: `{"profile":{"bio":"first changed"}}`},
+	} {
+		got, err := opts.Marshal(&pb_basic.User{Profile: tt.msg})
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+			t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
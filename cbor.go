@@ -0,0 +1,207 @@
+package protojson
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// CBOR major types
+const (
+	cborUint   = 0 << 5
+	cborNegint = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+	cborSimple = 7 << 5
+)
+
+// Tags of date/time values
+const (
+	cborEpochTime    = 1    // Epoch-based date/time
+	cborExtendedTime = 1001 // Extended time (RFC 9581)
+)
+
+// CBOREncoder writes messages as CBOR (RFC 8949) data items with the
+// semantics of their JSON encoding, for consumers that want a compact
+// binary equivalent. Objects become maps with text string keys, named and
+// ordered as in the JSON output, so options such as UseProtoNames,
+// EmitUnpopulated and masking apply as they do to JSON. Values are typed
+// by their fields rather than following JSON: 64-bit integers are CBOR
+// integers rather than strings, bytes are byte strings rather than
+// base64, and floats are written in the shortest of half (for NaN and
+// infinities), single or double precision that keeps their value.
+// google.protobuf.Timestamp is written as an epoch-based date/time (tag 1),
+// an integer when it has no fractional seconds and a double when that
+// keeps its value, and otherwise as an extended time (tag 1001, RFC 9581)
+// holding seconds and nanoseconds. google.protobuf.Duration is written as a
+// number of seconds. Options that only apply to JSON output, such as
+// Indent, BytesEncoding, TimestampFormat or MaxOutputBytes, are ignored.
+type CBOREncoder struct {
+	w   io.Writer
+	bin binaryEncoder
+	buf []byte
+}
+
+// NewCBOREncoder returns a new CBOR encoder that writes to w using the
+// provided MarshalOptions.
+func NewCBOREncoder(w io.Writer, opts MarshalOptions) *CBOREncoder {
+	e := &CBOREncoder{w: w}
+	e.bin.init(opts)
+	return e
+}
+
+// Encode writes m as a single CBOR data item.
+func (e *CBOREncoder) Encode(m proto.Message) error {
+	v, err := e.bin.encode(m)
+	if err != nil {
+		return err
+	}
+	if e.buf, err = appendCBOR(e.buf[:0], v); err != nil {
+		return err
+	}
+	_, err = e.w.Write(e.buf)
+	return err
+}
+
+// MarshalCBOR returns the CBOR encoding of m using options in o. See
+// CBOREncoder.
+func (o MarshalOptions) MarshalCBOR(m proto.Message) ([]byte, error) {
+	var e CBOREncoder
+	e.bin.init(o)
+	v, err := e.bin.encode(m)
+	if err != nil {
+		return nil, err
+	}
+	return appendCBOR(nil, v)
+}
+
+// appendCBOR appends the CBOR encoding of a value converted by
+// binaryEncoder
+func appendCBOR(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, cborSimple|22), nil
+	case bool:
+		if v {
+			return append(b, cborSimple|21), nil
+		}
+		return append(b, cborSimple|20), nil
+	case int32:
+		return appendCBORInt(b, int64(v)), nil
+	case uint32:
+		return appendCBORHead(b, cborUint, uint64(v)), nil
+	case int64:
+		return appendCBORInt(b, v), nil
+	case uint64:
+		return appendCBORHead(b, cborUint, v), nil
+	case float32:
+		return appendCBORFloat(b, float64(v)), nil
+	case float64:
+		return appendCBORFloat(b, v), nil
+	case string:
+		return appendCBORText(b, v), nil
+	case []byte:
+		b = appendCBORHead(b, cborBytes, uint64(len(v)))
+		return append(b, v...), nil
+	case time.Time:
+		return appendCBORTime(b, v), nil
+	case time.Duration:
+		if v%time.Second == 0 {
+			return appendCBORInt(b, int64(v/time.Second)), nil
+		}
+		return appendCBORFloat(b, v.Seconds()), nil
+	case []any:
+		b = appendCBORHead(b, cborArray, uint64(len(v)))
+		var err error
+		for _, elem := range v {
+			if b, err = appendCBOR(b, elem); err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	case binaryObject:
+		b = appendCBORHead(b, cborMap, uint64(len(v)))
+		var err error
+		for _, m := range v {
+			b = appendCBORText(b, m.key)
+			if b, err = appendCBOR(b, m.val); err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	}
+	return b, fmt.Errorf("unexpected value of type %T", v)
+}
+
+// appendCBORInt appends n as an unsigned or negative integer
+func appendCBORInt(b []byte, n int64) []byte {
+	if n < 0 {
+		return appendCBORHead(b, cborNegint, uint64(-(n + 1)))
+	}
+	return appendCBORHead(b, cborUint, uint64(n))
+}
+
+// appendCBORText appends s as a text string
+func appendCBORText(b []byte, s string) []byte {
+	b = appendCBORHead(b, cborText, uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendCBORTime appends t as an epoch-based date/time if an integer or
+// double keeps its value, and as an extended time of seconds and
+// nanoseconds otherwise
+func appendCBORTime(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	if nsec == 0 {
+		return appendCBORInt(appendCBORHead(b, cborTag, cborEpochTime), sec)
+	}
+	f := float64(sec) + float64(nsec)/1e9
+	if whole := math.Floor(f); int64(whole) == sec && int64(math.Round((f-whole)*1e9)) == nsec {
+		return appendCBORFloat(appendCBORHead(b, cborTag, cborEpochTime), f)
+	}
+	// Map of base time (key 1) and nanoseconds (key -9)
+	b = appendCBORHead(b, cborTag, cborExtendedTime)
+	b = appendCBORHead(b, cborMap, 2)
+	b = appendCBORInt(appendCBORInt(b, 1), sec)
+	return appendCBORInt(appendCBORInt(b, -9), nsec)
+}
+
+// appendCBORHead appends the initial bytes of a data item of the given
+// major type with argument n, in the shortest form
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), n)
+}
+
+// appendCBORFloat appends f as a half precision float if it is NaN or
+// infinite, as a single precision float if that keeps its value, and as a
+// double precision float otherwise
+func appendCBORFloat(b []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(b, cborSimple|25, 0x7e, 0x00)
+	case math.IsInf(f, 1):
+		return append(b, cborSimple|25, 0x7c, 0x00)
+	case math.IsInf(f, -1):
+		return append(b, cborSimple|25, 0xfc, 0x00)
+	case float64(float32(f)) == f:
+		return binary.BigEndian.AppendUint32(append(b, cborSimple|26), math.Float32bits(float32(f)))
+	}
+	return binary.BigEndian.AppendUint64(append(b, cborSimple|27), math.Float64bits(f))
+}
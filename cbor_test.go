@@ -0,0 +1,196 @@
+package protojson_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// cborText returns the hex encoding of s as a short CBOR text string
+func cborText(s string) string {
+	return hex.EncodeToString(append([]byte{0x60 | byte(len(s))}, s...))
+}
+
+// TestMarshalCBOR tests the CBOR encoding of messages
+func TestMarshalCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Integers",
+			msg:  &pb_basic.BasicTypes{Int32Field: 500, Int64Field: -2, Uint64Field: 1 << 40},
+			want: "a3" + cborText("int32Field") + "1901f4" + cborText("int64Field") + "21" +
+				cborText("uint64Field") + "1b0000010000000000",
+		},
+		{
+			name: "Scalars",
+			msg: &pb_basic.BasicTypes{
+				StringField: "a",
+				BoolField:   true,
+				FloatField:  float32(math.Inf(1)),
+				DoubleField: 0.1,
+				BytesField:  []byte{1, 2},
+			},
+			want: "a5" + cborText("stringField") + cborText("a") + cborText("boolField") + "f5" +
+				cborText("floatField") + "f97c00" + cborText("doubleField") + "fb3fb999999999999a" +
+				cborText("bytesField") + "420102",
+		},
+		{
+			name: "ProtoNames",
+			msg:  &pb_basic.BasicTypes{Fixed32Field: 1, DoubleField: 1.5},
+			opts: protojson.MarshalOptions{UseProtoNames: true},
+			want: "a2" + cborText("fixed32_field") + "01" + cborText("double_field") + "fa3fc00000",
+		},
+		{
+			name: "Masked",
+			msg:  &pb_basic.RedactedFields{Username: "u", Password: "p", Pin: 1234},
+			opts: protojson.MarshalOptions{MaskDebugRedact: true, MaskPolicy: protojson.MaskNull},
+			want: "a3" + cborText("username") + cborText("u") + cborText("password") + cborText("***") +
+				cborText("pin") + "f6",
+		},
+		{
+			name: "Repeated",
+			msg:  &pb_basic.RepeatedFields{Numbers: []int32{1, -1}, Strings: []string{"x"}},
+			want: "a2" + cborText("strings") + "81" + cborText("x") + cborText("numbers") + "820120",
+		},
+		{
+			name: "WellKnownTypes",
+			msg: &pb_basic.WellKnownTypes{
+				Timestamp: timestamppb.New(time.Unix(1700000000, 0)),
+				Duration:  durationpb.New(1500 * time.Millisecond),
+			},
+			want: "a2" + cborText("timestamp") + "c11a6553f100" + cborText("duration") + "fa3fc00000",
+		},
+		{
+			name: "FractionalTimestamp",
+			msg:  timestamppb.New(time.Unix(1, 5e8)),
+			want: "c1fa3fc00000",
+		},
+		{
+			name: "NanosecondTimestamp",
+			msg:  timestamppb.New(time.Unix(1700000000, 123456789)),
+			want: "d903e9a2011a6553f100281a075bcd15",
+		},
+		{
+			name: "Wrapper",
+			msg:  &pb_basic.WrapperTypes{Int64Value: wrapperspb.Int64(-300), BytesValue: wrapperspb.Bytes([]byte("b"))},
+			want: "a2" + cborText("int64Value") + "39012b" + cborText("bytesValue") + "4162",
+		},
+		{
+			name: "WrapperAsObject",
+			msg:  &pb_basic.WrapperTypes{Int64Value: wrapperspb.Int64(-300)},
+			opts: protojson.MarshalOptions{WrapperAsObject: true},
+			want: "a1" + cborText("int64Value") + "a1" + cborText("value") + "39012b",
+		},
+		{
+			name: "Any",
+			msg:  mustAny(t, &pb_basic.BasicTypes{Int64Field: 5}),
+			want: "a2" + cborText("@type") + "7829" + hex.EncodeToString([]byte("type.googleapis.com/test.basic.BasicTypes")) +
+				cborText("int64Field") + "05",
+		},
+		{
+			name: "Enum",
+			msg:  &pb_basic.EnumFields{Status: pb_basic.Status_STATUS_ACTIVE, Priority: pb_basic.Priority(9)},
+			want: "a2" + cborText("status") + cborText("STATUS_ACTIVE") + cborText("priority") + "09",
+		},
+		{
+			name: "WrappedOneof",
+			msg:  &pb_basic.NestedOneOf_Inner{Data: &pb_basic.NestedOneOf_Inner_Binary{Binary: []byte{1}}},
+			opts: protojson.MarshalOptions{WrapOneofs: true},
+			want: "a1" + cborText("data") + "a1" + cborText("binary") + "4101",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.MarshalCBOR(tt.msg)
+			if err != nil {
+				t.Fatalf("MarshalCBOR() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, hex.EncodeToString(got)); diff != "" {
+				t.Errorf("MarshalCBOR() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestCBOREncoder tests writing a sequence of CBOR data items
+func TestCBOREncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := protojson.NewCBOREncoder(&buf, protojson.MarshalOptions{
+		FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "string_field" },
+	})
+	for _, s := range []string{"a", "b"} {
+		if err := enc.Encode(&pb_basic.BasicTypes{StringField: s, Int32Field: 1}); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+	if err := enc.Encode(&pb_basic.User{Name: "bad\xff"}); err == nil {
+		t.Error("Encode() of invalid UTF-8 succeeded, want error")
+	}
+
+	item := "a2" + cborText("stringField") + cborText("***") + cborText("int32Field") + "01"
+	if diff := cmp.Diff(item+item, hex.EncodeToString(buf.Bytes())); diff != "" {
+		t.Errorf("Encode() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestMarshalCBORResolver tests that Any messages are resolved with the
+// configured Resolver
+func TestMarshalCBORResolver(t *testing.T) {
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("runtime/point.proto"),
+		Package: proto.String("test.runtime"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Point"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("x"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), JsonName: proto.String("x")},
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile() error = %v", err)
+	}
+	mt := dynamicpb.NewMessageType(fd.Messages().ByName("Point"))
+	types := new(protoregistry.Types)
+	if err := types.RegisterMessage(mt); err != nil {
+		t.Fatalf("RegisterMessage() error = %v", err)
+	}
+	point := mt.New()
+	point.Set(mt.Descriptor().Fields().ByName("x"), protoreflect.ValueOfInt64(5))
+	value, err := proto.Marshal(point.Interface())
+	if err != nil {
+		t.Fatalf("proto.Marshal() error = %v", err)
+	}
+	msg := &anypb.Any{TypeUrl: "type.googleapis.com/test.runtime.Point", Value: value}
+
+	got, err := protojson.MarshalOptions{Resolver: types}.MarshalCBOR(msg)
+	if err != nil {
+		t.Fatalf("MarshalCBOR() error = %v", err)
+	}
+	want := "a2" + cborText("@type") + "7826" + hex.EncodeToString([]byte(msg.TypeUrl)) + cborText("x") + "05"
+	if diff := cmp.Diff(want, hex.EncodeToString(got)); diff != "" {
+		t.Errorf("MarshalCBOR() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	fmtPackage       = protogen.GoImportPath("fmt")
+	mapsPackage      = protogen.GoImportPath("maps")
+	mathPackage      = protogen.GoImportPath("math")
+	slicesPackage    = protogen.GoImportPath("slices")
+	protojsonPackage = protogen.GoImportPath("github.com/wreulicke/protojson")
+)
+
+// generateFile generates the _protojson.pb.go file for f, or nothing if f
+// does not have proto3 syntax
+func generateFile(gen *protogen.Plugin, f *protogen.File) *protogen.GeneratedFile {
+	if f.Desc.Syntax() != protoreflect.Proto3 {
+		return nil
+	}
+
+	g := gen.NewGeneratedFile(f.GeneratedFilenamePrefix+"_protojson.pb.go", f.GoImportPath)
+	g.P("// Code generated by protoc-gen-protojson. DO NOT EDIT.")
+	g.P("// source: ", f.Desc.Path())
+	g.P()
+	g.P("package ", f.GoPackageName)
+	for _, m := range messages(f.Messages) {
+		g.P()
+		generateMessage(g, m)
+	}
+	return g
+}
+
+// messages returns ms and their nested messages, except map entries
+func messages(ms []*protogen.Message) []*protogen.Message {
+	var out []*protogen.Message
+	for _, m := range ms {
+		if m.Desc.IsMapEntry() {
+			continue
+		}
+		out = append(out, m)
+		out = append(out, messages(m.Messages)...)
+	}
+	return out
+}
+
+// generateMessage generates the MarshalProtoJSON method of m
+func generateMessage(g *protogen.GeneratedFile, m *protogen.Message) {
+	g.P("// MarshalProtoJSON writes x in JSON format to w.")
+	g.P("func (x *", m.GoIdent, ") MarshalProtoJSON(w *", protojsonPackage.Ident("Writer"), ") error {")
+	g.P("w.BeginObject()")
+	if len(m.Fields) > 0 {
+		g.P("if x == nil {")
+		g.P("w.EndObject()")
+		g.P("return nil")
+		g.P("}")
+		g.P("first := true")
+	}
+
+	done := make(map[*protogen.Oneof]bool)
+	for _, field := range m.Fields {
+		oneof := field.Oneof
+		switch {
+		case oneof != nil && oneof.Desc.IsSynthetic():
+			g.P("if x.", field.GoName, " != nil {")
+			generateName(g, field)
+			generateValue(g, field, optionalValue(field, "x."+field.GoName))
+			g.P("}")
+		case oneof != nil:
+			if done[oneof] {
+				continue
+			}
+			done[oneof] = true
+			g.P("switch v := x.", oneof.GoName, ".(type) {")
+			for _, member := range oneof.Fields {
+				g.P("case *", member.GoIdent, ":")
+				generateName(g, member)
+				generateValue(g, member, "v."+member.GoName)
+			}
+			g.P("}")
+		default:
+			g.P("if ", populated(g, field, "x."+field.GoName), " {")
+			generateName(g, field)
+			switch {
+			case field.Desc.IsList():
+				generateList(g, field, "x."+field.GoName)
+			case field.Desc.IsMap():
+				generateMap(g, field, "x."+field.GoName)
+			default:
+				generateValue(g, field, "x."+field.GoName)
+			}
+			g.P("}")
+		}
+	}
+
+	g.P("w.EndObject()")
+	g.P("return nil")
+	g.P("}")
+}
+
+// optionalValue returns the value of the proto3 optional field accessed by
+// expr. Scalars and enums are generated as pointers, but bytes and
+// messages hold their value directly, with nil for an unset field.
+func optionalValue(field *protogen.Field, expr string) string {
+	switch field.Desc.Kind() {
+	case protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		return expr
+	}
+	return "*" + expr
+}
+
+// populated returns the condition under which field, accessed by expr, is
+// written, matching protoreflect.Message.Has for proto3 fields
+func populated(g *protogen.GeneratedFile, field *protogen.Field, expr string) string {
+	if field.Desc.IsList() || field.Desc.IsMap() {
+		return "len(" + expr + ") > 0"
+	}
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		return expr
+	case protoreflect.StringKind:
+		return expr + ` != ""`
+	case protoreflect.BytesKind:
+		return "len(" + expr + ") > 0"
+	case protoreflect.FloatKind:
+		// Has reports -0 as populated
+		return g.QualifiedGoIdent(mathPackage.Ident("Float32bits")) + "(" + expr + ") != 0"
+	case protoreflect.DoubleKind:
+		return g.QualifiedGoIdent(mathPackage.Ident("Float64bits")) + "(" + expr + ") != 0"
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return expr + " != nil"
+	}
+	return expr + " != 0"
+}
+
+// generateName generates writing the name of field
+func generateName(g *protogen.GeneratedFile, field *protogen.Field) {
+	g.P("w.WriteName(first, ", strconv.Quote(field.Desc.JSONName()), ", ", strconv.Quote(string(field.Desc.Name())), ")")
+	g.P("first = false")
+}
+
+// generateList generates writing the elements of the list field accessed
+// by expr
+func generateList(g *protogen.GeneratedFile, field *protogen.Field, expr string) {
+	g.P("w.BeginArray()")
+	g.P("for i, v := range ", expr, " {")
+	g.P("if i > 0 {")
+	g.P("w.WriteComma()")
+	g.P("}")
+	generateValue(g, field, "v")
+	g.P("}")
+	g.P("w.EndArray()")
+}
+
+// generateMap generates writing the entries of the map field accessed by
+// expr, ordered by key like the encoder
+func generateMap(g *protogen.GeneratedFile, field *protogen.Field, expr string) {
+	key, value := field.Message.Fields[0], field.Message.Fields[1]
+	g.P("w.BeginObject()")
+	if key.Desc.Kind() == protoreflect.BoolKind {
+		g.P("n := 0")
+		g.P("for _, k := range [2]bool{false, true} {")
+		g.P("v, ok := ", expr, "[k]")
+		g.P("if !ok {")
+		g.P("continue")
+		g.P("}")
+		g.P("if n > 0 {")
+		g.P("w.WriteComma()")
+		g.P("}")
+		g.P("n++")
+	} else {
+		g.P("for i, k := range ", slicesPackage.Ident("Sorted"), "(", mapsPackage.Ident("Keys"), "(", expr, ")) {")
+		g.P("v := ", expr, "[k]")
+		g.P("if i > 0 {")
+		g.P("w.WriteComma()")
+		g.P("}")
+	}
+
+	switch key.Desc.Kind() {
+	case protoreflect.StringKind:
+		g.P("if err := w.WriteString(k); err != nil {")
+		g.P("return ", fmtPackage.Ident("Errorf"), "(", strconv.Quote("map key of field "+string(field.Desc.FullName())+": %w"), ", err)")
+		g.P("}")
+	case protoreflect.BoolKind:
+		g.P("w.WriteBoolKey(k)")
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		g.P("w.WriteUintKey(uint64(k))")
+	default:
+		g.P("w.WriteIntKey(int64(k))")
+	}
+	g.P("w.WriteColon()")
+	generateValue(g, value, "v")
+	g.P("}")
+	g.P("w.EndObject()")
+}
+
+// generateValue generates writing the singular value of field accessed by
+// expr
+func generateValue(g *protogen.GeneratedFile, field *protogen.Field, expr string) {
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		g.P("w.WriteBool(", expr, ")")
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		g.P("w.WriteInt32(", expr, ")")
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		g.P("w.WriteUint32(", expr, ")")
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		g.P("w.WriteInt64(", expr, ")")
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		g.P("w.WriteUint64(", expr, ")")
+	case protoreflect.FloatKind:
+		generateChecked(g, field, "w.WriteFloat32("+expr+")")
+	case protoreflect.DoubleKind:
+		generateChecked(g, field, "w.WriteFloat64("+expr+")")
+	case protoreflect.StringKind:
+		generateChecked(g, field, "w.WriteString("+expr+")")
+	case protoreflect.BytesKind:
+		g.P("w.WriteBytes(", expr, ")")
+	case protoreflect.EnumKind:
+		if field.Desc.Enum().FullName() == "google.protobuf.NullValue" {
+			g.P("w.WriteNull()")
+			return
+		}
+		names := protogen.GoIdent{
+			GoName:       field.Enum.GoIdent.GoName + "_name",
+			GoImportPath: field.Enum.GoIdent.GoImportPath,
+		}
+		g.P("w.WriteEnum(int32(", expr, "), ", names, "[int32(", expr, ")])")
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		g.P("if err := w.WriteMessage(", expr, "); err != nil {")
+		g.P("return err")
+		g.P("}")
+	}
+}
+
+// generateChecked generates a call returning an error, wrapped with the
+// name of field like the encoder does
+func generateChecked(g *protogen.GeneratedFile, field *protogen.Field, call string) {
+	g.P("if err := ", call, "; err != nil {")
+	g.P("return ", fmtPackage.Ident("Errorf"), "(", strconv.Quote("field "+string(field.Desc.FullName())+": %w"), ", err)")
+	g.P("}")
+}
@@ -0,0 +1,30 @@
+// Command protoc-gen-protojson generates MarshalProtoJSON methods for
+// protobuf messages. github.com/wreulicke/protojson detects them and uses
+// them instead of walking messages with protoreflect, which makes encoding
+// the generated types considerably faster.
+//
+// It is run alongside protoc-gen-go, writing a _protojson.pb.go file next
+// to each .pb.go file:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//		--protojson_out=. --protojson_opt=paths=source_relative foo.proto
+//
+// Only files with proto3 syntax are supported; other files are skipped.
+package main
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func main() {
+	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+		for _, f := range gen.Files {
+			if f.Generate {
+				generateFile(gen, f)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// request returns a CodeGeneratorRequest for fd and its dependencies
+func request(fd protoreflect.FileDescriptor) *pluginpb.CodeGeneratorRequest {
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{fd.Path()},
+		Parameter:      proto.String("paths=source_relative"),
+	}
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		req.ProtoFile = append(req.ProtoFile, protodesc.ToFileDescriptorProto(fd))
+	}
+	add(fd)
+	return req
+}
+
+// TestGenerateFile tests that the checked-in generated code for
+// proto/generated.proto is up to date
+func TestGenerateFile(t *testing.T) {
+	gen, err := protogen.Options{}.New(request(pb_basic.File_generated_proto))
+	if err != nil {
+		t.Fatalf("protogen.Options.New() error = %v", err)
+	}
+	for _, f := range gen.Files {
+		if f.Generate {
+			generateFile(gen, f)
+		}
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatalf("Response() error = %s", resp.GetError())
+	}
+	if len(resp.File) != 1 {
+		t.Fatalf("generated %d files, want 1", len(resp.File))
+	}
+
+	want, err := os.ReadFile("../../gen/" + resp.File[0].GetName())
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+	if diff := cmp.Diff(string(want), resp.File[0].GetContent()); diff != "" {
+		t.Errorf("generated code mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestGenerateFileProto2 tests that files without proto3 syntax are
+// skipped
+func TestGenerateFileProto2(t *testing.T) {
+	gen, err := protogen.Options{}.New(request(pb_basic.File_proto2_proto))
+	if err != nil {
+		t.Fatalf("protogen.Options.New() error = %v", err)
+	}
+	for _, f := range gen.Files {
+		if f.Generate && generateFile(gen, f) != nil {
+			t.Errorf("generated code for %s, want none", f.Desc.Path())
+		}
+	}
+}
+
+// TestGenerateFileNullValue tests that google.protobuf.NullValue fields are
+// written as null
+func TestGenerateFileNullValue(t *testing.T) {
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("nulls.proto"),
+		Package:    proto.String("test.nulls"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/struct.proto"},
+		Options:    &descriptorpb.FileOptions{GoPackage: proto.String("example.com/nulls")},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Nulls"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("null"),
+				Number:   proto.Int32(1),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum(),
+				TypeName: proto.String(".google.protobuf.NullValue"),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				JsonName: proto.String("null"),
+			}},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile() error = %v", err)
+	}
+	gen, err := protogen.Options{}.New(request(fd))
+	if err != nil {
+		t.Fatalf("protogen.Options.New() error = %v", err)
+	}
+	for _, f := range gen.Files {
+		if f.Generate {
+			generateFile(gen, f)
+		}
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatalf("Response() error = %s", resp.GetError())
+	}
+	if len(resp.File) != 1 {
+		t.Fatalf("generated %d files, want 1", len(resp.File))
+	}
+	content := resp.File[0].GetContent()
+	if !strings.Contains(content, "w.WriteNull()") || strings.Contains(content, "NullValue_name") {
+		t.Errorf("generated code does not write null:\n%s", content)
+	}
+}
@@ -0,0 +1,91 @@
+package protojson
+
+import (
+	"errors"
+	"fmt"
+
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+)
+
+// Codec marshals messages with this package and unmarshals them with the
+// standard package. It implements the Codec interface of
+// connectrpc.com/connect, so Connect handlers and clients can serve
+// application/json with their own MarshalOptions:
+//
+//	connect.WithCodec(protojson.NewCodec(protojson.MarshalOptions{
+//		FieldMaskFunc: isSensitive,
+//	}))
+type Codec struct {
+	opts MarshalOptions
+}
+
+// NewCodec returns a Codec that marshals with opts. Unmarshaling discards
+// unknown fields, like Connect's default JSON codec, and resolves types
+// through opts.Resolver.
+func NewCodec(opts MarshalOptions) *Codec {
+	opts.Comments = nil
+	return &Codec{opts: opts}
+}
+
+// Name returns "json", the name Connect uses for the application/json codec.
+func (c *Codec) Name() string {
+	return "json"
+}
+
+// Marshal marshals message, which must be a proto.Message or a legacy
+// protoadapt.MessageV1.
+func (c *Codec) Marshal(message any) ([]byte, error) {
+	return c.MarshalAppend(nil, message)
+}
+
+// MarshalAppend appends the encoding of message, which must be a
+// proto.Message or a legacy protoadapt.MessageV1, to dst.
+func (c *Codec) MarshalAppend(dst []byte, message any) ([]byte, error) {
+	m, ok := asMessage(message)
+	if !ok {
+		return nil, errNotProto(message)
+	}
+	return c.opts.MarshalAppend(dst, m)
+}
+
+// MarshalStable marshals message like Marshal, but always without
+// indentation and with the Stable option, so that equal messages produce
+// equal bytes.
+func (c *Codec) MarshalStable(message any) ([]byte, error) {
+	m, ok := asMessage(message)
+	if !ok {
+		return nil, errNotProto(message)
+	}
+	opts := c.opts
+	opts.Indent = ""
+	opts.Multiline = false
+	opts.Stable = true
+	return opts.Marshal(m)
+}
+
+// Unmarshal unmarshals data into message, which must be a proto.Message or
+// a legacy protoadapt.MessageV1.
+func (c *Codec) Unmarshal(data []byte, message any) error {
+	m, ok := asMessage(message)
+	if !ok {
+		return errNotProto(message)
+	}
+	if len(data) == 0 {
+		return errors.New("zero-length payload is not a valid JSON object")
+	}
+	opts := stdprotojson.UnmarshalOptions{DiscardUnknown: true, Resolver: resolverOf(c.opts.Resolver)}
+	if err := opts.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("unmarshal into %T: %w", message, err)
+	}
+	return nil
+}
+
+// IsBinary reports false, as JSON is a text format.
+func (c *Codec) IsBinary() bool {
+	return false
+}
+
+// errNotProto returns the error for values that are not proto.Message
+func errNotProto(message any) error {
+	return fmt.Errorf("%T doesn't implement proto.Message", message)
+}
@@ -0,0 +1,82 @@
+package protojson_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// TestCodec tests the Connect codec adapter
+func TestCodec(t *testing.T) {
+	codec := protojson.NewCodec(protojson.MarshalOptions{
+		Indent:        "  ",
+		FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "email" },
+	})
+	msg := &pb_basic.User{Name: "alice", Email: "alice@example.com"}
+
+	if got := codec.Name(); got != "json" {
+		t.Errorf("Name() = %q, want %q", got, "json")
+	}
+	if codec.IsBinary() {
+		t.Errorf("IsBinary() = true, want false")
+	}
+
+	got, err := codec.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := "{\n  \"name\": \"alice\",\n  \"email\": \"***\"\n}"
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = codec.MarshalAppend([]byte("x"), msg)
+	if err != nil {
+		t.Fatalf("MarshalAppend() error = %v", err)
+	}
+	if diff := cmp.Diff("x"+want, string(got)); diff != "" {
+		t.Errorf("MarshalAppend() mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = codec.MarshalStable(msg)
+	if err != nil {
+		t.Fatalf("MarshalStable() error = %v", err)
+	}
+	if diff := cmp.Diff(`{"name":"alice","email":"***"}`, string(got)); diff != "" {
+		t.Errorf("MarshalStable() mismatch (-want +got):\n%s", diff)
+	}
+
+	var decoded pb_basic.User
+	if err := codec.Unmarshal([]byte(`{"name":"bob","unknownField":1}`), &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if diff := cmp.Diff(&pb_basic.User{Name: "bob"}, &decoded, protocmp.Transform()); diff != "" {
+		t.Errorf("Unmarshal() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestCodecErrors tests the errors of the Connect codec adapter
+func TestCodecErrors(t *testing.T) {
+	codec := protojson.NewCodec(protojson.MarshalOptions{})
+
+	if _, err := codec.Marshal("not a message"); err == nil {
+		t.Errorf("Marshal() error = nil, want error")
+	}
+	if _, err := codec.MarshalStable(42); err == nil {
+		t.Errorf("MarshalStable() error = nil, want error")
+	}
+	var s string
+	if err := codec.Unmarshal([]byte(`{}`), &s); err == nil {
+		t.Errorf("Unmarshal() error = nil, want error for non-message")
+	}
+	if err := codec.Unmarshal(nil, &pb_basic.User{}); err == nil {
+		t.Errorf("Unmarshal() error = nil, want error for empty payload")
+	}
+	if err := codec.Unmarshal([]byte(`{"name":`), &pb_basic.User{}); err == nil {
+		t.Errorf("Unmarshal() error = nil, want error for invalid JSON")
+	}
+}
@@ -0,0 +1,106 @@
+package protojson
+
+import (
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// FieldComments maps the full names of fields to the comments written
+// before them by MarshalOptions.Comments.
+type FieldComments map[protoreflect.FullName]string
+
+// Field numbers of descriptor.proto used in source code info paths
+const (
+	fileMessageTypeTag   = 4 // FileDescriptorProto.message_type
+	messageFieldTag      = 2 // DescriptorProto.field
+	messageNestedTypeTag = 3 // DescriptorProto.nested_type
+)
+
+// CommentsFromDescriptorSet returns the leading comments of the fields
+// declared in set, which must have been built with source code info, e.g.
+// with protoc --include_source_info or buf build --as-file-descriptor-set.
+// Fields without a leading comment are left out. Descriptors of generated
+// code do not keep comments, so they must be loaded from a descriptor set.
+func CommentsFromDescriptorSet(set *descriptorpb.FileDescriptorSet) FieldComments {
+	comments := FieldComments{}
+	for _, file := range set.GetFile() {
+		leading := make(map[string]string)
+		for _, loc := range file.GetSourceCodeInfo().GetLocation() {
+			if c := strings.TrimSpace(loc.GetLeadingComments()); c != "" {
+				leading[commentPath(loc.GetPath())] = c
+			}
+		}
+		if len(leading) == 0 {
+			continue
+		}
+		prefix := ""
+		if file.GetPackage() != "" {
+			prefix = file.GetPackage() + "."
+		}
+		for i, md := range file.GetMessageType() {
+			addFieldComments(comments, leading, md, prefix, []int32{fileMessageTypeTag, int32(i)})
+		}
+	}
+	return comments
+}
+
+// addFieldComments adds the comments of the fields of md, and of messages
+// nested in it, found at path
+func addFieldComments(comments FieldComments, leading map[string]string, md *descriptorpb.DescriptorProto, prefix string, path []int32) {
+	name := prefix + md.GetName()
+	for i, fd := range md.GetField() {
+		if c, ok := leading[commentPath(append(path, messageFieldTag, int32(i)))]; ok {
+			comments[protoreflect.FullName(name+"."+fd.GetName())] = c
+		}
+	}
+	for i, nested := range md.GetNestedType() {
+		addFieldComments(comments, leading, nested, name+".", append(path[:len(path):len(path)], messageNestedTypeTag, int32(i)))
+	}
+}
+
+// commentPath returns a source code info path as a map key
+func commentPath(path []int32) string {
+	var b strings.Builder
+	for i, n := range path {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(int(n)))
+	}
+	return b.String()
+}
+
+// writeComment writes the comment for fd, if any, before its key: as //
+// lines followed by the indentation in multiline output, or as a /* */
+// block otherwise
+func (e *encoder) writeComment(fd protoreflect.FieldDescriptor) {
+	c, ok := e.opts.Comments[fd.FullName()]
+	if !ok {
+		return
+	}
+	lines := strings.Split(c, "\n")
+	if e.opts.Indent == "" && !e.opts.Multiline {
+		e.w.WriteString("/* ")
+		for i, line := range lines {
+			if i > 0 {
+				e.w.WriteByte(' ')
+			}
+			e.w.WriteString(strings.ReplaceAll(strings.TrimSpace(line), "*/", "* /"))
+		}
+		e.w.WriteString(" */")
+		return
+	}
+	for _, line := range lines {
+		e.w.WriteString("//")
+		if line = strings.TrimRight(line, " \t\r"); line != "" {
+			if line[0] != ' ' {
+				e.w.WriteByte(' ')
+			}
+			e.w.WriteString(line)
+		}
+		e.writeIndent()
+	}
+}
@@ -0,0 +1,161 @@
+package protojson_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// basicCommentSet returns a descriptor set for basic.proto with leading
+// comments on the first two fields of BasicTypes
+func basicCommentSet() *descriptorpb.FileDescriptorSet {
+	file := protodesc.ToFileDescriptorProto(pb_basic.File_basic_proto)
+	file.SourceCodeInfo = &descriptorpb.SourceCodeInfo{
+		Location: []*descriptorpb.SourceCodeInfo_Location{
+			{Path: []int32{4, 0}, LeadingComments: proto.String(" BasicTypes tests all basic scalar types\n")},
+			{Path: []int32{4, 0, 2, 0}, LeadingComments: proto.String(" The string value.\n Second line.\n")},
+			{Path: []int32{4, 0, 2, 1}, LeadingComments: proto.String(" Count */ here\n")},
+			{Path: []int32{4, 0, 2, 2}, TrailingComments: proto.String(" Not leading\n")},
+		},
+	}
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}}
+}
+
+// TestCommentsFromDescriptorSet tests loading leading comments of fields,
+// including fields of nested messages
+func TestCommentsFromDescriptorSet(t *testing.T) {
+	set := basicCommentSet()
+	set.File = append(set.File, &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("outer.proto"),
+		Package: proto.String("x"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name:       proto.String("Outer"),
+			Field:      []*descriptorpb.FieldDescriptorProto{{Name: proto.String("a")}},
+			NestedType: []*descriptorpb.DescriptorProto{{Name: proto.String("Inner"), Field: []*descriptorpb.FieldDescriptorProto{{Name: proto.String("b")}}}},
+		}},
+		SourceCodeInfo: &descriptorpb.SourceCodeInfo{
+			Location: []*descriptorpb.SourceCodeInfo_Location{
+				{Path: []int32{4, 0, 3, 0, 2, 0}, LeadingComments: proto.String(" Inner field\n")},
+			},
+		},
+	})
+
+	want := protojson.FieldComments{
+		"test.basic.BasicTypes.string_field": "The string value.\n Second line.",
+		"test.basic.BasicTypes.int32_field":  "Count */ here",
+		"x.Outer.Inner.b":                    "Inner field",
+	}
+	if diff := cmp.Diff(want, protojson.CommentsFromDescriptorSet(set)); diff != "" {
+		t.Errorf("CommentsFromDescriptorSet() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestComments tests writing JSONC with field comments
+func TestComments(t *testing.T) {
+	comments := protojson.CommentsFromDescriptorSet(basicCommentSet())
+	msg := &pb_basic.BasicTypes{StringField: "s", Int32Field: 1, Int64Field: 2}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Indent",
+			opts: protojson.MarshalOptions{Comments: comments, Indent: "  "},
+			want: "{\n  // The string value.\n  // Second line.\n  \"stringField\": \"s\",\n" +
+				"  // Count */ here\n  \"int32Field\": 1,\n  \"int64Field\": \"2\"\n}",
+		},
+		{
+			name: "Compact",
+			opts: protojson.MarshalOptions{Comments: comments},
+			want: `{/* The string value. Second line. */"stringField":"s",/* Count * / here */"int32Field":1,"int64Field":"2"}`,
+		},
+		{
+			name: "Canonical",
+			opts: protojson.MarshalOptions{Comments: comments, Canonical: true},
+			want: `{"int32Field":1,"int64Field":"2","stringField":"s"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestCommentsIgnored tests that entry points requiring JSON leave out
+// comments
+func TestCommentsIgnored(t *testing.T) {
+	opts := protojson.MarshalOptions{Comments: protojson.CommentsFromDescriptorSet(basicCommentSet())}
+	msg := &pb_basic.BasicTypes{StringField: "s"}
+
+	tests := []struct {
+		name    string
+		marshal func() ([]byte, error)
+	}{
+		{
+			name:    "JSONWrapper",
+			marshal: func() ([]byte, error) { return json.Marshal(protojson.JSONWrapper(msg, opts)) },
+		},
+		{
+			name: "LogValue",
+			marshal: func() ([]byte, error) {
+				v := protojson.LogValue(msg, opts).LogValue().Any()
+				raw, ok := v.(json.RawMessage)
+				if !ok {
+					return nil, fmt.Errorf("LogValue() = %v", v)
+				}
+				return raw, nil
+			},
+		},
+		{
+			name:    "Codec",
+			marshal: func() ([]byte, error) { return protojson.NewCodec(opts).Marshal(msg) },
+		},
+		{
+			name: "JSONColumn",
+			marshal: func() ([]byte, error) {
+				v, err := protojson.JSONColumn[*pb_basic.BasicTypes]{Message: msg, Options: opts}.Value()
+				if err != nil {
+					return nil, err
+				}
+				return []byte(v.(string)), nil
+			},
+		},
+		{
+			name:    "MarshalDiff",
+			marshal: func() ([]byte, error) { return opts.MarshalDiff(&pb_basic.BasicTypes{}, msg) },
+		},
+		{
+			name:    "MarshalMergePatch",
+			marshal: func() ([]byte, error) { return opts.MarshalMergePatch(&pb_basic.BasicTypes{}, msg) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.marshal()
+			if err != nil {
+				t.Fatalf("%s() error = %v", tt.name, err)
+			}
+			if diff := cmp.Diff(`{"stringField":"s"}`, string(got)); diff != "" {
+				t.Errorf("%s() mismatch (-want +got):\n%s", tt.name, diff)
+			}
+		})
+	}
+}
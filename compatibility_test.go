@@ -1,7 +1,12 @@
 package protojson_test
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -11,11 +16,51 @@ import (
 	pb_basic "github.com/wreulicke/protojson/gen"
 	stdprotojson "google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
+// stdMarshal marshals m with google.golang.org/protobuf/encoding/protojson and
+// strips the randomized whitespace it inserts to discourage byte-for-byte
+// comparisons, so the result can be compared against our output.
+func stdMarshal(opts stdprotojson.MarshalOptions, m proto.Message) ([]byte, error) {
+	data, err := opts.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if opts.Multiline || opts.Indent != "" {
+		indent := opts.Indent
+		if indent == "" {
+			indent = "  "
+		}
+		err = json.Indent(&buf, data, "", indent)
+	} else {
+		err = json.Compact(&buf, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mustAny wraps m in a google.protobuf.Any
+func mustAny(t *testing.T, m proto.Message) *anypb.Any {
+	t.Helper()
+	a, err := anypb.New(m)
+	if err != nil {
+		t.Fatalf("anypb.New failed: %v", err)
+	}
+	return a
+}
+
 // TestMarshalCompatibility tests that our Marshal implementation produces
 // the same output as google.golang.org/protobuf/encoding/protojson
 func TestMarshalCompatibility(t *testing.T) {
@@ -44,6 +89,43 @@ func TestMarshalCompatibility(t *testing.T) {
 				BytesField:    []byte("binary data"),
 			},
 		},
+		{
+			name: "BasicTypes_LargeFloats",
+			msg: &pb_basic.BasicTypes{
+				FloatField:  1e20,
+				DoubleField: 123456789012345680000,
+			},
+		},
+		{
+			name: "BasicTypes_ExponentFloats",
+			msg: &pb_basic.BasicTypes{
+				FloatField:  -1e21,
+				DoubleField: 1.5e300,
+			},
+		},
+		{
+			name: "BasicTypes_SmallFloats",
+			msg: &pb_basic.BasicTypes{
+				FloatField:  0.000001,
+				DoubleField: -1.25e-7,
+			},
+		},
+		{
+			name: "BasicTypes_TinyFloats",
+			msg: &pb_basic.BasicTypes{
+				FloatField:  1e-30,
+				DoubleField: 5e-324,
+			},
+		},
+		{
+			name: "ListValue_LargeAndSmallNumbers",
+			msg: &pb_basic.WellKnownTypes{
+				ListValue: &structpb.ListValue{Values: []*structpb.Value{
+					structpb.NewNumberValue(1e15),
+					structpb.NewNumberValue(1e-7),
+				}},
+			},
+		},
 		{
 			name: "BasicTypes_Empty",
 			msg:  &pb_basic.BasicTypes{},
@@ -176,6 +258,21 @@ func TestMarshalCompatibility(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "MapFields_IntKeyOrdering",
+			msg: &pb_basic.MapFields{
+				IntKeyMap: map[int32]string{1: "one", 2: "two", 10: "ten", -3: "minus three"},
+			},
+		},
+		{
+			name: "TypedKeyMaps",
+			msg: &pb_basic.TypedKeyMaps{
+				BoolKeyMap:   map[bool]string{true: "t", false: "f"},
+				Int64KeyMap:  map[int64]string{100: "a", -9223372036854775808: "min", 20: "b"},
+				Uint32KeyMap: map[uint32]string{4294967295: "max", 9: "nine", 10: "ten"},
+				Sint32KeyMap: map[int32]string{-1: "a", -10: "b", 5: "c"},
+			},
+		},
 		{
 			name: "MapFields_MessageMap",
 			msg: &pb_basic.MapFields{
@@ -261,12 +358,119 @@ func TestMarshalCompatibility(t *testing.T) {
 				Timestamp: timestamppb.New(time.Unix(1609459200, 0)), // 2021-01-01 00:00:00 UTC
 			},
 		},
+		{
+			name: "WellKnownTypes_TimestampMillis",
+			msg: &pb_basic.WellKnownTypes{
+				Timestamp: &timestamppb.Timestamp{Seconds: 1609459200, Nanos: 120000000},
+			},
+		},
+		{
+			name: "WellKnownTypes_TimestampMicros",
+			msg: &pb_basic.WellKnownTypes{
+				Timestamp: &timestamppb.Timestamp{Seconds: 1609459200, Nanos: 123400000 + 500},
+			},
+		},
+		{
+			name: "WellKnownTypes_TimestampNanos",
+			msg: &pb_basic.WellKnownTypes{
+				Timestamp: &timestamppb.Timestamp{Seconds: 1609459200, Nanos: 100},
+			},
+		},
+		{
+			name: "WellKnownTypes_TimestampBeforeEpoch",
+			msg: &pb_basic.WellKnownTypes{
+				Timestamp: &timestamppb.Timestamp{Seconds: -1, Nanos: 5000000},
+			},
+		},
+		{
+			name: "WellKnownTypes_AnyMessage",
+			msg: &pb_basic.WellKnownTypes{
+				Any: mustAny(t, &pb_basic.BasicTypes{StringField: "hello", Int32Field: 42}),
+			},
+		},
+		{
+			name: "WellKnownTypes_AnyMessage_Multiline",
+			msg: &pb_basic.WellKnownTypes{
+				Any: mustAny(t, &pb_basic.Nested{Id: "root", Inner: &pb_basic.Inner{Name: "inner", Value: 1}}),
+			},
+			opts: protojson.MarshalOptions{Multiline: true},
+		},
+		{
+			name: "WellKnownTypes_AnyTimestamp",
+			msg: &pb_basic.WellKnownTypes{
+				Any: mustAny(t, timestamppb.New(time.Unix(1609459200, 0))),
+			},
+		},
+		{
+			name: "WellKnownTypes_AnyWrapper_Indent",
+			msg: &pb_basic.WellKnownTypes{
+				Any: mustAny(t, wrapperspb.Int64(-7)),
+			},
+			opts: protojson.MarshalOptions{Indent: "  "},
+		},
+		{
+			name: "WellKnownTypes_AnyStruct",
+			msg: &pb_basic.WellKnownTypes{
+				Any: mustAny(t, &structpb.Struct{Fields: map[string]*structpb.Value{
+					"key": structpb.NewStringValue("value"),
+				}}),
+			},
+		},
+		{
+			name: "WellKnownTypes_AnyFieldMask",
+			msg: &pb_basic.WellKnownTypes{
+				Any: mustAny(t, &fieldmaskpb.FieldMask{Paths: []string{"user.display_name", "id"}}),
+			},
+		},
+		{
+			name: "WellKnownTypes_AnyEmpty",
+			msg: &pb_basic.WellKnownTypes{
+				Any: mustAny(t, &emptypb.Empty{}),
+			},
+		},
+		{
+			name: "WellKnownTypes_AnyInAny_Multiline",
+			msg: &pb_basic.WellKnownTypes{
+				Any: mustAny(t, mustAny(t, &pb_basic.BasicTypes{StringField: "inner"})),
+			},
+			opts: protojson.MarshalOptions{Multiline: true},
+		},
+		{
+			name: "WellKnownTypes_AnyUnset",
+			msg: &pb_basic.WellKnownTypes{
+				Any: &anypb.Any{},
+			},
+		},
 		{
 			name: "WellKnownTypes_Duration",
 			msg: &pb_basic.WellKnownTypes{
 				Duration: durationpb.New(3600 * time.Second), // 1 hour
 			},
 		},
+		{
+			name: "WellKnownTypes_DurationNegative",
+			msg: &pb_basic.WellKnownTypes{
+				Duration: &durationpb.Duration{Seconds: -5, Nanos: -250000000},
+			},
+		},
+		{
+			name: "WellKnownTypes_DurationNegativeFraction",
+			msg: &pb_basic.WellKnownTypes{
+				Duration: &durationpb.Duration{Nanos: -500000000},
+			},
+		},
+		{
+			name: "WellKnownTypes_DurationMicros",
+			msg: &pb_basic.WellKnownTypes{
+				Duration: &durationpb.Duration{Seconds: 1, Nanos: 1000},
+			},
+		},
+		{
+			name: "WellKnownTypes_DurationMax",
+			msg: &pb_basic.WellKnownTypes{
+				Duration: &durationpb.Duration{Seconds: 315576000000, Nanos: 999999999},
+			},
+		},
 		{
 			name: "WrapperTypes_AllSet",
 			msg: &pb_basic.WrapperTypes{
@@ -436,6 +640,53 @@ func TestMarshalCompatibility(t *testing.T) {
 				Indent: "  ",
 			},
 		},
+		{
+			name: "Indent_RepeatedAndMaps",
+			msg: &pb_basic.MapFields{
+				StringMap:  map[string]string{"a": "1", "b": "2"},
+				IntKeyMap:  map[int32]string{1: "one"},
+				MessageMap: map[string]*pb_basic.Value{"x": {Data: "d", Count: 1}},
+			},
+			opts: protojson.MarshalOptions{
+				Indent: "\t",
+			},
+		},
+		{
+			name: "Multiline_RepeatedMessages",
+			msg: &pb_basic.RepeatedMessages{
+				Items: []*pb_basic.Item{
+					{Name: "item1", Value: 100},
+					{Name: "item2", Value: 200},
+				},
+			},
+			opts: protojson.MarshalOptions{
+				Multiline:       true,
+				EmitUnpopulated: true,
+			},
+		},
+		{
+			name: "Multiline_Struct",
+			msg: &pb_basic.WellKnownTypes{
+				Struct: &structpb.Struct{Fields: map[string]*structpb.Value{
+					"list": structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{
+						structpb.NewNumberValue(1),
+						structpb.NewStringValue("two"),
+					}}),
+				}},
+				ListValue: &structpb.ListValue{},
+			},
+			opts: protojson.MarshalOptions{
+				Multiline: true,
+			},
+		},
+		{
+			name: "Struct",
+			msg: &pb_basic.WellKnownTypes{
+				Struct: &structpb.Struct{Fields: map[string]*structpb.Value{
+					"key": structpb.NewBoolValue(true),
+				}},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -449,7 +700,7 @@ func TestMarshalCompatibility(t *testing.T) {
 				UseEnumNumbers:  tt.opts.UseEnumNumbers,
 				EmitUnpopulated: tt.opts.EmitUnpopulated,
 			}
-			expectedJSON, err := stdOpts.Marshal(tt.msg)
+			expectedJSON, err := stdMarshal(stdOpts, tt.msg)
 			if err != nil {
 				t.Fatalf("standard protojson.Marshal failed: %v", err)
 			}
@@ -472,6 +723,53 @@ func TestMarshalCompatibility(t *testing.T) {
 	}
 }
 
+// TestEmitUnpopulatedCompatibility tests that EmitUnpopulated and
+// EmitDefaultValues treat fields with presence like the standard package
+func TestEmitUnpopulatedCompatibility(t *testing.T) {
+	messages := []struct {
+		name string
+		msg  proto.Message
+	}{
+		{name: "UnsetMessage", msg: &pb_basic.Nested{Id: "a"}},
+		{name: "OptionalFields", msg: &pb_basic.OptionalFields{OptionalInt32: proto.Int32(0)}},
+		{name: "Oneof", msg: &pb_basic.OneOfFields{}},
+		{name: "Wrappers", msg: &pb_basic.WrapperTypes{BoolValue: wrapperspb.Bool(false)}},
+		{name: "Proto2", msg: &pb_basic.RequiredFields{Name: proto.String("n"), Id: proto.Int32(1)}},
+		{name: "Proto2Extendable", msg: &pb_basic.Extendable{}},
+		{name: "Maps", msg: &pb_basic.MapFields{}},
+	}
+	options := []struct {
+		name string
+		opts protojson.MarshalOptions
+	}{
+		{name: "EmitUnpopulated", opts: protojson.MarshalOptions{EmitUnpopulated: true}},
+		{name: "EmitDefaultValues", opts: protojson.MarshalOptions{EmitDefaultValues: true}},
+		{name: "Both", opts: protojson.MarshalOptions{EmitUnpopulated: true, EmitDefaultValues: true}},
+	}
+
+	for _, o := range options {
+		for _, m := range messages {
+			t.Run(o.name+"/"+m.name, func(t *testing.T) {
+				stdOpts := stdprotojson.MarshalOptions{
+					EmitUnpopulated:   o.opts.EmitUnpopulated,
+					EmitDefaultValues: o.opts.EmitDefaultValues,
+				}
+				want, err := stdMarshal(stdOpts, m.msg)
+				if err != nil {
+					t.Fatalf("standard protojson.Marshal failed: %v", err)
+				}
+				got, err := o.opts.Marshal(m.msg)
+				if err != nil {
+					t.Fatalf("Marshal() error = %v", err)
+				}
+				if diff := cmp.Diff(string(want), string(got)); diff != "" {
+					t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+				}
+			})
+		}
+	}
+}
+
 // TestEncoderCompatibility tests that our Encoder implementation produces
 // the same output as repeated calls to google.golang.org/protobuf/encoding/protojson.Marshal
 func TestEncoderCompatibility(t *testing.T) {
@@ -484,7 +782,7 @@ func TestEncoderCompatibility(t *testing.T) {
 	// Expected output: marshal each message separately
 	var expectedBuf bytes.Buffer
 	for _, msg := range messages {
-		data, err := stdprotojson.Marshal(msg)
+		data, err := stdMarshal(stdprotojson.MarshalOptions{}, msg)
 		if err != nil {
 			t.Fatalf("standard protojson.Marshal failed: %v", err)
 		}
@@ -560,7 +858,7 @@ func TestEncoderWithOptions(t *testing.T) {
 				UseEnumNumbers:  tt.opts.UseEnumNumbers,
 				EmitUnpopulated: tt.opts.EmitUnpopulated,
 			}
-			expectedData, err := stdOpts.Marshal(tt.msg)
+			expectedData, err := stdMarshal(stdOpts, tt.msg)
 			if err != nil {
 				t.Fatalf("standard protojson.Marshal failed: %v", err)
 			}
@@ -616,7 +914,7 @@ func TestEncoderSetOptions(t *testing.T) {
 	stdOpts := stdprotojson.MarshalOptions{
 		Indent: "  ",
 	}
-	expected, err := stdOpts.Marshal(msg)
+	expected, err := stdMarshal(stdOpts, msg)
 	if err != nil {
 		t.Fatalf("standard protojson.Marshal failed: %v", err)
 	}
@@ -637,7 +935,7 @@ func TestEncoderMultipleMessages(t *testing.T) {
 	// Expected output
 	var expectedBuf bytes.Buffer
 	for _, msg := range messages {
-		data, err := stdprotojson.Marshal(msg)
+		data, err := stdMarshal(stdprotojson.MarshalOptions{}, msg)
 		if err != nil {
 			t.Fatalf("standard protojson.Marshal failed: %v", err)
 		}
@@ -657,3 +955,673 @@ func TestEncoderMultipleMessages(t *testing.T) {
 		t.Errorf("Encoder output mismatch (-want +got):\n%s", diff)
 	}
 }
+
+// TestEncoderReset tests that Reset rebinds the encoder to a new writer
+// while keeping its options
+func TestEncoderReset(t *testing.T) {
+	msg := &pb_basic.BasicTypes{StringField: "test", Int32Field: 42}
+	opts := protojson.MarshalOptions{UseProtoNames: true}
+
+	var first, second bytes.Buffer
+	encoder := protojson.NewEncoderWithOptions(&first, opts)
+	if err := encoder.Encode(msg); err != nil {
+		t.Fatalf("Encoder.Encode failed: %v", err)
+	}
+
+	encoder.Reset(&second)
+	if err := encoder.Encode(msg); err != nil {
+		t.Fatalf("Encoder.Encode after Reset failed: %v", err)
+	}
+
+	expected, err := stdMarshal(stdprotojson.MarshalOptions{UseProtoNames: true}, msg)
+	if err != nil {
+		t.Fatalf("standard protojson.Marshal failed: %v", err)
+	}
+	if diff := cmp.Diff(string(expected), first.String()); diff != "" {
+		t.Errorf("output before Reset mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(string(expected), second.String()); diff != "" {
+		t.Errorf("output after Reset mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestEncoderBufferedWriters tests encoding directly into writers that are
+// already buffered
+func TestEncoderBufferedWriters(t *testing.T) {
+	msg := &pb_basic.BasicTypes{StringField: "test", Int32Field: 42}
+	expected, err := stdMarshal(stdprotojson.MarshalOptions{}, msg)
+	if err != nil {
+		t.Fatalf("standard protojson.Marshal failed: %v", err)
+	}
+
+	t.Run("StringsBuilder", func(t *testing.T) {
+		var sb strings.Builder
+		if err := protojson.NewEncoder(&sb).Encode(msg); err != nil {
+			t.Fatalf("Encoder.Encode failed: %v", err)
+		}
+		if diff := cmp.Diff(string(expected), sb.String()); diff != "" {
+			t.Errorf("Encoder output mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("BufioWriter", func(t *testing.T) {
+		var buf bytes.Buffer
+		bw := bufio.NewWriter(&buf)
+		if err := protojson.NewEncoder(bw).Encode(msg); err != nil {
+			t.Fatalf("Encoder.Encode failed: %v", err)
+		}
+		// Output stays in the caller's buffer until the caller flushes it
+		if buf.Len() != 0 {
+			t.Errorf("Encoder flushed the caller's bufio.Writer")
+		}
+		if err := bw.Flush(); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		if diff := cmp.Diff(string(expected), buf.String()); diff != "" {
+			t.Errorf("Encoder output mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+// TestEncoderBufferDiscardsFailedOutput tests that a failed Encode call
+// leaves a caller's *bytes.Buffer as it was
+func TestEncoderBufferDiscardsFailedOutput(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("before")
+	encoder := protojson.NewEncoder(&buf)
+	err := encoder.Encode(&pb_basic.BasicTypes{Int32Field: 1, StringField: "\xff"})
+	if !errors.Is(err, protojson.ErrInvalidUTF8) {
+		t.Fatalf("Encode() error = %v, want ErrInvalidUTF8", err)
+	}
+	if diff := cmp.Diff("before", buf.String()); diff != "" {
+		t.Errorf("buffer after failed Encode mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// writeCounter counts the writes made to it
+type writeCounter struct {
+	buf    bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.buf.Write(p)
+}
+
+// TestEncoderSize tests that the buffer size of NewEncoderSize determines
+// how often a large message is written to the underlying writer
+func TestEncoderSize(t *testing.T) {
+	msg := &pb_basic.RepeatedFields{}
+	for range 100 {
+		msg.Strings = append(msg.Strings, strings.Repeat("x", 100))
+	}
+	expected, err := stdMarshal(stdprotojson.MarshalOptions{}, msg)
+	if err != nil {
+		t.Fatalf("standard protojson.Marshal failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		size       int
+		wantWrites int
+	}{
+		{name: "Default", size: 0, wantWrites: len(expected)/4096 + 1},
+		{name: "Large", size: 64 << 10, wantWrites: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w writeCounter
+			encoder := protojson.NewEncoderSize(&w, tt.size)
+			if err := encoder.Encode(msg); err != nil {
+				t.Fatalf("Encoder.Encode failed: %v", err)
+			}
+			if diff := cmp.Diff(string(expected), w.buf.String()); diff != "" {
+				t.Errorf("Encoder output mismatch (-want +got):\n%s", diff)
+			}
+			if w.writes != tt.wantWrites {
+				t.Errorf("got %d writes, want %d", w.writes, tt.wantWrites)
+			}
+		})
+	}
+}
+
+// TestEncoderWriteNewline tests newline-delimited output
+func TestEncoderWriteNewline(t *testing.T) {
+	messages := []proto.Message{
+		&pb_basic.BasicTypes{StringField: "first", Int32Field: 1},
+		&pb_basic.BasicTypes{StringField: "second", Int32Field: 2},
+	}
+
+	var expectedBuf bytes.Buffer
+	for _, msg := range messages {
+		data, err := stdMarshal(stdprotojson.MarshalOptions{}, msg)
+		if err != nil {
+			t.Fatalf("standard protojson.Marshal failed: %v", err)
+		}
+		expectedBuf.Write(data)
+		expectedBuf.WriteByte('\n')
+	}
+
+	var gotBuf bytes.Buffer
+	encoder := protojson.NewEncoder(&gotBuf)
+	encoder.SetWriteNewline(true)
+	for _, msg := range messages {
+		if err := encoder.Encode(msg); err != nil {
+			t.Fatalf("Encoder.Encode failed: %v", err)
+		}
+	}
+
+	if diff := cmp.Diff(expectedBuf.String(), gotBuf.String()); diff != "" {
+		t.Errorf("Encoder output mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestEncoderArray tests streaming messages as a single JSON array
+func TestEncoderArray(t *testing.T) {
+	messages := []proto.Message{
+		&pb_basic.BasicTypes{StringField: "first", Int32Field: 1},
+		&pb_basic.BasicTypes{StringField: "second", Int32Field: 2},
+	}
+
+	tests := []struct {
+		name     string
+		messages []proto.Message
+		opts     protojson.MarshalOptions
+		newline  bool
+		want     string
+	}{
+		{
+			name:     "Empty",
+			messages: nil,
+			want:     `[]`,
+		},
+		{
+			name:     "Compact",
+			messages: messages,
+			want:     `[{"stringField":"first","int32Field":1},{"stringField":"second","int32Field":2}]`,
+		},
+		{
+			name:     "WithNewline",
+			messages: messages,
+			newline:  true,
+			want:     "[{\"stringField\":\"first\",\"int32Field\":1},{\"stringField\":\"second\",\"int32Field\":2}]\n",
+		},
+		{
+			name:     "Indent",
+			messages: messages,
+			opts:     protojson.MarshalOptions{Indent: "  "},
+			want: `[
+  {
+    "stringField": "first",
+    "int32Field": 1
+  },
+  {
+    "stringField": "second",
+    "int32Field": 2
+  }
+]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := protojson.NewEncoderWithOptions(&buf, tt.opts)
+			encoder.SetWriteNewline(tt.newline)
+			if err := encoder.BeginArray(); err != nil {
+				t.Fatalf("BeginArray failed: %v", err)
+			}
+			for _, msg := range tt.messages {
+				if err := encoder.Encode(msg); err != nil {
+					t.Fatalf("Encoder.Encode failed: %v", err)
+				}
+			}
+			if err := encoder.EndArray(); err != nil {
+				t.Fatalf("EndArray failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Errorf("Encoder output mismatch (-want +got):\n%s", diff)
+			}
+			if !json.Valid(buf.Bytes()) {
+				t.Errorf("Encoder output is not valid JSON: %s", buf.String())
+			}
+		})
+	}
+
+	t.Run("Misuse", func(t *testing.T) {
+		encoder := protojson.NewEncoder(&bytes.Buffer{})
+		if err := encoder.EndArray(); err == nil {
+			t.Errorf("EndArray without BeginArray should fail")
+		}
+		if err := encoder.BeginArray(); err != nil {
+			t.Fatalf("BeginArray failed: %v", err)
+		}
+		if err := encoder.BeginArray(); err == nil {
+			t.Errorf("nested BeginArray should fail")
+		}
+	})
+}
+
+// TestEncoderArrayFailedElement tests that a message failing to encode is
+// left out of an array, or abandons it when its output cannot be dropped
+func TestEncoderArrayFailedElement(t *testing.T) {
+	encode := func(t *testing.T, w io.Writer) (endErr error) {
+		t.Helper()
+		encoder := protojson.NewEncoder(w)
+		if err := encoder.BeginArray(); err != nil {
+			t.Fatalf("BeginArray failed: %v", err)
+		}
+		for _, s := range []string{"a", "\xff", "c"} {
+			err := encoder.Encode(&pb_basic.BasicTypes{StringField: s})
+			if (err != nil) != (s == "\xff") {
+				t.Fatalf("Encode(%q) error = %v", s, err)
+			}
+		}
+		return encoder.EndArray()
+	}
+
+	t.Run("Buffer", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := encode(t, &buf); err != nil {
+			t.Fatalf("EndArray failed: %v", err)
+		}
+		if diff := cmp.Diff(`[{"stringField":"a"},{"stringField":"c"}]`, buf.String()); diff != "" {
+			t.Errorf("Encoder output mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Unbuffered", func(t *testing.T) {
+		var buf bytes.Buffer
+		// Hide the buffer's type so that the encoder buffers output itself
+		if err := encode(t, struct{ io.Writer }{&buf}); err != nil {
+			t.Fatalf("EndArray failed: %v", err)
+		}
+		if diff := cmp.Diff(`[{"stringField":"a"},{"stringField":"c"}]`, buf.String()); diff != "" {
+			t.Errorf("Encoder output mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("StringsBuilder", func(t *testing.T) {
+		var sb strings.Builder
+		if err := encode(t, &sb); err == nil {
+			t.Errorf("EndArray succeeded after the array was abandoned: %s", sb.String())
+		}
+	})
+}
+
+// TestEncoderListFailedElement tests that a failed EncodeList call
+// abandons its array, so that later messages are written outside of it
+func TestEncoderListFailedElement(t *testing.T) {
+	msgs := []proto.Message{&pb_basic.BasicTypes{StringField: "a"}, &pb_basic.BasicTypes{StringField: "\xff"}}
+	tests := []struct {
+		name string
+		w    func(buf *bytes.Buffer) io.Writer
+		want string
+	}{
+		{
+			name: "Buffer",
+			w:    func(buf *bytes.Buffer) io.Writer { return buf },
+			want: `{"stringField":"c"}`,
+		},
+		{
+			name: "Unbuffered",
+			w:    func(buf *bytes.Buffer) io.Writer { return struct{ io.Writer }{buf} },
+			want: `[{"stringField":"a"}{"stringField":"c"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := protojson.NewEncoder(tt.w(&buf))
+			if err := encoder.EncodeList(msgs); err == nil {
+				t.Fatal("EncodeList succeeded, want error")
+			}
+			if err := encoder.Encode(&pb_basic.BasicTypes{StringField: "c"}); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if err := encoder.EndArray(); err == nil {
+				t.Error("EndArray succeeded after the array was abandoned")
+			}
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Errorf("Encoder output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMarshalList tests encoding a slice of messages as a JSON array
+func TestMarshalList(t *testing.T) {
+	messages := []proto.Message{
+		&pb_basic.BasicTypes{StringField: "basic", Int32Field: 1},
+		&pb_basic.EnumFields{Status: pb_basic.Status_STATUS_ACTIVE},
+		&pb_basic.RepeatedFields{Strings: []string{"a", "b", "c"}},
+	}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+	}{
+		{name: "DefaultOptions"},
+		{name: "WithIndent", opts: protojson.MarshalOptions{Indent: "\t"}},
+		{name: "WithUseProtoNames", opts: protojson.MarshalOptions{UseProtoNames: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdOpts := stdprotojson.MarshalOptions{
+				Indent:        tt.opts.Indent,
+				UseProtoNames: tt.opts.UseProtoNames,
+			}
+			var elems []json.RawMessage
+			for _, msg := range messages {
+				data, err := stdOpts.Marshal(msg)
+				if err != nil {
+					t.Fatalf("standard protojson.Marshal failed: %v", err)
+				}
+				elems = append(elems, data)
+			}
+			var expected []byte
+			var err error
+			if tt.opts.Indent != "" {
+				expected, err = json.MarshalIndent(elems, "", tt.opts.Indent)
+			} else {
+				expected, err = json.Marshal(elems)
+			}
+			if err != nil {
+				t.Fatalf("json.Marshal failed: %v", err)
+			}
+
+			got, err := tt.opts.MarshalList(messages)
+			if err != nil {
+				t.Fatalf("MarshalList failed: %v", err)
+			}
+			if diff := cmp.Diff(string(expected), string(got)); diff != "" {
+				t.Errorf("MarshalList output mismatch (-want +got):\n%s", diff)
+			}
+
+			var buf bytes.Buffer
+			if err := protojson.NewEncoderWithOptions(&buf, tt.opts).EncodeList(messages); err != nil {
+				t.Fatalf("Encoder.EncodeList failed: %v", err)
+			}
+			if diff := cmp.Diff(string(expected), buf.String()); diff != "" {
+				t.Errorf("EncodeList output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestEncoderEncodeSeq tests encoding messages yielded by an iterator
+func TestEncoderEncodeSeq(t *testing.T) {
+	messages := []proto.Message{
+		&pb_basic.BasicTypes{StringField: "first", Int32Field: 1},
+		&pb_basic.BasicTypes{StringField: "second", Int32Field: 2},
+	}
+	seq := func(yield func(proto.Message) bool) {
+		for _, msg := range messages {
+			if !yield(msg) {
+				return
+			}
+		}
+	}
+
+	t.Run("NDJSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		encoder := protojson.NewEncoder(&buf)
+		encoder.SetWriteNewline(true)
+		if err := encoder.EncodeSeq(seq); err != nil {
+			t.Fatalf("Encoder.EncodeSeq failed: %v", err)
+		}
+		want := "{\"stringField\":\"first\",\"int32Field\":1}\n{\"stringField\":\"second\",\"int32Field\":2}\n"
+		if diff := cmp.Diff(want, buf.String()); diff != "" {
+			t.Errorf("Encoder output mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Array", func(t *testing.T) {
+		var buf bytes.Buffer
+		encoder := protojson.NewEncoder(&buf)
+		if err := encoder.BeginArray(); err != nil {
+			t.Fatalf("BeginArray failed: %v", err)
+		}
+		if err := encoder.EncodeSeq(seq); err != nil {
+			t.Fatalf("Encoder.EncodeSeq failed: %v", err)
+		}
+		if err := encoder.EndArray(); err != nil {
+			t.Fatalf("EndArray failed: %v", err)
+		}
+		want := `[{"stringField":"first","int32Field":1},{"stringField":"second","int32Field":2}]`
+		if diff := cmp.Diff(want, buf.String()); diff != "" {
+			t.Errorf("Encoder output mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+// TestRequiredFields tests that missing proto2 required fields are reported
+// unless AllowPartial is set, matching the standard package
+func TestRequiredFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     proto.Message
+		opts    protojson.MarshalOptions
+		wantErr bool
+	}{
+		{
+			name: "AllSet",
+			msg: &pb_basic.RequiredFields{
+				Name:     proto.String("name"),
+				Id:       proto.Int32(1),
+				Child:    &pb_basic.RequiredChild{Value: proto.String("child")},
+				Children: []*pb_basic.RequiredChild{{Value: proto.String("a")}},
+			},
+		},
+		{
+			name:    "MissingField",
+			msg:     &pb_basic.RequiredFields{Name: proto.String("name")},
+			wantErr: true,
+		},
+		{
+			name: "MissingNestedField",
+			msg: &pb_basic.RequiredFields{
+				Name:  proto.String("name"),
+				Id:    proto.Int32(1),
+				Child: &pb_basic.RequiredChild{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "MissingRepeatedElementField",
+			msg: &pb_basic.RequiredFields{
+				Name:     proto.String("name"),
+				Id:       proto.Int32(1),
+				Children: []*pb_basic.RequiredChild{{}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "MissingFieldWithAllowPartial",
+			msg:  &pb_basic.RequiredFields{Name: proto.String("name")},
+			opts: protojson.MarshalOptions{AllowPartial: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdOpts := stdprotojson.MarshalOptions{AllowPartial: tt.opts.AllowPartial}
+			expected, stdErr := stdMarshal(stdOpts, tt.msg)
+
+			var gotBuf bytes.Buffer
+			err := protojson.NewEncoderWithOptions(&gotBuf, tt.opts).Encode(tt.msg)
+			if tt.wantErr {
+				if stdErr == nil {
+					t.Fatalf("standard protojson.Marshal unexpectedly succeeded")
+				}
+				if err == nil {
+					t.Fatalf("Encode() expected error, got %s", gotBuf.String())
+				}
+				if gotBuf.Len() != 0 {
+					t.Errorf("Encode() wrote output for an incomplete message: %s", gotBuf.String())
+				}
+				return
+			}
+			if stdErr != nil {
+				t.Fatalf("standard protojson.Marshal failed: %v", stdErr)
+			}
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if diff := cmp.Diff(string(expected), gotBuf.String()); diff != "" {
+				t.Errorf("Encode() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestExtensions tests that proto2 extension fields are written with
+// bracketed full names, matching the standard package
+func TestExtensions(t *testing.T) {
+	withExtensions := func(m *pb_basic.Extendable) *pb_basic.Extendable {
+		proto.SetExtension(m, pb_basic.E_ExtLabel, "label")
+		proto.SetExtension(m, pb_basic.E_ExtCount, int32(3))
+		proto.SetExtension(m, pb_basic.E_ExtTags, []string{"a", "b"})
+		proto.SetExtension(m, pb_basic.E_ExtensionScope_Child, &pb_basic.RequiredChild{Value: proto.String("child")})
+		return m
+	}
+
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+	}{
+		{
+			name: "NoExtensions",
+			msg:  &pb_basic.Extendable{Name: proto.String("name")},
+		},
+		{
+			name: "Extensions",
+			msg:  withExtensions(&pb_basic.Extendable{Name: proto.String("name")}),
+		},
+		{
+			name: "ExtensionsOnly",
+			msg:  withExtensions(&pb_basic.Extendable{}),
+		},
+		{
+			name: "Extensions_ProtoNames",
+			msg:  withExtensions(&pb_basic.Extendable{Name: proto.String("name")}),
+			opts: protojson.MarshalOptions{UseProtoNames: true},
+		},
+		{
+			name: "Extensions_Multiline",
+			msg:  withExtensions(&pb_basic.Extendable{Name: proto.String("name")}),
+			opts: protojson.MarshalOptions{Multiline: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdOpts := stdprotojson.MarshalOptions{
+				Multiline:     tt.opts.Multiline,
+				UseProtoNames: tt.opts.UseProtoNames,
+			}
+			expected, err := stdMarshal(stdOpts, tt.msg)
+			if err != nil {
+				t.Fatalf("standard protojson.Marshal failed: %v", err)
+			}
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(string(expected), string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// messageResolver looks up messages only, like resolvers written for the
+// two-method MarshalOptions.Resolver
+type messageResolver struct {
+	types *protoregistry.Types
+}
+
+func (r messageResolver) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	return r.types.FindMessageByName(name)
+}
+
+func (r messageResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	return r.types.FindMessageByURL(url)
+}
+
+// TestMessageResolver tests that extensions inside google.protobuf.Any are
+// resolved with the global registry when MarshalOptions.Resolver does not
+// look up extensions
+func TestMessageResolver(t *testing.T) {
+	inner := &pb_basic.Extendable{Name: proto.String("name")}
+	proto.SetExtension(inner, pb_basic.E_ExtLabel, "label")
+	msg := &pb_basic.WellKnownTypes{Any: mustAny(t, inner)}
+	types := new(protoregistry.Types)
+	if err := types.RegisterMessage((&pb_basic.Extendable{}).ProtoReflect().Type()); err != nil {
+		t.Fatalf("RegisterMessage failed: %v", err)
+	}
+
+	expected, err := stdMarshal(stdprotojson.MarshalOptions{}, msg)
+	if err != nil {
+		t.Fatalf("standard protojson.Marshal failed: %v", err)
+	}
+	got, err := protojson.MarshalOptions{Resolver: messageResolver{types}}.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if diff := cmp.Diff(string(expected), string(got)); diff != "" {
+		t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestExtensionResolver tests that extensions inside google.protobuf.Any are
+// resolved with MarshalOptions.Resolver, matching the standard package
+func TestExtensionResolver(t *testing.T) {
+	inner := &pb_basic.Extendable{Name: proto.String("name")}
+	proto.SetExtension(inner, pb_basic.E_ExtLabel, "label")
+	proto.SetExtension(inner, pb_basic.E_ExtCount, int32(3))
+	msg := &pb_basic.WellKnownTypes{Any: mustAny(t, inner)}
+
+	newTypes := func(exts ...protoreflect.ExtensionType) *protoregistry.Types {
+		types := new(protoregistry.Types)
+		if err := types.RegisterMessage((&pb_basic.Extendable{}).ProtoReflect().Type()); err != nil {
+			t.Fatalf("RegisterMessage failed: %v", err)
+		}
+		for _, xt := range exts {
+			if err := types.RegisterExtension(xt); err != nil {
+				t.Fatalf("RegisterExtension failed: %v", err)
+			}
+		}
+		return types
+	}
+
+	tests := []struct {
+		name  string
+		types *protoregistry.Types
+	}{
+		{name: "NoExtensions", types: newTypes()},
+		{name: "SomeExtensions", types: newTypes(pb_basic.E_ExtLabel)},
+		{name: "AllExtensions", types: newTypes(pb_basic.E_ExtLabel, pb_basic.E_ExtCount)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, err := stdMarshal(stdprotojson.MarshalOptions{Resolver: tt.types}, msg)
+			if err != nil {
+				t.Fatalf("standard protojson.Marshal failed: %v", err)
+			}
+			got, err := protojson.MarshalOptions{Resolver: tt.types}.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(string(expected), string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package protojson
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// CSVEncoder writes messages as rows of CSV, one column per field path,
+// for data exports. Each message is encoded as JSON with the encoder's
+// options, and each column holds the value at its path: strings unquoted,
+// other scalars as their JSON encoding and objects and arrays as compact
+// JSON. Values that are not written, such as unpopulated fields without
+// EmitUnpopulated, and null values are empty cells. Output is buffered;
+// call Flush when done.
+type CSVEncoder struct {
+	w   *csv.Writer
+	rec bytes.Buffer // Output of the message being encoded
+	enc *Encoder
+
+	protoNames bool
+	columns    []string
+	paths      [][]string
+	header     bool
+	started    bool // Whether the header, if any, has been written
+	row        []string
+	cell       bytes.Buffer
+}
+
+// NewCSVEncoder returns a new CSV encoder that writes to w using the
+// provided MarshalOptions. Indent, Multiline, Flatten, TruncateOutput,
+// UseFieldNumbers and WrapOneofs are ignored.
+func NewCSVEncoder(w io.Writer, opts MarshalOptions) *CSVEncoder {
+	opts.Indent = ""
+	opts.Multiline = false
+	opts.Flatten = false
+	opts.TruncateOutput = false
+	opts.Comments = nil
+	opts.UseFieldNumbers = false
+	opts.WrapOneofs = false
+	e := &CSVEncoder{w: csv.NewWriter(w), protoNames: opts.UseProtoNames, header: true}
+	e.enc = NewEncoderWithOptions(&e.rec, opts)
+	return e
+}
+
+// SetColumns selects the columns written, as paths of keys separated by
+// dots, e.g. "profile.address.city". Path segments are keys as written in
+// the JSON output, so they are proto field names if UseProtoNames is set,
+// JSON names otherwise, and map keys; list elements are selected by index,
+// e.g. "permissions.0". By default, the columns are all fields of the first
+// message encoded, with singular message fields expanded into the fields
+// they contain, except for well-known types and recursive messages. It
+// must be called before the first row is written.
+func (e *CSVEncoder) SetColumns(paths ...string) {
+	e.setColumns(paths)
+}
+
+// SetHeader sets whether a header row with the column paths is written
+// before the first row. It is written by default.
+func (e *CSVEncoder) SetHeader(header bool) {
+	e.header = header
+}
+
+// SetComma sets the field delimiter, which is ',' by default.
+func (e *CSVEncoder) SetComma(r rune) {
+	e.w.Comma = r
+}
+
+// setColumns sets the columns and their split paths
+func (e *CSVEncoder) setColumns(columns []string) {
+	e.columns = columns
+	e.paths = make([][]string, len(columns))
+	for i, c := range columns {
+		e.paths[i] = strings.Split(c, ".")
+	}
+	e.row = make([]string, len(columns))
+}
+
+// Encode writes m as the next row.
+func (e *CSVEncoder) Encode(m proto.Message) error {
+	return e.encode(m.ProtoReflect())
+}
+
+// EncodeList writes a row for each element of the repeated message field
+// of m with the given name, either its proto name or its JSON name, e.g.
+// the results of a list response.
+func (e *CSVEncoder) EncodeList(m proto.Message, field string) error {
+	rm := m.ProtoReflect()
+	fields := rm.Descriptor().Fields()
+	fd := fields.ByTextName(field)
+	if fd == nil {
+		fd = fields.ByJSONName(field)
+	}
+	if fd == nil || !fd.IsList() || fd.Message() == nil {
+		return fmt.Errorf("%s has no repeated message field %q", rm.Descriptor().FullName(), field)
+	}
+
+	if e.columns == nil {
+		e.setColumns(csvColumns(fd.Message(), e.protoNames))
+	}
+	list := rm.Get(fd).List()
+	for i := 0; i < list.Len(); i++ {
+		if err := e.encode(list.Get(i).Message()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode writes m as the next row
+func (e *CSVEncoder) encode(m protoreflect.Message) error {
+	if e.columns == nil {
+		e.setColumns(csvColumns(m.Descriptor(), e.protoNames))
+	}
+
+	e.rec.Reset()
+	if err := e.enc.EncodeReflect(m); err != nil {
+		return err
+	}
+	root, err := parseJSON(e.rec.Bytes())
+	if err != nil {
+		return err
+	}
+	for i, path := range e.paths {
+		e.row[i] = e.cellOf(root.lookup(path))
+	}
+
+	if !e.started {
+		e.started = true
+		if e.header {
+			if err := e.w.Write(e.columns); err != nil {
+				return err
+			}
+		}
+	}
+	return e.w.Write(e.row)
+}
+
+// cellOf returns the cell for value n, or an empty cell if n is nil
+func (e *CSVEncoder) cellOf(n *jsonNode) string {
+	switch {
+	case n == nil || string(n.raw) == "null":
+		return ""
+	case n.kind != 0:
+		e.cell.Reset()
+		n.write(&e.cell, false)
+		return e.cell.String()
+	case n.raw[0] == '"':
+		var s string
+		if err := json.Unmarshal(n.raw, &s); err != nil {
+			return string(n.raw)
+		}
+		return s
+	}
+	return string(n.raw)
+}
+
+// Flush writes any buffered rows to the underlying writer.
+func (e *CSVEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// lookup returns the value at path below n, or nil if there is none
+func (n *jsonNode) lookup(path []string) *jsonNode {
+	for _, seg := range path {
+		switch n.kind {
+		case '{':
+			var next *jsonNode
+			for i := range n.entries {
+				if keyEquals(n.entries[i].key, seg) {
+					next = n.entries[i].val
+					break
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			n = next
+		case '[':
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(n.entries) {
+				return nil
+			}
+			n = n.entries[i].val
+		default:
+			return nil
+		}
+	}
+	return n
+}
+
+// keyEquals reports whether the quoted JSON key is s
+func keyEquals(key []byte, s string) bool {
+	if bytes.IndexByte(key, '\\') < 0 {
+		return string(key[1:len(key)-1]) == s
+	}
+	var k string
+	return json.Unmarshal(key, &k) == nil && k == s
+}
+
+// csvColumns returns the default columns for messages of type md
+func csvColumns(md protoreflect.MessageDescriptor, protoNames bool) []string {
+	return appendCSVColumns(nil, md, protoNames, "", nil)
+}
+
+// appendCSVColumns appends the columns for the fields of md, whose path is
+// prefix, to columns. stack holds the enclosing message types, so that
+// recursive messages are not expanded.
+func appendCSVColumns(columns []string, md protoreflect.MessageDescriptor, protoNames bool, prefix string, stack []protoreflect.FullName) []string {
+	stack = append(stack, md.FullName())
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		name := fd.JSONName()
+		if protoNames {
+			name = fd.TextName()
+		}
+		path := prefix + name
+
+		sub := fd.Message()
+		if sub == nil || fd.IsList() || fd.IsMap() ||
+			strings.HasPrefix(string(sub.FullName()), "google.protobuf.") ||
+			slices.Contains(stack, sub.FullName()) {
+			columns = append(columns, path)
+			continue
+		}
+		columns = appendCSVColumns(columns, sub, protoNames, path+".", stack)
+	}
+	return columns
+}
@@ -0,0 +1,123 @@
+package protojson_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestCSVEncoder tests writing messages as CSV rows
+func TestCSVEncoder(t *testing.T) {
+	alice := &pb_basic.User{
+		Id:          "1",
+		Name:        "Alice, \"Al\"",
+		Role:        pb_basic.Role_ROLE_ADMIN,
+		Permissions: []string{"read", "write"},
+		Profile:     &pb_basic.Profile{Address: &pb_basic.Address{City: "Tokyo"}},
+		Metadata:    map[string]string{"team": "a.b"},
+	}
+	bob := &pb_basic.User{Id: "2", Name: "Bob\nSmith"}
+
+	tests := []struct {
+		name    string
+		msgs    []proto.Message
+		opts    protojson.MarshalOptions
+		columns []string
+		noHead  bool
+		want    string
+	}{
+		{
+			name:    "Columns",
+			msgs:    []proto.Message{alice, bob},
+			columns: []string{"id", "name", "profile.address.city", "permissions.1", "metadata.team"},
+			want: "id,name,profile.address.city,permissions.1,metadata.team\n" +
+				"1,\"Alice, \"\"Al\"\"\",Tokyo,write,a.b\n" +
+				"2,\"Bob\nSmith\",,,\n",
+		},
+		{
+			name:    "Containers",
+			msgs:    []proto.Message{alice},
+			columns: []string{"permissions", "profile.address"},
+			want:    "permissions,profile.address\n\"[\"\"read\"\",\"\"write\"\"]\",\"{\"\"city\"\":\"\"Tokyo\"\"}\"\n",
+		},
+		{
+			name:    "ProtoNames",
+			msgs:    []proto.Message{&pb_basic.Profile{AvatarUrl: "a.png"}},
+			opts:    protojson.MarshalOptions{UseProtoNames: true},
+			columns: []string{"avatar_url", "avatarUrl"},
+			want:    "avatar_url,avatarUrl\na.png,\n",
+		},
+		{
+			name:    "NoHeader",
+			msgs:    []proto.Message{bob},
+			columns: []string{"id"},
+			noHead:  true,
+			want:    "2\n",
+		},
+		{
+			name: "DefaultColumns",
+			msgs: []proto.Message{&pb_basic.Address{City: "Tokyo", Location: &pb_basic.Location{Latitude: 1.5}}},
+			want: "street,city,state,country,postalCode,location.latitude,location.longitude\n" +
+				",Tokyo,,,,1.5,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := protojson.NewCSVEncoder(&buf, tt.opts)
+			if tt.columns != nil {
+				enc.SetColumns(tt.columns...)
+			}
+			enc.SetHeader(!tt.noHead)
+			for _, m := range tt.msgs {
+				if err := enc.Encode(m); err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+			}
+			if err := enc.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Errorf("Encode() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestCSVEncoderList tests writing the elements of a repeated field as
+// rows, with default columns
+func TestCSVEncoderList(t *testing.T) {
+	msg := &pb_basic.ComplexMessage{
+		Users: []*pb_basic.User{
+			{Id: "1", Profile: &pb_basic.Profile{Bio: "hi"}},
+			{Id: "2", Role: pb_basic.Role_ROLE_GUEST},
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := protojson.NewCSVEncoder(&buf, protojson.MarshalOptions{})
+	if err := enc.EncodeList(msg, "users"); err != nil {
+		t.Fatalf("EncodeList() error = %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	want := "id,name,email,role,permissions,profile.avatarUrl,profile.bio,profile.address.street," +
+		"profile.address.city,profile.address.state,profile.address.country,profile.address.postalCode," +
+		"profile.address.location.latitude,profile.address.location.longitude,profile.socialLinks,metadata\n" +
+		"1,,,,,,hi,,,,,,,,,\n" +
+		"2,,,ROLE_GUEST,,,,,,,,,,,,\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("EncodeList() mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := enc.EncodeList(msg, "id"); err == nil {
+		t.Error("EncodeList() of a string field succeeded, want error")
+	}
+}
@@ -0,0 +1,111 @@
+package protojson
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// MarshalDiff writes the fields of current whose values differ from base,
+// using default options.
+func MarshalDiff(base, current proto.Message) ([]byte, error) {
+	return MarshalOptions{}.MarshalDiff(base, current)
+}
+
+// MarshalDiff writes the fields of current whose values differ from base as
+// a JSON object, using options in o. Singular message fields set in both
+// are compared field by field, so only the changed nested fields are
+// written; well-known types, lists and maps are written whole when they
+// differ. Message fields cleared in current are written as null, other
+// cleared fields as their default value. base and current must be of the
+// same message type.
+func (o MarshalOptions) MarshalDiff(base, current proto.Message) ([]byte, error) {
+	o.Comments = nil
+	b, c := base.ProtoReflect(), current.ProtoReflect()
+	if b.Descriptor().FullName() != c.Descriptor().FullName() {
+		return nil, fmt.Errorf("mismatched message types %s and %s", b.Descriptor().FullName(), c.Descriptor().FullName())
+	}
+	if !o.AllowPartial {
+		if err := proto.CheckInitialized(current); err != nil {
+			return nil, err
+		}
+	}
+
+	s := marshalPool.Get().(*marshalState)
+	defer s.release()
+
+	s.enc.SetOptions(o)
+	s.enc.prepare()
+	write := func() error { return s.enc.enc.marshalDiff(b, c) }
+	if err := s.enc.enc.writeRoot(write); err != nil {
+		return nil, err
+	}
+	if err := s.enc.enc.checkLimit(); err != nil {
+		return nil, err
+	}
+	s.enc.reportMasks(current)
+	if err := s.enc.flush(); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), s.buf.Bytes()...), nil
+}
+
+// marshalDiff writes the fields of cur that differ from base as an object
+func (e *encoder) marshalDiff(base, cur protoreflect.Message) error {
+	name := cur.Descriptor().FullName()
+	if e.formatted(name) {
+		return e.marshalMessage(cur)
+	}
+
+	e.w.WriteByte('{')
+	e.depth++
+
+	first := true
+	plan := e.planOf(cur.Descriptor())
+	fields := e.fields(plan)
+	for i := range fields {
+		fd := fields[i].fd
+		inBase, inCur := base.Has(fd), cur.Has(fd)
+		if !inBase && !inCur {
+			continue
+		}
+		bv, cv := base.Get(fd), cur.Get(fd)
+		if inBase && inCur && bv.Equal(cv) {
+			continue
+		}
+
+		if !first {
+			e.writeComma()
+		}
+		first = false
+
+		e.writeIndent()
+		e.writeKey(&fields[i])
+
+		var err error
+		switch {
+		case fd.IsList() || fd.IsMap():
+			err = e.marshalField(fd, cv)
+		case fd.Message() != nil && !inCur:
+			e.w.WriteString("null")
+		case fd.Message() != nil && inBase:
+			err = e.marshalDiff(bv.Message(), cv.Message())
+		default:
+			err = e.marshalField(fd, cv)
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.checkLimit(); err != nil {
+			return err
+		}
+	}
+
+	e.depth--
+	if !first {
+		e.writeIndent()
+	}
+	e.w.WriteByte('}')
+	return nil
+}
@@ -0,0 +1,98 @@
+package protojson_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TestMarshalDiff tests writing only the fields that differ from a baseline
+func TestMarshalDiff(t *testing.T) {
+	base := &pb_basic.ComplexMessage{
+		Id:        "c1",
+		Users:     []*pb_basic.User{{Id: "u1", Name: "alice"}},
+		Settings:  &pb_basic.Settings{Theme: "dark", Language: "en"},
+		CreatedAt: &timestamppb.Timestamp{Seconds: 1},
+	}
+
+	tests := []struct {
+		name    string
+		current proto.Message
+		opts    protojson.MarshalOptions
+		want    string
+	}{
+		{
+			name:    "Unchanged",
+			current: proto.Clone(base),
+			want:    `{}`,
+		},
+		{
+			name: "Scalar",
+			current: &pb_basic.ComplexMessage{
+				Id:        "c2",
+				Users:     []*pb_basic.User{{Id: "u1", Name: "alice"}},
+				Settings:  &pb_basic.Settings{Theme: "dark", Language: "en"},
+				CreatedAt: &timestamppb.Timestamp{Seconds: 1},
+			},
+			want: `{"id":"c2"}`,
+		},
+		{
+			name: "NestedMessage",
+			current: &pb_basic.ComplexMessage{
+				Id:        "c1",
+				Users:     []*pb_basic.User{{Id: "u1", Name: "alice"}},
+				Settings:  &pb_basic.Settings{Theme: "light", Language: "en"},
+				CreatedAt: &timestamppb.Timestamp{Seconds: 1},
+			},
+			want: `{"settings":{"theme":"light"}}`,
+		},
+		{
+			name: "ListAndWellKnownType",
+			current: &pb_basic.ComplexMessage{
+				Id:        "c1",
+				Users:     []*pb_basic.User{{Id: "u1", Name: "alice"}, {Id: "u2"}},
+				Settings:  &pb_basic.Settings{Theme: "dark", Language: "en"},
+				CreatedAt: &timestamppb.Timestamp{Seconds: 2},
+			},
+			want: `{"users":[{"id":"u1","name":"alice"},{"id":"u2"}],"createdAt":"1970-01-01T00:00:02Z"}`,
+		},
+		{
+			name:    "Cleared",
+			current: &pb_basic.ComplexMessage{Id: "c1"},
+			want:    `{"users":[],"settings":null,"createdAt":null}`,
+		},
+		{
+			name: "ClearedNestedScalar",
+			current: &pb_basic.ComplexMessage{
+				Id:        "c1",
+				Users:     []*pb_basic.User{{Id: "u1", Name: "alice"}},
+				Settings:  &pb_basic.Settings{Theme: "dark"},
+				CreatedAt: &timestamppb.Timestamp{Seconds: 1},
+			},
+			opts: protojson.MarshalOptions{Multiline: true},
+			want: "{\n  \"settings\": {\n    \"language\": \"\"\n  }\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.MarshalDiff(base, tt.current)
+			if err != nil {
+				t.Fatalf("MarshalDiff() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("MarshalDiff() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("MismatchedTypes", func(t *testing.T) {
+		if _, err := protojson.MarshalDiff(base, &pb_basic.User{}); err == nil {
+			t.Error("MarshalDiff() expected error for mismatched types")
+		}
+	})
+}
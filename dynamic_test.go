@@ -0,0 +1,493 @@
+package protojson_test
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// toDynamic copies m into a dynamicpb message of the same descriptor
+func toDynamic(t *testing.T, m proto.Message) *dynamicpb.Message {
+	t.Helper()
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatalf("proto.Marshal() error = %v", err)
+	}
+	dm := dynamicpb.NewMessage(m.ProtoReflect().Descriptor())
+	if err := proto.Unmarshal(b, dm); err != nil {
+		t.Fatalf("proto.Unmarshal() error = %v", err)
+	}
+	return dm
+}
+
+// TestEncodeReflectDynamic tests that dynamic messages encode like their
+// generated counterparts and round-trip through the standard package
+func TestEncodeReflectDynamic(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+	}{
+		{
+			name: "scalars",
+			msg: &pb_basic.BasicTypes{
+				StringField: "hello",
+				Int64Field:  -42,
+				Uint32Field: 7,
+				DoubleField: 1.5,
+				BytesField:  []byte("hi"),
+			},
+		},
+		{
+			name: "nested messages, enums, lists and maps",
+			msg: &pb_basic.User{
+				Id:          "1",
+				Name:        "alice",
+				Role:        pb_basic.Role_ROLE_ADMIN,
+				Permissions: []string{"read", "write"},
+				Profile:     &pb_basic.Profile{Bio: "hi", Address: &pb_basic.Address{City: "Tokyo"}},
+				Metadata:    map[string]string{"team": "core"},
+			},
+		},
+		{
+			name: "well-known types",
+			msg: &pb_basic.WellKnownTypes{
+				Timestamp: timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+				Any:       mustAny(t, &pb_basic.BasicTypes{StringField: "inner"}),
+				Value:     structpb.NewStringValue("v"),
+			},
+		},
+		{
+			name: "options",
+			msg:  &pb_basic.BasicTypes{StringField: "hello"},
+			opts: protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dm := toDynamic(t, tt.msg)
+
+			var buf bytes.Buffer
+			if err := protojson.NewEncoderWithOptions(&buf, tt.opts).EncodeReflect(dm); err != nil {
+				t.Fatalf("EncodeReflect() error = %v", err)
+			}
+
+			want, err := stdMarshal(stdprotojson.MarshalOptions{UseProtoNames: tt.opts.UseProtoNames, EmitUnpopulated: tt.opts.EmitUnpopulated}, tt.msg)
+			if err != nil {
+				t.Fatalf("stdMarshal() error = %v", err)
+			}
+			if diff := cmp.Diff(string(want), buf.String()); diff != "" {
+				t.Errorf("EncodeReflect() mismatch (-want +got):\n%s", diff)
+			}
+
+			got := dynamicpb.NewMessage(dm.Descriptor())
+			if err := stdprotojson.Unmarshal(buf.Bytes(), got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !proto.Equal(dm, got) {
+				t.Errorf("round trip = %v, want %v", got, dm)
+			}
+		})
+	}
+}
+
+// TestEncodeReflectRuntimeDescriptor tests encoding messages of a type
+// built from a descriptor at run time
+func TestEncodeReflectRuntimeDescriptor(t *testing.T) {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("runtime/order.proto"),
+		Package: proto.String("test.runtime"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("order_id"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Label: optional, JsonName: proto.String("orderId")},
+				{Name: proto.String("items"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(), JsonName: proto.String("items")},
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile() error = %v", err)
+	}
+	md := fd.Messages().ByName("Order")
+	order := dynamicpb.NewMessage(md)
+	order.Set(md.Fields().ByName("order_id"), protoreflect.ValueOfInt64(99))
+	items := order.Mutable(md.Fields().ByName("items")).List()
+	items.Append(protoreflect.ValueOfString("apple"))
+	items.Append(protoreflect.ValueOfString("pear"))
+
+	var buf bytes.Buffer
+	if err := protojson.NewEncoder(&buf).EncodeReflect(order); err != nil {
+		t.Fatalf("EncodeReflect() error = %v", err)
+	}
+	want := `{"orderId":"99","items":["apple","pear"]}`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("EncodeReflect() mismatch (-want +got):\n%s", diff)
+	}
+
+	got := dynamicpb.NewMessage(md)
+	if err := stdprotojson.Unmarshal(buf.Bytes(), got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !proto.Equal(order, got) {
+		t.Errorf("round trip = %v, want %v", got, order)
+	}
+}
+
+// TestEnumNamesRuntimeDescriptor tests enum names of types with negative,
+// sparse and aliased numbers, and that two enum types in one message are
+// not mixed up
+func TestEnumNamesRuntimeDescriptor(t *testing.T) {
+	value := func(name string, n int32) *descriptorpb.EnumValueDescriptorProto {
+		return &descriptorpb.EnumValueDescriptorProto{Name: proto.String(name), Number: proto.Int32(n)}
+	}
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	enumType := descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum()
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("runtime/enums.proto"),
+		Package: proto.String("test.runtime"),
+		Syntax:  proto.String("proto3"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{
+			{
+				Name:    proto.String("Dense"),
+				Value:   []*descriptorpb.EnumValueDescriptorProto{value("DENSE_ZERO", 0), value("DENSE_NEG", -1), value("DENSE_TWO", 2), value("DENSE_ALIAS", 2)},
+				Options: &descriptorpb.EnumOptions{AllowAlias: proto.Bool(true)},
+			},
+			{
+				Name:  proto.String("Sparse"),
+				Value: []*descriptorpb.EnumValueDescriptorProto{value("SPARSE_ZERO", 0), value("SPARSE_BIG", 1000000), value("SPARSE_MIN", -2147483648)},
+			},
+		},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Enums"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("dense"), Number: proto.Int32(1), Type: enumType, TypeName: proto.String(".test.runtime.Dense"), Label: repeated, JsonName: proto.String("dense")},
+				{Name: proto.String("sparse"), Number: proto.Int32(2), Type: enumType, TypeName: proto.String(".test.runtime.Sparse"), Label: repeated, JsonName: proto.String("sparse")},
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile() error = %v", err)
+	}
+	md := fd.Messages().ByName("Enums")
+	msg := dynamicpb.NewMessage(md)
+	dense := msg.Mutable(md.Fields().ByName("dense")).List()
+	for _, n := range []protoreflect.EnumNumber{0, -1, 2, 1, 3, -2} {
+		dense.Append(protoreflect.ValueOfEnum(n))
+	}
+	sparse := msg.Mutable(md.Fields().ByName("sparse")).List()
+	for _, n := range []protoreflect.EnumNumber{1000000, -2147483648, 0, 1, 999999} {
+		sparse.Append(protoreflect.ValueOfEnum(n))
+	}
+
+	want, err := stdMarshal(stdprotojson.MarshalOptions{}, msg)
+	if err != nil {
+		t.Fatalf("standard protojson.Marshal failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := protojson.NewEncoder(&buf).EncodeReflect(msg); err != nil {
+		t.Fatalf("EncodeReflect() error = %v", err)
+	}
+	if diff := cmp.Diff(string(want), buf.String()); diff != "" {
+		t.Errorf("EncodeReflect() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestNullValueRuntimeDescriptor tests that fields of the
+// google.protobuf.NullValue enum are written as null like the standard
+// package, and decoded back
+func TestNullValueRuntimeDescriptor(t *testing.T) {
+	enumType := descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum()
+	nullType := proto.String(".google.protobuf.NullValue")
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("runtime/nulls.proto"),
+		Package:    proto.String("test.runtime"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/struct.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Nulls"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("null"), Number: proto.Int32(1), Type: enumType, TypeName: nullType, Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), JsonName: proto.String("null")},
+				{Name: proto.String("nulls"), Number: proto.Int32(2), Type: enumType, TypeName: nullType, Label: descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(), JsonName: proto.String("nulls")},
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile() error = %v", err)
+	}
+	md := fd.Messages().ByName("Nulls")
+	msg := dynamicpb.NewMessage(md)
+	nulls := msg.Mutable(md.Fields().ByName("nulls")).List()
+	nulls.Append(protoreflect.ValueOfEnum(0))
+	nulls.Append(protoreflect.ValueOfEnum(0))
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{name: "Default", want: `{"nulls":[null,null]}`},
+		{name: "EmitUnpopulated", opts: protojson.MarshalOptions{EmitUnpopulated: true}, want: `{"null":null,"nulls":[null,null]}`},
+		{name: "UseEnumNumbers", opts: protojson.MarshalOptions{EmitUnpopulated: true, UseEnumNumbers: true}, want: `{"null":null,"nulls":[null,null]}`},
+		{name: "EnumAsObject", opts: protojson.MarshalOptions{EmitUnpopulated: true, EnumAsObject: true}, want: `{"null":null,"nulls":[null,null]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := protojson.NewEncoderWithOptions(&buf, tt.opts).EncodeReflect(msg); err != nil {
+				t.Fatalf("EncodeReflect() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Errorf("EncodeReflect() mismatch (-want +got):\n%s", diff)
+			}
+
+			stdOpts := stdprotojson.MarshalOptions{EmitUnpopulated: tt.opts.EmitUnpopulated, UseEnumNumbers: tt.opts.UseEnumNumbers}
+			want, err := stdMarshal(stdOpts, msg)
+			if err != nil {
+				t.Fatalf("standard protojson.Marshal failed: %v", err)
+			}
+			if diff := cmp.Diff(string(want), buf.String()); diff != "" {
+				t.Errorf("EncodeReflect() differs from the standard package (-want +got):\n%s", diff)
+			}
+
+			got := dynamicpb.NewMessage(md)
+			if err := stdprotojson.Unmarshal(buf.Bytes(), got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !proto.Equal(msg, got) {
+				t.Errorf("round trip = %v, want %v", got, msg)
+			}
+
+			m, err := protojson.UnmarshalToMap(buf.Bytes(), md)
+			if err != nil {
+				t.Fatalf("UnmarshalToMap() error = %v", err)
+			}
+			if diff := cmp.Diff([]any{nil, nil}, m["nulls"]); diff != "" {
+				t.Errorf("UnmarshalToMap() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestEmitDeclaredDefaultsRuntimeDescriptor tests writing the declared
+// defaults of unset proto2 fields
+func TestEmitDeclaredDefaultsRuntimeDescriptor(t *testing.T) {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("runtime/defaults.proto"),
+		Package: proto.String("test.runtime"),
+		Syntax:  proto.String("proto2"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Level"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("LOW"), Number: proto.Int32(0)},
+				{Name: proto.String("HIGH"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Defaults"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("retries"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(), Label: optional, DefaultValue: proto.String("3"), JsonName: proto.String("retries")},
+				{Name: proto.String("host_name"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: optional, DefaultValue: proto.String("localhost"), JsonName: proto.String("hostName")},
+				{Name: proto.String("level"), Number: proto.Int32(3), Type: descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum(), TypeName: proto.String(".test.runtime.Level"), Label: optional, DefaultValue: proto.String("HIGH"), JsonName: proto.String("level")},
+				{Name: proto.String("ratio"), Number: proto.Int32(4), Type: descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum(), Label: optional, DefaultValue: proto.String("inf"), JsonName: proto.String("ratio")},
+				{Name: proto.String("note"), Number: proto.Int32(5), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: optional, JsonName: proto.String("note")},
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile() error = %v", err)
+	}
+	md := fd.Messages().ByName("Defaults")
+	msg := dynamicpb.NewMessage(md)
+	msg.Set(md.Fields().ByName("retries"), protoreflect.ValueOfInt32(5))
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{name: "Off", want: `{"retries":5}`},
+		{
+			name: "Declared",
+			opts: protojson.MarshalOptions{EmitDeclaredDefaults: true},
+			want: `{"retries":5,"hostName":"localhost","level":"HIGH","ratio":"Infinity"}`,
+		},
+		{
+			name: "WithEmitUnpopulated",
+			opts: protojson.MarshalOptions{EmitDeclaredDefaults: true, EmitUnpopulated: true, UseEnumNumbers: true},
+			want: `{"retries":5,"hostName":"localhost","level":1,"ratio":"Infinity","note":null}`,
+		},
+		{
+			name: "OverridesOmitPaths",
+			opts: protojson.MarshalOptions{EmitDeclaredDefaults: true, EmitUnpopulated: true, OmitUnpopulatedPaths: []string{"*"}},
+			want: `{"retries":5,"hostName":"localhost","level":"HIGH","ratio":"Infinity"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := protojson.NewEncoderWithOptions(&buf, tt.opts).EncodeReflect(msg); err != nil {
+				t.Fatalf("EncodeReflect() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Errorf("EncodeReflect() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestGroupNamesRuntimeDescriptor tests that proto2 group fields are named
+// like the standard package, using the group type name as the proto name,
+// and round-trip through the standard package and UnmarshalToMap
+func TestGroupNamesRuntimeDescriptor(t *testing.T) {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	groupType := descriptorpb.FieldDescriptorProto_TYPE_GROUP.Enum()
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("runtime/groups.proto"),
+		Package: proto.String("test.runtime"),
+		Syntax:  proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Search"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("resultset"), Number: proto.Int32(1), Type: groupType, TypeName: proto.String(".test.runtime.Search.ResultSet"), Label: optional, JsonName: proto.String("resultset")},
+				{Name: proto.String("extrainfo"), Number: proto.Int32(3), Type: groupType, TypeName: proto.String(".test.runtime.Search.ExtraInfo"), Label: descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(), JsonName: proto.String("extrainfo")},
+			},
+			NestedType: []*descriptorpb.DescriptorProto{
+				{
+					Name:  proto.String("ResultSet"),
+					Field: []*descriptorpb.FieldDescriptorProto{{Name: proto.String("page_token"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: optional, JsonName: proto.String("pageToken")}},
+				},
+				{
+					Name:  proto.String("ExtraInfo"),
+					Field: []*descriptorpb.FieldDescriptorProto{{Name: proto.String("note"), Number: proto.Int32(4), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: optional, JsonName: proto.String("note")}},
+				},
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile() error = %v", err)
+	}
+	md := fd.Messages().ByName("Search")
+	msg := dynamicpb.NewMessage(md)
+	rs := msg.Mutable(md.Fields().ByName("resultset")).Message()
+	rs.Set(rs.Descriptor().Fields().ByName("page_token"), protoreflect.ValueOfString("t"))
+	infos := msg.Mutable(md.Fields().ByName("extrainfo")).List()
+	info := infos.NewElement()
+	info.Message().Set(info.Message().Descriptor().Fields().ByName("note"), protoreflect.ValueOfString("n"))
+	infos.Append(info)
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{name: "JSONNames", want: `{"resultset":{"pageToken":"t"},"extrainfo":[{"note":"n"}]}`},
+		{name: "ProtoNames", opts: protojson.MarshalOptions{UseProtoNames: true}, want: `{"ResultSet":{"page_token":"t"},"ExtraInfo":[{"note":"n"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := protojson.NewEncoderWithOptions(&buf, tt.opts).EncodeReflect(msg); err != nil {
+				t.Fatalf("EncodeReflect() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Errorf("EncodeReflect() mismatch (-want +got):\n%s", diff)
+			}
+
+			want, err := stdMarshal(stdprotojson.MarshalOptions{UseProtoNames: tt.opts.UseProtoNames}, msg)
+			if err != nil {
+				t.Fatalf("standard protojson.Marshal failed: %v", err)
+			}
+			if diff := cmp.Diff(string(want), buf.String()); diff != "" {
+				t.Errorf("EncodeReflect() differs from the standard package (-want +got):\n%s", diff)
+			}
+
+			got := dynamicpb.NewMessage(md)
+			if err := stdprotojson.Unmarshal(buf.Bytes(), got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !proto.Equal(msg, got) {
+				t.Errorf("round trip = %v, want %v", got, msg)
+			}
+
+			if _, err := protojson.UnmarshalToMap(buf.Bytes(), md); err != nil {
+				t.Errorf("UnmarshalToMap() error = %v", err)
+			}
+		})
+	}
+}
+
+// TestRuntimeDescriptorsReleased tests that encoding messages of types
+// built at run time does not keep their descriptors alive
+func TestRuntimeDescriptorsReleased(t *testing.T) {
+	released := make(chan struct{})
+	func() {
+		fdp := &descriptorpb.FileDescriptorProto{
+			Name:    proto.String("runtime/released.proto"),
+			Package: proto.String("test.runtime"),
+			Syntax:  proto.String("proto3"),
+			Options: &descriptorpb.FileOptions{GoPackage: proto.String("runtime")},
+			EnumType: []*descriptorpb.EnumDescriptorProto{{
+				Name:  proto.String("State"),
+				Value: []*descriptorpb.EnumValueDescriptorProto{{Name: proto.String("STATE_UNKNOWN"), Number: proto.Int32(0)}},
+			}},
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Job"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("state"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum(), TypeName: proto.String(".test.runtime.State"), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), JsonName: proto.String("state")},
+				},
+			}},
+		}
+		fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+		if err != nil {
+			t.Fatalf("protodesc.NewFile() error = %v", err)
+		}
+		// Descriptors refer to each other, so watch their options instead,
+		// which are only reachable through the descriptor
+		runtime.AddCleanup(fd.Options().(*descriptorpb.FileOptions), func(ch chan struct{}) { close(ch) }, released)
+
+		job := dynamicpb.NewMessage(fd.Messages().ByName("Job"))
+		if _, err := (protojson.MarshalOptions{EmitUnpopulated: true}).Marshal(job); err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if err := protojson.NewEncoder(io.Discard).Encode(job); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}()
+
+	for range 10 {
+		runtime.GC()
+		select {
+		case <-released:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Error("descriptor of a type built at run time was not released")
+}
@@ -0,0 +1,77 @@
+package protojson
+
+import "strings"
+
+// pathFilter selects the fields written by IncludePaths, ExcludePaths and
+// FieldMask. Paths are kept split into segments.
+type pathFilter struct {
+	include [][]string
+	exclude [][]string
+	mask    [][]string
+}
+
+// newPathFilter splits the dotted include, exclude and mask paths into
+// segments
+func newPathFilter(include, exclude, mask []string) *pathFilter {
+	return &pathFilter{
+		include: splitPaths(include),
+		exclude: splitPaths(exclude),
+		mask:    splitPaths(mask),
+	}
+}
+
+func splitPaths(paths []string) [][]string {
+	if len(paths) == 0 {
+		return nil
+	}
+	split := make([][]string, len(paths))
+	for i, p := range paths {
+		split[i] = strings.Split(p, ".")
+	}
+	return split
+}
+
+// matchPath compares path with pattern, where a "*" pattern segment matches
+// any path segment. List index segments are passed over if skipElements is
+// set, as FieldMask paths do not address them. It reports whether path is
+// the pattern or lies below it, and whether path is an ancestor of the
+// pattern.
+func matchPath(pattern []string, path FieldPath, skipElements bool) (match, ancestor bool) {
+	i := 0
+	for _, seg := range path {
+		if skipElements && seg.Index >= 0 {
+			continue
+		}
+		if i == len(pattern) {
+			return true, false
+		}
+		if pattern[i] != "*" && pattern[i] != seg.name {
+			return false, false
+		}
+		i++
+	}
+	return i == len(pattern), i < len(pattern)
+}
+
+// selected reports whether path is selected by, or leads to, one of patterns
+func selected(patterns [][]string, path FieldPath, skipElements bool) bool {
+	for _, pattern := range patterns {
+		if match, ancestor := matchPath(pattern, path, skipElements); match || ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// skip reports whether the value at path is left out of the output
+func (f *pathFilter) skip(path FieldPath) bool {
+	for _, pattern := range f.exclude {
+		if match, _ := matchPath(pattern, path, false); match {
+			return true
+		}
+	}
+	if len(f.include) > 0 && !selected(f.include, path, false) {
+		return true
+	}
+	return len(f.mask) > 0 && !selected(f.mask, path, true)
+}
@@ -0,0 +1,163 @@
+package protojson_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// TestPathFilters tests IncludePaths and ExcludePaths
+func TestPathFilters(t *testing.T) {
+	msg := &pb_basic.ComplexMessage{
+		Id: "c1",
+		Users: []*pb_basic.User{
+			{Id: "u1", Name: "alice", Metadata: map[string]string{"k": "v"}},
+			{Id: "u2", Name: "bob", Email: "bob@example.com"},
+		},
+		Projects: map[string]*pb_basic.Project{
+			"p1": {Id: "p1", Name: "one", Tags: []string{"a"}},
+			"p2": {Id: "p2", Name: "two"},
+		},
+		Settings: &pb_basic.Settings{Theme: "dark", Language: "en"},
+	}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "ExcludeField",
+			opts: protojson.MarshalOptions{ExcludePaths: []string{"settings", "projects"}},
+			want: `{"id":"c1","users":[{"id":"u1","name":"alice","metadata":{"k":"v"}},{"id":"u2","name":"bob","email":"bob@example.com"}]}`,
+		},
+		{
+			name: "ExcludeWildcard",
+			opts: protojson.MarshalOptions{ExcludePaths: []string{"users.*.metadata", "users.*.email", "projects", "settings"}},
+			want: `{"id":"c1","users":[{"id":"u1","name":"alice"},{"id":"u2","name":"bob"}]}`,
+		},
+		{
+			name: "ExcludeListElement",
+			opts: protojson.MarshalOptions{ExcludePaths: []string{"users.0", "projects", "settings"}},
+			want: `{"id":"c1","users":[{"id":"u2","name":"bob","email":"bob@example.com"}]}`,
+		},
+		{
+			name: "ExcludeMapKey",
+			opts: protojson.MarshalOptions{ExcludePaths: []string{"projects.p1", "users", "settings"}},
+			want: `{"id":"c1","projects":{"p2":{"id":"p2","name":"two"}}}`,
+		},
+		{
+			name: "Include",
+			opts: protojson.MarshalOptions{IncludePaths: []string{"id", "settings.theme"}},
+			want: `{"id":"c1","settings":{"theme":"dark"}}`,
+		},
+		{
+			name: "IncludeWildcard",
+			opts: protojson.MarshalOptions{IncludePaths: []string{"users.*.name", "projects.*.tags"}},
+			want: `{"users":[{"name":"alice"},{"name":"bob"}],"projects":{"p1":{"tags":["a"]},"p2":{}}}`,
+		},
+		{
+			name: "IncludeSubtree",
+			opts: protojson.MarshalOptions{IncludePaths: []string{"users.1"}},
+			want: `{"users":[{"id":"u2","name":"bob","email":"bob@example.com"}]}`,
+		},
+		{
+			name: "IncludeAndExclude",
+			opts: protojson.MarshalOptions{IncludePaths: []string{"users"}, ExcludePaths: []string{"users.*.id"}},
+			want: `{"users":[{"name":"alice","metadata":{"k":"v"}},{"name":"bob","email":"bob@example.com"}]}`,
+		},
+		{
+			name: "Multiline",
+			opts: protojson.MarshalOptions{IncludePaths: []string{"users.0.name"}, Multiline: true},
+			want: "{\n  \"users\": [\n    {\n      \"name\": \"alice\"\n    }\n  ]\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestFieldMaskProjection tests projecting the output with a FieldMask
+func TestFieldMaskProjection(t *testing.T) {
+	msg := &pb_basic.ComplexMessage{
+		Id: "c1",
+		Users: []*pb_basic.User{
+			{Id: "u1", Name: "alice", Profile: &pb_basic.Profile{Bio: "hi", AvatarUrl: "a.png"}},
+			{Id: "u2", Name: "bob"},
+		},
+		Projects: map[string]*pb_basic.Project{
+			"p1": {Id: "p1", Name: "one"},
+			"p2": {Id: "p2", Name: "two"},
+		},
+		Settings: &pb_basic.Settings{Theme: "dark", Language: "en"},
+	}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "EmptyMask",
+			opts: protojson.MarshalOptions{FieldMask: &fieldmaskpb.FieldMask{}},
+			want: `{"id":"c1","users":[{"id":"u1","name":"alice","profile":{"avatarUrl":"a.png","bio":"hi"}},{"id":"u2","name":"bob"}],"projects":{"p1":{"id":"p1","name":"one"},"p2":{"id":"p2","name":"two"}},"settings":{"theme":"dark","language":"en"}}`,
+		},
+		{
+			name: "TopLevel",
+			opts: protojson.MarshalOptions{FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"id", "settings"}}},
+			want: `{"id":"c1","settings":{"theme":"dark","language":"en"}}`,
+		},
+		{
+			name: "Nested",
+			opts: protojson.MarshalOptions{FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"settings.language"}}},
+			want: `{"settings":{"language":"en"}}`,
+		},
+		{
+			name: "ThroughRepeated",
+			opts: protojson.MarshalOptions{FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"users.name", "users.profile.bio"}}},
+			want: `{"users":[{"name":"alice","profile":{"bio":"hi"}},{"name":"bob"}]}`,
+		},
+		{
+			name: "MapKey",
+			opts: protojson.MarshalOptions{FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"projects.p2.name"}}},
+			want: `{"projects":{"p2":{"name":"two"}}}`,
+		},
+		{
+			name: "MapWildcard",
+			opts: protojson.MarshalOptions{FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"projects.*.id"}}},
+			want: `{"projects":{"p1":{"id":"p1"},"p2":{"id":"p2"}}}`,
+		},
+		{
+			name: "WithExcludePaths",
+			opts: protojson.MarshalOptions{
+				FieldMask:    &fieldmaskpb.FieldMask{Paths: []string{"users"}},
+				ExcludePaths: []string{"users.*.profile"},
+			},
+			want: `{"users":[{"id":"u1","name":"alice"},{"id":"u2","name":"bob"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
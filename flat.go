@@ -0,0 +1,81 @@
+package protojson
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+// writeFlat runs write with its output captured, then writes the output as
+// a single object mapping the dotted path of each scalar value to the value
+func (e *encoder) writeFlat(write func() error) error {
+	var buf bytes.Buffer
+	opts := e.opts
+	w := e.redirect(&buf)
+	e.opts.Indent, e.opts.Multiline = "", false
+	err := write()
+	e.redirect(w)
+	e.opts = opts
+	if err != nil {
+		return err
+	}
+
+	root, err := parseJSON(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	if root.kind != '{' {
+		return errors.New("cannot flatten a top-level value that is not an object")
+	}
+
+	e.w.WriteByte('{')
+	e.depth++
+	first := true
+	if len(root.entries) > 0 {
+		e.writeFlatNode(root, nil, &first)
+	}
+	e.depth--
+	if !first {
+		e.writeIndent()
+	}
+	e.w.WriteByte('}')
+	return nil
+}
+
+// writeFlatNode writes the members for the scalar values below n, whose
+// path is the unquoted, escaped key path. Empty objects and arrays are
+// written as values so that they are not lost.
+func (e *encoder) writeFlatNode(n *jsonNode, path []byte, first *bool) {
+	if n.kind == 0 || len(n.entries) == 0 {
+		if !*first {
+			e.writeComma()
+		}
+		*first = false
+		e.writeIndent()
+		e.w.WriteByte('"')
+		e.w.Write(path)
+		e.w.WriteByte('"')
+		e.writeColon()
+		if n.kind == 0 {
+			e.w.Write(n.raw)
+		} else {
+			e.w.WriteByte(n.kind)
+			e.w.WriteByte(n.kind + 2) // '}' or ']'
+		}
+		return
+	}
+
+	if len(path) > 0 {
+		path = append(path, '.')
+	}
+	for i := range n.entries {
+		ent := &n.entries[i]
+		p := path
+		if n.kind == '{' {
+			p = append(p, ent.key[1:len(ent.key)-1]...)
+		} else {
+			p = strconv.AppendInt(p, int64(i), 10)
+		}
+		e.writeFlatNode(ent.val, p, first)
+	}
+}
@@ -0,0 +1,115 @@
+package protojson_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TestFlatten tests writing messages as flat objects of dotted paths
+func TestFlatten(t *testing.T) {
+	user := &pb_basic.User{
+		Id:          "u1",
+		Role:        pb_basic.Role_ROLE_ADMIN,
+		Permissions: []string{"read", "write"},
+		Profile: &pb_basic.Profile{
+			Address: &pb_basic.Address{
+				City:     "Tokyo",
+				Location: &pb_basic.Location{Latitude: 35.5},
+			},
+		},
+		Metadata: map[string]string{"a.b": "1"},
+	}
+
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Nested",
+			msg:  user,
+			want: `{"id":"u1","role":"ROLE_ADMIN","permissions.0":"read","permissions.1":"write",` +
+				`"profile.address.city":"Tokyo","profile.address.location.latitude":35.5,"metadata.a.b":"1"}`,
+		},
+		{
+			name: "ProtoNames",
+			msg:  &pb_basic.User{Profile: &pb_basic.Profile{AvatarUrl: "a.png"}},
+			opts: protojson.MarshalOptions{UseProtoNames: true},
+			want: `{"profile.avatar_url":"a.png"}`,
+		},
+		{
+			name: "Empty",
+			msg:  &pb_basic.User{},
+			want: `{}`,
+		},
+		{
+			name: "EmptyContainers",
+			msg:  &pb_basic.User{Profile: &pb_basic.Profile{}},
+			opts: protojson.MarshalOptions{EmitUnpopulated: true},
+			want: `{"id":"","name":"","email":"","role":"ROLE_UNSPECIFIED","permissions":[],` +
+				`"profile.avatarUrl":"","profile.bio":"","profile.address":null,"profile.socialLinks":[],"metadata":{}}`,
+		},
+		{
+			name: "Indent",
+			msg:  &pb_basic.User{Id: "u1", Profile: &pb_basic.Profile{Bio: "hi"}},
+			opts: protojson.MarshalOptions{Indent: "  "},
+			want: "{\n  \"id\": \"u1\",\n  \"profile.bio\": \"hi\"\n}",
+		},
+		{
+			name: "Canonical",
+			msg:  &pb_basic.User{Name: "n", Profile: &pb_basic.Profile{Bio: "hi"}},
+			opts: protojson.MarshalOptions{Canonical: true},
+			want: `{"name":"n","profile.bio":"hi"}`,
+		},
+		{
+			name: "Generated",
+			msg: &pb_basic.GeneratedContainer{
+				Name:  "c",
+				Inner: &pb_basic.GeneratedContainer_Inner{Note: "x"},
+			},
+			want: `{"name":"c","inner.note":"x"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Flatten = true
+			got, err := opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestFlattenEncoder tests flattening messages written by an Encoder
+func TestFlattenEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := protojson.NewEncoderWithOptions(&buf, protojson.MarshalOptions{Flatten: true})
+	enc.SetWriteNewline(true)
+	for _, city := range []string{"Tokyo", "Osaka"} {
+		msg := &pb_basic.Address{City: city}
+		if err := enc.Encode(msg); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+	if err := enc.Encode(timestamppb.Now()); err == nil {
+		t.Error("Encode() of a Timestamp succeeded, want error")
+	}
+
+	want := "{\"city\":\"Tokyo\"}\n{\"city\":\"Osaka\"}\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("Encode() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,65 @@
+package protojson
+
+// flushWriter passes writes through to the writer of an Encoder and flushes
+// it every threshold bytes. Like limitWriter it records the first flush
+// error in err, which is checked at field boundaries.
+type flushWriter struct {
+	e         *Encoder
+	n         int // Bytes written since the last flush
+	threshold int
+	flushed   bool // Whether output was flushed during the current call
+	err       error
+}
+
+// reset prepares f to flush a new message written by e
+func (f *flushWriter) reset(e *Encoder) {
+	f.e = e
+	f.n = 0
+	f.err = nil
+}
+
+// grow accounts for n more bytes, flushing once the threshold is reached
+func (f *flushWriter) grow(n int) {
+	f.n += n
+	if f.n < f.threshold || f.err != nil {
+		return
+	}
+	f.n = 0
+	f.flushed = true
+	f.err = f.e.flushOut()
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.e.w.Write(p)
+	f.grow(n)
+	return n, err
+}
+
+func (f *flushWriter) WriteByte(c byte) error {
+	err := f.e.w.WriteByte(c)
+	if err == nil {
+		f.grow(1)
+	}
+	return err
+}
+
+func (f *flushWriter) WriteString(s string) (int, error) {
+	n, err := f.e.w.WriteString(s)
+	f.grow(n)
+	return n, err
+}
+
+// flushOut writes any buffered output to the underlying writer and flushes
+// the underlying writer too when it has a Flush method
+func (e *Encoder) flushOut() error {
+	if err := e.flush(); err != nil {
+		return err
+	}
+	switch w := e.out.(type) {
+	case interface{ Flush() error }:
+		return w.Flush()
+	case interface{ Flush() }:
+		w.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package protojson_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+)
+
+// flushRecorder records the size of the output at each flush
+type flushRecorder struct {
+	buf     bytes.Buffer
+	flushes []int
+	err     error
+}
+
+func (r *flushRecorder) Write(p []byte) (int, error) {
+	return r.buf.Write(p)
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushes = append(r.flushes, r.buf.Len())
+}
+
+// failingFlusher is a buffered writer whose Flush always fails
+type failingFlusher struct {
+	bytes.Buffer
+}
+
+func (f *failingFlusher) Flush() error {
+	return errors.New("flush failed")
+}
+
+// TestSetFlushThreshold tests that output is flushed to the underlying
+// writer while a large message is encoded, without changing the output
+func TestSetFlushThreshold(t *testing.T) {
+	msg := &pb_basic.RepeatedFields{}
+	for range 100 {
+		msg.Strings = append(msg.Strings, strings.Repeat("x", 100))
+	}
+	want, err := protojson.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		threshold   int
+		wantFlushes int
+	}{
+		{name: "Disabled", threshold: 0, wantFlushes: 0},
+		{name: "1KB", threshold: 1024, wantFlushes: len(want) / 1024},
+		{name: "LargerThanMessage", threshold: len(want) + 1, wantFlushes: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w flushRecorder
+			encoder := protojson.NewEncoder(&w)
+			encoder.SetFlushThreshold(tt.threshold)
+			if err := encoder.Encode(msg); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+
+			if diff := cmp.Diff(string(want), w.buf.String()); diff != "" {
+				t.Errorf("Encode() mismatch (-want +got):\n%s", diff)
+			}
+			if len(w.flushes) != tt.wantFlushes {
+				t.Fatalf("got %d flushes, want %d", len(w.flushes), tt.wantFlushes)
+			}
+			for i, n := range w.flushes {
+				if n < (i+1)*tt.threshold {
+					t.Errorf("flush %d after %d bytes, want at least %d", i, n, (i+1)*tt.threshold)
+				}
+			}
+		})
+	}
+}
+
+// TestSetFlushThresholdError tests that a failing flush aborts encoding
+func TestSetFlushThresholdError(t *testing.T) {
+	msg := &pb_basic.RepeatedFields{
+		Strings: []string{strings.Repeat("x", 100), strings.Repeat("y", 100)},
+	}
+
+	var w failingFlusher
+	encoder := protojson.NewEncoder(&w)
+	encoder.SetFlushThreshold(64)
+	err := encoder.Encode(msg)
+	if err == nil || err.Error() != "flush failed" {
+		t.Fatalf("Encode() error = %v, want flush failed", err)
+	}
+}
@@ -0,0 +1,129 @@
+package protojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Formatter returns the JSON representation of m, which must be a single
+// valid JSON value. opts are the options in effect, e.g. for marshaling
+// nested messages with opts.Marshal.
+type Formatter func(m protoreflect.Message, opts MarshalOptions) ([]byte, error)
+
+// wellKnownFormatters marshals the well-known types that have a special
+// JSON representation, keyed by full name
+var wellKnownFormatters map[protoreflect.FullName]func(*encoder, protoreflect.Message) error
+
+func init() {
+	// Assigned in init, since the formatters refer back to the map through
+	// marshalMessage
+	wellKnownFormatters = map[protoreflect.FullName]func(*encoder, protoreflect.Message) error{
+		"google.protobuf.Timestamp":   (*encoder).marshalTimestamp,
+		"google.protobuf.Duration":    (*encoder).marshalDuration,
+		"google.protobuf.Struct":      (*encoder).marshalStruct,
+		"google.protobuf.Value":       (*encoder).marshalValue,
+		"google.protobuf.ListValue":   (*encoder).marshalListValue,
+		"google.protobuf.Any":         (*encoder).marshalAny,
+		"google.protobuf.FieldMask":   (*encoder).marshalFieldMask,
+		"google.protobuf.Empty":       (*encoder).marshalEmpty,
+		"google.protobuf.StringValue": (*encoder).marshalWrapper,
+		"google.protobuf.Int32Value":  (*encoder).marshalWrapper,
+		"google.protobuf.Int64Value":  (*encoder).marshalWrapper,
+		"google.protobuf.UInt32Value": (*encoder).marshalWrapper,
+		"google.protobuf.UInt64Value": (*encoder).marshalWrapper,
+		"google.protobuf.BoolValue":   (*encoder).marshalWrapper,
+		"google.protobuf.FloatValue":  (*encoder).marshalWrapper,
+		"google.protobuf.DoubleValue": (*encoder).marshalWrapper,
+		"google.protobuf.BytesValue":  (*encoder).marshalWrapper,
+	}
+}
+
+// formatted reports whether messages named name are written by a formatter
+// rather than as objects of their fields
+func (e *encoder) formatted(name protoreflect.FullName) bool {
+	if _, ok := e.opts.Formatters[name]; ok {
+		return true
+	}
+	return isWellKnownType(name) || e.googleTypeFormatter(name) != nil
+}
+
+// marshalFormatted writes m with a formatter from Formatters
+func (e *encoder) marshalFormatted(f Formatter, m protoreflect.Message) error {
+	b, err := f(m, e.opts)
+	if err != nil {
+		return fmt.Errorf("format %s: %w", m.Descriptor().FullName(), err)
+	}
+	if !json.Valid(b) {
+		return fmt.Errorf("format %s: formatter returned invalid JSON", m.Descriptor().FullName())
+	}
+	if e.opts.Indent == "" && !e.opts.Multiline {
+		e.w.Write(b)
+		return nil
+	}
+
+	// Re-indent the value to its depth in the output
+	indent := e.opts.Indent
+	if indent == "" {
+		indent = "  "
+	}
+	var buf bytes.Buffer
+	json.Indent(&buf, bytes.TrimSpace(b), strings.Repeat(indent, e.depth), indent)
+	e.w.Write(buf.Bytes())
+	return nil
+}
+
+// marshalEmpty marshals google.protobuf.Empty
+func (e *encoder) marshalEmpty(m protoreflect.Message) error {
+	e.w.WriteString("{}")
+	return nil
+}
+
+// marshalFieldMask marshals google.protobuf.FieldMask as a comma-separated
+// string of lowerCamelCase paths, like the standard package
+func (e *encoder) marshalFieldMask(m protoreflect.Message) error {
+	list := m.Get(m.Descriptor().Fields().ByName("paths")).List()
+	paths := make([]string, list.Len())
+	for i := range paths {
+		path := list.Get(i).String()
+		paths[i] = jsonCamelCase(path)
+		if jsonSnakeCase(paths[i]) != path {
+			return fmt.Errorf("google.protobuf.FieldMask.paths contains irreversible value %q", path)
+		}
+	}
+	return e.marshalString(strings.Join(paths, ","))
+}
+
+// jsonCamelCase converts a snake_case path to lowerCamelCase
+func jsonCamelCase(s string) string {
+	b := make([]byte, 0, len(s))
+	wasUnderscore := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '_' {
+			if wasUnderscore && 'a' <= c && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+		}
+		wasUnderscore = c == '_'
+	}
+	return string(b)
+}
+
+// jsonSnakeCase converts a lowerCamelCase path to snake_case
+func jsonSnakeCase(s string) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' {
+			b = append(b, '_')
+			c += 'a' - 'A'
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
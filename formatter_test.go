@@ -0,0 +1,108 @@
+package protojson_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TestFormatters tests custom formatters for message types
+func TestFormatters(t *testing.T) {
+	// Write Address messages as a single line of text
+	address := func(m protoreflect.Message, opts protojson.MarshalOptions) ([]byte, error) {
+		fields := m.Descriptor().Fields()
+		street := m.Get(fields.ByName("street")).String()
+		city := m.Get(fields.ByName("city")).String()
+		return []byte(fmt.Sprintf("%q", street+", "+city)), nil
+	}
+	unixTime := func(m protoreflect.Message, opts protojson.MarshalOptions) ([]byte, error) {
+		return []byte(fmt.Sprint(m.Get(m.Descriptor().Fields().ByName("seconds")).Int())), nil
+	}
+
+	tests := []struct {
+		name    string
+		msg     proto.Message
+		opts    protojson.MarshalOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "UserType",
+			msg:  &pb_basic.User{Name: "alice", Profile: &pb_basic.Profile{Address: &pb_basic.Address{Street: "1 Main St", City: "Springfield"}}},
+			opts: protojson.MarshalOptions{Formatters: map[protoreflect.FullName]protojson.Formatter{
+				"test.complex.Address": address,
+			}},
+			want: `{"name":"alice","profile":{"address":"1 Main St, Springfield"}}`,
+		},
+		{
+			name: "OverrideWellKnownType",
+			msg:  &pb_basic.WellKnownTypes{Timestamp: timestamppb.New(time.Unix(1700000000, 0))},
+			opts: protojson.MarshalOptions{Formatters: map[protoreflect.FullName]protojson.Formatter{
+				"google.protobuf.Timestamp": unixTime,
+			}},
+			want: `{"timestamp":1700000000}`,
+		},
+		{
+			name: "Indented",
+			msg:  &pb_basic.WellKnownTypes{Timestamp: timestamppb.New(time.Unix(0, 0))},
+			opts: protojson.MarshalOptions{Indent: "  ", Formatters: map[protoreflect.FullName]protojson.Formatter{
+				"google.protobuf.Timestamp": func(protoreflect.Message, protojson.MarshalOptions) ([]byte, error) {
+					return []byte(`{"unix":0, "zones":["UTC"]}`), nil
+				},
+			}},
+			want: "{\n  \"timestamp\": {\n    \"unix\": 0,\n    \"zones\": [\n      \"UTC\"\n    ]\n  }\n}",
+		},
+		{
+			name: "InvalidJSON",
+			msg:  &pb_basic.WellKnownTypes{Timestamp: timestamppb.New(time.Unix(0, 0))},
+			opts: protojson.MarshalOptions{Formatters: map[protoreflect.FullName]protojson.Formatter{
+				"google.protobuf.Timestamp": func(protoreflect.Message, protojson.MarshalOptions) ([]byte, error) {
+					return []byte("{"), nil
+				},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "Error",
+			msg:  &pb_basic.WellKnownTypes{Timestamp: timestamppb.New(time.Unix(0, 0))},
+			opts: protojson.MarshalOptions{Formatters: map[protoreflect.FullName]protojson.Formatter{
+				"google.protobuf.Timestamp": func(protoreflect.Message, protojson.MarshalOptions) ([]byte, error) {
+					return nil, errors.New("boom")
+				},
+			}},
+			wantErr: true,
+		},
+		{
+			name:    "IrreversibleFieldMask",
+			msg:     &pb_basic.WellKnownTypes{Any: mustAny(t, &fieldmaskpb.FieldMask{Paths: []string{"userName"}})},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Marshal() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package protojson
+
+import (
+	"io"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// FrameEncoder writes each message as a separate frame of a message-based
+// transport such as a WebSocket connection, one JSON document per text
+// frame. Its buffers are reused across frames. A FrameEncoder is not safe
+// for concurrent use.
+type FrameEncoder struct {
+	write func(frame []byte) error
+	next  func() (io.WriteCloser, error)
+	opts  MarshalOptions
+	buf   []byte
+	enc   *Encoder
+}
+
+// NewFrameEncoder returns a FrameEncoder that passes each encoded message
+// to write as a complete frame, e.g. with nhooyr.io/websocket:
+//
+//	protojson.NewFrameEncoder(func(frame []byte) error {
+//		return conn.Write(ctx, websocket.MessageText, frame)
+//	}, opts)
+//
+// The frame is only valid until write returns.
+func NewFrameEncoder(write func(frame []byte) error, opts MarshalOptions) *FrameEncoder {
+	return &FrameEncoder{write: write, opts: opts}
+}
+
+// NewFrameWriterEncoder returns a FrameEncoder that streams each encoded
+// message into a frame writer obtained from next, closing it to end the
+// frame, e.g. with github.com/gorilla/websocket:
+//
+//	protojson.NewFrameWriterEncoder(func() (io.WriteCloser, error) {
+//		return conn.NextWriter(websocket.TextMessage)
+//	}, opts)
+func NewFrameWriterEncoder(next func() (io.WriteCloser, error), opts MarshalOptions) *FrameEncoder {
+	return &FrameEncoder{next: next, opts: opts}
+}
+
+// Encode writes m as one frame. With a frame writer, the writer is closed
+// even if encoding fails, which may send an empty frame.
+func (e *FrameEncoder) Encode(m proto.Message) error {
+	if e.next == nil {
+		b, err := e.opts.MarshalAppend(e.buf[:0], m)
+		if err != nil {
+			return err
+		}
+		e.buf = b
+		return e.write(b)
+	}
+
+	w, err := e.next()
+	if err != nil {
+		return err
+	}
+	if e.enc == nil {
+		e.enc = NewEncoderWithOptions(w, e.opts)
+	} else {
+		e.enc.Reset(w)
+	}
+	err = e.enc.Encode(m)
+	// Drop the frame writer, but keep the buffer for the next frame
+	e.enc.Reset(nil)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package protojson_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+)
+
+// frameWriter records a frame when closed
+type frameWriter struct {
+	bytes.Buffer
+	frames *[]string
+}
+
+func (w *frameWriter) Close() error {
+	*w.frames = append(*w.frames, w.String())
+	return nil
+}
+
+// TestFrameEncoder tests writing one message per frame
+func TestFrameEncoder(t *testing.T) {
+	msgs := []proto.Message{
+		&pb_basic.User{Name: "alice"},
+		&pb_basic.User{Name: "bob", Email: "bob@example.com"},
+	}
+	want := []string{`{"name":"alice"}`, `{"name":"bob","email":"bob@example.com"}`}
+
+	t.Run("Callback", func(t *testing.T) {
+		var frames []string
+		enc := protojson.NewFrameEncoder(func(frame []byte) error {
+			frames = append(frames, string(frame))
+			return nil
+		}, protojson.MarshalOptions{})
+		for _, m := range msgs {
+			if err := enc.Encode(m); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+		}
+		if diff := cmp.Diff(want, frames); diff != "" {
+			t.Errorf("frames mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("FrameWriter", func(t *testing.T) {
+		var frames []string
+		enc := protojson.NewFrameWriterEncoder(func() (io.WriteCloser, error) {
+			return &frameWriter{frames: &frames}, nil
+		}, protojson.MarshalOptions{})
+		for _, m := range msgs {
+			if err := enc.Encode(m); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+		}
+		if diff := cmp.Diff(want, frames); diff != "" {
+			t.Errorf("frames mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+// TestFrameEncoderErrors tests error handling of FrameEncoder
+func TestFrameEncoderErrors(t *testing.T) {
+	errWrite := errors.New("connection closed")
+
+	enc := protojson.NewFrameEncoder(func([]byte) error { return errWrite }, protojson.MarshalOptions{})
+	if err := enc.Encode(&pb_basic.User{}); !errors.Is(err, errWrite) {
+		t.Errorf("Encode() error = %v, want %v", err, errWrite)
+	}
+
+	enc = protojson.NewFrameWriterEncoder(func() (io.WriteCloser, error) { return nil, errWrite }, protojson.MarshalOptions{})
+	if err := enc.Encode(&pb_basic.User{}); !errors.Is(err, errWrite) {
+		t.Errorf("Encode() error = %v, want %v", err, errWrite)
+	}
+
+	var frames []string
+	enc = protojson.NewFrameWriterEncoder(func() (io.WriteCloser, error) {
+		return &frameWriter{frames: &frames}, nil
+	}, protojson.MarshalOptions{})
+	if err := enc.Encode(&pb_basic.BasicTypes{StringField: "\xff"}); err == nil {
+		t.Errorf("Encode() error = nil, want error for invalid UTF-8")
+	}
+	if diff := cmp.Diff([]string{""}, frames); diff != "" {
+		t.Errorf("frames mismatch (-want +got):\n%s", diff)
+	}
+}
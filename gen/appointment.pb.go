@@ -0,0 +1,171 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: appointment.proto
+
+package gen
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Appointment tests google.type fields
+type Appointment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Date          *Date                  `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"`
+	Time          *TimeOfDay             `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	Price         *Money                 `protobuf:"bytes,3,opt,name=price,proto3" json:"price,omitempty"`
+	Location      *LatLng                `protobuf:"bytes,4,opt,name=location,proto3" json:"location,omitempty"`
+	Color         *Color                 `protobuf:"bytes,5,opt,name=color,proto3" json:"color,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Appointment) Reset() {
+	*x = Appointment{}
+	mi := &file_appointment_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Appointment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Appointment) ProtoMessage() {}
+
+func (x *Appointment) ProtoReflect() protoreflect.Message {
+	mi := &file_appointment_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Appointment.ProtoReflect.Descriptor instead.
+func (*Appointment) Descriptor() ([]byte, []int) {
+	return file_appointment_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Appointment) GetDate() *Date {
+	if x != nil {
+		return x.Date
+	}
+	return nil
+}
+
+func (x *Appointment) GetTime() *TimeOfDay {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Appointment) GetPrice() *Money {
+	if x != nil {
+		return x.Price
+	}
+	return nil
+}
+
+func (x *Appointment) GetLocation() *LatLng {
+	if x != nil {
+		return x.Location
+	}
+	return nil
+}
+
+func (x *Appointment) GetColor() *Color {
+	if x != nil {
+		return x.Color
+	}
+	return nil
+}
+
+var File_appointment_proto protoreflect.FileDescriptor
+
+const file_appointment_proto_rawDesc = "" +
+	"\n" +
+	"\x11appointment.proto\x12\x10test.appointment\x1a\vgtype.proto\"\xe5\x01\n" +
+	"\vAppointment\x12%\n" +
+	"\x04date\x18\x01 \x01(\v2\x11.google.type.DateR\x04date\x12*\n" +
+	"\x04time\x18\x02 \x01(\v2\x16.google.type.TimeOfDayR\x04time\x12(\n" +
+	"\x05price\x18\x03 \x01(\v2\x12.google.type.MoneyR\x05price\x12/\n" +
+	"\blocation\x18\x04 \x01(\v2\x13.google.type.LatLngR\blocation\x12(\n" +
+	"\x05color\x18\x05 \x01(\v2\x12.google.type.ColorR\x05colorB\xad\x01\n" +
+	"\x14com.test.appointmentB\x10AppointmentProtoP\x01Z\"github.com/wreulicke/protojson/gen\xa2\x02\x03TAX\xaa\x02\x10Test.Appointment\xca\x02\x10Test\\Appointment\xe2\x02\x1cTest\\Appointment\\GPBMetadata\xea\x02\x11Test::Appointmentb\x06proto3"
+
+var (
+	file_appointment_proto_rawDescOnce sync.Once
+	file_appointment_proto_rawDescData []byte
+)
+
+func file_appointment_proto_rawDescGZIP() []byte {
+	file_appointment_proto_rawDescOnce.Do(func() {
+		file_appointment_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_appointment_proto_rawDesc), len(file_appointment_proto_rawDesc)))
+	})
+	return file_appointment_proto_rawDescData
+}
+
+var file_appointment_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_appointment_proto_goTypes = []any{
+	(*Appointment)(nil), // 0: test.appointment.Appointment
+	(*Date)(nil),        // 1: google.type.Date
+	(*TimeOfDay)(nil),   // 2: google.type.TimeOfDay
+	(*Money)(nil),       // 3: google.type.Money
+	(*LatLng)(nil),      // 4: google.type.LatLng
+	(*Color)(nil),       // 5: google.type.Color
+}
+var file_appointment_proto_depIdxs = []int32{
+	1, // 0: test.appointment.Appointment.date:type_name -> google.type.Date
+	2, // 1: test.appointment.Appointment.time:type_name -> google.type.TimeOfDay
+	3, // 2: test.appointment.Appointment.price:type_name -> google.type.Money
+	4, // 3: test.appointment.Appointment.location:type_name -> google.type.LatLng
+	5, // 4: test.appointment.Appointment.color:type_name -> google.type.Color
+	5, // [5:5] is the sub-list for method output_type
+	5, // [5:5] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_appointment_proto_init() }
+func file_appointment_proto_init() {
+	if File_appointment_proto != nil {
+		return
+	}
+	file_gtype_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_appointment_proto_rawDesc), len(file_appointment_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_appointment_proto_goTypes,
+		DependencyIndexes: file_appointment_proto_depIdxs,
+		MessageInfos:      file_appointment_proto_msgTypes,
+	}.Build()
+	File_appointment_proto = out.File
+	file_appointment_proto_goTypes = nil
+	file_appointment_proto_depIdxs = nil
+}
@@ -345,6 +345,67 @@ func (x *DefaultValues) GetEmptyArray() []string {
 	return nil
 }
 
+// RedactedFields tests fields marked debug_redact
+type RedactedFields struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Password      string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	Pin           int32                  `protobuf:"varint,3,opt,name=pin,proto3" json:"pin,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RedactedFields) Reset() {
+	*x = RedactedFields{}
+	mi := &file_basic_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RedactedFields) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RedactedFields) ProtoMessage() {}
+
+func (x *RedactedFields) ProtoReflect() protoreflect.Message {
+	mi := &file_basic_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RedactedFields.ProtoReflect.Descriptor instead.
+func (*RedactedFields) Descriptor() ([]byte, []int) {
+	return file_basic_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RedactedFields) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *RedactedFields) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *RedactedFields) GetPin() int32 {
+	if x != nil {
+		return x.Pin
+	}
+	return 0
+}
+
 var File_basic_proto protoreflect.FileDescriptor
 
 const file_basic_proto_rawDesc = "" +
@@ -388,7 +449,11 @@ const file_basic_proto_rawDesc = "" +
 	"\n" +
 	"false_bool\x18\x03 \x01(\bR\tfalseBool\x12\x1f\n" +
 	"\vempty_array\x18\x04 \x03(\tR\n" +
-	"emptyArrayB\x89\x01\n" +
+	"emptyArray\"d\n" +
+	"\x0eRedactedFields\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x1f\n" +
+	"\bpassword\x18\x02 \x01(\tB\x03\x80\x01\x01R\bpassword\x12\x15\n" +
+	"\x03pin\x18\x03 \x01(\x05B\x03\x80\x01\x01R\x03pinB\x89\x01\n" +
 	"\x0ecom.test.basicB\n" +
 	"BasicProtoP\x01Z\"github.com/wreulicke/protojson/gen\xa2\x02\x03TBX\xaa\x02\n" +
 	"Test.Basic\xca\x02\n" +
@@ -406,12 +471,13 @@ func file_basic_proto_rawDescGZIP() []byte {
 	return file_basic_proto_rawDescData
 }
 
-var file_basic_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_basic_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_basic_proto_goTypes = []any{
 	(*BasicTypes)(nil),     // 0: test.basic.BasicTypes
 	(*OptionalFields)(nil), // 1: test.basic.OptionalFields
 	(*EmptyMessage)(nil),   // 2: test.basic.EmptyMessage
 	(*DefaultValues)(nil),  // 3: test.basic.DefaultValues
+	(*RedactedFields)(nil), // 4: test.basic.RedactedFields
 }
 var file_basic_proto_depIdxs = []int32{
 	0, // [0:0] is the sub-list for method output_type
@@ -433,7 +499,7 @@ func file_basic_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_basic_proto_rawDesc), len(file_basic_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
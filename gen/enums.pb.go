@@ -240,6 +240,7 @@ type RepeatedEnums struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Statuses      []Status               `protobuf:"varint,1,rep,packed,name=statuses,proto3,enum=test.enums.Status" json:"statuses,omitempty"`
 	Priorities    []Priority             `protobuf:"varint,2,rep,packed,name=priorities,proto3,enum=test.enums.Priority" json:"priorities,omitempty"`
+	StatusMap     map[string]Status      `protobuf:"bytes,3,rep,name=status_map,json=statusMap,proto3" json:"status_map,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value,enum=test.enums.Status"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -288,6 +289,13 @@ func (x *RepeatedEnums) GetPriorities() []Priority {
 	return nil
 }
 
+func (x *RepeatedEnums) GetStatusMap() map[string]Status {
+	if x != nil {
+		return x.StatusMap
+	}
+	return nil
+}
+
 // NestedEnum tests nested enum definition
 type NestedEnum struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -387,12 +395,17 @@ const file_enums_proto_rawDesc = "" +
 	"\n" +
 	"EnumFields\x12*\n" +
 	"\x06status\x18\x01 \x01(\x0e2\x12.test.enums.StatusR\x06status\x120\n" +
-	"\bpriority\x18\x02 \x01(\x0e2\x14.test.enums.PriorityR\bpriority\"u\n" +
+	"\bpriority\x18\x02 \x01(\x0e2\x14.test.enums.PriorityR\bpriority\"\x90\x02\n" +
 	"\rRepeatedEnums\x12.\n" +
 	"\bstatuses\x18\x01 \x03(\x0e2\x12.test.enums.StatusR\bstatuses\x124\n" +
 	"\n" +
 	"priorities\x18\x02 \x03(\x0e2\x14.test.enums.PriorityR\n" +
-	"priorities\"\x7f\n" +
+	"priorities\x12G\n" +
+	"\n" +
+	"status_map\x18\x03 \x03(\v2(.test.enums.RepeatedEnums.StatusMapEntryR\tstatusMap\x1aP\n" +
+	"\x0eStatusMapEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12(\n" +
+	"\x05value\x18\x02 \x01(\x0e2\x12.test.enums.StatusR\x05value:\x028\x01\"\x7f\n" +
 	"\n" +
 	"NestedEnum\x12/\n" +
 	"\x04type\x18\x01 \x01(\x0e2\x1b.test.enums.NestedEnum.TypeR\x04type\"@\n" +
@@ -435,7 +448,7 @@ func file_enums_proto_rawDescGZIP() []byte {
 }
 
 var file_enums_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
-var file_enums_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_enums_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_enums_proto_goTypes = []any{
 	(Status)(0),           // 0: test.enums.Status
 	(Priority)(0),         // 1: test.enums.Priority
@@ -444,19 +457,22 @@ var file_enums_proto_goTypes = []any{
 	(*RepeatedEnums)(nil), // 4: test.enums.RepeatedEnums
 	(*NestedEnum)(nil),    // 5: test.enums.NestedEnum
 	(*DefaultEnum)(nil),   // 6: test.enums.DefaultEnum
+	nil,                   // 7: test.enums.RepeatedEnums.StatusMapEntry
 }
 var file_enums_proto_depIdxs = []int32{
 	0, // 0: test.enums.EnumFields.status:type_name -> test.enums.Status
 	1, // 1: test.enums.EnumFields.priority:type_name -> test.enums.Priority
 	0, // 2: test.enums.RepeatedEnums.statuses:type_name -> test.enums.Status
 	1, // 3: test.enums.RepeatedEnums.priorities:type_name -> test.enums.Priority
-	2, // 4: test.enums.NestedEnum.type:type_name -> test.enums.NestedEnum.Type
-	0, // 5: test.enums.DefaultEnum.default_status:type_name -> test.enums.Status
-	6, // [6:6] is the sub-list for method output_type
-	6, // [6:6] is the sub-list for method input_type
-	6, // [6:6] is the sub-list for extension type_name
-	6, // [6:6] is the sub-list for extension extendee
-	0, // [0:6] is the sub-list for field type_name
+	7, // 4: test.enums.RepeatedEnums.status_map:type_name -> test.enums.RepeatedEnums.StatusMapEntry
+	2, // 5: test.enums.NestedEnum.type:type_name -> test.enums.NestedEnum.Type
+	0, // 6: test.enums.DefaultEnum.default_status:type_name -> test.enums.Status
+	0, // 7: test.enums.RepeatedEnums.StatusMapEntry.value:type_name -> test.enums.Status
+	8, // [8:8] is the sub-list for method output_type
+	8, // [8:8] is the sub-list for method input_type
+	8, // [8:8] is the sub-list for extension type_name
+	8, // [8:8] is the sub-list for extension extendee
+	0, // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_enums_proto_init() }
@@ -470,7 +486,7 @@ func file_enums_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_enums_proto_rawDesc), len(file_enums_proto_rawDesc)),
 			NumEnums:      3,
-			NumMessages:   4,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,722 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: generated.proto
+
+package gen
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// GeneratedColor tests generated encoding of enums
+type GeneratedColor int32
+
+const (
+	GeneratedColor_GENERATED_COLOR_UNSPECIFIED GeneratedColor = 0
+	GeneratedColor_GENERATED_COLOR_RED         GeneratedColor = 1
+	GeneratedColor_GENERATED_COLOR_GREEN       GeneratedColor = 2
+)
+
+// Enum value maps for GeneratedColor.
+var (
+	GeneratedColor_name = map[int32]string{
+		0: "GENERATED_COLOR_UNSPECIFIED",
+		1: "GENERATED_COLOR_RED",
+		2: "GENERATED_COLOR_GREEN",
+	}
+	GeneratedColor_value = map[string]int32{
+		"GENERATED_COLOR_UNSPECIFIED": 0,
+		"GENERATED_COLOR_RED":         1,
+		"GENERATED_COLOR_GREEN":       2,
+	}
+)
+
+func (x GeneratedColor) Enum() *GeneratedColor {
+	p := new(GeneratedColor)
+	*p = x
+	return p
+}
+
+func (x GeneratedColor) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (GeneratedColor) Descriptor() protoreflect.EnumDescriptor {
+	return file_generated_proto_enumTypes[0].Descriptor()
+}
+
+func (GeneratedColor) Type() protoreflect.EnumType {
+	return &file_generated_proto_enumTypes[0]
+}
+
+func (x GeneratedColor) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use GeneratedColor.Descriptor instead.
+func (GeneratedColor) EnumDescriptor() ([]byte, []int) {
+	return file_generated_proto_rawDescGZIP(), []int{0}
+}
+
+// GeneratedScalars tests generated encoding of scalar and repeated fields
+type GeneratedScalars struct {
+	state          protoimpl.MessageState    `protogen:"open.v1"`
+	StringField    string                    `protobuf:"bytes,1,opt,name=string_field,json=stringField,proto3" json:"string_field,omitempty"`
+	Int32Field     int32                     `protobuf:"varint,2,opt,name=int32_field,json=int32Field,proto3" json:"int32_field,omitempty"`
+	Int64Field     int64                     `protobuf:"varint,3,opt,name=int64_field,json=int64Field,proto3" json:"int64_field,omitempty"`
+	Uint32Field    uint32                    `protobuf:"varint,4,opt,name=uint32_field,json=uint32Field,proto3" json:"uint32_field,omitempty"`
+	Uint64Field    uint64                    `protobuf:"varint,5,opt,name=uint64_field,json=uint64Field,proto3" json:"uint64_field,omitempty"`
+	Sint32Field    int32                     `protobuf:"zigzag32,6,opt,name=sint32_field,json=sint32Field,proto3" json:"sint32_field,omitempty"`
+	Sint64Field    int64                     `protobuf:"zigzag64,7,opt,name=sint64_field,json=sint64Field,proto3" json:"sint64_field,omitempty"`
+	Fixed32Field   uint32                    `protobuf:"fixed32,8,opt,name=fixed32_field,json=fixed32Field,proto3" json:"fixed32_field,omitempty"`
+	Fixed64Field   uint64                    `protobuf:"fixed64,9,opt,name=fixed64_field,json=fixed64Field,proto3" json:"fixed64_field,omitempty"`
+	Sfixed32Field  int32                     `protobuf:"fixed32,10,opt,name=sfixed32_field,json=sfixed32Field,proto3" json:"sfixed32_field,omitempty"`
+	Sfixed64Field  int64                     `protobuf:"fixed64,11,opt,name=sfixed64_field,json=sfixed64Field,proto3" json:"sfixed64_field,omitempty"`
+	BoolField      bool                      `protobuf:"varint,12,opt,name=bool_field,json=boolField,proto3" json:"bool_field,omitempty"`
+	FloatField     float32                   `protobuf:"fixed32,13,opt,name=float_field,json=floatField,proto3" json:"float_field,omitempty"`
+	DoubleField    float64                   `protobuf:"fixed64,14,opt,name=double_field,json=doubleField,proto3" json:"double_field,omitempty"`
+	BytesField     []byte                    `protobuf:"bytes,15,opt,name=bytes_field,json=bytesField,proto3" json:"bytes_field,omitempty"`
+	Color          GeneratedColor            `protobuf:"varint,16,opt,name=color,proto3,enum=test.generated.GeneratedColor" json:"color,omitempty"`
+	OptionalString *string                   `protobuf:"bytes,17,opt,name=optional_string,json=optionalString,proto3,oneof" json:"optional_string,omitempty"`
+	OptionalInt64  *int64                    `protobuf:"varint,18,opt,name=optional_int64,json=optionalInt64,proto3,oneof" json:"optional_int64,omitempty"`
+	OptionalColor  *GeneratedColor           `protobuf:"varint,19,opt,name=optional_color,json=optionalColor,proto3,enum=test.generated.GeneratedColor,oneof" json:"optional_color,omitempty"`
+	Int64List      []int64                   `protobuf:"varint,20,rep,packed,name=int64_list,json=int64List,proto3" json:"int64_list,omitempty"`
+	FloatList      []float32                 `protobuf:"fixed32,21,rep,packed,name=float_list,json=floatList,proto3" json:"float_list,omitempty"`
+	BytesList      [][]byte                  `protobuf:"bytes,22,rep,name=bytes_list,json=bytesList,proto3" json:"bytes_list,omitempty"`
+	Colors         []GeneratedColor          `protobuf:"varint,23,rep,packed,name=colors,proto3,enum=test.generated.GeneratedColor" json:"colors,omitempty"`
+	CustomNamed    string                    `protobuf:"bytes,24,opt,name=custom_named,json=renamed,proto3" json:"custom_named,omitempty"`
+	OptionalBytes  []byte                    `protobuf:"bytes,25,opt,name=optional_bytes,json=optionalBytes,proto3,oneof" json:"optional_bytes,omitempty"`
+	OptionalInner  *GeneratedContainer_Inner `protobuf:"bytes,26,opt,name=optional_inner,json=optionalInner,proto3,oneof" json:"optional_inner,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GeneratedScalars) Reset() {
+	*x = GeneratedScalars{}
+	mi := &file_generated_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GeneratedScalars) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GeneratedScalars) ProtoMessage() {}
+
+func (x *GeneratedScalars) ProtoReflect() protoreflect.Message {
+	mi := &file_generated_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GeneratedScalars.ProtoReflect.Descriptor instead.
+func (*GeneratedScalars) Descriptor() ([]byte, []int) {
+	return file_generated_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GeneratedScalars) GetStringField() string {
+	if x != nil {
+		return x.StringField
+	}
+	return ""
+}
+
+func (x *GeneratedScalars) GetInt32Field() int32 {
+	if x != nil {
+		return x.Int32Field
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetInt64Field() int64 {
+	if x != nil {
+		return x.Int64Field
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetUint32Field() uint32 {
+	if x != nil {
+		return x.Uint32Field
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetUint64Field() uint64 {
+	if x != nil {
+		return x.Uint64Field
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetSint32Field() int32 {
+	if x != nil {
+		return x.Sint32Field
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetSint64Field() int64 {
+	if x != nil {
+		return x.Sint64Field
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetFixed32Field() uint32 {
+	if x != nil {
+		return x.Fixed32Field
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetFixed64Field() uint64 {
+	if x != nil {
+		return x.Fixed64Field
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetSfixed32Field() int32 {
+	if x != nil {
+		return x.Sfixed32Field
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetSfixed64Field() int64 {
+	if x != nil {
+		return x.Sfixed64Field
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetBoolField() bool {
+	if x != nil {
+		return x.BoolField
+	}
+	return false
+}
+
+func (x *GeneratedScalars) GetFloatField() float32 {
+	if x != nil {
+		return x.FloatField
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetDoubleField() float64 {
+	if x != nil {
+		return x.DoubleField
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetBytesField() []byte {
+	if x != nil {
+		return x.BytesField
+	}
+	return nil
+}
+
+func (x *GeneratedScalars) GetColor() GeneratedColor {
+	if x != nil {
+		return x.Color
+	}
+	return GeneratedColor_GENERATED_COLOR_UNSPECIFIED
+}
+
+func (x *GeneratedScalars) GetOptionalString() string {
+	if x != nil && x.OptionalString != nil {
+		return *x.OptionalString
+	}
+	return ""
+}
+
+func (x *GeneratedScalars) GetOptionalInt64() int64 {
+	if x != nil && x.OptionalInt64 != nil {
+		return *x.OptionalInt64
+	}
+	return 0
+}
+
+func (x *GeneratedScalars) GetOptionalColor() GeneratedColor {
+	if x != nil && x.OptionalColor != nil {
+		return *x.OptionalColor
+	}
+	return GeneratedColor_GENERATED_COLOR_UNSPECIFIED
+}
+
+func (x *GeneratedScalars) GetInt64List() []int64 {
+	if x != nil {
+		return x.Int64List
+	}
+	return nil
+}
+
+func (x *GeneratedScalars) GetFloatList() []float32 {
+	if x != nil {
+		return x.FloatList
+	}
+	return nil
+}
+
+func (x *GeneratedScalars) GetBytesList() [][]byte {
+	if x != nil {
+		return x.BytesList
+	}
+	return nil
+}
+
+func (x *GeneratedScalars) GetColors() []GeneratedColor {
+	if x != nil {
+		return x.Colors
+	}
+	return nil
+}
+
+func (x *GeneratedScalars) GetCustomNamed() string {
+	if x != nil {
+		return x.CustomNamed
+	}
+	return ""
+}
+
+func (x *GeneratedScalars) GetOptionalBytes() []byte {
+	if x != nil {
+		return x.OptionalBytes
+	}
+	return nil
+}
+
+func (x *GeneratedScalars) GetOptionalInner() *GeneratedContainer_Inner {
+	if x != nil {
+		return x.OptionalInner
+	}
+	return nil
+}
+
+// GeneratedContainer tests generated encoding of messages, maps and oneofs
+type GeneratedContainer struct {
+	state   protoimpl.MessageState      `protogen:"open.v1"`
+	Name    string                      `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Scalars *GeneratedScalars           `protobuf:"bytes,2,opt,name=scalars,proto3" json:"scalars,omitempty"`
+	Items   []*GeneratedScalars         `protobuf:"bytes,3,rep,name=items,proto3" json:"items,omitempty"`
+	Labels  map[string]string           `protobuf:"bytes,4,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ById    map[int32]*GeneratedScalars `protobuf:"bytes,5,rep,name=by_id,json=byId,proto3" json:"by_id,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Flags   map[bool]int64              `protobuf:"bytes,6,rep,name=flags,proto3" json:"flags,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Colors  map[uint64]GeneratedColor   `protobuf:"bytes,7,rep,name=colors,proto3" json:"colors,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value,enum=test.generated.GeneratedColor"`
+	// Types that are valid to be assigned to Choice:
+	//
+	//	*GeneratedContainer_Text
+	//	*GeneratedContainer_Nested
+	//	*GeneratedContainer_Number
+	Choice        isGeneratedContainer_Choice `protobuf_oneof:"choice"`
+	CreatedAt     *timestamppb.Timestamp      `protobuf:"bytes,11,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Attributes    *structpb.Struct            `protobuf:"bytes,12,opt,name=attributes,proto3" json:"attributes,omitempty"`
+	Owner         *User                       `protobuf:"bytes,13,opt,name=owner,proto3" json:"owner,omitempty"`
+	Inner         *GeneratedContainer_Inner   `protobuf:"bytes,14,opt,name=inner,proto3" json:"inner,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GeneratedContainer) Reset() {
+	*x = GeneratedContainer{}
+	mi := &file_generated_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GeneratedContainer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GeneratedContainer) ProtoMessage() {}
+
+func (x *GeneratedContainer) ProtoReflect() protoreflect.Message {
+	mi := &file_generated_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GeneratedContainer.ProtoReflect.Descriptor instead.
+func (*GeneratedContainer) Descriptor() ([]byte, []int) {
+	return file_generated_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GeneratedContainer) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *GeneratedContainer) GetScalars() *GeneratedScalars {
+	if x != nil {
+		return x.Scalars
+	}
+	return nil
+}
+
+func (x *GeneratedContainer) GetItems() []*GeneratedScalars {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *GeneratedContainer) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *GeneratedContainer) GetById() map[int32]*GeneratedScalars {
+	if x != nil {
+		return x.ById
+	}
+	return nil
+}
+
+func (x *GeneratedContainer) GetFlags() map[bool]int64 {
+	if x != nil {
+		return x.Flags
+	}
+	return nil
+}
+
+func (x *GeneratedContainer) GetColors() map[uint64]GeneratedColor {
+	if x != nil {
+		return x.Colors
+	}
+	return nil
+}
+
+func (x *GeneratedContainer) GetChoice() isGeneratedContainer_Choice {
+	if x != nil {
+		return x.Choice
+	}
+	return nil
+}
+
+func (x *GeneratedContainer) GetText() string {
+	if x != nil {
+		if x, ok := x.Choice.(*GeneratedContainer_Text); ok {
+			return x.Text
+		}
+	}
+	return ""
+}
+
+func (x *GeneratedContainer) GetNested() *GeneratedScalars {
+	if x != nil {
+		if x, ok := x.Choice.(*GeneratedContainer_Nested); ok {
+			return x.Nested
+		}
+	}
+	return nil
+}
+
+func (x *GeneratedContainer) GetNumber() int32 {
+	if x != nil {
+		if x, ok := x.Choice.(*GeneratedContainer_Number); ok {
+			return x.Number
+		}
+	}
+	return 0
+}
+
+func (x *GeneratedContainer) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *GeneratedContainer) GetAttributes() *structpb.Struct {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *GeneratedContainer) GetOwner() *User {
+	if x != nil {
+		return x.Owner
+	}
+	return nil
+}
+
+func (x *GeneratedContainer) GetInner() *GeneratedContainer_Inner {
+	if x != nil {
+		return x.Inner
+	}
+	return nil
+}
+
+type isGeneratedContainer_Choice interface {
+	isGeneratedContainer_Choice()
+}
+
+type GeneratedContainer_Text struct {
+	Text string `protobuf:"bytes,8,opt,name=text,proto3,oneof"`
+}
+
+type GeneratedContainer_Nested struct {
+	Nested *GeneratedScalars `protobuf:"bytes,9,opt,name=nested,proto3,oneof"`
+}
+
+type GeneratedContainer_Number struct {
+	Number int32 `protobuf:"varint,10,opt,name=number,proto3,oneof"`
+}
+
+func (*GeneratedContainer_Text) isGeneratedContainer_Choice() {}
+
+func (*GeneratedContainer_Nested) isGeneratedContainer_Choice() {}
+
+func (*GeneratedContainer_Number) isGeneratedContainer_Choice() {}
+
+// Inner tests nested message types
+type GeneratedContainer_Inner struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Note          string                 `protobuf:"bytes,1,opt,name=note,proto3" json:"note,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GeneratedContainer_Inner) Reset() {
+	*x = GeneratedContainer_Inner{}
+	mi := &file_generated_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GeneratedContainer_Inner) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GeneratedContainer_Inner) ProtoMessage() {}
+
+func (x *GeneratedContainer_Inner) ProtoReflect() protoreflect.Message {
+	mi := &file_generated_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GeneratedContainer_Inner.ProtoReflect.Descriptor instead.
+func (*GeneratedContainer_Inner) Descriptor() ([]byte, []int) {
+	return file_generated_proto_rawDescGZIP(), []int{1, 0}
+}
+
+func (x *GeneratedContainer_Inner) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+var File_generated_proto protoreflect.FileDescriptor
+
+const file_generated_proto_rawDesc = "" +
+	"\n" +
+	"\x0fgenerated.proto\x12\x0etest.generated\x1a\rcomplex.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x91\t\n" +
+	"\x10GeneratedScalars\x12!\n" +
+	"\fstring_field\x18\x01 \x01(\tR\vstringField\x12\x1f\n" +
+	"\vint32_field\x18\x02 \x01(\x05R\n" +
+	"int32Field\x12\x1f\n" +
+	"\vint64_field\x18\x03 \x01(\x03R\n" +
+	"int64Field\x12!\n" +
+	"\fuint32_field\x18\x04 \x01(\rR\vuint32Field\x12!\n" +
+	"\fuint64_field\x18\x05 \x01(\x04R\vuint64Field\x12!\n" +
+	"\fsint32_field\x18\x06 \x01(\x11R\vsint32Field\x12!\n" +
+	"\fsint64_field\x18\a \x01(\x12R\vsint64Field\x12#\n" +
+	"\rfixed32_field\x18\b \x01(\aR\ffixed32Field\x12#\n" +
+	"\rfixed64_field\x18\t \x01(\x06R\ffixed64Field\x12%\n" +
+	"\x0esfixed32_field\x18\n" +
+	" \x01(\x0fR\rsfixed32Field\x12%\n" +
+	"\x0esfixed64_field\x18\v \x01(\x10R\rsfixed64Field\x12\x1d\n" +
+	"\n" +
+	"bool_field\x18\f \x01(\bR\tboolField\x12\x1f\n" +
+	"\vfloat_field\x18\r \x01(\x02R\n" +
+	"floatField\x12!\n" +
+	"\fdouble_field\x18\x0e \x01(\x01R\vdoubleField\x12\x1f\n" +
+	"\vbytes_field\x18\x0f \x01(\fR\n" +
+	"bytesField\x124\n" +
+	"\x05color\x18\x10 \x01(\x0e2\x1e.test.generated.GeneratedColorR\x05color\x12,\n" +
+	"\x0foptional_string\x18\x11 \x01(\tH\x00R\x0eoptionalString\x88\x01\x01\x12*\n" +
+	"\x0eoptional_int64\x18\x12 \x01(\x03H\x01R\roptionalInt64\x88\x01\x01\x12J\n" +
+	"\x0eoptional_color\x18\x13 \x01(\x0e2\x1e.test.generated.GeneratedColorH\x02R\roptionalColor\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"int64_list\x18\x14 \x03(\x03R\tint64List\x12\x1d\n" +
+	"\n" +
+	"float_list\x18\x15 \x03(\x02R\tfloatList\x12\x1d\n" +
+	"\n" +
+	"bytes_list\x18\x16 \x03(\fR\tbytesList\x126\n" +
+	"\x06colors\x18\x17 \x03(\x0e2\x1e.test.generated.GeneratedColorR\x06colors\x12\x1d\n" +
+	"\fcustom_named\x18\x18 \x01(\tR\arenamed\x12*\n" +
+	"\x0eoptional_bytes\x18\x19 \x01(\fH\x03R\roptionalBytes\x88\x01\x01\x12T\n" +
+	"\x0eoptional_inner\x18\x1a \x01(\v2(.test.generated.GeneratedContainer.InnerH\x04R\roptionalInner\x88\x01\x01B\x12\n" +
+	"\x10_optional_stringB\x11\n" +
+	"\x0f_optional_int64B\x11\n" +
+	"\x0f_optional_colorB\x11\n" +
+	"\x0f_optional_bytesB\x11\n" +
+	"\x0f_optional_inner\"\xd0\b\n" +
+	"\x12GeneratedContainer\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12:\n" +
+	"\ascalars\x18\x02 \x01(\v2 .test.generated.GeneratedScalarsR\ascalars\x126\n" +
+	"\x05items\x18\x03 \x03(\v2 .test.generated.GeneratedScalarsR\x05items\x12F\n" +
+	"\x06labels\x18\x04 \x03(\v2..test.generated.GeneratedContainer.LabelsEntryR\x06labels\x12A\n" +
+	"\x05by_id\x18\x05 \x03(\v2,.test.generated.GeneratedContainer.ByIdEntryR\x04byId\x12C\n" +
+	"\x05flags\x18\x06 \x03(\v2-.test.generated.GeneratedContainer.FlagsEntryR\x05flags\x12F\n" +
+	"\x06colors\x18\a \x03(\v2..test.generated.GeneratedContainer.ColorsEntryR\x06colors\x12\x14\n" +
+	"\x04text\x18\b \x01(\tH\x00R\x04text\x12:\n" +
+	"\x06nested\x18\t \x01(\v2 .test.generated.GeneratedScalarsH\x00R\x06nested\x12\x18\n" +
+	"\x06number\x18\n" +
+	" \x01(\x05H\x00R\x06number\x129\n" +
+	"\n" +
+	"created_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x127\n" +
+	"\n" +
+	"attributes\x18\f \x01(\v2\x17.google.protobuf.StructR\n" +
+	"attributes\x12(\n" +
+	"\x05owner\x18\r \x01(\v2\x12.test.complex.UserR\x05owner\x12>\n" +
+	"\x05inner\x18\x0e \x01(\v2(.test.generated.GeneratedContainer.InnerR\x05inner\x1a\x1b\n" +
+	"\x05Inner\x12\x12\n" +
+	"\x04note\x18\x01 \x01(\tR\x04note\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1aY\n" +
+	"\tByIdEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x05R\x03key\x126\n" +
+	"\x05value\x18\x02 \x01(\v2 .test.generated.GeneratedScalarsR\x05value:\x028\x01\x1a8\n" +
+	"\n" +
+	"FlagsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\bR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1aY\n" +
+	"\vColorsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x04R\x03key\x124\n" +
+	"\x05value\x18\x02 \x01(\x0e2\x1e.test.generated.GeneratedColorR\x05value:\x028\x01B\b\n" +
+	"\x06choice*e\n" +
+	"\x0eGeneratedColor\x12\x1f\n" +
+	"\x1bGENERATED_COLOR_UNSPECIFIED\x10\x00\x12\x17\n" +
+	"\x13GENERATED_COLOR_RED\x10\x01\x12\x19\n" +
+	"\x15GENERATED_COLOR_GREEN\x10\x02B\xa1\x01\n" +
+	"\x12com.test.generatedB\x0eGeneratedProtoP\x01Z\"github.com/wreulicke/protojson/gen\xa2\x02\x03TGX\xaa\x02\x0eTest.Generated\xca\x02\x0eTest\\Generated\xe2\x02\x1aTest\\Generated\\GPBMetadata\xea\x02\x0fTest::Generatedb\x06proto3"
+
+var (
+	file_generated_proto_rawDescOnce sync.Once
+	file_generated_proto_rawDescData []byte
+)
+
+func file_generated_proto_rawDescGZIP() []byte {
+	file_generated_proto_rawDescOnce.Do(func() {
+		file_generated_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_generated_proto_rawDesc), len(file_generated_proto_rawDesc)))
+	})
+	return file_generated_proto_rawDescData
+}
+
+var file_generated_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_generated_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_generated_proto_goTypes = []any{
+	(GeneratedColor)(0),              // 0: test.generated.GeneratedColor
+	(*GeneratedScalars)(nil),         // 1: test.generated.GeneratedScalars
+	(*GeneratedContainer)(nil),       // 2: test.generated.GeneratedContainer
+	(*GeneratedContainer_Inner)(nil), // 3: test.generated.GeneratedContainer.Inner
+	nil,                              // 4: test.generated.GeneratedContainer.LabelsEntry
+	nil,                              // 5: test.generated.GeneratedContainer.ByIdEntry
+	nil,                              // 6: test.generated.GeneratedContainer.FlagsEntry
+	nil,                              // 7: test.generated.GeneratedContainer.ColorsEntry
+	(*timestamppb.Timestamp)(nil),    // 8: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),          // 9: google.protobuf.Struct
+	(*User)(nil),                     // 10: test.complex.User
+}
+var file_generated_proto_depIdxs = []int32{
+	0,  // 0: test.generated.GeneratedScalars.color:type_name -> test.generated.GeneratedColor
+	0,  // 1: test.generated.GeneratedScalars.optional_color:type_name -> test.generated.GeneratedColor
+	0,  // 2: test.generated.GeneratedScalars.colors:type_name -> test.generated.GeneratedColor
+	3,  // 3: test.generated.GeneratedScalars.optional_inner:type_name -> test.generated.GeneratedContainer.Inner
+	1,  // 4: test.generated.GeneratedContainer.scalars:type_name -> test.generated.GeneratedScalars
+	1,  // 5: test.generated.GeneratedContainer.items:type_name -> test.generated.GeneratedScalars
+	4,  // 6: test.generated.GeneratedContainer.labels:type_name -> test.generated.GeneratedContainer.LabelsEntry
+	5,  // 7: test.generated.GeneratedContainer.by_id:type_name -> test.generated.GeneratedContainer.ByIdEntry
+	6,  // 8: test.generated.GeneratedContainer.flags:type_name -> test.generated.GeneratedContainer.FlagsEntry
+	7,  // 9: test.generated.GeneratedContainer.colors:type_name -> test.generated.GeneratedContainer.ColorsEntry
+	1,  // 10: test.generated.GeneratedContainer.nested:type_name -> test.generated.GeneratedScalars
+	8,  // 11: test.generated.GeneratedContainer.created_at:type_name -> google.protobuf.Timestamp
+	9,  // 12: test.generated.GeneratedContainer.attributes:type_name -> google.protobuf.Struct
+	10, // 13: test.generated.GeneratedContainer.owner:type_name -> test.complex.User
+	3,  // 14: test.generated.GeneratedContainer.inner:type_name -> test.generated.GeneratedContainer.Inner
+	1,  // 15: test.generated.GeneratedContainer.ByIdEntry.value:type_name -> test.generated.GeneratedScalars
+	0,  // 16: test.generated.GeneratedContainer.ColorsEntry.value:type_name -> test.generated.GeneratedColor
+	17, // [17:17] is the sub-list for method output_type
+	17, // [17:17] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
+}
+
+func init() { file_generated_proto_init() }
+func file_generated_proto_init() {
+	if File_generated_proto != nil {
+		return
+	}
+	file_complex_proto_init()
+	file_generated_proto_msgTypes[0].OneofWrappers = []any{}
+	file_generated_proto_msgTypes[1].OneofWrappers = []any{
+		(*GeneratedContainer_Text)(nil),
+		(*GeneratedContainer_Nested)(nil),
+		(*GeneratedContainer_Number)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_generated_proto_rawDesc), len(file_generated_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_generated_proto_goTypes,
+		DependencyIndexes: file_generated_proto_depIdxs,
+		EnumInfos:         file_generated_proto_enumTypes,
+		MessageInfos:      file_generated_proto_msgTypes,
+	}.Build()
+	File_generated_proto = out.File
+	file_generated_proto_goTypes = nil
+	file_generated_proto_depIdxs = nil
+}
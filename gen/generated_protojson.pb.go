@@ -0,0 +1,372 @@
+// Code generated by protoc-gen-protojson. DO NOT EDIT.
+// source: generated.proto
+
+package gen
+
+import (
+	fmt "fmt"
+	protojson "github.com/wreulicke/protojson"
+	maps "maps"
+	math "math"
+	slices "slices"
+)
+
+// MarshalProtoJSON writes x in JSON format to w.
+func (x *GeneratedScalars) MarshalProtoJSON(w *protojson.Writer) error {
+	w.BeginObject()
+	if x == nil {
+		w.EndObject()
+		return nil
+	}
+	first := true
+	if x.StringField != "" {
+		w.WriteName(first, "stringField", "string_field")
+		first = false
+		if err := w.WriteString(x.StringField); err != nil {
+			return fmt.Errorf("field test.generated.GeneratedScalars.string_field: %w", err)
+		}
+	}
+	if x.Int32Field != 0 {
+		w.WriteName(first, "int32Field", "int32_field")
+		first = false
+		w.WriteInt32(x.Int32Field)
+	}
+	if x.Int64Field != 0 {
+		w.WriteName(first, "int64Field", "int64_field")
+		first = false
+		w.WriteInt64(x.Int64Field)
+	}
+	if x.Uint32Field != 0 {
+		w.WriteName(first, "uint32Field", "uint32_field")
+		first = false
+		w.WriteUint32(x.Uint32Field)
+	}
+	if x.Uint64Field != 0 {
+		w.WriteName(first, "uint64Field", "uint64_field")
+		first = false
+		w.WriteUint64(x.Uint64Field)
+	}
+	if x.Sint32Field != 0 {
+		w.WriteName(first, "sint32Field", "sint32_field")
+		first = false
+		w.WriteInt32(x.Sint32Field)
+	}
+	if x.Sint64Field != 0 {
+		w.WriteName(first, "sint64Field", "sint64_field")
+		first = false
+		w.WriteInt64(x.Sint64Field)
+	}
+	if x.Fixed32Field != 0 {
+		w.WriteName(first, "fixed32Field", "fixed32_field")
+		first = false
+		w.WriteUint32(x.Fixed32Field)
+	}
+	if x.Fixed64Field != 0 {
+		w.WriteName(first, "fixed64Field", "fixed64_field")
+		first = false
+		w.WriteUint64(x.Fixed64Field)
+	}
+	if x.Sfixed32Field != 0 {
+		w.WriteName(first, "sfixed32Field", "sfixed32_field")
+		first = false
+		w.WriteInt32(x.Sfixed32Field)
+	}
+	if x.Sfixed64Field != 0 {
+		w.WriteName(first, "sfixed64Field", "sfixed64_field")
+		first = false
+		w.WriteInt64(x.Sfixed64Field)
+	}
+	if x.BoolField {
+		w.WriteName(first, "boolField", "bool_field")
+		first = false
+		w.WriteBool(x.BoolField)
+	}
+	if math.Float32bits(x.FloatField) != 0 {
+		w.WriteName(first, "floatField", "float_field")
+		first = false
+		if err := w.WriteFloat32(x.FloatField); err != nil {
+			return fmt.Errorf("field test.generated.GeneratedScalars.float_field: %w", err)
+		}
+	}
+	if math.Float64bits(x.DoubleField) != 0 {
+		w.WriteName(first, "doubleField", "double_field")
+		first = false
+		if err := w.WriteFloat64(x.DoubleField); err != nil {
+			return fmt.Errorf("field test.generated.GeneratedScalars.double_field: %w", err)
+		}
+	}
+	if len(x.BytesField) > 0 {
+		w.WriteName(first, "bytesField", "bytes_field")
+		first = false
+		w.WriteBytes(x.BytesField)
+	}
+	if x.Color != 0 {
+		w.WriteName(first, "color", "color")
+		first = false
+		w.WriteEnum(int32(x.Color), GeneratedColor_name[int32(x.Color)])
+	}
+	if x.OptionalString != nil {
+		w.WriteName(first, "optionalString", "optional_string")
+		first = false
+		if err := w.WriteString(*x.OptionalString); err != nil {
+			return fmt.Errorf("field test.generated.GeneratedScalars.optional_string: %w", err)
+		}
+	}
+	if x.OptionalInt64 != nil {
+		w.WriteName(first, "optionalInt64", "optional_int64")
+		first = false
+		w.WriteInt64(*x.OptionalInt64)
+	}
+	if x.OptionalColor != nil {
+		w.WriteName(first, "optionalColor", "optional_color")
+		first = false
+		w.WriteEnum(int32(*x.OptionalColor), GeneratedColor_name[int32(*x.OptionalColor)])
+	}
+	if len(x.Int64List) > 0 {
+		w.WriteName(first, "int64List", "int64_list")
+		first = false
+		w.BeginArray()
+		for i, v := range x.Int64List {
+			if i > 0 {
+				w.WriteComma()
+			}
+			w.WriteInt64(v)
+		}
+		w.EndArray()
+	}
+	if len(x.FloatList) > 0 {
+		w.WriteName(first, "floatList", "float_list")
+		first = false
+		w.BeginArray()
+		for i, v := range x.FloatList {
+			if i > 0 {
+				w.WriteComma()
+			}
+			if err := w.WriteFloat32(v); err != nil {
+				return fmt.Errorf("field test.generated.GeneratedScalars.float_list: %w", err)
+			}
+		}
+		w.EndArray()
+	}
+	if len(x.BytesList) > 0 {
+		w.WriteName(first, "bytesList", "bytes_list")
+		first = false
+		w.BeginArray()
+		for i, v := range x.BytesList {
+			if i > 0 {
+				w.WriteComma()
+			}
+			w.WriteBytes(v)
+		}
+		w.EndArray()
+	}
+	if len(x.Colors) > 0 {
+		w.WriteName(first, "colors", "colors")
+		first = false
+		w.BeginArray()
+		for i, v := range x.Colors {
+			if i > 0 {
+				w.WriteComma()
+			}
+			w.WriteEnum(int32(v), GeneratedColor_name[int32(v)])
+		}
+		w.EndArray()
+	}
+	if x.CustomNamed != "" {
+		w.WriteName(first, "renamed", "custom_named")
+		first = false
+		if err := w.WriteString(x.CustomNamed); err != nil {
+			return fmt.Errorf("field test.generated.GeneratedScalars.custom_named: %w", err)
+		}
+	}
+	if x.OptionalBytes != nil {
+		w.WriteName(first, "optionalBytes", "optional_bytes")
+		first = false
+		w.WriteBytes(x.OptionalBytes)
+	}
+	if x.OptionalInner != nil {
+		w.WriteName(first, "optionalInner", "optional_inner")
+		first = false
+		if err := w.WriteMessage(x.OptionalInner); err != nil {
+			return err
+		}
+	}
+	w.EndObject()
+	return nil
+}
+
+// MarshalProtoJSON writes x in JSON format to w.
+func (x *GeneratedContainer) MarshalProtoJSON(w *protojson.Writer) error {
+	w.BeginObject()
+	if x == nil {
+		w.EndObject()
+		return nil
+	}
+	first := true
+	if x.Name != "" {
+		w.WriteName(first, "name", "name")
+		first = false
+		if err := w.WriteString(x.Name); err != nil {
+			return fmt.Errorf("field test.generated.GeneratedContainer.name: %w", err)
+		}
+	}
+	if x.Scalars != nil {
+		w.WriteName(first, "scalars", "scalars")
+		first = false
+		if err := w.WriteMessage(x.Scalars); err != nil {
+			return err
+		}
+	}
+	if len(x.Items) > 0 {
+		w.WriteName(first, "items", "items")
+		first = false
+		w.BeginArray()
+		for i, v := range x.Items {
+			if i > 0 {
+				w.WriteComma()
+			}
+			if err := w.WriteMessage(v); err != nil {
+				return err
+			}
+		}
+		w.EndArray()
+	}
+	if len(x.Labels) > 0 {
+		w.WriteName(first, "labels", "labels")
+		first = false
+		w.BeginObject()
+		for i, k := range slices.Sorted(maps.Keys(x.Labels)) {
+			v := x.Labels[k]
+			if i > 0 {
+				w.WriteComma()
+			}
+			if err := w.WriteString(k); err != nil {
+				return fmt.Errorf("map key of field test.generated.GeneratedContainer.labels: %w", err)
+			}
+			w.WriteColon()
+			if err := w.WriteString(v); err != nil {
+				return fmt.Errorf("field test.generated.GeneratedContainer.LabelsEntry.value: %w", err)
+			}
+		}
+		w.EndObject()
+	}
+	if len(x.ById) > 0 {
+		w.WriteName(first, "byId", "by_id")
+		first = false
+		w.BeginObject()
+		for i, k := range slices.Sorted(maps.Keys(x.ById)) {
+			v := x.ById[k]
+			if i > 0 {
+				w.WriteComma()
+			}
+			w.WriteIntKey(int64(k))
+			w.WriteColon()
+			if err := w.WriteMessage(v); err != nil {
+				return err
+			}
+		}
+		w.EndObject()
+	}
+	if len(x.Flags) > 0 {
+		w.WriteName(first, "flags", "flags")
+		first = false
+		w.BeginObject()
+		n := 0
+		for _, k := range [2]bool{false, true} {
+			v, ok := x.Flags[k]
+			if !ok {
+				continue
+			}
+			if n > 0 {
+				w.WriteComma()
+			}
+			n++
+			w.WriteBoolKey(k)
+			w.WriteColon()
+			w.WriteInt64(v)
+		}
+		w.EndObject()
+	}
+	if len(x.Colors) > 0 {
+		w.WriteName(first, "colors", "colors")
+		first = false
+		w.BeginObject()
+		for i, k := range slices.Sorted(maps.Keys(x.Colors)) {
+			v := x.Colors[k]
+			if i > 0 {
+				w.WriteComma()
+			}
+			w.WriteUintKey(uint64(k))
+			w.WriteColon()
+			w.WriteEnum(int32(v), GeneratedColor_name[int32(v)])
+		}
+		w.EndObject()
+	}
+	switch v := x.Choice.(type) {
+	case *GeneratedContainer_Text:
+		w.WriteName(first, "text", "text")
+		first = false
+		if err := w.WriteString(v.Text); err != nil {
+			return fmt.Errorf("field test.generated.GeneratedContainer.text: %w", err)
+		}
+	case *GeneratedContainer_Nested:
+		w.WriteName(first, "nested", "nested")
+		first = false
+		if err := w.WriteMessage(v.Nested); err != nil {
+			return err
+		}
+	case *GeneratedContainer_Number:
+		w.WriteName(first, "number", "number")
+		first = false
+		w.WriteInt32(v.Number)
+	}
+	if x.CreatedAt != nil {
+		w.WriteName(first, "createdAt", "created_at")
+		first = false
+		if err := w.WriteMessage(x.CreatedAt); err != nil {
+			return err
+		}
+	}
+	if x.Attributes != nil {
+		w.WriteName(first, "attributes", "attributes")
+		first = false
+		if err := w.WriteMessage(x.Attributes); err != nil {
+			return err
+		}
+	}
+	if x.Owner != nil {
+		w.WriteName(first, "owner", "owner")
+		first = false
+		if err := w.WriteMessage(x.Owner); err != nil {
+			return err
+		}
+	}
+	if x.Inner != nil {
+		w.WriteName(first, "inner", "inner")
+		first = false
+		if err := w.WriteMessage(x.Inner); err != nil {
+			return err
+		}
+	}
+	w.EndObject()
+	return nil
+}
+
+// MarshalProtoJSON writes x in JSON format to w.
+func (x *GeneratedContainer_Inner) MarshalProtoJSON(w *protojson.Writer) error {
+	w.BeginObject()
+	if x == nil {
+		w.EndObject()
+		return nil
+	}
+	first := true
+	if x.Note != "" {
+		w.WriteName(first, "note", "note")
+		first = false
+		if err := w.WriteString(x.Note); err != nil {
+			return fmt.Errorf("field test.generated.GeneratedContainer.Inner.note: %w", err)
+		}
+	}
+	w.EndObject()
+	return nil
+}
@@ -292,6 +292,75 @@ func (x *EmptyMaps) GetEmptyMessageMap() map[string]*Value {
 	return nil
 }
 
+// TypedKeyMaps tests ordering of non-string map keys
+type TypedKeyMaps struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BoolKeyMap    map[bool]string        `protobuf:"bytes,1,rep,name=bool_key_map,json=boolKeyMap,proto3" json:"bool_key_map,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Int64KeyMap   map[int64]string       `protobuf:"bytes,2,rep,name=int64_key_map,json=int64KeyMap,proto3" json:"int64_key_map,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Uint32KeyMap  map[uint32]string      `protobuf:"bytes,3,rep,name=uint32_key_map,json=uint32KeyMap,proto3" json:"uint32_key_map,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Sint32KeyMap  map[int32]string       `protobuf:"bytes,4,rep,name=sint32_key_map,json=sint32KeyMap,proto3" json:"sint32_key_map,omitempty" protobuf_key:"zigzag32,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TypedKeyMaps) Reset() {
+	*x = TypedKeyMaps{}
+	mi := &file_maps_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TypedKeyMaps) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TypedKeyMaps) ProtoMessage() {}
+
+func (x *TypedKeyMaps) ProtoReflect() protoreflect.Message {
+	mi := &file_maps_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TypedKeyMaps.ProtoReflect.Descriptor instead.
+func (*TypedKeyMaps) Descriptor() ([]byte, []int) {
+	return file_maps_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TypedKeyMaps) GetBoolKeyMap() map[bool]string {
+	if x != nil {
+		return x.BoolKeyMap
+	}
+	return nil
+}
+
+func (x *TypedKeyMaps) GetInt64KeyMap() map[int64]string {
+	if x != nil {
+		return x.Int64KeyMap
+	}
+	return nil
+}
+
+func (x *TypedKeyMaps) GetUint32KeyMap() map[uint32]string {
+	if x != nil {
+		return x.Uint32KeyMap
+	}
+	return nil
+}
+
+func (x *TypedKeyMaps) GetSint32KeyMap() map[int32]string {
+	if x != nil {
+		return x.Sint32KeyMap
+	}
+	return nil
+}
+
 var File_maps_proto protoreflect.FileDescriptor
 
 const file_maps_proto_rawDesc = "" +
@@ -344,7 +413,25 @@ const file_maps_proto_rawDesc = "" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1aT\n" +
 	"\x14EmptyMessageMapEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12&\n" +
-	"\x05value\x18\x02 \x01(\v2\x10.test.maps.ValueR\x05value:\x028\x01B\x83\x01\n" +
+	"\x05value\x18\x02 \x01(\v2\x10.test.maps.ValueR\x05value:\x028\x01\"\xca\x04\n" +
+	"\fTypedKeyMaps\x12I\n" +
+	"\fbool_key_map\x18\x01 \x03(\v2'.test.maps.TypedKeyMaps.BoolKeyMapEntryR\n" +
+	"boolKeyMap\x12L\n" +
+	"\rint64_key_map\x18\x02 \x03(\v2(.test.maps.TypedKeyMaps.Int64KeyMapEntryR\vint64KeyMap\x12O\n" +
+	"\x0euint32_key_map\x18\x03 \x03(\v2).test.maps.TypedKeyMaps.Uint32KeyMapEntryR\fuint32KeyMap\x12O\n" +
+	"\x0esint32_key_map\x18\x04 \x03(\v2).test.maps.TypedKeyMaps.Sint32KeyMapEntryR\fsint32KeyMap\x1a=\n" +
+	"\x0fBoolKeyMapEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\bR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a>\n" +
+	"\x10Int64KeyMapEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a?\n" +
+	"\x11Uint32KeyMapEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\rR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a?\n" +
+	"\x11Sint32KeyMapEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x11R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x83\x01\n" +
 	"\rcom.test.mapsB\tMapsProtoP\x01Z\"github.com/wreulicke/protojson/gen\xa2\x02\x03TMX\xaa\x02\tTest.Maps\xca\x02\tTest\\Maps\xe2\x02\x15Test\\Maps\\GPBMetadata\xea\x02\n" +
 	"Test::Mapsb\x06proto3"
 
@@ -360,41 +447,50 @@ func file_maps_proto_rawDescGZIP() []byte {
 	return file_maps_proto_rawDescData
 }
 
-var file_maps_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_maps_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
 var file_maps_proto_goTypes = []any{
-	(*MapFields)(nil),  // 0: test.maps.MapFields
-	(*Value)(nil),      // 1: test.maps.Value
-	(*NestedMaps)(nil), // 2: test.maps.NestedMaps
-	(*InnerMap)(nil),   // 3: test.maps.InnerMap
-	(*EmptyMaps)(nil),  // 4: test.maps.EmptyMaps
-	nil,                // 5: test.maps.MapFields.StringMapEntry
-	nil,                // 6: test.maps.MapFields.IntMapEntry
-	nil,                // 7: test.maps.MapFields.BoolMapEntry
-	nil,                // 8: test.maps.MapFields.IntKeyMapEntry
-	nil,                // 9: test.maps.MapFields.MessageMapEntry
-	nil,                // 10: test.maps.NestedMaps.OuterMapEntry
-	nil,                // 11: test.maps.InnerMap.InnerEntry
-	nil,                // 12: test.maps.EmptyMaps.EmptyStringMapEntry
-	nil,                // 13: test.maps.EmptyMaps.EmptyMessageMapEntry
+	(*MapFields)(nil),    // 0: test.maps.MapFields
+	(*Value)(nil),        // 1: test.maps.Value
+	(*NestedMaps)(nil),   // 2: test.maps.NestedMaps
+	(*InnerMap)(nil),     // 3: test.maps.InnerMap
+	(*EmptyMaps)(nil),    // 4: test.maps.EmptyMaps
+	(*TypedKeyMaps)(nil), // 5: test.maps.TypedKeyMaps
+	nil,                  // 6: test.maps.MapFields.StringMapEntry
+	nil,                  // 7: test.maps.MapFields.IntMapEntry
+	nil,                  // 8: test.maps.MapFields.BoolMapEntry
+	nil,                  // 9: test.maps.MapFields.IntKeyMapEntry
+	nil,                  // 10: test.maps.MapFields.MessageMapEntry
+	nil,                  // 11: test.maps.NestedMaps.OuterMapEntry
+	nil,                  // 12: test.maps.InnerMap.InnerEntry
+	nil,                  // 13: test.maps.EmptyMaps.EmptyStringMapEntry
+	nil,                  // 14: test.maps.EmptyMaps.EmptyMessageMapEntry
+	nil,                  // 15: test.maps.TypedKeyMaps.BoolKeyMapEntry
+	nil,                  // 16: test.maps.TypedKeyMaps.Int64KeyMapEntry
+	nil,                  // 17: test.maps.TypedKeyMaps.Uint32KeyMapEntry
+	nil,                  // 18: test.maps.TypedKeyMaps.Sint32KeyMapEntry
 }
 var file_maps_proto_depIdxs = []int32{
-	5,  // 0: test.maps.MapFields.string_map:type_name -> test.maps.MapFields.StringMapEntry
-	6,  // 1: test.maps.MapFields.int_map:type_name -> test.maps.MapFields.IntMapEntry
-	7,  // 2: test.maps.MapFields.bool_map:type_name -> test.maps.MapFields.BoolMapEntry
-	8,  // 3: test.maps.MapFields.int_key_map:type_name -> test.maps.MapFields.IntKeyMapEntry
-	9,  // 4: test.maps.MapFields.message_map:type_name -> test.maps.MapFields.MessageMapEntry
-	10, // 5: test.maps.NestedMaps.outer_map:type_name -> test.maps.NestedMaps.OuterMapEntry
-	11, // 6: test.maps.InnerMap.inner:type_name -> test.maps.InnerMap.InnerEntry
-	12, // 7: test.maps.EmptyMaps.empty_string_map:type_name -> test.maps.EmptyMaps.EmptyStringMapEntry
-	13, // 8: test.maps.EmptyMaps.empty_message_map:type_name -> test.maps.EmptyMaps.EmptyMessageMapEntry
-	1,  // 9: test.maps.MapFields.MessageMapEntry.value:type_name -> test.maps.Value
-	3,  // 10: test.maps.NestedMaps.OuterMapEntry.value:type_name -> test.maps.InnerMap
-	1,  // 11: test.maps.EmptyMaps.EmptyMessageMapEntry.value:type_name -> test.maps.Value
-	12, // [12:12] is the sub-list for method output_type
-	12, // [12:12] is the sub-list for method input_type
-	12, // [12:12] is the sub-list for extension type_name
-	12, // [12:12] is the sub-list for extension extendee
-	0,  // [0:12] is the sub-list for field type_name
+	6,  // 0: test.maps.MapFields.string_map:type_name -> test.maps.MapFields.StringMapEntry
+	7,  // 1: test.maps.MapFields.int_map:type_name -> test.maps.MapFields.IntMapEntry
+	8,  // 2: test.maps.MapFields.bool_map:type_name -> test.maps.MapFields.BoolMapEntry
+	9,  // 3: test.maps.MapFields.int_key_map:type_name -> test.maps.MapFields.IntKeyMapEntry
+	10, // 4: test.maps.MapFields.message_map:type_name -> test.maps.MapFields.MessageMapEntry
+	11, // 5: test.maps.NestedMaps.outer_map:type_name -> test.maps.NestedMaps.OuterMapEntry
+	12, // 6: test.maps.InnerMap.inner:type_name -> test.maps.InnerMap.InnerEntry
+	13, // 7: test.maps.EmptyMaps.empty_string_map:type_name -> test.maps.EmptyMaps.EmptyStringMapEntry
+	14, // 8: test.maps.EmptyMaps.empty_message_map:type_name -> test.maps.EmptyMaps.EmptyMessageMapEntry
+	15, // 9: test.maps.TypedKeyMaps.bool_key_map:type_name -> test.maps.TypedKeyMaps.BoolKeyMapEntry
+	16, // 10: test.maps.TypedKeyMaps.int64_key_map:type_name -> test.maps.TypedKeyMaps.Int64KeyMapEntry
+	17, // 11: test.maps.TypedKeyMaps.uint32_key_map:type_name -> test.maps.TypedKeyMaps.Uint32KeyMapEntry
+	18, // 12: test.maps.TypedKeyMaps.sint32_key_map:type_name -> test.maps.TypedKeyMaps.Sint32KeyMapEntry
+	1,  // 13: test.maps.MapFields.MessageMapEntry.value:type_name -> test.maps.Value
+	3,  // 14: test.maps.NestedMaps.OuterMapEntry.value:type_name -> test.maps.InnerMap
+	1,  // 15: test.maps.EmptyMaps.EmptyMessageMapEntry.value:type_name -> test.maps.Value
+	16, // [16:16] is the sub-list for method output_type
+	16, // [16:16] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
 }
 
 func init() { file_maps_proto_init() }
@@ -408,7 +504,7 @@ func file_maps_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_maps_proto_rawDesc), len(file_maps_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   14,
+			NumMessages:   19,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,356 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: proto2.proto
+
+package gen
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// RequiredFields tests proto2 required fields
+type RequiredFields struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          *string                `protobuf:"bytes,1,req,name=name" json:"name,omitempty"`
+	Id            *int32                 `protobuf:"varint,2,req,name=id" json:"id,omitempty"`
+	Note          *string                `protobuf:"bytes,3,opt,name=note" json:"note,omitempty"`
+	Child         *RequiredChild         `protobuf:"bytes,4,opt,name=child" json:"child,omitempty"`
+	Children      []*RequiredChild       `protobuf:"bytes,5,rep,name=children" json:"children,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequiredFields) Reset() {
+	*x = RequiredFields{}
+	mi := &file_proto2_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequiredFields) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequiredFields) ProtoMessage() {}
+
+func (x *RequiredFields) ProtoReflect() protoreflect.Message {
+	mi := &file_proto2_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequiredFields.ProtoReflect.Descriptor instead.
+func (*RequiredFields) Descriptor() ([]byte, []int) {
+	return file_proto2_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RequiredFields) GetName() string {
+	if x != nil && x.Name != nil {
+		return *x.Name
+	}
+	return ""
+}
+
+func (x *RequiredFields) GetId() int32 {
+	if x != nil && x.Id != nil {
+		return *x.Id
+	}
+	return 0
+}
+
+func (x *RequiredFields) GetNote() string {
+	if x != nil && x.Note != nil {
+		return *x.Note
+	}
+	return ""
+}
+
+func (x *RequiredFields) GetChild() *RequiredChild {
+	if x != nil {
+		return x.Child
+	}
+	return nil
+}
+
+func (x *RequiredFields) GetChildren() []*RequiredChild {
+	if x != nil {
+		return x.Children
+	}
+	return nil
+}
+
+// RequiredChild tests required fields in nested messages
+type RequiredChild struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         *string                `protobuf:"bytes,1,req,name=value" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequiredChild) Reset() {
+	*x = RequiredChild{}
+	mi := &file_proto2_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequiredChild) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequiredChild) ProtoMessage() {}
+
+func (x *RequiredChild) ProtoReflect() protoreflect.Message {
+	mi := &file_proto2_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequiredChild.ProtoReflect.Descriptor instead.
+func (*RequiredChild) Descriptor() ([]byte, []int) {
+	return file_proto2_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RequiredChild) GetValue() string {
+	if x != nil && x.Value != nil {
+		return *x.Value
+	}
+	return ""
+}
+
+// Extendable tests proto2 extension fields
+type Extendable struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Name            *string                `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	extensionFields protoimpl.ExtensionFields
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Extendable) Reset() {
+	*x = Extendable{}
+	mi := &file_proto2_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Extendable) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Extendable) ProtoMessage() {}
+
+func (x *Extendable) ProtoReflect() protoreflect.Message {
+	mi := &file_proto2_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Extendable.ProtoReflect.Descriptor instead.
+func (*Extendable) Descriptor() ([]byte, []int) {
+	return file_proto2_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Extendable) GetName() string {
+	if x != nil && x.Name != nil {
+		return *x.Name
+	}
+	return ""
+}
+
+// ExtensionScope tests extensions declared inside a message
+type ExtensionScope struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExtensionScope) Reset() {
+	*x = ExtensionScope{}
+	mi := &file_proto2_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtensionScope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtensionScope) ProtoMessage() {}
+
+func (x *ExtensionScope) ProtoReflect() protoreflect.Message {
+	mi := &file_proto2_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtensionScope.ProtoReflect.Descriptor instead.
+func (*ExtensionScope) Descriptor() ([]byte, []int) {
+	return file_proto2_proto_rawDescGZIP(), []int{3}
+}
+
+var file_proto2_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*Extendable)(nil),
+		ExtensionType: (*int32)(nil),
+		Field:         100,
+		Name:          "test.proto2.ext_count",
+		Tag:           "varint,100,opt,name=ext_count",
+		Filename:      "proto2.proto",
+	},
+	{
+		ExtendedType:  (*Extendable)(nil),
+		ExtensionType: (*string)(nil),
+		Field:         101,
+		Name:          "test.proto2.ext_label",
+		Tag:           "bytes,101,opt,name=ext_label",
+		Filename:      "proto2.proto",
+	},
+	{
+		ExtendedType:  (*Extendable)(nil),
+		ExtensionType: ([]string)(nil),
+		Field:         102,
+		Name:          "test.proto2.ext_tags",
+		Tag:           "bytes,102,rep,name=ext_tags",
+		Filename:      "proto2.proto",
+	},
+	{
+		ExtendedType:  (*Extendable)(nil),
+		ExtensionType: (*RequiredChild)(nil),
+		Field:         110,
+		Name:          "test.proto2.ExtensionScope.child",
+		Tag:           "bytes,110,opt,name=child",
+		Filename:      "proto2.proto",
+	},
+}
+
+// Extension fields to Extendable.
+var (
+	// optional int32 ext_count = 100;
+	E_ExtCount = &file_proto2_proto_extTypes[0]
+	// optional string ext_label = 101;
+	E_ExtLabel = &file_proto2_proto_extTypes[1]
+	// repeated string ext_tags = 102;
+	E_ExtTags = &file_proto2_proto_extTypes[2]
+	// optional test.proto2.RequiredChild child = 110;
+	E_ExtensionScope_Child = &file_proto2_proto_extTypes[3]
+)
+
+var File_proto2_proto protoreflect.FileDescriptor
+
+const file_proto2_proto_rawDesc = "" +
+	"\n" +
+	"\fproto2.proto\x12\vtest.proto2\"\xb2\x01\n" +
+	"\x0eRequiredFields\x12\x12\n" +
+	"\x04name\x18\x01 \x02(\tR\x04name\x12\x0e\n" +
+	"\x02id\x18\x02 \x02(\x05R\x02id\x12\x12\n" +
+	"\x04note\x18\x03 \x01(\tR\x04note\x120\n" +
+	"\x05child\x18\x04 \x01(\v2\x1a.test.proto2.RequiredChildR\x05child\x126\n" +
+	"\bchildren\x18\x05 \x03(\v2\x1a.test.proto2.RequiredChildR\bchildren\"%\n" +
+	"\rRequiredChild\x12\x14\n" +
+	"\x05value\x18\x01 \x02(\tR\x05value\"'\n" +
+	"\n" +
+	"Extendable\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name*\x05\bd\x10\xc8\x01\"[\n" +
+	"\x0eExtensionScope2I\n" +
+	"\x05child\x12\x17.test.proto2.Extendable\x18n \x01(\v2\x1a.test.proto2.RequiredChildR\x05child:4\n" +
+	"\text_count\x12\x17.test.proto2.Extendable\x18d \x01(\x05R\bextCount:4\n" +
+	"\text_label\x12\x17.test.proto2.Extendable\x18e \x01(\tR\bextLabel:2\n" +
+	"\bext_tags\x12\x17.test.proto2.Extendable\x18f \x03(\tR\aextTagsB\x8f\x01\n" +
+	"\x0fcom.test.proto2B\vProto2ProtoP\x01Z\"github.com/wreulicke/protojson/gen\xa2\x02\x03TPX\xaa\x02\vTest.Proto2\xca\x02\vTest\\Proto2\xe2\x02\x17Test\\Proto2\\GPBMetadata\xea\x02\fTest::Proto2"
+
+var (
+	file_proto2_proto_rawDescOnce sync.Once
+	file_proto2_proto_rawDescData []byte
+)
+
+func file_proto2_proto_rawDescGZIP() []byte {
+	file_proto2_proto_rawDescOnce.Do(func() {
+		file_proto2_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto2_proto_rawDesc), len(file_proto2_proto_rawDesc)))
+	})
+	return file_proto2_proto_rawDescData
+}
+
+var file_proto2_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_proto2_proto_goTypes = []any{
+	(*RequiredFields)(nil), // 0: test.proto2.RequiredFields
+	(*RequiredChild)(nil),  // 1: test.proto2.RequiredChild
+	(*Extendable)(nil),     // 2: test.proto2.Extendable
+	(*ExtensionScope)(nil), // 3: test.proto2.ExtensionScope
+}
+var file_proto2_proto_depIdxs = []int32{
+	1, // 0: test.proto2.RequiredFields.child:type_name -> test.proto2.RequiredChild
+	1, // 1: test.proto2.RequiredFields.children:type_name -> test.proto2.RequiredChild
+	2, // 2: test.proto2.ext_count:extendee -> test.proto2.Extendable
+	2, // 3: test.proto2.ext_label:extendee -> test.proto2.Extendable
+	2, // 4: test.proto2.ext_tags:extendee -> test.proto2.Extendable
+	2, // 5: test.proto2.ExtensionScope.child:extendee -> test.proto2.Extendable
+	1, // 6: test.proto2.ExtensionScope.child:type_name -> test.proto2.RequiredChild
+	7, // [7:7] is the sub-list for method output_type
+	7, // [7:7] is the sub-list for method input_type
+	6, // [6:7] is the sub-list for extension type_name
+	2, // [2:6] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_proto2_proto_init() }
+func file_proto2_proto_init() {
+	if File_proto2_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto2_proto_rawDesc), len(file_proto2_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 4,
+			NumServices:   0,
+		},
+		GoTypes:           file_proto2_proto_goTypes,
+		DependencyIndexes: file_proto2_proto_depIdxs,
+		MessageInfos:      file_proto2_proto_msgTypes,
+		ExtensionInfos:    file_proto2_proto_extTypes,
+	}.Build()
+	File_proto2_proto = out.File
+	file_proto2_proto_goTypes = nil
+	file_proto2_proto_depIdxs = nil
+}
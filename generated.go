@@ -0,0 +1,224 @@
+package protojson
+
+import (
+	"reflect"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Marshaler is implemented by messages with encoding code generated by
+// protoc-gen-protojson (see cmd/protoc-gen-protojson). The encoder calls
+// MarshalProtoJSON instead of walking the message with protoreflect when
+// the options only set Resolver, AllowPartial, UseProtoNames,
+// UseEnumNumbers, UnorderedMaps, MaxOutputBytes, TruncateOutput, Canonical,
+// Flatten, options for formatting scalar values, such as Int64AsNumber,
+// FloatFormat or BytesEncoding, or options for formatting well-known and
+// other types that are written through WriteMessage. With any other option
+// set, such as Indent or a masking option, the message is written with
+// protoreflect.
+type Marshaler interface {
+	MarshalProtoJSON(w *Writer) error
+}
+
+// Writer writes JSON values for generated MarshalProtoJSON methods. Its
+// methods apply the options of the encoder that calls MarshalProtoJSON.
+// It is not intended to be used by hand-written code.
+type Writer struct {
+	e *encoder
+}
+
+// BeginObject writes the start of a JSON object.
+func (w *Writer) BeginObject() {
+	w.e.w.WriteByte('{')
+}
+
+// EndObject writes the end of a JSON object.
+func (w *Writer) EndObject() {
+	w.e.w.WriteByte('}')
+}
+
+// BeginArray writes the start of a JSON array.
+func (w *Writer) BeginArray() {
+	w.e.w.WriteByte('[')
+}
+
+// EndArray writes the end of a JSON array.
+func (w *Writer) EndArray() {
+	w.e.w.WriteByte(']')
+}
+
+// WriteComma writes the separator between array elements or object
+// members.
+func (w *Writer) WriteComma() {
+	w.e.w.WriteByte(',')
+}
+
+// WriteColon writes the separator between an object key and its value.
+func (w *Writer) WriteColon() {
+	w.e.w.WriteByte(':')
+}
+
+// WriteName writes the name of a field as an object key followed by a
+// colon, preceded by a comma unless first is true. jsonName is used unless
+// UseProtoNames is set.
+func (w *Writer) WriteName(first bool, jsonName, protoName string) {
+	if !first {
+		w.e.w.WriteByte(',')
+	}
+	w.e.w.WriteByte('"')
+	if w.e.opts.UseProtoNames {
+		w.e.w.WriteString(protoName)
+	} else {
+		w.e.w.WriteString(jsonName)
+	}
+	w.e.w.WriteString(`":`)
+}
+
+// WriteBool writes a bool value.
+func (w *Writer) WriteBool(v bool) {
+	if v {
+		w.e.w.WriteString("true")
+	} else {
+		w.e.w.WriteString("false")
+	}
+}
+
+// WriteInt32 writes a 32-bit signed integer value.
+func (w *Writer) WriteInt32(v int32) {
+	w.e.w.Write(strconv.AppendInt(w.e.buf[:0], int64(v), 10))
+}
+
+// WriteUint32 writes a 32-bit unsigned integer value.
+func (w *Writer) WriteUint32(v uint32) {
+	w.e.w.Write(strconv.AppendUint(w.e.buf[:0], uint64(v), 10))
+}
+
+// WriteInt64 writes a 64-bit signed integer value, as a string unless
+// Int64AsNumber is set.
+func (w *Writer) WriteInt64(v int64) {
+	w.writeQuoted(strconv.AppendInt(w.e.buf[:0], v, 10), !w.e.opts.Int64AsNumber)
+}
+
+// WriteUint64 writes a 64-bit unsigned integer value, as a string unless
+// Int64AsNumber is set.
+func (w *Writer) WriteUint64(v uint64) {
+	w.writeQuoted(strconv.AppendUint(w.e.buf[:0], v, 10), !w.e.opts.Int64AsNumber)
+}
+
+// WriteFloat32 writes a float value.
+func (w *Writer) WriteFloat32(v float32) error {
+	return w.e.marshalFloat(float64(v), 32)
+}
+
+// WriteFloat64 writes a double value.
+func (w *Writer) WriteFloat64(v float64) error {
+	return w.e.marshalFloat(v, 64)
+}
+
+// WriteString writes a string value.
+func (w *Writer) WriteString(v string) error {
+	return w.e.marshalString(v)
+}
+
+// WriteBytes writes a bytes value.
+func (w *Writer) WriteBytes(v []byte) {
+	w.e.marshalBytes(v, "")
+}
+
+// WriteEnum writes an enum value as its name, or as its number if name is
+// empty because the number is not declared or if UseEnumNumbers is set.
+func (w *Writer) WriteEnum(n int32, name string) {
+	if name == "" || w.e.opts.UseEnumNumbers {
+		w.WriteInt32(n)
+		return
+	}
+	w.e.w.WriteByte('"')
+	w.e.w.WriteString(name)
+	w.e.w.WriteByte('"')
+}
+
+// WriteNull writes null, the value of google.protobuf.NullValue fields.
+func (w *Writer) WriteNull() {
+	w.e.w.WriteString("null")
+}
+
+// WriteMessage writes a message value, using its generated
+// MarshalProtoJSON method if it has one.
+func (w *Writer) WriteMessage(m proto.Message) error {
+	return w.e.marshalMessage(m.ProtoReflect())
+}
+
+// WriteIntKey writes a signed integer map key.
+func (w *Writer) WriteIntKey(k int64) {
+	w.writeQuoted(strconv.AppendInt(w.e.buf[:0], k, 10), true)
+}
+
+// WriteUintKey writes an unsigned integer map key.
+func (w *Writer) WriteUintKey(k uint64) {
+	w.writeQuoted(strconv.AppendUint(w.e.buf[:0], k, 10), true)
+}
+
+// WriteBoolKey writes a bool map key.
+func (w *Writer) WriteBoolKey(k bool) {
+	if k {
+		w.e.w.WriteString(`"true"`)
+	} else {
+		w.e.w.WriteString(`"false"`)
+	}
+}
+
+// writeQuoted writes b, enclosed in quotes if quote is set
+func (w *Writer) writeQuoted(b []byte, quote bool) {
+	if quote {
+		w.e.w.WriteByte('"')
+	}
+	w.e.w.Write(b)
+	if quote {
+		w.e.w.WriteByte('"')
+	}
+}
+
+// useGenerated reports whether generated MarshalProtoJSON methods honor
+// the options of e, computing it on first use
+func (e *encoder) useGenerated() bool {
+	if e.generated == 0 {
+		e.generated = -1
+		if generatedOptions(e.opts) {
+			e.generated = 1
+		}
+	}
+	return e.generated > 0
+}
+
+// generatedOptions reports whether o only sets options that generated
+// MarshalProtoJSON methods honor
+func generatedOptions(o MarshalOptions) bool {
+	o.Resolver = nil
+	o.AllowPartial = false
+	o.UseProtoNames = false
+	o.UseEnumNumbers = false
+	o.AllowInvalidUTF8 = false
+	o.UnorderedMaps = false
+	o.TimestampPrecision = 0
+	o.FloatDecimalPoint = false
+	o.NonFinitePolicy = NonFiniteString
+	o.Int64AsNumber = false
+	o.EscapeLineSeparators = false
+	o.ASCIIOnly = false
+	o.FloatFormat = FloatFormatStandard
+	o.TimestampFormat = TimestampRFC3339
+	o.TimestampEpochAsString = false
+	o.DurationFormat = DurationSeconds
+	o.BytesEncoding = BytesBase64
+	o.UnresolvedAny = UnresolvedAnyError
+	o.Formatters = nil
+	o.GoogleTypes = false
+	o.MoneyFormat = MoneyObject
+	o.MaxOutputBytes = 0
+	o.TruncateOutput = false
+	o.Cache = nil
+	o.Canonical = false
+	o.Flatten = false
+	return reflect.ValueOf(&o).Elem().IsZero()
+}
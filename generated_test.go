@@ -0,0 +1,166 @@
+package protojson_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// generatedContainer returns a message with generated MarshalProtoJSON
+// methods that sets every kind of field
+func generatedContainer(t *testing.T) *pb_basic.GeneratedContainer {
+	t.Helper()
+	attrs, err := structpb.NewStruct(map[string]any{"tier": "gold"})
+	if err != nil {
+		t.Fatalf("structpb.NewStruct() error = %v", err)
+	}
+	scalars := &pb_basic.GeneratedScalars{
+		StringField:   "héllo \"quoted\"\n",
+		Int32Field:    -32,
+		Int64Field:    -1 << 40,
+		Uint32Field:   32,
+		Uint64Field:   1 << 63,
+		Sint32Field:   -7,
+		Sint64Field:   -8,
+		Fixed32Field:  9,
+		Fixed64Field:  10,
+		Sfixed32Field: -11,
+		Sfixed64Field: -12,
+		BoolField:     true,
+		FloatField:    1.5,
+		DoubleField:   math.Inf(-1),
+		BytesField:    []byte{0xff, 0x00, 'a'},
+		Color:         pb_basic.GeneratedColor_GENERATED_COLOR_RED,
+		OptionalInt64: proto.Int64(0),
+		OptionalColor: pb_basic.GeneratedColor(7).Enum(),
+		Int64List:     []int64{1, -2},
+		FloatList:     []float32{0.25, float32(math.NaN())},
+		BytesList:     [][]byte{[]byte("x"), nil},
+		Colors:        []pb_basic.GeneratedColor{pb_basic.GeneratedColor_GENERATED_COLOR_GREEN, 9},
+		CustomNamed:   "custom",
+		OptionalBytes: []byte{},
+		OptionalInner: &pb_basic.GeneratedContainer_Inner{Note: "optional"},
+	}
+	return &pb_basic.GeneratedContainer{
+		Name:       "container",
+		Scalars:    scalars,
+		Items:      []*pb_basic.GeneratedScalars{{StringField: "a"}, {DoubleField: math.Copysign(0, -1)}},
+		Labels:     map[string]string{"b": "2", "a": "1", "é": "3"},
+		ById:       map[int32]*pb_basic.GeneratedScalars{10: {Int32Field: 10}, -1: {}, 2: {BoolField: true}},
+		Flags:      map[bool]int64{true: 1, false: 0},
+		Colors:     map[uint64]pb_basic.GeneratedColor{1 << 40: 1, 3: 2},
+		Choice:     &pb_basic.GeneratedContainer_Nested{Nested: &pb_basic.GeneratedScalars{OptionalString: proto.String("")}},
+		CreatedAt:  timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)),
+		Attributes: attrs,
+		Owner:      &pb_basic.User{Id: "1", Role: pb_basic.Role_ROLE_ADMIN, Metadata: map[string]string{"k": "v"}},
+		Inner:      &pb_basic.GeneratedContainer_Inner{Note: "note"},
+	}
+}
+
+// TestGeneratedMarshaler tests that generated MarshalProtoJSON methods
+// write what the encoder writes with protoreflect, for options they honor
+// and options that make the encoder fall back to protoreflect
+func TestGeneratedMarshaler(t *testing.T) {
+	msg := generatedContainer(t)
+	dm := toDynamic(t, msg)
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+	}{
+		{name: "default"},
+		{name: "proto names", opts: protojson.MarshalOptions{UseProtoNames: true}},
+		{name: "enum numbers", opts: protojson.MarshalOptions{UseEnumNumbers: true}},
+		{name: "int64 as number", opts: protojson.MarshalOptions{Int64AsNumber: true}},
+		{name: "float format", opts: protojson.MarshalOptions{FloatFormat: protojson.FloatFormatShortest, FloatDecimalPoint: true, NonFinitePolicy: protojson.NonFiniteNull}},
+		{name: "bytes encoding", opts: protojson.MarshalOptions{BytesEncoding: protojson.BytesHex}},
+		{name: "ASCII only", opts: protojson.MarshalOptions{ASCIIOnly: true}},
+		{name: "timestamp format", opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixMillis}},
+		{name: "max output bytes", opts: protojson.MarshalOptions{MaxOutputBytes: 1 << 20}},
+		{name: "indent", opts: protojson.MarshalOptions{Indent: "  "}},
+		{name: "emit unpopulated", opts: protojson.MarshalOptions{EmitUnpopulated: true}},
+		{
+			name: "field mask func",
+			opts: protojson.MarshalOptions{FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "name" }},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want, got bytes.Buffer
+			if err := protojson.NewEncoderWithOptions(&want, tt.opts).EncodeReflect(dm); err != nil {
+				t.Fatalf("EncodeReflect() error = %v", err)
+			}
+			if err := protojson.NewEncoderWithOptions(&got, tt.opts).Encode(msg); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if diff := cmp.Diff(want.String(), got.String()); diff != "" {
+				t.Errorf("Encode() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestGeneratedMarshalerCompatibility tests generated MarshalProtoJSON
+// methods against the standard package
+func TestGeneratedMarshalerCompatibility(t *testing.T) {
+	msg := generatedContainer(t)
+	want, err := stdMarshal(stdprotojson.MarshalOptions{}, msg)
+	if err != nil {
+		t.Fatalf("stdMarshal() error = %v", err)
+	}
+	got, err := protojson.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestGeneratedMarshalerErrors tests errors returned by generated
+// MarshalProtoJSON methods
+func TestGeneratedMarshalerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     proto.Message
+		opts    protojson.MarshalOptions
+		wantErr string
+	}{
+		{
+			name:    "invalid UTF-8",
+			msg:     &pb_basic.GeneratedContainer{Scalars: &pb_basic.GeneratedScalars{StringField: "\xff"}},
+			wantErr: "field test.generated.GeneratedScalars.string_field: invalid UTF-8 in string",
+		},
+		{
+			name:    "invalid UTF-8 map key",
+			msg:     &pb_basic.GeneratedContainer{Labels: map[string]string{"\xff": "v"}},
+			wantErr: "map key of field test.generated.GeneratedContainer.labels: invalid UTF-8 in string",
+		},
+		{
+			name:    "non-finite float",
+			msg:     &pb_basic.GeneratedScalars{FloatList: []float32{float32(math.Inf(1))}},
+			opts:    protojson.MarshalOptions{NonFinitePolicy: protojson.NonFiniteError},
+			wantErr: "field test.generated.GeneratedScalars.float_list: non-finite float: +Inf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.opts.Marshal(tt.msg)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Marshal() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package protojson
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// googleTypeFormatter returns the formatter for the google.type message
+// named name if options select a friendly form for it, or nil
+func (e *encoder) googleTypeFormatter(name protoreflect.FullName) func(*encoder, protoreflect.Message) error {
+	if name == "google.type.Money" {
+		if e.opts.MoneyFormat == MoneyString {
+			return (*encoder).marshalMoney
+		}
+		return nil
+	}
+	if !e.opts.GoogleTypes {
+		return nil
+	}
+	switch name {
+	case "google.type.Date":
+		return (*encoder).marshalDate
+	case "google.type.TimeOfDay":
+		return (*encoder).marshalTimeOfDay
+	case "google.type.LatLng":
+		return (*encoder).marshalLatLng
+	case "google.type.Color":
+		return (*encoder).marshalColor
+	}
+	return nil
+}
+
+// int32Field returns the value of the int32 field of m named name
+func int32Field(m protoreflect.Message, name protoreflect.Name) int64 {
+	return m.Get(m.Descriptor().Fields().ByName(name)).Int()
+}
+
+// floatField returns the value of the float or double field of m named name
+func floatField(m protoreflect.Message, name protoreflect.Name) float64 {
+	return m.Get(m.Descriptor().Fields().ByName(name)).Float()
+}
+
+// marshalDate marshals google.type.Date as an ISO 8601 date: "2024-05-01",
+// or "2024-05", "2024" or "--05-01" for partial dates
+func (e *encoder) marshalDate(m protoreflect.Message) error {
+	year, month, day := int32Field(m, "year"), int32Field(m, "month"), int32Field(m, "day")
+
+	b := append(e.buf[:0], '"')
+	switch {
+	case year > 0 && month > 0 && day > 0:
+		b = appendZeroPadded(b, year, 4)
+		b = append(b, '-')
+		b = appendZeroPadded(b, month, 2)
+		b = append(b, '-')
+		b = appendZeroPadded(b, day, 2)
+	case year > 0 && month > 0 && day == 0:
+		b = appendZeroPadded(b, year, 4)
+		b = append(b, '-')
+		b = appendZeroPadded(b, month, 2)
+	case year > 0 && month == 0 && day == 0:
+		b = appendZeroPadded(b, year, 4)
+	case year == 0 && month > 0 && day > 0:
+		b = append(b, '-', '-')
+		b = appendZeroPadded(b, month, 2)
+		b = append(b, '-')
+		b = appendZeroPadded(b, day, 2)
+	default:
+		return fmt.Errorf("%s: invalid date %d-%d-%d", m.Descriptor().FullName(), year, month, day)
+	}
+	e.w.Write(append(b, '"'))
+	return nil
+}
+
+// marshalTimeOfDay marshals google.type.TimeOfDay as "13:45:30", with
+// fractional seconds like google.protobuf.Timestamp
+func (e *encoder) marshalTimeOfDay(m protoreflect.Message) error {
+	hours, minutes := int32Field(m, "hours"), int32Field(m, "minutes")
+	seconds, nanos := int32Field(m, "seconds"), int32Field(m, "nanos")
+	if hours < 0 || hours > 24 || minutes < 0 || minutes > 59 || seconds < 0 || seconds > 60 || nanos < 0 || nanos > 999999999 {
+		return fmt.Errorf("%s: invalid time %d:%d:%d.%d", m.Descriptor().FullName(), hours, minutes, seconds, nanos)
+	}
+
+	b := append(e.buf[:0], '"')
+	b = appendZeroPadded(b, hours, 2)
+	b = append(b, ':')
+	b = appendZeroPadded(b, minutes, 2)
+	b = append(b, ':')
+	b = appendZeroPadded(b, seconds, 2)
+	if digits := fractionDigits(nanos); digits > 0 {
+		b = append(b, '.')
+		b = appendZeroPadded(b, nanos, 9)[:len(b)+digits]
+	}
+	e.w.Write(append(b, '"'))
+	return nil
+}
+
+// marshalMoney marshals google.type.Money as a decimal amount with at least
+// two fractional digits followed by the currency code, e.g. "12.34 USD"
+func (e *encoder) marshalMoney(m protoreflect.Message) error {
+	fields := m.Descriptor().Fields()
+	currency := m.Get(fields.ByName("currency_code")).String()
+	units := m.Get(fields.ByName("units")).Int()
+	nanos := m.Get(fields.ByName("nanos")).Int()
+	if nanos <= -1e9 || nanos >= 1e9 || units > 0 && nanos < 0 || units < 0 && nanos > 0 {
+		return fmt.Errorf("%s: invalid amount of %d units and %d nanos", m.Descriptor().FullName(), units, nanos)
+	}
+
+	b := e.buf[:0]
+	if units < 0 || nanos < 0 {
+		b = append(b, '-')
+	}
+	if units < 0 {
+		b = strconv.AppendUint(b, uint64(-units), 10)
+	} else {
+		b = strconv.AppendUint(b, uint64(units), 10)
+	}
+	b = append(b, '.')
+	frac := appendZeroPadded(b, max(nanos, -nanos), 9)
+	n := len(frac)
+	for n > len(b)+2 && frac[n-1] == '0' {
+		n--
+	}
+	b = frac[:n]
+
+	s := string(b)
+	if currency != "" {
+		s += " " + currency
+	}
+	return e.marshalString(s)
+}
+
+// marshalLatLng marshals google.type.LatLng as "latitude,longitude"
+func (e *encoder) marshalLatLng(m protoreflect.Message) error {
+	b := append(e.buf[:0], '"')
+	b = strconv.AppendFloat(b, floatField(m, "latitude"), 'f', -1, 64)
+	b = append(b, ',')
+	b = strconv.AppendFloat(b, floatField(m, "longitude"), 'f', -1, 64)
+	e.w.Write(append(b, '"'))
+	return nil
+}
+
+// marshalColor marshals google.type.Color as a CSS hex color, "#rrggbb" or
+// "#rrggbbaa" if alpha is set
+func (e *encoder) marshalColor(m protoreflect.Message) error {
+	b := append(e.buf[:0], '"', '#')
+	b = appendColorByte(b, floatField(m, "red"))
+	b = appendColorByte(b, floatField(m, "green"))
+	b = appendColorByte(b, floatField(m, "blue"))
+	if fd := m.Descriptor().Fields().ByName("alpha"); m.Has(fd) {
+		alpha := m.Get(fd).Message()
+		b = appendColorByte(b, floatField(alpha, "value"))
+	}
+	e.w.Write(append(b, '"'))
+	return nil
+}
+
+// appendColorByte appends a color component in [0, 1] as two hex digits
+func appendColorByte(b []byte, f float64) []byte {
+	c := uint8(math.Round(min(max(f, 0), 1) * 255))
+	return append(b, hexDigits[c>>4], hexDigits[c&0xf])
+}
@@ -0,0 +1,139 @@
+package protojson_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// TestGoogleTypes tests the friendly forms of google.type messages
+func TestGoogleTypes(t *testing.T) {
+	friendly := protojson.MarshalOptions{GoogleTypes: true}
+
+	tests := []struct {
+		name    string
+		msg     *pb_basic.Appointment
+		opts    protojson.MarshalOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Disabled",
+			msg:  &pb_basic.Appointment{Date: &pb_basic.Date{Year: 2024, Month: 5, Day: 1}},
+			want: `{"date":{"year":2024,"month":5,"day":1}}`,
+		},
+		{
+			name: "Date",
+			msg:  &pb_basic.Appointment{Date: &pb_basic.Date{Year: 2024, Month: 5, Day: 1}},
+			opts: friendly,
+			want: `{"date":"2024-05-01"}`,
+		},
+		{
+			name: "DateYearMonth",
+			msg:  &pb_basic.Appointment{Date: &pb_basic.Date{Year: 2024, Month: 5}},
+			opts: friendly,
+			want: `{"date":"2024-05"}`,
+		},
+		{
+			name: "DateMonthDay",
+			msg:  &pb_basic.Appointment{Date: &pb_basic.Date{Month: 12, Day: 25}},
+			opts: friendly,
+			want: `{"date":"--12-25"}`,
+		},
+		{
+			name:    "DateInvalid",
+			msg:     &pb_basic.Appointment{Date: &pb_basic.Date{Year: 2024, Day: 1}},
+			opts:    friendly,
+			wantErr: true,
+		},
+		{
+			name: "TimeOfDay",
+			msg:  &pb_basic.Appointment{Time: &pb_basic.TimeOfDay{Hours: 9, Minutes: 5, Seconds: 30, Nanos: 500000000}},
+			opts: friendly,
+			want: `{"time":"09:05:30.500"}`,
+		},
+		{
+			name: "LatLng",
+			msg:  &pb_basic.Appointment{Location: &pb_basic.LatLng{Latitude: 37.422, Longitude: -122.084}},
+			opts: friendly,
+			want: `{"location":"37.422,-122.084"}`,
+		},
+		{
+			name: "Color",
+			msg:  &pb_basic.Appointment{Color: &pb_basic.Color{Red: 1, Green: 0.5, Blue: 0}},
+			opts: friendly,
+			want: `{"color":"#ff8000"}`,
+		},
+		{
+			name: "ColorAlpha",
+			msg:  &pb_basic.Appointment{Color: &pb_basic.Color{Red: 1, Alpha: wrapperspb.Float(0.5)}},
+			opts: friendly,
+			want: `{"color":"#ff000080"}`,
+		},
+		{
+			name: "MoneyObject",
+			msg:  &pb_basic.Appointment{Price: &pb_basic.Money{CurrencyCode: "USD", Units: 12, Nanos: 340000000}},
+			opts: friendly,
+			want: `{"price":{"currencyCode":"USD","units":"12","nanos":340000000}}`,
+		},
+		{
+			name: "MoneyString",
+			msg:  &pb_basic.Appointment{Price: &pb_basic.Money{CurrencyCode: "USD", Units: 12, Nanos: 340000000}},
+			opts: protojson.MarshalOptions{MoneyFormat: protojson.MoneyString},
+			want: `{"price":"12.34 USD"}`,
+		},
+		{
+			name: "MoneyStringWhole",
+			msg:  &pb_basic.Appointment{Price: &pb_basic.Money{CurrencyCode: "JPY", Units: 500}},
+			opts: protojson.MarshalOptions{MoneyFormat: protojson.MoneyString},
+			want: `{"price":"500.00 JPY"}`,
+		},
+		{
+			name: "MoneyStringNegative",
+			msg:  &pb_basic.Appointment{Price: &pb_basic.Money{CurrencyCode: "EUR", Nanos: -1000000}},
+			opts: protojson.MarshalOptions{MoneyFormat: protojson.MoneyString},
+			want: `{"price":"-0.001 EUR"}`,
+		},
+		{
+			name:    "MoneyStringMixedSigns",
+			msg:     &pb_basic.Appointment{Price: &pb_basic.Money{Units: 1, Nanos: -1}},
+			opts:    protojson.MarshalOptions{MoneyFormat: protojson.MoneyString},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Marshal() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestGoogleTypesInAny tests that friendly forms nest under "value" in
+// google.protobuf.Any
+func TestGoogleTypesInAny(t *testing.T) {
+	msg := &pb_basic.WellKnownTypes{Any: mustAny(t, &pb_basic.Date{Year: 2024, Month: 5, Day: 1})}
+	got, err := protojson.MarshalOptions{GoogleTypes: true}.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"any":{"@type":"type.googleapis.com/google.type.Date","value":"2024-05-01"}}`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+	}
+}
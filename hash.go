@@ -0,0 +1,42 @@
+package protojson
+
+import (
+	"hash"
+	"io"
+)
+
+// hashWriter writes to the underlying writer of an Encoder and feeds the
+// bytes written to a hash
+type hashWriter struct {
+	w io.Writer
+	h hash.Hash
+}
+
+func (w *hashWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.h.Write(p[:n])
+	return n, err
+}
+
+// SetHash makes the encoder feed h exactly the bytes it writes to the
+// underlying writer, so that a checksum or ETag of the output is available
+// without a second pass over it. Output of an Encode call that fails is
+// not fed to h unless it already reached the writer, e.g. with
+// SetFlushThreshold. The encoder does not reset h; call h.Reset between
+// documents as needed. A nil h stops hashing.
+//
+// While hashing, output is buffered by the encoder even if the underlying
+// writer is already buffered.
+func (e *Encoder) SetHash(h hash.Hash) {
+	e.hasher.h = h
+	e.bind()
+}
+
+// sink returns the writer that the encoder's own buffer flushes to
+func (e *Encoder) sink() io.Writer {
+	if e.hasher.h == nil {
+		return e.out
+	}
+	e.hasher.w = e.out
+	return &e.hasher
+}
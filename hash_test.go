@@ -0,0 +1,101 @@
+package protojson_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestSetHash tests that the hash is fed exactly the bytes written
+func TestSetHash(t *testing.T) {
+	messages := []proto.Message{
+		&pb_basic.BasicTypes{StringField: "first", Int32Field: 1},
+		&pb_basic.BasicTypes{StringField: "second", Int32Field: 2},
+	}
+
+	tests := []struct {
+		name   string
+		writer func(*bytes.Buffer) io.Writer
+		encode func(*protojson.Encoder) error
+	}{
+		{
+			name:   "Unbuffered",
+			writer: func(buf *bytes.Buffer) io.Writer { return struct{ io.Writer }{buf} },
+			encode: func(e *protojson.Encoder) error { return e.Encode(messages[0]) },
+		},
+		{
+			name:   "Buffered",
+			writer: func(buf *bytes.Buffer) io.Writer { return buf },
+			encode: func(e *protojson.Encoder) error { return e.Encode(messages[0]) },
+		},
+		{
+			name:   "NDJSON",
+			writer: func(buf *bytes.Buffer) io.Writer { return buf },
+			encode: func(e *protojson.Encoder) error {
+				e.SetWriteNewline(true)
+				return e.EncodeSeq(slices.Values(messages))
+			},
+		},
+		{
+			name:   "Array",
+			writer: func(buf *bytes.Buffer) io.Writer { return buf },
+			encode: func(e *protojson.Encoder) error { return e.EncodeList(messages) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := sha256.New()
+			encoder := protojson.NewEncoder(tt.writer(&buf))
+			encoder.SetHash(h)
+			if err := tt.encode(encoder); err != nil {
+				t.Fatalf("encode error = %v", err)
+			}
+
+			want := sha256.Sum256(buf.Bytes())
+			if diff := cmp.Diff(want[:], h.Sum(nil)); diff != "" {
+				t.Errorf("hash of %q mismatch (-want +got):\n%s", buf.String(), diff)
+			}
+		})
+	}
+}
+
+// TestSetHashFailedEncode tests that output of a failed Encode call is not
+// hashed, and that a nil hash stops hashing
+func TestSetHashFailedEncode(t *testing.T) {
+	var buf bytes.Buffer
+	h := sha256.New()
+	encoder := protojson.NewEncoderWithOptions(&buf, protojson.MarshalOptions{MaxOutputBytes: 32})
+	encoder.SetHash(h)
+
+	large := &pb_basic.BasicTypes{StringField: strings.Repeat("x", 64)}
+	if err := encoder.Encode(large); !errors.Is(err, protojson.ErrOutputTooLarge) {
+		t.Fatalf("Encode() error = %v, want ErrOutputTooLarge", err)
+	}
+	small := &pb_basic.BasicTypes{Int32Field: 1}
+	if err := encoder.Encode(small); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	encoder.SetHash(nil)
+	if err := encoder.Encode(small); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	if diff := cmp.Diff(`{"int32Field":1}{"int32Field":1}`, buf.String()); diff != "" {
+		t.Errorf("Encode() mismatch (-want +got):\n%s", diff)
+	}
+	want := sha256.Sum256([]byte(`{"int32Field":1}`))
+	if diff := cmp.Diff(want[:], h.Sum(nil)); diff != "" {
+		t.Errorf("hash mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,87 @@
+package protojson
+
+import "google.golang.org/protobuf/reflect/protoreflect"
+
+// Hooks are called while messages are encoded, so that instrumentation
+// such as tracing, auditing or custom validation can observe encoding
+// without wrapping the encoder. Any of them may be nil. They are called
+// synchronously and must not retain the descriptors beyond their use.
+type Hooks struct {
+	// OnMessageStart is called before each message is written, including
+	// messages in fields, list elements and map values.
+	OnMessageStart func(md protoreflect.MessageDescriptor)
+
+	// OnMessageEnd is called after each message OnMessageStart was called
+	// for, with the number of bytes of JSON written for it. It is also
+	// called when writing the message fails, with the bytes written up to
+	// the failure.
+	OnMessageEnd func(md protoreflect.MessageDescriptor, bytesWritten int)
+
+	// OnField is called before the value of each field is written, after
+	// the field was selected for output.
+	OnField func(fd protoreflect.FieldDescriptor)
+}
+
+// enabled reports whether any hook is set
+func (h *Hooks) enabled() bool {
+	return h.OnMessageStart != nil || h.OnMessageEnd != nil || h.OnField != nil
+}
+
+// countWriter counts the bytes written through it to w
+type countWriter struct {
+	w writer
+	n int
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+func (c *countWriter) WriteByte(b byte) error {
+	err := c.w.WriteByte(b)
+	if err == nil {
+		c.n++
+	}
+	return err
+}
+
+func (c *countWriter) WriteString(s string) (int, error) {
+	n, err := c.w.WriteString(s)
+	c.n += n
+	return n, err
+}
+
+// marshalHooked writes m between the OnMessageStart and OnMessageEnd hooks,
+// passing the bytes written for it as the difference of the offsets of the
+// output before and after
+func (e *encoder) marshalHooked(m protoreflect.Message, plan *messagePlan) error {
+	h := &e.opts.Hooks
+	md := m.Descriptor()
+	if h.OnMessageStart != nil {
+		h.OnMessageStart(md)
+	}
+	if h.OnMessageEnd == nil {
+		return e.marshalPlanned(m, plan)
+	}
+
+	start := e.count.n
+	err := e.marshalPlanned(m, plan)
+	h.OnMessageEnd(md, e.count.n-start)
+	return err
+}
+
+// redirect points the output of e at w, returning the previous output to
+// be restored by another call. The counter of the offsets for OnMessageEnd
+// stays in place, so that output captured for post-processing is counted.
+func (e *encoder) redirect(w writer) writer {
+	if e.count == nil {
+		prev := e.w
+		e.w = w
+		return prev
+	}
+	prev := e.count.w
+	e.count.w = w
+	return prev
+}
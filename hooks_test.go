@@ -0,0 +1,145 @@
+package protojson_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// recordHooks returns hooks appending the events they observe to events
+func recordHooks(events *[]string) protojson.Hooks {
+	return protojson.Hooks{
+		OnMessageStart: func(md protoreflect.MessageDescriptor) {
+			*events = append(*events, "start "+string(md.Name()))
+		},
+		OnMessageEnd: func(md protoreflect.MessageDescriptor, n int) {
+			*events = append(*events, fmt.Sprintf("end %s %d", md.Name(), n))
+		},
+		OnField: func(fd protoreflect.FieldDescriptor) {
+			*events = append(*events, "field "+string(fd.Name()))
+		},
+	}
+}
+
+// TestHooks tests the events observed by encoding hooks
+func TestHooks(t *testing.T) {
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		msg  proto.Message
+		want []string
+	}{
+		{
+			name: "Nested",
+			msg:  &pb_basic.Nested{Id: "a", Inner: &pb_basic.Inner{Name: "b", Deep: &pb_basic.DeepInner{}}},
+			want: []string{
+				"start Nested", "field id", "field inner",
+				"start Inner", "field name", "field deep",
+				"start DeepInner", "end DeepInner 2",
+				"end Inner 22",
+				"end Nested 41",
+			},
+		},
+		{
+			name: "List",
+			msg:  &pb_basic.RepeatedNested{People: []*pb_basic.SimplePerson{{Name: "a"}, {Age: 2}}},
+			want: []string{
+				"start RepeatedNested", "field people",
+				"start SimplePerson", "field name", "end SimplePerson 12",
+				"start SimplePerson", "field age", "end SimplePerson 9",
+				"end RepeatedNested 35",
+			},
+		},
+		{
+			name: "WellKnownType",
+			msg:  &pb_basic.WellKnownTypes{Duration: durationpb.New(0)},
+			want: []string{
+				"start WellKnownTypes", "field duration",
+				"start Duration", "end Duration 4",
+				"end WellKnownTypes 17",
+			},
+		},
+		{
+			name: "FieldsLeftOut",
+			opts: protojson.MarshalOptions{ExcludePaths: []string{"inner"}},
+			msg:  &pb_basic.Nested{Id: "a", Inner: &pb_basic.Inner{}},
+			want: []string{"start Nested", "field id", "end Nested 10"},
+		},
+		{
+			name: "Canonical",
+			opts: protojson.MarshalOptions{Canonical: true},
+			msg:  &pb_basic.Nested{Id: "a", Inner: &pb_basic.Inner{Name: "b"}},
+			want: []string{
+				"start Nested", "field id", "field inner",
+				"start Inner", "field name", "end Inner 12",
+				"end Nested 31",
+			},
+		},
+		{
+			name: "Indent",
+			opts: protojson.MarshalOptions{Indent: " "},
+			msg:  &pb_basic.Inner{Name: "b"},
+			want: []string{"start Inner", "field name", "end Inner 16"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []string
+			tt.opts.Hooks = recordHooks(&events)
+			b, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, events); diff != "" {
+				t.Errorf("hook events mismatch (-want +got):\n%s", diff)
+			}
+			if last := tt.want[len(tt.want)-1]; !strings.HasSuffix(last, fmt.Sprintf(" %d", len(b))) {
+				t.Errorf("last event %q does not count the %d bytes of %s", last, len(b), b)
+			}
+		})
+	}
+}
+
+// TestHooksError tests that OnMessageEnd is called when encoding fails
+func TestHooksError(t *testing.T) {
+	var events []string
+	opts := protojson.MarshalOptions{Hooks: recordHooks(&events)}
+	_, err := opts.Marshal(&pb_basic.Nested{Id: "\xff", Inner: &pb_basic.Inner{}})
+	if !errors.Is(err, protojson.ErrInvalidUTF8) {
+		t.Fatalf("Marshal() error = %v, want ErrInvalidUTF8", err)
+	}
+	want := []string{"start Nested", "field id", "end Nested 7"}
+	if diff := cmp.Diff(want, events); diff != "" {
+		t.Errorf("hook events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestHooksCache tests that cached messages are encoded again while hooks
+// are set
+func TestHooksCache(t *testing.T) {
+	inner := &pb_basic.Inner{Name: "b"}
+	cache := protojson.NewMessageCache("test.nested.Inner")
+	var events []string
+	opts := protojson.MarshalOptions{Cache: cache}
+	if _, err := opts.Marshal(inner); err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	opts.Hooks = protojson.Hooks{OnField: func(fd protoreflect.FieldDescriptor) {
+		events = append(events, string(fd.Name()))
+	}}
+	if _, err := opts.Marshal(inner); err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"name"}, events); diff != "" {
+		t.Errorf("hook events mismatch (-want +got):\n%s", diff)
+	}
+}
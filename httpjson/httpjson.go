@@ -0,0 +1,103 @@
+// Package httpjson writes protobuf messages as JSON HTTP responses using
+// github.com/wreulicke/protojson.
+package httpjson
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wreulicke/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType is the Content-Type of the responses written by this package.
+const ContentType = "application/json"
+
+// WriteMessage writes m as the JSON body of a response with the given
+// status code, streaming it to w. The status code and headers are only
+// sent once encoding has produced output, so if an error is returned
+// before that, nothing has been written and the caller can still send an
+// error response.
+func WriteMessage(w http.ResponseWriter, status int, m proto.Message, opts protojson.MarshalOptions) error {
+	rw := &responseWriter{w: w, status: status}
+	if err := protojson.NewEncoderWithOptions(rw, opts).Encode(m); err != nil {
+		return err
+	}
+	rw.writeHeader()
+	return nil
+}
+
+// WriteMessageGzip is like WriteMessage, but compresses the body with gzip
+// if r accepts the gzip content coding.
+func WriteMessageGzip(w http.ResponseWriter, r *http.Request, status int, m proto.Message, opts protojson.MarshalOptions) error {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) {
+		return WriteMessage(w, status, m, opts)
+	}
+
+	rw := &responseWriter{w: w, status: status, gzip: true}
+	gz := gzipPool.Get().(*gzip.Writer)
+	defer gzipPool.Put(gz)
+	gz.Reset(rw)
+
+	if err := protojson.NewEncoderWithOptions(gz, opts).Encode(m); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+var gzipPool = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// responseWriter sends the status code and headers of a response before
+// its first write
+type responseWriter struct {
+	w           http.ResponseWriter
+	status      int
+	gzip        bool
+	wroteHeader bool
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	rw.writeHeader()
+	return rw.w.Write(p)
+}
+
+// writeHeader sends the status code and headers unless already sent
+func (rw *responseWriter) writeHeader() {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+	h := rw.w.Header()
+	h.Set("Content-Type", ContentType)
+	if rw.gzip {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+	}
+	rw.w.WriteHeader(rw.status)
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of r lists gzip
+// with a non-zero quality
+func acceptsGzip(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept-Encoding") {
+		for part := range strings.SplitSeq(value, ",") {
+			coding, params, _ := strings.Cut(part, ";")
+			if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+				continue
+			}
+			name, q, ok := strings.Cut(strings.TrimSpace(params), "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				return true
+			}
+			quality, err := strconv.ParseFloat(strings.TrimSpace(q), 64)
+			return err == nil && quality > 0
+		}
+	}
+	return false
+}
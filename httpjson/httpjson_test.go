@@ -0,0 +1,110 @@
+package httpjson_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"github.com/wreulicke/protojson/httpjson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TestWriteMessage tests writing a message as a JSON response
+func TestWriteMessage(t *testing.T) {
+	rec := httptest.NewRecorder()
+	opts := protojson.MarshalOptions{
+		FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "email" },
+	}
+	msg := &pb_basic.User{Name: "alice", Email: "alice@example.com"}
+	if err := httpjson.WriteMessage(rec, http.StatusCreated, msg, opts); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if got := rec.Header().Get("Content-Type"); got != httpjson.ContentType {
+		t.Errorf("Content-Type = %q, want %q", got, httpjson.ContentType)
+	}
+	if diff := cmp.Diff(`{"name":"alice","email":"***"}`, rec.Body.String()); diff != "" {
+		t.Errorf("body mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestWriteMessageError tests that nothing is written when encoding fails
+func TestWriteMessageError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	msg := &pb_basic.BasicTypes{StringField: "\xff"}
+	if err := httpjson.WriteMessage(rec, http.StatusOK, msg, protojson.MarshalOptions{}); err == nil {
+		t.Fatalf("WriteMessage() error = nil, want error")
+	}
+	if rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+		t.Errorf("response written on error: headers %v, body %q", rec.Header(), rec.Body.String())
+	}
+
+	// The caller can still write an error response
+	http.Error(rec, "encoding failed", http.StatusInternalServerError)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+// TestWriteMessageGzip tests compressing responses for clients accepting gzip
+func TestWriteMessageGzip(t *testing.T) {
+	msg := &pb_basic.User{Name: "alice"}
+	want := `{"name":"alice"}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{name: "NoHeader"},
+		{name: "Gzip", acceptEncoding: "gzip, deflate", wantGzip: true},
+		{name: "GzipQuality", acceptEncoding: "br;q=1.0, gzip;q=0.8", wantGzip: true},
+		{name: "GzipRefused", acceptEncoding: "gzip;q=0, deflate"},
+		{name: "OtherCoding", acceptEncoding: "br"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			if err := httpjson.WriteMessageGzip(rec, req, http.StatusOK, msg, protojson.MarshalOptions{}); err != nil {
+				t.Fatalf("WriteMessageGzip() error = %v", err)
+			}
+
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+			body := rec.Body.String()
+			if tt.wantGzip {
+				if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+					t.Fatalf("Content-Encoding = %q, want gzip", got)
+				}
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("gzip.NewReader() error = %v", err)
+				}
+				b, err := io.ReadAll(zr)
+				if err != nil {
+					t.Fatalf("reading gzip body: %v", err)
+				}
+				body = string(b)
+			} else if got := rec.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("Content-Encoding = %q, want none", got)
+			}
+			if diff := cmp.Diff(want, body); diff != "" {
+				t.Errorf("body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package protojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// writeCanonical runs write with its output captured, then writes the
+// output canonicalized per RFC 8785, the JSON Canonicalization Scheme
+func (e *encoder) writeCanonical(write func() error) error {
+	var buf bytes.Buffer
+	w := e.redirect(&buf)
+	err := write()
+	e.redirect(w)
+	if err != nil {
+		return err
+	}
+
+	v, err := decodeJSON(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("canonicalizing output: %w", err)
+	}
+	return e.writeCanonicalValue(v)
+}
+
+// writeCanonicalValue writes a value decoded by decodeJSON in canonical
+// form: object members sorted by key, no whitespace, numbers formatted like
+// ECMAScript and strings with minimal escaping
+func (e *encoder) writeCanonicalValue(v any) error {
+	switch v := v.(type) {
+	case nil:
+		e.w.WriteString("null")
+	case bool:
+		e.w.WriteString(strconv.FormatBool(v))
+	case string:
+		return e.marshalString(v)
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return fmt.Errorf("canonicalizing number %s: %w", v, err)
+		}
+		e.w.Write(appendCanonicalNumber(e.buf[:0], f))
+	case []any:
+		e.w.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				e.w.WriteByte(',')
+			}
+			if err := e.writeCanonicalValue(elem); err != nil {
+				return err
+			}
+		}
+		e.w.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.SortFunc(keys, compareUTF16)
+
+		e.w.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				e.w.WriteByte(',')
+			}
+			if err := e.marshalString(k); err != nil {
+				return err
+			}
+			e.w.WriteByte(':')
+			if err := e.writeCanonicalValue(v[k]); err != nil {
+				return err
+			}
+		}
+		e.w.WriteByte('}')
+	default:
+		return fmt.Errorf("canonicalizing unexpected value %T", v)
+	}
+	return nil
+}
+
+// appendCanonicalNumber appends f formatted like ECMAScript's
+// Number.prototype.toString, as RFC 8785 requires
+func appendCanonicalNumber(b []byte, f float64) []byte {
+	if f == 0 {
+		return append(b, '0') // Also for -0
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// Go writes e-07 where ECMAScript writes e-7
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+// compareUTF16 orders strings by their UTF-16 code units, as RFC 8785
+// sorts object members
+func compareUTF16(a, b string) int {
+	for a != "" && b != "" {
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+		if ra != rb {
+			return compareUnits(ra, rb)
+		}
+		a, b = a[na:], b[nb:]
+	}
+	return len(a) - len(b)
+}
+
+// compareUnits compares the UTF-16 encodings of two different runes
+func compareUnits(a, b rune) int {
+	a1, a2 := utf16.EncodeRune(a)
+	if a1 == utf8.RuneError {
+		a1, a2 = a, 0
+	}
+	b1, b2 := utf16.EncodeRune(b)
+	if b1 == utf8.RuneError {
+		b1, b2 = b, 0
+	}
+	if a1 != b1 {
+		return int(a1 - b1)
+	}
+	return int(a2 - b2)
+}
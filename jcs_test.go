@@ -0,0 +1,149 @@
+package protojson_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// TestCanonical tests output canonicalized per RFC 8785
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "SortedFields",
+			msg: &pb_basic.BasicTypes{
+				StringField: "s",
+				Int32Field:  1,
+				BoolField:   true,
+				DoubleField: 1.5,
+			},
+			want: `{"boolField":true,"doubleField":1.5,"int32Field":1,"stringField":"s"}`,
+		},
+		{
+			name: "ProtoNames",
+			msg:  &pb_basic.BasicTypes{StringField: "s", Int32Field: 1, BoolField: true},
+			opts: protojson.MarshalOptions{UseProtoNames: true},
+			want: `{"bool_field":true,"int32_field":1,"string_field":"s"}`,
+		},
+		{
+			name: "IgnoresIndent",
+			msg:  &pb_basic.RepeatedFields{Strings: []string{"b", "a"}, Numbers: []int32{2, 1}},
+			opts: protojson.MarshalOptions{Indent: "  "},
+			want: `{"numbers":[2,1],"strings":["b","a"]}`,
+		},
+		{
+			name: "MapKeys",
+			msg:  &pb_basic.MapFields{IntKeyMap: map[int32]string{10: "ten", 9: "nine", -1: "minus"}},
+			want: `{"intKeyMap":{"-1":"minus","10":"ten","9":"nine"}}`,
+		},
+		{
+			// Keys from RFC 8785, section 3.2.3
+			name: "UTF16KeyOrder",
+			msg: mustStruct(t, map[string]any{
+				"\u20ac":     "Euro Sign",
+				"\r":         "Carriage Return",
+				"\ufb33":     "Hebrew Letter Dalet With Dagesh",
+				"1":          "One",
+				"\U0001f600": "Emoji: Grinning Face",
+				"\u0080":     "Control",
+				"\u00f6":     "Latin Small Letter O With Diaeresis",
+			}),
+			want: "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001f600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		},
+		{
+			name: "Escaping",
+			msg:  &pb_basic.BasicTypes{StringField: "\u2028\"\\\n\x01\u00e9"},
+			opts: protojson.MarshalOptions{ASCIIOnly: true, EscapeLineSeparators: true},
+			want: "{\"stringField\":\"\u2028\\\"\\\\\\n\\u0001\u00e9\"}",
+		},
+		{
+			name: "Int64AsNumber",
+			msg:  &pb_basic.BasicTypes{Int64Field: 1 << 60},
+			opts: protojson.MarshalOptions{Int64AsNumber: true},
+			want: `{"int64Field":1152921504606847000}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Canonical = true
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestCanonicalNumbers tests number formatting from RFC 8785, appendix B
+func TestCanonicalNumbers(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{math.Copysign(0, -1), "0"},
+		{5e-324, "5e-324"},
+		{-1.7976931348623157e308, "-1.7976931348623157e+308"},
+		{9007199254740992, "9007199254740992"},
+		{-9007199254740992, "-9007199254740992"},
+		{295147905179352830000, "295147905179352830000"},
+		{1e21, "1e+21"},
+		{1e-7, "1e-7"},
+		{0.000001, "0.000001"},
+		{333333333.3333333, "333333333.3333333"},
+		{1e23, "1e+23"},
+		{4.5, "4.5"},
+		{2e-3, "0.002"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got, err := protojson.MarshalOptions{Canonical: true}.Marshal(structpb.NewNumberValue(tt.value))
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal(%v) mismatch (-want +got):\n%s", tt.value, diff)
+			}
+		})
+	}
+}
+
+// mustStruct returns a google.protobuf.Struct holding v
+func mustStruct(t *testing.T, v map[string]any) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(v)
+	if err != nil {
+		t.Fatalf("structpb.NewStruct() error = %v", err)
+	}
+	return s
+}
+
+// TestCanonicalDiff tests that MarshalDiff honors Canonical
+func TestCanonicalDiff(t *testing.T) {
+	base := &pb_basic.BasicTypes{StringField: "a", Int32Field: 1}
+	current := &pb_basic.BasicTypes{StringField: "b", Int32Field: 2, BoolField: true}
+
+	got, err := protojson.MarshalOptions{Canonical: true, Indent: "  "}.MarshalDiff(base, current)
+	if err != nil {
+		t.Fatalf("MarshalDiff() error = %v", err)
+	}
+	want := `{"boolField":true,"int32Field":2,"stringField":"b"}`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("MarshalDiff() mismatch (-want +got):\n%s", diff)
+	}
+}
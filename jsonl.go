@@ -0,0 +1,137 @@
+package protojson
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrorPolicy selects how a JSONLEncoder handles messages that cannot be
+// encoded.
+type ErrorPolicy int
+
+const (
+	// ErrorAbort returns the error from Encode. Records written before are
+	// kept.
+	ErrorAbort ErrorPolicy = iota
+	// ErrorSkip leaves the message out of the output, counts it as skipped
+	// and lets Encode succeed, so that one bad record does not stop an
+	// export.
+	ErrorSkip
+)
+
+// JSONLEncoder writes messages as JSON Lines: one JSON object per line,
+// each terminated by a newline. It is meant for batch export jobs writing
+// many messages to a file. Output is buffered and written in full records
+// only, so a message that fails to encode never leaves a partial line.
+// Call Flush when done.
+type JSONLEncoder struct {
+	bw  *bufio.Writer
+	out io.Writer
+	rec bytes.Buffer // Output of the record being encoded
+	enc *Encoder
+
+	flushEvery int
+	pending    int // Records written since the last flush
+	policy     ErrorPolicy
+	onSkip     func(m proto.Message, err error)
+
+	records int64
+	skipped int64
+	err     error // First write error, returned by all later calls
+}
+
+// NewJSONLEncoder returns a new JSON Lines encoder that writes to w using
+// the provided MarshalOptions. Indent and Multiline are ignored, since
+// each record must fit on a single line.
+func NewJSONLEncoder(w io.Writer, opts MarshalOptions) *JSONLEncoder {
+	opts.Indent = ""
+	opts.Multiline = false
+	e := &JSONLEncoder{bw: bufio.NewWriter(w), out: w}
+	e.enc = NewEncoderWithOptions(&e.rec, opts)
+	return e
+}
+
+// SetFlushEvery makes the encoder flush its output, and the underlying
+// writer if it has a Flush method, after every n records. A value of 0, the
+// default, flushes only when the internal buffer is full or Flush is
+// called.
+func (e *JSONLEncoder) SetFlushEvery(n int) {
+	e.flushEvery = max(n, 0)
+}
+
+// SetErrorPolicy sets how messages that cannot be encoded are handled.
+// With ErrorSkip, onSkip, if non-nil, is called with each skipped message
+// and its error. Errors writing to the underlying writer always abort.
+func (e *JSONLEncoder) SetErrorPolicy(p ErrorPolicy, onSkip func(m proto.Message, err error)) {
+	e.policy = p
+	e.onSkip = onSkip
+}
+
+// Encode writes m as the next record. Once writing to the underlying
+// writer has failed, Encode returns that error.
+func (e *JSONLEncoder) Encode(m proto.Message) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	e.rec.Reset()
+	if err := e.enc.Encode(m); err != nil {
+		if e.policy != ErrorSkip {
+			return err
+		}
+		e.skipped++
+		if e.onSkip != nil {
+			e.onSkip(m, err)
+		}
+		return nil
+	}
+	e.rec.WriteByte('\n')
+
+	if _, err := e.bw.Write(e.rec.Bytes()); err != nil {
+		e.err = err
+		return err
+	}
+	e.records++
+	e.pending++
+	if e.flushEvery > 0 && e.pending >= e.flushEvery {
+		return e.Flush()
+	}
+	return nil
+}
+
+// Flush writes any buffered records to the underlying writer, and flushes
+// the underlying writer too if it has a Flush method.
+func (e *JSONLEncoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	e.pending = 0
+	if err := e.bw.Flush(); err != nil {
+		e.err = err
+		return err
+	}
+	switch w := e.out.(type) {
+	case interface{ Flush() error }:
+		if err := w.Flush(); err != nil {
+			e.err = err
+			return err
+		}
+	case interface{ Flush() }:
+		w.Flush()
+	}
+	return nil
+}
+
+// Records returns the number of records written so far, including records
+// still buffered.
+func (e *JSONLEncoder) Records() int64 {
+	return e.records
+}
+
+// Skipped returns the number of messages skipped under ErrorSkip.
+func (e *JSONLEncoder) Skipped() int64 {
+	return e.skipped
+}
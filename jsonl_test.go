@@ -0,0 +1,130 @@
+package protojson_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestJSONLEncoder tests writing records, flushing every N records and
+// the error policies
+func TestJSONLEncoder(t *testing.T) {
+	good := func(n int32) proto.Message { return &pb_basic.BasicTypes{Int32Field: n} }
+	bad := &pb_basic.BasicTypes{StringField: "bad\xff"}
+
+	tests := []struct {
+		name        string
+		policy      protojson.ErrorPolicy
+		flushEvery  int
+		msgs        []proto.Message
+		want        string
+		wantRecords int64
+		wantSkipped int64
+		wantErr     bool
+		wantFlushes []int
+	}{
+		{
+			name:        "Records",
+			msgs:        []proto.Message{good(1), good(2), good(3)},
+			want:        "{\"int32Field\":1}\n{\"int32Field\":2}\n{\"int32Field\":3}\n",
+			wantRecords: 3,
+			wantFlushes: []int{51},
+		},
+		{
+			name:        "FlushEvery",
+			flushEvery:  2,
+			msgs:        []proto.Message{good(1), good(2), good(3)},
+			want:        "{\"int32Field\":1}\n{\"int32Field\":2}\n{\"int32Field\":3}\n",
+			wantRecords: 3,
+			wantFlushes: []int{34, 51},
+		},
+		{
+			name:        "Skip",
+			policy:      protojson.ErrorSkip,
+			msgs:        []proto.Message{good(1), bad, good(3)},
+			want:        "{\"int32Field\":1}\n{\"int32Field\":3}\n",
+			wantRecords: 2,
+			wantSkipped: 1,
+			wantFlushes: []int{34},
+		},
+		{
+			name:        "Abort",
+			msgs:        []proto.Message{good(1), bad},
+			want:        "{\"int32Field\":1}\n",
+			wantRecords: 1,
+			wantErr:     true,
+			wantFlushes: []int{17},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w flushRecorder
+			var skipped []proto.Message
+			encoder := protojson.NewJSONLEncoder(&w, protojson.MarshalOptions{Indent: "  "})
+			encoder.SetFlushEvery(tt.flushEvery)
+			encoder.SetErrorPolicy(tt.policy, func(m proto.Message, err error) {
+				if !errors.Is(err, protojson.ErrInvalidUTF8) {
+					t.Errorf("onSkip error = %v, want ErrInvalidUTF8", err)
+				}
+				skipped = append(skipped, m)
+			})
+
+			var err error
+			for _, m := range tt.msgs {
+				if err = encoder.Encode(m); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Encode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := encoder.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, w.buf.String()); diff != "" {
+				t.Errorf("output mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantFlushes, w.flushes); diff != "" {
+				t.Errorf("flushes mismatch (-want +got):\n%s", diff)
+			}
+			if got := encoder.Records(); got != tt.wantRecords {
+				t.Errorf("Records() = %d, want %d", got, tt.wantRecords)
+			}
+			if got := encoder.Skipped(); got != tt.wantSkipped || int64(len(skipped)) != tt.wantSkipped {
+				t.Errorf("Skipped() = %d with %d onSkip calls, want %d", got, len(skipped), tt.wantSkipped)
+			}
+		})
+	}
+}
+
+// errWriter fails every write
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// TestJSONLEncoderWriteError tests that write errors abort even under
+// ErrorSkip and are returned by later calls
+func TestJSONLEncoderWriteError(t *testing.T) {
+	encoder := protojson.NewJSONLEncoder(errWriter{}, protojson.MarshalOptions{})
+	encoder.SetFlushEvery(1)
+	encoder.SetErrorPolicy(protojson.ErrorSkip, nil)
+
+	msg := &pb_basic.BasicTypes{Int32Field: 1}
+	if err := encoder.Encode(msg); err == nil || err.Error() != "disk full" {
+		t.Fatalf("Encode() error = %v, want disk full", err)
+	}
+	if err := encoder.Encode(msg); err == nil || err.Error() != "disk full" {
+		t.Errorf("second Encode() error = %v, want disk full", err)
+	}
+	if err := encoder.Flush(); err == nil {
+		t.Errorf("Flush() succeeded, want error")
+	}
+}
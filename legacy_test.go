@@ -0,0 +1,83 @@
+package protojson_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// legacyUser is a message in the style of the legacy
+// github.com/golang/protobuf package, without a ProtoReflect method
+type legacyUser struct {
+	Name  string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Roles []string `protobuf:"bytes,2,rep,name=roles,proto3" json:"roles,omitempty"`
+}
+
+func (m *legacyUser) Reset()         { *m = legacyUser{} }
+func (m *legacyUser) String() string { return m.Name }
+func (*legacyUser) ProtoMessage()    {}
+
+// TestMarshalLegacy tests encoding legacy and current messages adapted
+// with protoadapt.MessageV2Of
+func TestMarshalLegacy(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  protoadapt.MessageV1
+		want string
+	}{
+		{
+			name: "legacy message",
+			msg:  &legacyUser{Name: "alice", Roles: []string{"admin"}},
+			want: `{"name":"alice","roles":["admin"]}`,
+		},
+		{
+			name: "current message",
+			msg:  &pb_basic.User{Name: "alice"},
+			want: `{"name":"alice"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protojson.Marshal(protoadapt.MessageV2Of(tt.msg))
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+
+			var buf bytes.Buffer
+			if err := protojson.NewEncoder(&buf).Encode(protoadapt.MessageV2Of(tt.msg)); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Errorf("Encode() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestCodecLegacy tests that Codec accepts legacy messages
+func TestCodecLegacy(t *testing.T) {
+	codec := protojson.NewCodec(protojson.MarshalOptions{})
+	data, err := codec.Marshal(&legacyUser{Name: "bob"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if diff := cmp.Diff(`{"name":"bob"}`, string(data)); diff != "" {
+		t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+	}
+
+	var got legacyUser
+	if err := codec.Unmarshal([]byte(`{"name":"carol","roles":["guest"]}`), &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if diff := cmp.Diff(legacyUser{Name: "carol", Roles: []string{"guest"}}, got); diff != "" {
+		t.Errorf("Unmarshal() mismatch (-want +got):\n%s", diff)
+	}
+}
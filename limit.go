@@ -0,0 +1,74 @@
+package protojson
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOutputTooLarge is returned, wrapped, when the encoded output of a
+// message exceeds MarshalOptions.MaxOutputBytes.
+var ErrOutputTooLarge = errors.New("output too large")
+
+// limitWriter counts bytes written to w and refuses writes once max bytes
+// would be exceeded. The encoder ignores individual write errors, so the
+// first failure is recorded in err and checked at field boundaries.
+type limitWriter struct {
+	w   writer
+	n   int
+	max int
+	err error
+}
+
+// reset prepares l to count a new message written to w
+func (l *limitWriter) reset(w writer, max int) {
+	l.w = w
+	l.n = 0
+	l.max = max
+	l.err = nil
+}
+
+// grow accounts for n more bytes, reporting whether they fit in the limit
+func (l *limitWriter) grow(n int) bool {
+	if l.err != nil {
+		return false
+	}
+	l.n += n
+	if l.n > l.max {
+		l.err = fmt.Errorf("%w: exceeds MaxOutputBytes limit of %d bytes", ErrOutputTooLarge, l.max)
+		return false
+	}
+	return true
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if !l.grow(len(p)) {
+		return 0, l.err
+	}
+	return l.w.Write(p)
+}
+
+func (l *limitWriter) WriteByte(c byte) error {
+	if !l.grow(1) {
+		return l.err
+	}
+	return l.w.WriteByte(c)
+}
+
+func (l *limitWriter) WriteString(s string) (int, error) {
+	if !l.grow(len(s)) {
+		return 0, l.err
+	}
+	return l.w.WriteString(s)
+}
+
+// checkLimit returns the MaxOutputBytes error once the limit was exceeded,
+// or the error of a periodic flush
+func (e *encoder) checkLimit() error {
+	if e.limit != nil && e.limit.err != nil {
+		return e.limit.err
+	}
+	if e.flusher != nil {
+		return e.flusher.err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package protojson_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+)
+
+// TestMaxOutputBytes tests that encoding is aborted once the output
+// exceeds MaxOutputBytes
+func TestMaxOutputBytes(t *testing.T) {
+	msg := &pb_basic.RepeatedFields{
+		Strings: []string{"a", "b", "c"},
+	}
+	want := `{"strings":["a","b","c"]}`
+
+	tests := []struct {
+		name    string
+		max     int
+		wantErr bool
+	}{
+		{name: "NoLimit", max: 0},
+		{name: "ExactLimit", max: len(want)},
+		{name: "OneByteShort", max: len(want) - 1, wantErr: true},
+		{name: "TinyLimit", max: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := protojson.MarshalOptions{MaxOutputBytes: tt.max}
+			got, err := opts.Marshal(msg)
+			if tt.wantErr {
+				if !errors.Is(err, protojson.ErrOutputTooLarge) {
+					t.Fatalf("Marshal() error = %v, want ErrOutputTooLarge", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMaxOutputBytesDiscardsPartialOutput tests that a message aborted by
+// the limit does not leak into the output of the next Encode call
+func TestMaxOutputBytesDiscardsPartialOutput(t *testing.T) {
+	var buf bytes.Buffer
+	// Hide the buffer's type so that the encoder buffers output itself
+	w := struct{ io.Writer }{&buf}
+	encoder := protojson.NewEncoderWithOptions(w, protojson.MarshalOptions{MaxOutputBytes: 32})
+
+	large := &pb_basic.BasicTypes{StringField: strings.Repeat("x", 64)}
+	if err := encoder.Encode(large); !errors.Is(err, protojson.ErrOutputTooLarge) {
+		t.Fatalf("Encode() error = %v, want ErrOutputTooLarge", err)
+	}
+
+	small := &pb_basic.BasicTypes{Int32Field: 1}
+	if err := encoder.Encode(small); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if diff := cmp.Diff(`{"int32Field":1}`, buf.String()); diff != "" {
+		t.Errorf("Encode() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,558 @@
+package protojson
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// UnmarshalToMap decodes protojson data into a map[string]any using the
+// given message descriptor to type each value. Unlike encoding/json, values
+// follow the protobuf JSON mapping:
+//
+//   - int32, sint32, sfixed32 fields become int32; uint32, fixed32 become uint32
+//   - int64, sint64, sfixed64 fields become int64; uint64, fixed64 become uint64
+//   - float fields become float32; double fields become float64
+//   - bytes fields become []byte
+//   - enum fields become the enum value name, or int32 for undeclared numbers
+//   - google.protobuf.Timestamp becomes time.Time
+//   - google.protobuf.Duration becomes time.Duration, or *durationpb.Duration
+//     beyond the roughly 292 years time.Duration can hold
+//   - wrapper types become their unwrapped value
+//   - google.protobuf.Struct, Value and ListValue become plain JSON values
+//   - repeated fields become []any and map fields become map[string]any
+//
+// Keys are kept as they appear in the input; both JSON names and proto names
+// are accepted. Fields set to null are omitted from the result.
+func UnmarshalToMap(data []byte, md protoreflect.MessageDescriptor) (map[string]any, error) {
+	raw, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	v, err := convertMessage(md, raw)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s is not encoded as a JSON object", md.FullName())
+	}
+	return m, nil
+}
+
+// MarshalToMap converts m into a map[string]any holding the same tree of
+// values as its JSON encoding, using default options.
+func MarshalToMap(m proto.Message) (map[string]any, error) {
+	return MarshalOptions{}.MarshalToMap(m)
+}
+
+// MarshalToMap converts m into a map[string]any holding the same tree of
+// values as its JSON encoding with options in o, for consumers such as
+// template engines and document stores that want native Go values. Keys,
+// well-known types and masking follow o exactly, so 64-bit integers are
+// strings unless Int64AsNumber is set. Objects become map[string]any and
+// arrays []any; JSON numbers become int64 when integral, uint64 when
+// integral but too large for int64, and float64 otherwise. m must not be a
+// well-known type written as a non-object, such as google.protobuf.Timestamp.
+func (o MarshalOptions) MarshalToMap(m proto.Message) (map[string]any, error) {
+	o.Comments = nil
+	data, err := o.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	v, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := nativeJSON(v).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s is not encoded as a JSON object", m.ProtoReflect().Descriptor().FullName())
+	}
+	return obj, nil
+}
+
+// nativeJSON converts json.Number values in a decoded JSON tree to int64,
+// uint64 or float64
+func nativeJSON(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return n
+		}
+		f, _ := strconv.ParseFloat(string(v), 64)
+		return f
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = nativeJSON(elem)
+		}
+		return v
+	case []any:
+		for i, elem := range v {
+			v[i] = nativeJSON(elem)
+		}
+		return v
+	}
+	return v
+}
+
+// decodeJSON decodes data into generic JSON values, keeping numbers as
+// json.Number so they can be typed later without loss of precision.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after top-level value")
+	}
+	return v, nil
+}
+
+// parseDuration parses the JSON string of a google.protobuf.Duration, such
+// as "-1.5s", into seconds and nanos with the same sign, accepting the
+// full range of the type
+func parseDuration(s string) (seconds, nanos int64, ok bool) {
+	s, ok = strings.CutSuffix(s, "s")
+	if !ok {
+		return 0, 0, false
+	}
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	intPart, frac, hasFrac := strings.Cut(s, ".")
+	if intPart == "" || hasFrac && (frac == "" || len(frac) > 9) {
+		return 0, 0, false
+	}
+	for _, digits := range []string{intPart, frac} {
+		for i := 0; i < len(digits); i++ {
+			if digits[i] < '0' || digits[i] > '9' {
+				return 0, 0, false
+			}
+		}
+	}
+	seconds, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || seconds > maxDurationSeconds {
+		return 0, 0, false
+	}
+	for i := 0; i < 9; i++ {
+		nanos *= 10
+		if i < len(frac) {
+			nanos += int64(frac[i] - '0')
+		}
+	}
+	if neg {
+		seconds, nanos = -seconds, -nanos
+	}
+	return seconds, nanos, true
+}
+
+// durationOf returns seconds and nanos as a time.Duration, reporting
+// whether it can hold them
+func durationOf(seconds, nanos int64) (time.Duration, bool) {
+	if seconds > math.MaxInt64/int64(time.Second) || seconds < math.MinInt64/int64(time.Second) {
+		return 0, false
+	}
+	d := seconds * 1e9
+	if nanos > 0 && d > math.MaxInt64-nanos || nanos < 0 && d < math.MinInt64-nanos {
+		return 0, false
+	}
+	return time.Duration(d + nanos), true
+}
+
+// convertMessage converts a decoded JSON value for the given message type
+func convertMessage(md protoreflect.MessageDescriptor, v any) (any, error) {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s value: %v", md.FullName(), v)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value: %w", md.FullName(), err)
+		}
+		return t.UTC(), nil
+	case "google.protobuf.Duration":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s value: %v", md.FullName(), v)
+		}
+		seconds, nanos, ok := parseDuration(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s value: %q", md.FullName(), s)
+		}
+		if d, ok := durationOf(seconds, nanos); ok {
+			return d, nil
+		}
+		return &durationpb.Duration{Seconds: seconds, Nanos: int32(nanos)}, nil
+	case "google.protobuf.Struct":
+		if _, ok := v.(map[string]any); !ok {
+			return nil, fmt.Errorf("invalid %s value: %v", md.FullName(), v)
+		}
+		return plainJSON(v), nil
+	case "google.protobuf.ListValue":
+		if _, ok := v.([]any); !ok {
+			return nil, fmt.Errorf("invalid %s value: %v", md.FullName(), v)
+		}
+		return plainJSON(v), nil
+	case "google.protobuf.Value":
+		return plainJSON(v), nil
+	case "google.protobuf.Empty":
+		if obj, ok := v.(map[string]any); !ok || len(obj) != 0 {
+			return nil, fmt.Errorf("invalid %s value: %v", md.FullName(), v)
+		}
+		return map[string]any{}, nil
+	case "google.protobuf.FieldMask":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s value: %v", md.FullName(), v)
+		}
+		if s == "" {
+			return []string{}, nil
+		}
+		return strings.Split(s, ","), nil
+	case "google.protobuf.Any":
+		return convertAny(md, v)
+	}
+
+	if isWrapperType(md.FullName()) {
+		return convertSingular(md.Fields().ByName("value"), v)
+	}
+
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid value for message %s: %v", md.FullName(), v)
+	}
+
+	fields := md.Fields()
+	out := make(map[string]any, len(obj))
+	seen := make(map[protoreflect.FieldNumber]string, len(obj))
+	for key, val := range obj {
+		fd := fields.ByJSONName(key)
+		if fd == nil {
+			fd = fields.ByTextName(key)
+		}
+		if fd == nil {
+			return nil, fmt.Errorf("unknown field %q in %s", key, md.FullName())
+		}
+		if prev, ok := seen[fd.Number()]; ok {
+			return nil, fmt.Errorf("duplicate field %q and %q in %s", prev, key, md.FullName())
+		}
+		seen[fd.Number()] = key
+
+		if val == nil && !isNullField(fd) {
+			continue
+		}
+		cv, err := convertField(fd, val)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = cv
+	}
+	return out, nil
+}
+
+// convertAny converts a google.protobuf.Any value by resolving its @type
+// from the global registry.
+func convertAny(md protoreflect.MessageDescriptor, v any) (any, error) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid %s value: %v", md.FullName(), v)
+	}
+	typeURL, ok := obj["@type"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing @type in %s", md.FullName())
+	}
+	mt, err := protoregistry.GlobalTypes.FindMessageByURL(typeURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %q: %w", typeURL, err)
+	}
+
+	inner := mt.Descriptor()
+	var payload any
+	if isWellKnownType(inner.FullName()) {
+		payload = obj["value"]
+	} else {
+		rest := make(map[string]any, len(obj)-1)
+		for k, val := range obj {
+			if k != "@type" {
+				rest[k] = val
+			}
+		}
+		payload = rest
+	}
+
+	cv, err := convertMessage(inner, payload)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]any{"@type": typeURL}
+	if m, ok := cv.(map[string]any); ok && !isWellKnownType(inner.FullName()) {
+		for k, val := range m {
+			out[k] = val
+		}
+	} else {
+		out["value"] = cv
+	}
+	return out, nil
+}
+
+// convertField converts a decoded JSON value for a field, handling
+// repeated and map fields.
+func convertField(fd protoreflect.FieldDescriptor, v any) (any, error) {
+	switch {
+	case fd.IsList():
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid value for repeated field %s: %v", fd.FullName(), v)
+		}
+		out := make([]any, 0, len(arr))
+		for _, elem := range arr {
+			cv, err := convertSingular(fd, elem)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, cv)
+		}
+		return out, nil
+	case fd.IsMap():
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid value for map field %s: %v", fd.FullName(), v)
+		}
+		out := make(map[string]any, len(obj))
+		for k, elem := range obj {
+			if err := checkMapKey(fd.MapKey(), k); err != nil {
+				return nil, err
+			}
+			cv, err := convertSingular(fd.MapValue(), elem)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = cv
+		}
+		return out, nil
+	}
+	return convertSingular(fd, v)
+}
+
+// checkMapKey validates that a JSON object key is valid for the map key type
+func checkMapKey(fd protoreflect.FieldDescriptor, k string) error {
+	if fd.Kind() == protoreflect.BoolKind {
+		if k != "true" && k != "false" {
+			return fmt.Errorf("invalid map key for %s: %q", fd.FullName(), k)
+		}
+		return nil
+	}
+	_, err := convertSingular(fd, k)
+	return err
+}
+
+// convertSingular converts a decoded JSON value for a singular field value
+func convertSingular(fd protoreflect.FieldDescriptor, v any) (any, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if n, ok := jsonInt(v, 32); ok {
+			return int32(n), nil
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if n, ok := jsonInt(v, 64); ok {
+			return n, nil
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if n, ok := jsonUint(v, 32); ok {
+			return uint32(n), nil
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if n, ok := jsonUint(v, 64); ok {
+			return n, nil
+		}
+	case protoreflect.FloatKind:
+		if f, ok := jsonFloat(v, 32); ok {
+			return float32(f), nil
+		}
+	case protoreflect.DoubleKind:
+		if f, ok := jsonFloat(v, 64); ok {
+			return f, nil
+		}
+	case protoreflect.StringKind:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case protoreflect.BytesKind:
+		if s, ok := v.(string); ok {
+			if b, err := decodeBase64(s); err == nil {
+				return b, nil
+			}
+		}
+	case protoreflect.EnumKind:
+		if fd.Enum().FullName() == "google.protobuf.NullValue" && v == nil {
+			return nil, nil
+		}
+		switch v := v.(type) {
+		case string:
+			if ev := fd.Enum().Values().ByName(protoreflect.Name(v)); ev != nil {
+				return v, nil
+			}
+		default:
+			if n, ok := jsonInt(v, 32); ok {
+				if ev := fd.Enum().Values().ByNumber(protoreflect.EnumNumber(n)); ev != nil {
+					return string(ev.Name()), nil
+				}
+				return int32(n), nil
+			}
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return convertMessage(fd.Message(), v)
+	}
+	return nil, fmt.Errorf("invalid value for %v field %s: %v", fd.Kind(), fd.FullName(), v)
+}
+
+// jsonInt parses a signed integer given as a JSON number or string
+func jsonInt(v any, bitSize int) (int64, bool) {
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return 0, false
+	}
+	if n, err := strconv.ParseInt(s, 10, bitSize); err == nil {
+		return n, true
+	}
+	// Accept integral values in exponent or decimal form, e.g. 1e3 or 1.0
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) {
+		return 0, false
+	}
+	limit := math.Ldexp(1, bitSize-1)
+	if f < -limit || f >= limit {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// jsonUint parses an unsigned integer given as a JSON number or string
+func jsonUint(v any, bitSize int) (uint64, bool) {
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return 0, false
+	}
+	if n, err := strconv.ParseUint(s, 10, bitSize); err == nil {
+		return n, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || f < 0 || f >= math.Ldexp(1, bitSize) {
+		return 0, false
+	}
+	return uint64(f), true
+}
+
+// jsonFloat parses a floating point value given as a JSON number or string,
+// including the special "NaN", "Infinity" and "-Infinity" strings.
+func jsonFloat(v any, bitSize int) (float64, bool) {
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = string(v)
+	case string:
+		switch v {
+		case "NaN":
+			return math.NaN(), true
+		case "Infinity":
+			return math.Inf(1), true
+		case "-Infinity":
+			return math.Inf(-1), true
+		}
+		s = v
+	default:
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, bitSize)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// decodeBase64 decodes standard or URL-safe base64, with or without padding
+func decodeBase64(s string) ([]byte, error) {
+	enc := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.URLEncoding
+	}
+	if len(s)%4 != 0 {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	return enc.DecodeString(s)
+}
+
+// plainJSON converts json.Number values in a decoded JSON tree to float64,
+// matching the number semantics of google.protobuf.Value.
+func plainJSON(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := strconv.ParseFloat(string(v), 64)
+		return f
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = plainJSON(elem)
+		}
+		return v
+	case []any:
+		for i, elem := range v {
+			v[i] = plainJSON(elem)
+		}
+		return v
+	}
+	return v
+}
+
+// isNullField reports whether a JSON null is a meaningful value for fd
+// rather than an unset field.
+func isNullField(fd protoreflect.FieldDescriptor) bool {
+	if fd.IsList() || fd.IsMap() {
+		return false
+	}
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		return fd.Enum().FullName() == "google.protobuf.NullValue"
+	case protoreflect.MessageKind:
+		return fd.Message().FullName() == "google.protobuf.Value"
+	}
+	return false
+}
+
+// isWellKnownType reports whether name is a well-known type with a special
+// JSON representation, which google.protobuf.Any nests under "value".
+func isWellKnownType(name protoreflect.FullName) bool {
+	_, ok := wellKnownFormatters[name]
+	return ok
+}
@@ -0,0 +1,315 @@
+package protojson_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// TestUnmarshalToMap tests decoding protojson into typed Go values
+func TestUnmarshalToMap(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		want map[string]any
+	}{
+		{
+			name: "BasicTypes",
+			msg: &pb_basic.BasicTypes{
+				StringField:  "hello",
+				Int32Field:   -42,
+				Int64Field:   9223372036854775807,
+				Uint32Field:  123,
+				Uint64Field:  18446744073709551615,
+				BoolField:    true,
+				FloatField:   1.5,
+				DoubleField:  2.25,
+				BytesField:   []byte("binary data"),
+				Fixed64Field: 222,
+			},
+			want: map[string]any{
+				"stringField":  "hello",
+				"int32Field":   int32(-42),
+				"int64Field":   int64(9223372036854775807),
+				"uint32Field":  uint32(123),
+				"uint64Field":  uint64(18446744073709551615),
+				"boolField":    true,
+				"floatField":   float32(1.5),
+				"doubleField":  2.25,
+				"bytesField":   []byte("binary data"),
+				"fixed64Field": uint64(222),
+			},
+		},
+		{
+			name: "EnumsAndRepeated",
+			msg: &pb_basic.RepeatedEnums{
+				Statuses: []pb_basic.Status{pb_basic.Status_STATUS_ACTIVE, pb_basic.Status(99)},
+			},
+			want: map[string]any{
+				"statuses": []any{"STATUS_ACTIVE", int32(99)},
+			},
+		},
+		{
+			name: "Maps",
+			msg: &pb_basic.MapFields{
+				IntKeyMap:  map[int32]string{1: "one"},
+				MessageMap: map[string]*pb_basic.Value{"a": {Data: "x", Count: 2}},
+			},
+			want: map[string]any{
+				"intKeyMap":  map[string]any{"1": "one"},
+				"messageMap": map[string]any{"a": map[string]any{"data": "x", "count": int32(2)}},
+			},
+		},
+		{
+			name: "WellKnownTypes",
+			msg: &pb_basic.WellKnownTypes{
+				Timestamp: timestamppb.New(time.Date(2024, 5, 1, 12, 30, 0, 500, time.UTC)),
+				Duration:  durationpb.New(90 * time.Second),
+				Struct: &structpb.Struct{Fields: map[string]*structpb.Value{
+					"n": structpb.NewNumberValue(1),
+				}},
+			},
+			want: map[string]any{
+				"timestamp": time.Date(2024, 5, 1, 12, 30, 0, 500, time.UTC),
+				"duration":  90 * time.Second,
+				"struct":    map[string]any{"n": float64(1)},
+			},
+		},
+		{
+			name: "Wrappers",
+			msg: &pb_basic.WrapperTypes{
+				StringValue: wrapperspb.String("s"),
+				Int64Value:  wrapperspb.Int64(-5),
+				BytesValue:  wrapperspb.Bytes([]byte{0xff}),
+			},
+			want: map[string]any{
+				"stringValue": "s",
+				"int64Value":  int64(-5),
+				"bytesValue":  []byte{0xff},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := stdprotojson.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("standard protojson.Marshal failed: %v", err)
+			}
+			got, err := protojson.UnmarshalToMap(data, tt.msg.ProtoReflect().Descriptor())
+			if err != nil {
+				t.Fatalf("UnmarshalToMap() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("UnmarshalToMap() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestUnmarshalToMapInput tests accepted spellings and rejected input
+func TestUnmarshalToMapInput(t *testing.T) {
+	md := (&pb_basic.BasicTypes{}).ProtoReflect().Descriptor()
+
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name:    "InvalidDouble",
+			data:    `{"doubleField":"NaN-free"}`,
+			wantErr: true,
+		},
+		{
+			name: "ProtoNames",
+			data: `{"int32_field":"7","uint64Field":5,"string_field":null}`,
+			want: map[string]any{"int32_field": int32(7), "uint64Field": uint64(5)},
+		},
+		{
+			name: "ExponentInteger",
+			data: `{"int64Field":1e3}`,
+			want: map[string]any{"int64Field": int64(1000)},
+		},
+		{
+			name:    "UnknownField",
+			data:    `{"nope":1}`,
+			wantErr: true,
+		},
+		{
+			name:    "DuplicateField",
+			data:    `{"int32Field":1,"int32_field":2}`,
+			wantErr: true,
+		},
+		{
+			name:    "Int32Overflow",
+			data:    `{"int32Field":2147483648}`,
+			wantErr: true,
+		},
+		{
+			name:    "NotAnObject",
+			data:    `[]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protojson.UnmarshalToMap([]byte(tt.data), md)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("UnmarshalToMap() expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnmarshalToMap() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("UnmarshalToMap() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestUnmarshalToMapDuration tests decoding Durations over the whole range
+// of google.protobuf.Duration
+func TestUnmarshalToMapDuration(t *testing.T) {
+	md := (&pb_basic.WellKnownTypes{}).ProtoReflect().Descriptor()
+
+	tests := []struct {
+		name    string
+		data    string
+		want    any
+		wantErr bool
+	}{
+		{name: "Fraction", data: `"1.5s"`, want: 1500 * time.Millisecond},
+		{name: "NegativeFraction", data: `"-0.000000001s"`, want: -time.Nanosecond},
+		{name: "Max", data: `"315576000000.999999999s"`, want: &durationpb.Duration{Seconds: 315576000000, Nanos: 999999999}},
+		{name: "Min", data: `"-315576000000s"`, want: &durationpb.Duration{Seconds: -315576000000}},
+		{name: "OutOfRange", data: `"315576000001s"`, wantErr: true},
+		{name: "TooManyDigits", data: `"1.0000000001s"`, wantErr: true},
+		{name: "EmptyFraction", data: `"1.s"`, wantErr: true},
+		{name: "NoUnit", data: `"1"`, wantErr: true},
+		{name: "Units", data: `"1h"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protojson.UnmarshalToMap([]byte(`{"duration":`+tt.data+`}`), md)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("UnmarshalToMap() expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnmarshalToMap() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got["duration"], protocmp.Transform()); diff != "" {
+				t.Errorf("UnmarshalToMap() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMarshalToMap tests converting messages into native Go values
+func TestMarshalToMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     proto.Message
+		opts    protojson.MarshalOptions
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name: "scalars",
+			msg: &pb_basic.BasicTypes{
+				StringField: "hello",
+				Int32Field:  -1,
+				Int64Field:  1 << 40,
+				Uint64Field: 1 << 63,
+				BoolField:   true,
+				FloatField:  1.5,
+				DoubleField: 2,
+				BytesField:  []byte("hi"),
+			},
+			want: map[string]any{
+				"stringField": "hello",
+				"int32Field":  int64(-1),
+				"int64Field":  "1099511627776",
+				"uint64Field": "9223372036854775808",
+				"boolField":   true,
+				"floatField":  1.5,
+				"doubleField": int64(2),
+				"bytesField":  "aGk=",
+			},
+		},
+		{
+			name: "int64 as number",
+			msg:  &pb_basic.BasicTypes{Int64Field: 1 << 40, Uint64Field: 1 << 63},
+			opts: protojson.MarshalOptions{Int64AsNumber: true},
+			want: map[string]any{
+				"int64Field":  int64(1 << 40),
+				"uint64Field": uint64(1 << 63),
+			},
+		},
+		{
+			name: "nested messages, lists and maps",
+			msg: &pb_basic.User{
+				Name:        "alice",
+				Permissions: []string{"read"},
+				Profile:     &pb_basic.Profile{Bio: "hi"},
+				Metadata:    map[string]string{"k": "v"},
+			},
+			opts: protojson.MarshalOptions{UseProtoNames: true},
+			want: map[string]any{
+				"name":        "alice",
+				"permissions": []any{"read"},
+				"profile":     map[string]any{"bio": "hi"},
+				"metadata":    map[string]any{"k": "v"},
+			},
+		},
+		{
+			name: "well-known types",
+			msg: &pb_basic.WellKnownTypes{
+				Timestamp: timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+				Duration:  durationpb.New(1500 * time.Millisecond),
+				Value:     structpb.NewNumberValue(0.5),
+			},
+			want: map[string]any{
+				"timestamp": "2024-01-02T03:04:05Z",
+				"duration":  "1.500s",
+				"value":     0.5,
+			},
+		},
+		{
+			name:    "well-known type",
+			msg:     timestamppb.New(time.Unix(0, 0)),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.MarshalToMap(tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MarshalToMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("MarshalToMap() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
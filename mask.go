@@ -0,0 +1,245 @@
+package protojson
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// maskField reports whether the value of fd at the current path is masked
+// by FieldMaskFunc, MaskDebugRedact or FieldMaskPathFunc
+func (e *encoder) maskField(fd protoreflect.FieldDescriptor) bool {
+	if e.maskDescriptor(fd) {
+		return true
+	}
+	return e.opts.FieldMaskPathFunc != nil && e.opts.FieldMaskPathFunc(e.path, fd)
+}
+
+// maskDescriptor reports whether all values of fd are masked by
+// FieldMaskFunc or MaskDebugRedact
+func (e *encoder) maskDescriptor(fd protoreflect.FieldDescriptor) bool {
+	if e.opts.FieldMaskFunc != nil && e.opts.FieldMaskFunc(fd) {
+		return true
+	}
+	return e.opts.MaskDebugRedact && debugRedact(fd)
+}
+
+// debugRedact reports whether the options of fd set debug_redact
+func debugRedact(fd protoreflect.FieldDescriptor) bool {
+	opts, ok := fd.Options().(*descriptorpb.FieldOptions)
+	return ok && opts.GetDebugRedact()
+}
+
+// defaultMaskString replaces masked values when MaskString is empty
+const defaultMaskString = "***"
+
+// writeMask writes the replacement for a masked string or bytes value
+func (e *encoder) writeMask(fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	switch e.opts.MaskMode {
+	case MaskHash:
+		e.writeMaskHash(maskBytes(fd, v))
+		return nil
+	}
+	return e.writeMaskString()
+}
+
+// writeMaskString writes MaskString, or "***" if it is empty
+func (e *encoder) writeMaskString() error {
+	if e.opts.MaskString == "" {
+		e.w.WriteString(`"` + defaultMaskString + `"`)
+		return nil
+	}
+	if err := e.marshalString(e.opts.MaskString); err != nil {
+		return fmt.Errorf("MaskString: %w", err)
+	}
+	return nil
+}
+
+// maskBytes returns the raw content of a string or bytes value
+func maskBytes(fd protoreflect.FieldDescriptor, v protoreflect.Value) []byte {
+	if fd.Kind() == protoreflect.BytesKind {
+		return v.Bytes()
+	}
+	return []byte(v.String())
+}
+
+// writeMaskHash writes the hex digest of b as a JSON string
+func (e *encoder) writeMaskHash(b []byte) {
+	e.w.WriteByte('"')
+	for _, c := range e.maskDigest(b) {
+		e.w.WriteByte(hexDigits[c>>4])
+		e.w.WriteByte(hexDigits[c&0xf])
+	}
+	e.w.WriteByte('"')
+}
+
+// maskDigest returns the digest of b for MaskHash mode
+func (e *encoder) maskDigest(b []byte) []byte {
+	if e.opts.MaskHashFunc != nil {
+		return e.opts.MaskHashFunc(b)
+	}
+	h := sha256.New()
+	h.Write(e.opts.MaskHashSalt)
+	h.Write(b)
+	return h.Sum(e.buf[:0])
+}
+
+// omitMasked reports whether the value of fd at the current path is masked
+// and left out by MaskOmit
+func (e *encoder) omitMasked(fd protoreflect.FieldDescriptor) bool {
+	if e.maskOmits(fd) && e.maskField(fd) {
+		e.auditMask()
+		return true
+	}
+	return false
+}
+
+// maskOmits reports whether masked values of fd are left out by MaskOmit
+func (e *encoder) maskOmits(fd protoreflect.FieldDescriptor) bool {
+	if e.opts.MaskPolicy != MaskOmit {
+		return false
+	}
+	kind := fd.Kind()
+	return kind != protoreflect.StringKind && kind != protoreflect.BytesKind
+}
+
+// maskValue returns the replacement chosen by FieldMaskValueFunc for the
+// value v of fd, and whether v is masked
+func (e *encoder) maskValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, bool, error) {
+	replacement, masked := e.opts.FieldMaskValueFunc(fd, v)
+	if !masked {
+		return v, false, nil
+	}
+	if replacement.IsValid() && !valueOfKind(fd, replacement) {
+		return v, false, fmt.Errorf("field %s: FieldMaskValueFunc returned a %T for a %v field", fd.FullName(), replacement.Interface(), fd.Kind())
+	}
+	e.auditMask()
+	return replacement, true, nil
+}
+
+// valueOfKind reports whether v holds the kind of value of fd
+func valueOfKind(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+	switch x := v.Interface().(type) {
+	case bool:
+		return fd.Kind() == protoreflect.BoolKind
+	case protoreflect.EnumNumber:
+		return fd.Kind() == protoreflect.EnumKind
+	case int32:
+		switch fd.Kind() {
+		case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+			return true
+		}
+	case int64:
+		switch fd.Kind() {
+		case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+			return true
+		}
+	case uint32:
+		switch fd.Kind() {
+		case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+			return true
+		}
+	case uint64:
+		switch fd.Kind() {
+		case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+			return true
+		}
+	case float32:
+		return fd.Kind() == protoreflect.FloatKind
+	case float64:
+		return fd.Kind() == protoreflect.DoubleKind
+	case string:
+		return fd.Kind() == protoreflect.StringKind
+	case []byte:
+		return fd.Kind() == protoreflect.BytesKind
+	case protoreflect.Message:
+		return fd.Message() != nil && x.Descriptor().FullName() == fd.Message().FullName()
+	}
+	return false
+}
+
+// maskMapEntry reports whether the value of the map entry with key k is
+// masked, either through the map field fd or by MaskKeyPatterns
+func (e *encoder) maskMapEntry(fd protoreflect.FieldDescriptor, k protoreflect.MapKey) (bool, error) {
+	if e.maskField(fd) {
+		return true, nil
+	}
+	if len(e.opts.MaskKeyPatterns) == 0 {
+		return false, nil
+	}
+	key := strings.ToLower(k.String())
+	for _, pattern := range e.opts.MaskKeyPatterns {
+		matched, err := path.Match(strings.ToLower(pattern), key)
+		if err != nil {
+			return false, fmt.Errorf("MaskKeyPatterns %q: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// writeMaskedKey writes the replacement for the key of the i-th written
+// entry of a masked map entry
+func (e *encoder) writeMaskedKey(i int, k protoreflect.MapKey) error {
+	if e.opts.MaskMode == MaskHash {
+		e.writeMaskHash([]byte(k.String()))
+		return nil
+	}
+	if err := e.marshalString(e.maskedKey(i, k)); err != nil {
+		return fmt.Errorf("MaskString: %w", err)
+	}
+	return nil
+}
+
+// maskedKey returns the replacement for the key of the i-th written entry
+// of a masked map entry as a string
+func (e *encoder) maskedKey(i int, k protoreflect.MapKey) string {
+	if e.opts.MaskMode == MaskHash {
+		return hex.EncodeToString(e.maskDigest([]byte(k.String())))
+	}
+	mask := e.opts.MaskString
+	if mask == "" {
+		mask = defaultMaskString
+	}
+	return mask + strconv.Itoa(i)
+}
+
+// writeMaskPolicy writes the replacement MaskPolicy selects for a masked
+// value of a kind other than string and bytes
+func (e *encoder) writeMaskPolicy(fd protoreflect.FieldDescriptor) error {
+	if e.opts.MaskPolicy != MaskZero {
+		e.w.WriteString("null")
+		return nil
+	}
+
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		e.w.WriteString("false")
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if e.opts.Int64AsNumber {
+			e.w.WriteByte('0')
+		} else {
+			e.w.WriteString(`"0"`)
+		}
+	case protoreflect.FloatKind:
+		e.writeFloat(0, 32)
+	case protoreflect.DoubleKind:
+		e.writeFloat(0, 64)
+	case protoreflect.EnumKind:
+		return e.writeMaskString()
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		e.w.WriteString("{}")
+	default:
+		e.w.WriteByte('0')
+	}
+	return nil
+}
@@ -0,0 +1,423 @@
+package protojson_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TestFieldMaskPathFunc tests masking decided by the path to a field
+func TestFieldMaskPathFunc(t *testing.T) {
+	msg := &pb_basic.ComplexMessage{
+		Id:    "c1",
+		Users: []*pb_basic.User{{Name: "alice", Permissions: []string{"read", "write"}}},
+		Projects: map[string]*pb_basic.Project{
+			"p1": {Name: "one"},
+		},
+	}
+
+	var paths []string
+	opts := protojson.MarshalOptions{
+		FieldMaskPathFunc: func(path protojson.FieldPath, fd protoreflect.FieldDescriptor) bool {
+			paths = append(paths, path.String())
+			return len(path) > 1 && path[0].Field.Name() == "users" && fd.Name() != "id"
+		},
+	}
+	got, err := opts.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := `{"id":"c1","users":[{"name":"***","permissions":["***","***"]}],"projects":{"p1":{"name":"one"}}}`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+	}
+
+	wantPaths := []string{
+		"id",
+		"users[0]",
+		"users[0].name",
+		"users[0].permissions[0]",
+		"users[0].permissions[1]",
+		`projects["p1"]`,
+		`projects["p1"].name`,
+	}
+	if diff := cmp.Diff(wantPaths, paths); diff != "" {
+		t.Errorf("FieldMaskPathFunc paths mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestFieldMaskValueFunc tests masking with replacements chosen by value
+func TestFieldMaskValueFunc(t *testing.T) {
+	msg := &pb_basic.ComplexMessage{
+		Users: []*pb_basic.User{
+			{Email: "alice@example.com", Role: pb_basic.Role_ROLE_ADMIN},
+			{Email: "bob@example.org"},
+		},
+		Settings: &pb_basic.Settings{Theme: "dark"},
+	}
+
+	opts := protojson.MarshalOptions{
+		FieldMaskValueFunc: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, bool) {
+			switch fd.Name() {
+			case "email":
+				_, domain, _ := strings.Cut(v.String(), "@")
+				return protoreflect.ValueOfString("***@" + domain), true
+			case "role":
+				return protoreflect.ValueOfEnum(pb_basic.Role_ROLE_GUEST.Number()), true
+			case "settings":
+				return protoreflect.Value{}, true
+			}
+			return v, false
+		},
+	}
+	got, err := opts.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := `{"users":[{"email":"***@example.com","role":"ROLE_GUEST"},{"email":"***@example.org"}],"settings":null}`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestFieldMaskValueFuncWrongKind tests that a replacement of the wrong
+// kind fails to encode instead of panicking
+func TestFieldMaskValueFuncWrongKind(t *testing.T) {
+	tests := []struct {
+		name        string
+		replacement protoreflect.Value
+	}{
+		{name: "String", replacement: protoreflect.ValueOfString("***")},
+		{name: "Int64", replacement: protoreflect.ValueOfInt64(0)},
+		{name: "Message", replacement: protoreflect.ValueOfMessage((&pb_basic.User{}).ProtoReflect())},
+	}
+
+	msg := &pb_basic.ComplexMessage{Settings: &pb_basic.Settings{NotificationsEnabled: true}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := protojson.MarshalOptions{
+				FieldMaskValueFunc: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, bool) {
+					return tt.replacement, fd.Name() == "notifications_enabled" || fd.Name() == "settings"
+				},
+			}
+			if _, err := opts.Marshal(msg); err == nil {
+				t.Error("Marshal() expected error")
+			}
+			if _, err := opts.MarshalText(msg); err == nil {
+				t.Error("MarshalText() expected error")
+			}
+		})
+	}
+}
+
+// TestMaskString tests replacing masked values with a custom token
+func TestMaskString(t *testing.T) {
+	msg := &pb_basic.BasicTypes{StringField: "secret", BytesField: []byte("data"), Int32Field: 1}
+	maskFunc := func(fd protoreflect.FieldDescriptor) bool { return true }
+
+	tests := []struct {
+		name       string
+		maskString string
+		want       string
+	}{
+		{name: "Default", want: `{"stringField":"***","int32Field":1,"bytesField":"***"}`},
+		{name: "Custom", maskString: "[REDACTED]", want: `{"stringField":"[REDACTED]","int32Field":1,"bytesField":"[REDACTED]"}`},
+		{name: "Escaped", maskString: `<redacted:"pii">`, want: `{"stringField":"<redacted:\"pii\">","int32Field":1,"bytesField":"<redacted:\"pii\">"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := protojson.MarshalOptions{FieldMaskFunc: maskFunc, MaskString: tt.maskString}
+			got, err := opts.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMaskHash tests replacing masked values with their digest
+func TestMaskHash(t *testing.T) {
+	msg := &pb_basic.BasicTypes{StringField: "secret", BytesField: []byte("secret")}
+	maskFunc := func(fd protoreflect.FieldDescriptor) bool { return true }
+	digest := func(salt string) string {
+		sum := sha256.Sum256([]byte(salt + "secret"))
+		return hex.EncodeToString(sum[:])
+	}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "SHA256",
+			opts: protojson.MarshalOptions{MaskMode: protojson.MaskHash},
+			want: fmt.Sprintf(`{"stringField":"%s","bytesField":"%[1]s"}`, digest("")),
+		},
+		{
+			name: "Salted",
+			opts: protojson.MarshalOptions{MaskMode: protojson.MaskHash, MaskHashSalt: []byte("pepper")},
+			want: fmt.Sprintf(`{"stringField":"%s","bytesField":"%[1]s"}`, digest("pepper")),
+		},
+		{
+			name: "CustomFunc",
+			opts: protojson.MarshalOptions{
+				MaskMode:     protojson.MaskHash,
+				MaskHashFunc: func(b []byte) []byte { return []byte{byte(len(b)), 0xab} },
+			},
+			want: `{"stringField":"06ab","bytesField":"06ab"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.FieldMaskFunc = maskFunc
+			got, err := tt.opts.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMaskPolicy tests masking of kinds other than string and bytes
+func TestMaskPolicy(t *testing.T) {
+	basic := &pb_basic.BasicTypes{StringField: "s", Int32Field: 7, Int64Field: 8, BoolField: true, DoubleField: 1.5}
+	user := &pb_basic.User{
+		Name:        "alice",
+		Role:        pb_basic.Role_ROLE_ADMIN,
+		Profile:     &pb_basic.Profile{Bio: "hi"},
+		Permissions: []string{"read"},
+	}
+	maskAll := func(fd protoreflect.FieldDescriptor) bool { return fd.Name() != "name" }
+
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "UnchangedBasic",
+			msg:  basic,
+			want: `{"stringField":"***","int32Field":7,"int64Field":"8","boolField":true,"doubleField":1.5}`,
+		},
+		{
+			name: "ZeroBasic",
+			msg:  basic,
+			opts: protojson.MarshalOptions{MaskPolicy: protojson.MaskZero},
+			want: `{"stringField":"***","int32Field":0,"int64Field":"0","boolField":false,"doubleField":0}`,
+		},
+		{
+			name: "ZeroUser",
+			msg:  user,
+			opts: protojson.MarshalOptions{MaskPolicy: protojson.MaskZero, MaskString: "[REDACTED]"},
+			want: `{"name":"alice","role":"[REDACTED]","permissions":["[REDACTED]"],"profile":{}}`,
+		},
+		{
+			name: "NullBasic",
+			msg:  basic,
+			opts: protojson.MarshalOptions{MaskPolicy: protojson.MaskNull},
+			want: `{"stringField":"***","int32Field":null,"int64Field":null,"boolField":null,"doubleField":null}`,
+		},
+		{
+			name: "NullUser",
+			msg:  user,
+			opts: protojson.MarshalOptions{MaskPolicy: protojson.MaskNull},
+			want: `{"name":"alice","role":null,"permissions":["***"],"profile":null}`,
+		},
+		{
+			name: "OmitBasic",
+			msg:  basic,
+			opts: protojson.MarshalOptions{MaskPolicy: protojson.MaskOmit},
+			want: `{"stringField":"***"}`,
+		},
+		{
+			name: "OmitUser",
+			msg:  user,
+			opts: protojson.MarshalOptions{MaskPolicy: protojson.MaskOmit},
+			want: `{"name":"alice","permissions":["***"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.FieldMaskFunc = maskAll
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMaskMapEntries tests masking of map values and keys
+func TestMaskMapEntries(t *testing.T) {
+	msg := &pb_basic.User{
+		Name:     "alice",
+		Metadata: map[string]string{"X-Auth-Token": "t0k3n", "region": "eu", "session_token": "s3ss"},
+	}
+	maskMetadata := func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "metadata" }
+
+	tests := []struct {
+		name    string
+		opts    protojson.MarshalOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "MapField",
+			opts: protojson.MarshalOptions{FieldMaskFunc: maskMetadata},
+			want: `{"name":"alice","metadata":{"X-Auth-Token":"***","region":"***","session_token":"***"}}`,
+		},
+		{
+			name: "KeyPattern",
+			opts: protojson.MarshalOptions{MaskKeyPatterns: []string{"*token*"}},
+			want: `{"name":"alice","metadata":{"X-Auth-Token":"***","region":"eu","session_token":"***"}}`,
+		},
+		{
+			name: "MaskKeys",
+			opts: protojson.MarshalOptions{MaskKeyPatterns: []string{"*token*"}, MaskMapKeys: true},
+			want: `{"name":"alice","metadata":{"***0":"***","region":"eu","***2":"***"}}`,
+		},
+		{
+			name: "MaskKeysHash",
+			opts: protojson.MarshalOptions{
+				MaskKeyPatterns: []string{"region"},
+				MaskMapKeys:     true,
+				MaskMode:        protojson.MaskHash,
+				MaskHashFunc:    func(b []byte) []byte { return []byte{byte(len(b))} },
+			},
+			want: `{"name":"alice","metadata":{"X-Auth-Token":"t0k3n","06":"02","session_token":"s3ss"}}`,
+		},
+		{
+			name:    "BadPattern",
+			opts:    protojson.MarshalOptions{MaskKeyPatterns: []string{"["}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(msg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Marshal() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMaskRepeatedElements tests masking of every element of repeated fields
+func TestMaskRepeatedElements(t *testing.T) {
+	msg := &pb_basic.RepeatedFields{
+		Strings:   []string{"code-1", "code-2"},
+		Numbers:   []int32{1, 2},
+		BytesList: [][]byte{[]byte("a"), []byte("b")},
+	}
+	maskLists := func(fd protoreflect.FieldDescriptor) bool { return fd.IsList() }
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Elements",
+			opts: protojson.MarshalOptions{FieldMaskFunc: maskLists},
+			want: `{"strings":["***","***"],"numbers":[1,2],"bytesList":["***","***"]}`,
+		},
+		{
+			name: "ElementsWithPolicy",
+			opts: protojson.MarshalOptions{FieldMaskFunc: maskLists, MaskPolicy: protojson.MaskOmit},
+			want: `{"strings":["***","***"],"numbers":[],"bytesList":["***","***"]}`,
+		},
+		{
+			name: "ByIndex",
+			opts: protojson.MarshalOptions{
+				FieldMaskPathFunc: func(path protojson.FieldPath, fd protoreflect.FieldDescriptor) bool {
+					return path[len(path)-1].Index == 1
+				},
+			},
+			want: `{"strings":["code-1","***"],"numbers":[1,2],"bytesList":["YQ==","***"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMaskDebugRedact tests masking of fields marked debug_redact
+func TestMaskDebugRedact(t *testing.T) {
+	msg := &pb_basic.RedactedFields{Username: "alice", Password: "hunter2", Pin: 1234}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Disabled",
+			want: `{"username":"alice","password":"hunter2","pin":1234}`,
+		},
+		{
+			name: "Enabled",
+			opts: protojson.MarshalOptions{MaskDebugRedact: true},
+			want: `{"username":"alice","password":"***","pin":1234}`,
+		},
+		{
+			name: "WithPolicy",
+			opts: protojson.MarshalOptions{MaskDebugRedact: true, MaskPolicy: protojson.MaskNull},
+			want: `{"username":"alice","password":"***","pin":null}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
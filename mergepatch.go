@@ -0,0 +1,291 @@
+package protojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// MarshalMergePatch writes an RFC 7386 JSON Merge Patch that turns the JSON
+// form of original into that of updated, using default options.
+func MarshalMergePatch(original, updated proto.Message) ([]byte, error) {
+	return MarshalOptions{}.MarshalMergePatch(original, updated)
+}
+
+// MarshalMergePatch writes an RFC 7386 JSON Merge Patch that turns the JSON
+// form of original into that of updated, using options in o. Fields and map
+// entries missing from updated are written as null. Message fields and
+// maps present in both are patched recursively; lists and well-known types
+// are replaced whole. original and updated must be of the same message
+// type.
+func (o MarshalOptions) MarshalMergePatch(original, updated proto.Message) ([]byte, error) {
+	o.Comments = nil
+	a, b := original.ProtoReflect(), updated.ProtoReflect()
+	if a.Descriptor().FullName() != b.Descriptor().FullName() {
+		return nil, fmt.Errorf("mismatched message types %s and %s", a.Descriptor().FullName(), b.Descriptor().FullName())
+	}
+	if !o.AllowPartial {
+		if err := proto.CheckInitialized(updated); err != nil {
+			return nil, err
+		}
+	}
+
+	s := marshalPool.Get().(*marshalState)
+	defer s.release()
+
+	s.enc.SetOptions(o)
+	s.enc.prepare()
+	write := func() error { return s.enc.enc.marshalMergePatch(a, b) }
+	if err := s.enc.enc.writeRoot(write); err != nil {
+		return nil, err
+	}
+	if err := s.enc.enc.checkLimit(); err != nil {
+		return nil, err
+	}
+	s.enc.reportMasks(updated)
+	if err := s.enc.flush(); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), s.buf.Bytes()...), nil
+}
+
+// marshalMergePatch writes the merge patch from message a to b
+func (e *encoder) marshalMergePatch(a, b protoreflect.Message) error {
+	if e.formatted(b.Descriptor().FullName()) {
+		return e.marshalMessage(b)
+	}
+
+	e.w.WriteByte('{')
+	e.depth++
+
+	first := true
+	plan := e.planOf(b.Descriptor())
+	fields := e.fields(plan)
+	for i := range fields {
+		fd := fields[i].fd
+		inA, inB := a.Has(fd), b.Has(fd)
+		if !inA && !inB {
+			continue
+		}
+		av, bv := a.Get(fd), b.Get(fd)
+		if inA && inB && av.Equal(bv) {
+			continue
+		}
+
+		if !first {
+			e.writeComma()
+		}
+		first = false
+
+		e.writeIndent()
+		e.writeKey(&fields[i])
+
+		var err error
+		switch {
+		case !inB:
+			e.w.WriteString("null")
+		case !inA:
+			err = e.marshalField(fd, bv)
+		case fd.IsMap():
+			err = e.marshalMapPatch(fd, av.Map(), bv.Map())
+		case fd.Message() != nil && !fd.IsList():
+			err = e.marshalMergePatch(av.Message(), bv.Message())
+		default:
+			err = e.marshalField(fd, bv)
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.checkLimit(); err != nil {
+			return err
+		}
+	}
+
+	e.depth--
+	if !first {
+		e.writeIndent()
+	}
+	e.w.WriteByte('}')
+	return nil
+}
+
+// marshalMapPatch writes the merge patch from map a to b: removed entries
+// as null and changed entries as their new value, patched recursively for
+// message values
+func (e *encoder) marshalMapPatch(fd protoreflect.FieldDescriptor, a, b protoreflect.Map) error {
+	keys := make([]protoreflect.MapKey, 0, a.Len()+b.Len())
+	a.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		if !b.Has(k) {
+			keys = append(keys, k)
+		}
+		return true
+	})
+	b.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	slices.SortFunc(keys, mapKeyCompare(fd.MapKey().Kind()))
+
+	e.w.WriteByte('{')
+	e.depth++
+
+	n := 0
+	for _, k := range keys {
+		inA, inB := a.Has(k), b.Has(k)
+		av, bv := a.Get(k), b.Get(k)
+		if inA && inB && av.Equal(bv) {
+			continue
+		}
+
+		if n > 0 {
+			e.writeComma()
+		}
+		n++
+		e.writeIndent()
+		if err := e.writeMapKey(fd, k); err != nil {
+			return err
+		}
+		e.writeColon()
+
+		var err error
+		switch {
+		case !inB:
+			e.w.WriteString("null")
+		case inA && fd.MapValue().Message() != nil:
+			err = e.marshalMergePatch(av.Message(), bv.Message())
+		default:
+			var masked bool
+			if masked, err = e.maskMapEntry(fd, k); err == nil {
+				err = e.marshalSingularValue(fd.MapValue(), bv, masked)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	e.depth--
+	if n > 0 {
+		e.writeIndent()
+	}
+	e.w.WriteByte('}')
+	return nil
+}
+
+// ApplyMergePatch applies an RFC 7386 JSON Merge Patch to m: the patch is
+// merged into the JSON form of m and the result is parsed back into m with
+// google.golang.org/protobuf/encoding/protojson. Fields may be named by
+// their JSON or proto names, as in patches written with UseProtoNames. m
+// is left unchanged if the patch cannot be applied.
+func ApplyMergePatch(m proto.Message, patch []byte) error {
+	data, err := Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+	p, err := decodeJSON(patch)
+	if err != nil {
+		return fmt.Errorf("invalid merge patch: %w", err)
+	}
+	if p, err = jsonNamedPatch(m.ProtoReflect().Descriptor(), p); err != nil {
+		return fmt.Errorf("invalid merge patch: %w", err)
+	}
+
+	merged, err := json.Marshal(mergePatch(doc, p))
+	if err != nil {
+		return err
+	}
+
+	result := m.ProtoReflect().New().Interface()
+	if err := stdprotojson.Unmarshal(merged, result); err != nil {
+		return fmt.Errorf("applying merge patch: %w", err)
+	}
+	proto.Reset(m)
+	proto.Merge(m, result)
+	return nil
+}
+
+// jsonNamedPatch renames the fields of a merge patch for a message of type
+// md to their JSON names, which the JSON form of the message is keyed by.
+// Keys that are not field names are kept, to be rejected when the merged
+// document is parsed.
+func jsonNamedPatch(md protoreflect.MessageDescriptor, patch any) (any, error) {
+	obj, ok := patch.(map[string]any)
+	if !ok || isWellKnownType(md.FullName()) {
+		return patch, nil
+	}
+	fields := md.Fields()
+	named := make(map[string]any, len(obj))
+	for k, v := range obj {
+		fd := fields.ByJSONName(k)
+		if fd == nil {
+			fd = fields.ByTextName(k)
+		}
+		if fd == nil {
+			named[k] = v
+			continue
+		}
+
+		var err error
+		switch {
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v, err = jsonNamedEntries(fd.MapValue().Message(), v)
+		case !fd.IsList() && fd.Message() != nil:
+			v, err = jsonNamedPatch(fd.Message(), v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fd.Name(), err)
+		}
+		if _, dup := named[fd.JSONName()]; dup {
+			return nil, fmt.Errorf("duplicate field %s", fd.Name())
+		}
+		named[fd.JSONName()] = v
+	}
+	return named, nil
+}
+
+// jsonNamedEntries renames the fields of the message values in the patch
+// of a map field
+func jsonNamedEntries(md protoreflect.MessageDescriptor, patch any) (any, error) {
+	obj, ok := patch.(map[string]any)
+	if !ok {
+		return patch, nil
+	}
+	named := make(map[string]any, len(obj))
+	for k, v := range obj {
+		v, err := jsonNamedPatch(md, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		named[k] = v
+	}
+	return named, nil
+}
+
+// mergePatch implements the MergePatch function of RFC 7386
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any, len(p))
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
@@ -0,0 +1,154 @@
+package protojson_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// TestMergePatch tests creating merge patches and applying them
+func TestMergePatch(t *testing.T) {
+	original := &pb_basic.ComplexMessage{
+		Id:    "c1",
+		Users: []*pb_basic.User{{Id: "u1", Name: "alice"}},
+		Projects: map[string]*pb_basic.Project{
+			"p1": {Id: "p1", Name: "one"},
+			"p2": {Id: "p2", Name: "two"},
+		},
+		Settings: &pb_basic.Settings{Theme: "dark", Language: "en"},
+	}
+
+	tests := []struct {
+		name    string
+		updated *pb_basic.ComplexMessage
+		want    string
+	}{
+		{
+			name:    "Unchanged",
+			updated: proto.Clone(original).(*pb_basic.ComplexMessage),
+			want:    `{}`,
+		},
+		{
+			name: "ChangedAndCleared",
+			updated: &pb_basic.ComplexMessage{
+				Users: []*pb_basic.User{{Id: "u1", Name: "alice"}, {Id: "u2"}},
+				Projects: map[string]*pb_basic.Project{
+					"p1": {Id: "p1", Name: "one"},
+					"p2": {Id: "p2", Name: "two"},
+				},
+				Settings: &pb_basic.Settings{Theme: "light", Language: "en"},
+			},
+			want: `{"id":null,"users":[{"id":"u1","name":"alice"},{"id":"u2"}],"settings":{"theme":"light"}}`,
+		},
+		{
+			name: "MapEntries",
+			updated: &pb_basic.ComplexMessage{
+				Id:    "c1",
+				Users: []*pb_basic.User{{Id: "u1", Name: "alice"}},
+				Projects: map[string]*pb_basic.Project{
+					"p2": {Id: "p2"},
+					"p3": {Id: "p3"},
+				},
+				Settings: &pb_basic.Settings{Theme: "dark", Language: "en"},
+			},
+			want: `{"projects":{"p1":null,"p2":{"name":null},"p3":{"id":"p3"}}}`,
+		},
+		{
+			name:    "ClearedMessages",
+			updated: &pb_basic.ComplexMessage{Id: "c1"},
+			want:    `{"users":null,"projects":null,"settings":null}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := protojson.MarshalMergePatch(original, tt.updated)
+			if err != nil {
+				t.Fatalf("MarshalMergePatch() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(patch)); diff != "" {
+				t.Errorf("MarshalMergePatch() mismatch (-want +got):\n%s", diff)
+			}
+
+			got := proto.Clone(original)
+			if err := protojson.ApplyMergePatch(got, patch); err != nil {
+				t.Fatalf("ApplyMergePatch() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.updated, got, protocmp.Transform()); diff != "" {
+				t.Errorf("ApplyMergePatch() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestApplyMergePatch tests applying hand-written merge patches
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		want    proto.Message
+		wantErr bool
+	}{
+		{
+			name:  "SetAndRemove",
+			patch: `{"id":"c2","settings":{"theme":null,"language":"fr"}}`,
+			want: &pb_basic.ComplexMessage{
+				Id:       "c2",
+				Settings: &pb_basic.Settings{Language: "fr"},
+			},
+		},
+		{
+			name:  "ProtoNames",
+			patch: `{"settings":{"notifications_enabled":true,"theme":null}}`,
+			want: &pb_basic.ComplexMessage{
+				Id:       "c1",
+				Settings: &pb_basic.Settings{NotificationsEnabled: true, Language: "en"},
+			},
+		},
+		{
+			name:    "DuplicateField",
+			patch:   `{"settings":{"notificationsEnabled":true,"notifications_enabled":false}}`,
+			wantErr: true,
+		},
+		{
+			name:    "UnknownField",
+			patch:   `{"nope":1}`,
+			wantErr: true,
+		},
+		{
+			name:    "InvalidJSON",
+			patch:   `{`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &pb_basic.ComplexMessage{
+				Id:       "c1",
+				Settings: &pb_basic.Settings{Theme: "dark", Language: "en"},
+			}
+			before := proto.Clone(msg)
+			err := protojson.ApplyMergePatch(msg, []byte(tt.patch))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ApplyMergePatch() expected error")
+				}
+				if diff := cmp.Diff(before, msg, protocmp.Transform()); diff != "" {
+					t.Errorf("ApplyMergePatch() modified message on error (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyMergePatch() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, msg, protocmp.Transform()); diff != "" {
+				t.Errorf("ApplyMergePatch() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package protojson
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// msgpackTimestamp is the extension type of MessagePack timestamps
+const msgpackTimestamp = 0xff // -1
+
+// MsgPackEncoder writes messages as MessagePack with the semantics of
+// their JSON encoding, for transports that speak MessagePack. Objects
+// become maps with string keys, named and ordered as in the JSON output,
+// so options such as UseProtoNames, EmitUnpopulated and masking apply
+// exactly as they do to JSON. Values are typed by their fields rather than
+// following JSON: 64-bit integers are integers rather than strings, bytes
+// are binary rather than base64, and float fields are float 32.
+// google.protobuf.Timestamp is written with the timestamp extension type
+// and google.protobuf.Duration as a number of seconds. Options that only
+// apply to JSON output, such as Indent, BytesEncoding, TimestampFormat or
+// MaxOutputBytes, are ignored.
+type MsgPackEncoder struct {
+	w   io.Writer
+	bin binaryEncoder
+	buf []byte
+}
+
+// NewMsgPackEncoder returns a new MessagePack encoder that writes to w
+// using the provided MarshalOptions.
+func NewMsgPackEncoder(w io.Writer, opts MarshalOptions) *MsgPackEncoder {
+	e := &MsgPackEncoder{w: w}
+	e.bin.init(opts)
+	return e
+}
+
+// Encode writes m as a single MessagePack object.
+func (e *MsgPackEncoder) Encode(m proto.Message) error {
+	v, err := e.bin.encode(m)
+	if err != nil {
+		return err
+	}
+	if e.buf, err = appendMsgPack(e.buf[:0], v); err != nil {
+		return err
+	}
+	_, err = e.w.Write(e.buf)
+	return err
+}
+
+// MarshalMsgPack returns the MessagePack encoding of m using options in o.
+// See MsgPackEncoder.
+func (o MarshalOptions) MarshalMsgPack(m proto.Message) ([]byte, error) {
+	var e MsgPackEncoder
+	e.bin.init(o)
+	v, err := e.bin.encode(m)
+	if err != nil {
+		return nil, err
+	}
+	return appendMsgPack(nil, v)
+}
+
+// appendMsgPack appends the MessagePack encoding of a value converted by
+// binaryEncoder
+func appendMsgPack(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case int32:
+		return appendMsgPackInt(b, int64(v)), nil
+	case uint32:
+		return appendMsgPackInt(b, int64(v)), nil
+	case int64:
+		return appendMsgPackInt(b, v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return binary.BigEndian.AppendUint64(append(b, 0xcf), v), nil
+		}
+		return appendMsgPackInt(b, int64(v)), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(v)), nil
+	case float64:
+		return appendMsgPackDouble(b, v), nil
+	case string:
+		return appendMsgPackString(b, v), nil
+	case []byte:
+		b = appendMsgPackHead(b, len(v), 0, 0, [3]byte{0xc4, 0xc5, 0xc6})
+		return append(b, v...), nil
+	case time.Time:
+		return appendMsgPackTime(b, v), nil
+	case time.Duration:
+		if v%time.Second == 0 {
+			return appendMsgPackInt(b, int64(v/time.Second)), nil
+		}
+		return appendMsgPackDouble(b, v.Seconds()), nil
+	case []any:
+		b = appendMsgPackHead(b, len(v), 0x90, 16, [3]byte{0, 0xdc, 0xdd})
+		var err error
+		for _, elem := range v {
+			if b, err = appendMsgPack(b, elem); err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	case binaryObject:
+		b = appendMsgPackHead(b, len(v), 0x80, 16, [3]byte{0, 0xde, 0xdf})
+		var err error
+		for _, m := range v {
+			b = appendMsgPackString(b, m.key)
+			if b, err = appendMsgPack(b, m.val); err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	}
+	return b, fmt.Errorf("unexpected value of type %T", v)
+}
+
+// appendMsgPackDouble appends f as a float 64
+func appendMsgPackDouble(b []byte, f float64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(f))
+}
+
+// appendMsgPackString appends s as a str
+func appendMsgPackString(b []byte, s string) []byte {
+	b = appendMsgPackHead(b, len(s), 0xa0, 32, [3]byte{0xd9, 0xda, 0xdb})
+	return append(b, s...)
+}
+
+// appendMsgPackHead appends the header of a string, binary, array or map
+// of length n: fix|n if n is less than fixMax, and otherwise the shortest
+// of the 8, 16 and 32-bit forms whose codes are given, where a zero code
+// means that there is no such form
+func appendMsgPackHead(b []byte, n int, fix byte, fixMax int, codes [3]byte) []byte {
+	switch {
+	case n < fixMax:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint8 && codes[0] != 0:
+		return append(b, codes[0], byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, codes[1]), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, codes[2]), uint32(n))
+}
+
+// appendMsgPackInt appends n in the shortest integer form
+func appendMsgPackInt(b []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n <= math.MaxInt8:
+		return append(b, byte(n))
+	case n < 0 && n >= -32:
+		return append(b, byte(n))
+	case n >= 0 && n <= math.MaxUint8:
+		return append(b, 0xcc, byte(n))
+	case n >= 0 && n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(n))
+	case n >= 0 && n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(n))
+	case n >= 0:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), uint64(n))
+	case n >= math.MinInt8:
+		return append(b, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(n))
+	case n >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(n))
+}
+
+// appendMsgPackTime appends t with the timestamp extension type, in its
+// 32, 64 or 96-bit form
+func appendMsgPackTime(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case sec >= 0 && sec <= math.MaxUint32 && nsec == 0:
+		return binary.BigEndian.AppendUint32(append(b, 0xd6, msgpackTimestamp), uint32(sec))
+	case sec >= 0 && sec < 1<<34:
+		return binary.BigEndian.AppendUint64(append(b, 0xd7, msgpackTimestamp), nsec<<34|uint64(sec))
+	}
+	b = binary.BigEndian.AppendUint32(append(b, 0xc7, 12, msgpackTimestamp), uint32(nsec))
+	return binary.BigEndian.AppendUint64(b, uint64(sec))
+}
@@ -0,0 +1,108 @@
+package protojson_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// msgpackStr returns the hex encoding of s as a MessagePack fixstr
+func msgpackStr(s string) string {
+	return hex.EncodeToString(append([]byte{0xa0 | byte(len(s))}, s...))
+}
+
+// TestMarshalMsgPack tests the MessagePack encoding of messages
+func TestMarshalMsgPack(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Integers",
+			msg:  &pb_basic.BasicTypes{Int32Field: -100, Int64Field: 1 << 40, Uint32Field: 200, Sint64Field: -5},
+			want: "84" + msgpackStr("int32Field") + "d09c" + msgpackStr("int64Field") + "cf0000010000000000" +
+				msgpackStr("uint32Field") + "ccc8" + msgpackStr("sint64Field") + "fb",
+		},
+		{
+			name: "Scalars",
+			msg:  &pb_basic.BasicTypes{BoolField: true, FloatField: 1.5, DoubleField: 1.5, BytesField: []byte{7}},
+			want: "84" + msgpackStr("boolField") + "c3" + msgpackStr("floatField") + "ca3fc00000" +
+				msgpackStr("doubleField") + "cb3ff8000000000000" + msgpackStr("bytesField") + "c40107",
+		},
+		{
+			name: "LongString",
+			msg:  &pb_basic.BasicTypes{StringField: strings.Repeat("a", 40)},
+			want: "81" + msgpackStr("stringField") + "d928" + strings.Repeat("61", 40),
+		},
+		{
+			name: "EmitUnpopulated",
+			msg:  &pb_basic.DefaultValues{},
+			opts: protojson.MarshalOptions{EmitUnpopulated: true, UseProtoNames: true},
+			want: "84" + msgpackStr("empty_string") + "a0" + msgpackStr("zero_int") + "00" +
+				msgpackStr("false_bool") + "c2" + msgpackStr("empty_array") + "90",
+		},
+		{
+			name: "Masked",
+			msg:  &pb_basic.RedactedFields{Username: "u", Password: "p", Pin: 1234},
+			opts: protojson.MarshalOptions{MaskDebugRedact: true, MaskPolicy: protojson.MaskNull},
+			want: "83" + msgpackStr("username") + msgpackStr("u") + msgpackStr("password") + msgpackStr("***") +
+				msgpackStr("pin") + "c0",
+		},
+		{
+			name: "Timestamp32",
+			msg:  timestamppb.New(time.Unix(1700000000, 0)),
+			want: "d6ff6553f100",
+		},
+		{
+			name: "Timestamp64",
+			msg:  timestamppb.New(time.Unix(1, 1)),
+			want: "d7ff0000000400000001",
+		},
+		{
+			name: "Timestamp96",
+			msg:  timestamppb.New(time.Unix(-1, 0)),
+			want: "c70cff00000000ffffffffffffffff",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.MarshalMsgPack(tt.msg)
+			if err != nil {
+				t.Fatalf("MarshalMsgPack() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, hex.EncodeToString(got)); diff != "" {
+				t.Errorf("MarshalMsgPack() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMsgPackEncoder tests writing a sequence of MessagePack objects
+func TestMsgPackEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := protojson.NewMsgPackEncoder(&buf, protojson.MarshalOptions{})
+	for _, n := range []int32{1, 2} {
+		if err := enc.Encode(&pb_basic.BasicTypes{Int32Field: n}); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+	if err := enc.Encode(&pb_basic.BasicTypes{StringField: "bad\xff"}); err == nil {
+		t.Error("Encode() of invalid UTF-8 succeeded, want error")
+	}
+
+	want := "81" + msgpackStr("int32Field") + "01" + "81" + msgpackStr("int32Field") + "02"
+	if diff := cmp.Diff(want, hex.EncodeToString(buf.Bytes())); diff != "" {
+		t.Errorf("Encode() mismatch (-want +got):\n%s", diff)
+	}
+}
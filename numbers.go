@@ -0,0 +1,236 @@
+package protojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// UnmarshalFieldNumbers decodes JSON written with UseFieldNumbers into m
+// using default options. See UnmarshalFieldNumbersWithOptions.
+func UnmarshalFieldNumbers(data []byte, m proto.Message) error {
+	return UnmarshalFieldNumbersWithOptions(data, m, stdprotojson.UnmarshalOptions{})
+}
+
+// UnmarshalFieldNumbersWithOptions decodes JSON written with
+// UseFieldNumbers into m using opts, resolving object keys as field
+// numbers of the message types, and extensions and the types of
+// google.protobuf.Any messages through opts.Resolver. Keys that are not
+// numbers are taken as field names, so documents written without
+// UseFieldNumbers are accepted too. Unknown field numbers are an error
+// unless opts.DiscardUnknown is set. The keys are renamed in a single pass
+// over data before it is parsed with opts. m is reset first.
+func UnmarshalFieldNumbersWithOptions(data []byte, m proto.Message, opts stdprotojson.UnmarshalOptions) error {
+	var in bytes.Buffer
+	if err := json.Compact(&in, data); err != nil {
+		return err
+	}
+	r := fieldNamer{in: in.Bytes(), resolver: opts.Resolver, discard: opts.DiscardUnknown}
+	if r.resolver == nil {
+		r.resolver = protoregistry.GlobalTypes
+	}
+	r.out.Grow(len(r.in))
+	if _, err := r.message(m.ProtoReflect().Descriptor(), 0); err != nil {
+		return err
+	}
+	return opts.Unmarshal(r.out.Bytes(), m)
+}
+
+// fieldNamer copies compact JSON, replacing the field numbers keying
+// messages with field names
+type fieldNamer struct {
+	in       []byte
+	out      bytes.Buffer
+	resolver typeResolver
+	discard  bool // Whether unknown field numbers are dropped
+}
+
+// message copies the value at in[i] of a message of type md, returning the
+// index after it
+func (r *fieldNamer) message(md protoreflect.MessageDescriptor, i int) (int, error) {
+	b := r.in
+	if b[i] != '{' {
+		return r.copy(i), nil
+	}
+	if md.FullName() == "google.protobuf.Any" {
+		var err error
+		if md, err = r.anyType(i); err != nil {
+			return 0, err
+		}
+	}
+	if md == nil || isWellKnownType(md.FullName()) {
+		return r.copy(i), nil
+	}
+
+	r.out.WriteByte('{')
+	first := true
+	for i++; b[i] != '}'; {
+		if b[i] == ',' {
+			i++
+		}
+		j := scanString(b, i)
+		key := b[i:j]
+		i = j + 1 // Skip ':'
+
+		fd, name, err := r.field(md, key)
+		if err != nil {
+			return 0, err
+		}
+		if name == "" {
+			i = skipValue(b, i)
+			continue
+		}
+		if !first {
+			r.out.WriteByte(',')
+		}
+		first = false
+		r.out.WriteString(name)
+		r.out.WriteByte(':')
+		if i, err = r.value(fd, i); err != nil {
+			return 0, err
+		}
+	}
+	r.out.WriteByte('}')
+	return i + 1, nil
+}
+
+// field returns the field of md keyed by the quoted key, or nil if it is
+// not known, and the quoted key to write for it, or "" if it is dropped
+func (r *fieldNamer) field(md protoreflect.MessageDescriptor, key []byte) (protoreflect.FieldDescriptor, string, error) {
+	s, _ := unquoteKey(key)
+	n, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		// Field names are kept, looking them up for nested messages only
+		fd := md.Fields().ByJSONName(s)
+		if fd == nil {
+			fd = md.Fields().ByTextName(s)
+		}
+		return fd, string(key), nil
+	}
+	if fd := md.Fields().ByNumber(protoreflect.FieldNumber(n)); fd != nil {
+		return fd, `"` + fd.JSONName() + `"`, nil
+	}
+	if xt, err := r.resolver.FindExtensionByNumber(md.FullName(), protoreflect.FieldNumber(n)); err == nil {
+		fd := xt.TypeDescriptor()
+		return fd, `"[` + string(fd.FullName()) + `]"`, nil
+	}
+	if r.discard {
+		return nil, "", nil
+	}
+	return nil, "", fmt.Errorf("unknown field number %d in %s", n, md.FullName())
+}
+
+// value copies the value at in[i] of field fd, or of an unknown field if
+// fd is nil, returning the index after it
+func (r *fieldNamer) value(fd protoreflect.FieldDescriptor, i int) (int, error) {
+	b := r.in
+	switch {
+	case fd == nil:
+		return r.copy(i), nil
+	case fd.IsMap():
+		vd := fd.MapValue()
+		if vd.Message() == nil || b[i] != '{' {
+			return r.copy(i), nil
+		}
+		r.out.WriteByte('{')
+		var err error
+		for i++; b[i] != '}'; {
+			if b[i] == ',' {
+				r.out.WriteByte(',')
+				i++
+			}
+			j := scanString(b, i) + 1
+			r.out.Write(b[i:j]) // Key and ':'
+			if i, err = r.message(vd.Message(), j); err != nil {
+				return 0, err
+			}
+		}
+		r.out.WriteByte('}')
+		return i + 1, nil
+	case fd.Message() == nil:
+		return r.copy(i), nil
+	case fd.IsList():
+		if b[i] != '[' {
+			return r.copy(i), nil
+		}
+		r.out.WriteByte('[')
+		var err error
+		for i++; b[i] != ']'; {
+			if b[i] == ',' {
+				r.out.WriteByte(',')
+				i++
+			}
+			if i, err = r.message(fd.Message(), i); err != nil {
+				return 0, err
+			}
+		}
+		r.out.WriteByte(']')
+		return i + 1, nil
+	}
+	return r.message(fd.Message(), i)
+}
+
+// anyType returns the type of the message held by the
+// google.protobuf.Any object at in[i], or nil if it has no @type
+func (r *fieldNamer) anyType(i int) (protoreflect.MessageDescriptor, error) {
+	b := r.in
+	for i++; b[i] != '}'; {
+		if b[i] == ',' {
+			i++
+		}
+		j := scanString(b, i)
+		key, _ := unquoteKey(b[i:j])
+		i = j + 1
+		if key == "@type" && b[i] == '"' {
+			typeURL, _ := unquoteKey(b[i:scanString(b, i)])
+			mt, err := r.resolver.FindMessageByURL(typeURL)
+			if err != nil {
+				return nil, fmt.Errorf("unable to resolve %q: %w", typeURL, err)
+			}
+			return mt.Descriptor(), nil
+		}
+		i = skipValue(b, i)
+	}
+	return nil, nil
+}
+
+// copy copies the value at in[i], returning the index after it
+func (r *fieldNamer) copy(i int) int {
+	j := skipValue(r.in, i)
+	r.out.Write(r.in[i:j])
+	return j
+}
+
+// skipValue returns the index after the compact JSON value starting at b[i]
+func skipValue(b []byte, i int) int {
+	switch b[i] {
+	case '"':
+		return scanString(b, i)
+	case '{', '[':
+		depth := 0
+		for {
+			switch b[i] {
+			case '"':
+				i = scanString(b, i)
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+	}
+	for i < len(b) && b[i] != ',' && b[i] != '}' && b[i] != ']' {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,191 @@
+package protojson_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TestUseFieldNumbers tests keying fields by field number
+func TestUseFieldNumbers(t *testing.T) {
+	withExtension := func() *pb_basic.Extendable {
+		m := &pb_basic.Extendable{Name: proto.String("name")}
+		proto.SetExtension(m, pb_basic.E_ExtCount, int32(3))
+		return m
+	}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		msg  proto.Message
+		want string
+	}{
+		{
+			name: "Nested",
+			msg:  &pb_basic.Nested{Id: "a", Inner: &pb_basic.Inner{Name: "b", Value: 2}},
+			want: `{"1":"a","2":{"1":"b","2":2}}`,
+		},
+		{
+			name: "MapKeysUnchanged",
+			msg:  &pb_basic.MapFields{MessageMap: map[string]*pb_basic.Value{"k": {Data: "d"}}},
+			want: `{"5":{"k":{"1":"d"}}}`,
+		},
+		{
+			name: "List",
+			msg:  &pb_basic.RepeatedNested{People: []*pb_basic.SimplePerson{{Name: "a"}, {Age: 3}}},
+			want: `{"1":[{"1":"a"},{"2":3}]}`,
+		},
+		{
+			name: "WellKnownTypesUnchanged",
+			msg:  &pb_basic.WellKnownTypes{Duration: durationpb.New(1500000000)},
+			want: `{"2":"1.500s"}`,
+		},
+		{
+			name: "Extension",
+			msg:  withExtension(),
+			want: `{"1":"name","100":3}`,
+		},
+		{
+			name: "OverridesUseProtoNames",
+			opts: protojson.MarshalOptions{UseProtoNames: true},
+			msg:  &pb_basic.MapFields{IntMap: map[string]int32{"k": 1}},
+			want: `{"2":{"k":1}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.UseFieldNumbers = true
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestUnmarshalFieldNumbers tests decoding the output of UseFieldNumbers
+// back into messages
+func TestUnmarshalFieldNumbers(t *testing.T) {
+	withExtension := &pb_basic.Extendable{Name: proto.String("name")}
+	proto.SetExtension(withExtension, pb_basic.E_ExtCount, int32(3))
+
+	tests := []struct {
+		name string
+		msg  proto.Message
+	}{
+		{
+			name: "Nested",
+			msg:  &pb_basic.Nested{Id: "a", Inner: &pb_basic.Inner{Name: "b", Deep: &pb_basic.DeepInner{}}},
+		},
+		{
+			name: "Map",
+			msg: &pb_basic.MapFields{
+				IntKeyMap:  map[int32]string{7: "x"},
+				MessageMap: map[string]*pb_basic.Value{"1": {Data: "d", Count: 2}},
+			},
+		},
+		{
+			name: "List",
+			msg:  &pb_basic.RepeatedNested{People: []*pb_basic.SimplePerson{{Name: "a"}, {Age: 3}}},
+		},
+		{
+			name: "WellKnownTypes",
+			msg: &pb_basic.WellKnownTypes{
+				Timestamp: timestamppb.New(time.Unix(1700000000, 0)),
+				Any:       mustAny(t, &pb_basic.SimplePerson{Name: "a", Age: 3}),
+			},
+		},
+		{
+			name: "AnyWellKnownType",
+			msg:  &pb_basic.WellKnownTypes{Any: mustAny(t, durationpb.New(time.Second))},
+		},
+		{
+			name: "Extension",
+			msg:  withExtension,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := protojson.MarshalOptions{UseFieldNumbers: true}.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			got := tt.msg.ProtoReflect().New().Interface()
+			if err := protojson.UnmarshalFieldNumbers(b, got); err != nil {
+				t.Fatalf("UnmarshalFieldNumbers(%s) error = %v", b, err)
+			}
+			if diff := cmp.Diff(tt.msg, got, protocmp.Transform()); diff != "" {
+				t.Errorf("UnmarshalFieldNumbers() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestUnmarshalFieldNumbersErrors tests rejecting unknown field numbers
+func TestUnmarshalFieldNumbersErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "UnknownNumber", data: `{"9":"a"}`},
+		{name: "UnknownNestedNumber", data: `{"2":{"9":1}}`},
+		{name: "InvalidJSON", data: `{"1":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := protojson.UnmarshalFieldNumbers([]byte(tt.data), &pb_basic.Nested{}); err == nil {
+				t.Error("UnmarshalFieldNumbers() error = nil, want error")
+			}
+		})
+	}
+}
+
+// TestUnmarshalFieldNumbersWithOptions tests decoding with a resolver and
+// DiscardUnknown
+func TestUnmarshalFieldNumbersWithOptions(t *testing.T) {
+	withExtension := &pb_basic.Extendable{Name: proto.String("name")}
+	proto.SetExtension(withExtension, pb_basic.E_ExtCount, int32(3))
+	data, err := protojson.MarshalOptions{UseFieldNumbers: true}.Marshal(withExtension)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	opts := stdprotojson.UnmarshalOptions{Resolver: new(protoregistry.Types)}
+	if err := protojson.UnmarshalFieldNumbersWithOptions(data, &pb_basic.Extendable{}, opts); err == nil {
+		t.Error("UnmarshalFieldNumbersWithOptions() of an unresolved extension succeeded, want error")
+	}
+
+	opts.DiscardUnknown = true
+	got := &pb_basic.Extendable{}
+	if err := protojson.UnmarshalFieldNumbersWithOptions(data, got, opts); err != nil {
+		t.Fatalf("UnmarshalFieldNumbersWithOptions() error = %v", err)
+	}
+	if diff := cmp.Diff(&pb_basic.Extendable{Name: proto.String("name")}, got, protocmp.Transform()); diff != "" {
+		t.Errorf("UnmarshalFieldNumbersWithOptions() mismatch (-want +got):\n%s", diff)
+	}
+
+	nested := &pb_basic.Nested{}
+	data = []byte(`{"1": "a", "9": "x", "2": {"9": 1, "1": "b"}}`)
+	if err := protojson.UnmarshalFieldNumbersWithOptions(data, nested, opts); err != nil {
+		t.Fatalf("UnmarshalFieldNumbersWithOptions() error = %v", err)
+	}
+	want := &pb_basic.Nested{Id: "a", Inner: &pb_basic.Inner{Name: "b"}}
+	if diff := cmp.Diff(want, nested, protocmp.Transform()); diff != "" {
+		t.Errorf("UnmarshalFieldNumbersWithOptions() mismatch (-want +got):\n%s", diff)
+	}
+}
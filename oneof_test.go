@@ -0,0 +1,78 @@
+package protojson_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestWrapOneofs tests writing oneof fields wrapped in objects keyed by
+// the oneof name
+func TestWrapOneofs(t *testing.T) {
+	multiple := &pb_basic.MultipleOneOf{
+		First:  &pb_basic.MultipleOneOf_FirstInt{FirstInt: 1},
+		Second: &pb_basic.MultipleOneOf_SecondBool{SecondBool: true},
+	}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		msg  proto.Message
+		want string
+	}{
+		{
+			name: "Scalar",
+			msg:  &pb_basic.OneOfFields{Id: "a", Value: &pb_basic.OneOfFields_StringValue{StringValue: "s"}},
+			want: `{"id":"a","value":{"stringValue":"s"}}`,
+		},
+		{
+			name: "Message",
+			msg:  &pb_basic.OneOfFields{Value: &pb_basic.OneOfFields_MessageValue{MessageValue: &pb_basic.Message{Content: "c"}}},
+			want: `{"value":{"messageValue":{"content":"c"}}}`,
+		},
+		{
+			name: "Multiple",
+			msg:  multiple,
+			want: `{"first":{"firstInt":1},"second":{"secondBool":true}}`,
+		},
+		{
+			name: "Unset",
+			opts: protojson.MarshalOptions{EmitUnpopulated: true},
+			msg:  &pb_basic.OneOfFields{Id: "a"},
+			want: `{"id":"a"}`,
+		},
+		{
+			name: "ProtoNames",
+			opts: protojson.MarshalOptions{UseProtoNames: true},
+			msg:  multiple,
+			want: `{"first":{"first_int":1},"second":{"second_bool":true}}`,
+		},
+		{
+			name: "SyntheticOneofNotWrapped",
+			msg:  &pb_basic.OptionalFields{OptionalString: proto.String("s")},
+			want: `{"optionalString":"s"}`,
+		},
+		{
+			name: "Indent",
+			opts: protojson.MarshalOptions{Indent: "  "},
+			msg:  &pb_basic.OneOfFields{Value: &pb_basic.OneOfFields_IntValue{IntValue: 1}},
+			want: "{\n  \"value\": {\n    \"intValue\": 1\n  }\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.WrapOneofs = true
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package protojson
+
+import (
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// PathStep is one step on the path from the marshaled message to a value:
+// a field, a list element or a map entry.
+type PathStep struct {
+	// Field is the field entered by this step, or nil for list elements and
+	// map entries.
+	Field protoreflect.FieldDescriptor
+
+	// Index is the index of a list element, or -1 for other steps.
+	Index int
+
+	// Key is the key of a map entry. It is invalid for other steps.
+	Key protoreflect.MapKey
+
+	name string // Segment compared with path filter patterns
+}
+
+// FieldPath is the path from the marshaled message to a value. Fields of
+// messages embedded in google.protobuf.Any continue the path of the Any
+// field.
+type FieldPath []PathStep
+
+// String returns the path in a readable form, e.g. users[0].profile.bio or
+// metadata["key"].
+func (p FieldPath) String() string {
+	var b strings.Builder
+	for i, step := range p {
+		switch {
+		case step.Field != nil:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			if step.Field.IsExtension() {
+				b.WriteString("[" + string(step.Field.FullName()) + "]")
+			} else {
+				b.WriteString(string(step.Field.Name()))
+			}
+		case step.Index >= 0:
+			b.WriteString("[" + strconv.Itoa(step.Index) + "]")
+		default:
+			if s, ok := step.Key.Interface().(string); ok {
+				b.WriteString("[" + strconv.Quote(s) + "]")
+			} else {
+				b.WriteString("[" + step.Key.String() + "]")
+			}
+		}
+	}
+	return b.String()
+}
+
+// fieldStep returns the path step entering fd
+func fieldStep(fd protoreflect.FieldDescriptor) PathStep {
+	return PathStep{Field: fd, Index: -1}
+}
+
+// indexStep returns the path step entering the i-th list element
+func indexStep(i int) PathStep {
+	return PathStep{Index: i}
+}
+
+// keyStep returns the path step entering the map entry for k
+func keyStep(k protoreflect.MapKey) PathStep {
+	return PathStep{Index: -1, Key: k}
+}
+
+// enterPath appends step to the current path. It reports whether the value
+// there is left out by path filters, in which case the path is restored;
+// otherwise the caller must call leavePath once the value is written.
+func (e *encoder) enterPath(step PathStep) bool {
+	if e.filter == nil && e.unpopulated == nil {
+		e.path = append(e.path, step)
+		return false
+	}
+
+	switch {
+	case step.Field == nil && step.Index >= 0:
+		step.name = strconv.Itoa(step.Index)
+	case step.Field == nil:
+		step.name = step.Key.String()
+	case step.Field.IsExtension():
+		step.name = "[" + string(step.Field.FullName()) + "]"
+	default:
+		step.name = string(step.Field.Name())
+	}
+	e.path = append(e.path, step)
+	if e.filter != nil && e.filter.skip(e.path) {
+		e.path = e.path[:len(e.path)-1]
+		return true
+	}
+	return false
+}
+
+// leavePath removes the last step of the current path
+func (e *encoder) leavePath() {
+	e.path = e.path[:len(e.path)-1]
+}
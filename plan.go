@@ -0,0 +1,324 @@
+package protojson
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// messagePlan is what the encoder needs to know about a message type,
+// computed once per descriptor so that repeated encodes of the type skip
+// walking the descriptor
+type messagePlan struct {
+	name       protoreflect.FullName
+	wellKnown  func(e *encoder, m protoreflect.Message) error // Formatter of a well-known type, or nil
+	fields     []fieldPlan                                    // In declaration order
+	byNumber   []fieldPlan                                    // In field number order, for Stable
+	extensions bool                                           // Whether the message has extension ranges
+}
+
+// fieldPlan is what the encoder needs to know about a field
+type fieldPlan struct {
+	fd       protoreflect.FieldDescriptor
+	jsonKey  string // JSON name as an object key, followed by ": "
+	protoKey string // Proto name as an object key, followed by ": ", the type name for groups
+	numKey   string // Field number as an object key, followed by ": "
+	presence bool   // Whether the field has explicit presence
+	oneof    bool   // Whether the field is in a oneof, including a synthetic one
+	singular bool   // Whether the field is neither a list nor a map
+	declared bool   // Whether the field declares an explicit default value, outside of oneofs
+
+	write fieldWriter // Writes the value of the field, by cardinality and kind
+
+	unpopulated UnpopulatedKind // Kind of the field when unpopulated, or 0 if it is never written
+
+	// Keys of the oneof containing the field, for WrapOneofs, or "" if it
+	// is not in a oneof or only in the synthetic oneof of a proto3
+	// optional field
+	oneofKey      string // lowerCamelCase name, followed by ": "
+	oneofProtoKey string // Proto name, followed by ": "
+}
+
+// plans caches the plan of each registered message descriptor, which
+// lives as long as the program. Plans of types built at run time, e.g.
+// with dynamicpb, are cached by each encoder instead, so that they are
+// released with their descriptors.
+var plans sync.Map // map[protoreflect.MessageDescriptor]*messagePlan
+
+// maxLocalPlans bounds the number of plans and enum names of types built
+// at run time cached by an encoder
+const maxLocalPlans = 256
+
+// localPlans caches plans and enum names of types built at run time
+type localPlans struct {
+	plans map[protoreflect.MessageDescriptor]*messagePlan
+	enums map[protoreflect.EnumDescriptor]*enumNames
+}
+
+// planOf returns the plan of md, building it on first use
+func (e *encoder) planOf(md protoreflect.MessageDescriptor) *messagePlan {
+	if p, ok := plans.Load(md); ok {
+		return p.(*messagePlan)
+	}
+	if p, ok := e.local.plans[md]; ok {
+		return p
+	}
+	if registered(md) {
+		p, _ := plans.LoadOrStore(md, newPlan(md))
+		return p.(*messagePlan)
+	}
+	if e.local.plans == nil || len(e.local.plans) >= maxLocalPlans {
+		e.local.plans = make(map[protoreflect.MessageDescriptor]*messagePlan)
+	}
+	p := newPlan(md)
+	e.local.plans[md] = p
+	return p
+}
+
+// registered reports whether d is the descriptor registered under its name
+// in protoregistry.GlobalFiles, as for generated types
+func registered(d protoreflect.Descriptor) bool {
+	found, err := protoregistry.GlobalFiles.FindDescriptorByName(d.FullName())
+	return err == nil && found == d
+}
+
+// newPlan builds the plan of md
+func newPlan(md protoreflect.MessageDescriptor) *messagePlan {
+	fields := md.Fields()
+	p := &messagePlan{
+		name:       md.FullName(),
+		wellKnown:  wellKnownFormatters[md.FullName()],
+		fields:     make([]fieldPlan, fields.Len()),
+		extensions: md.ExtensionRanges().Len() > 0,
+	}
+	for i := range p.fields {
+		fd := fields.Get(i)
+		p.fields[i] = fieldPlan{
+			fd:       fd,
+			jsonKey:  `"` + fd.JSONName() + `": `,
+			protoKey: `"` + fd.TextName() + `": `,
+			numKey:   `"` + strconv.Itoa(int(fd.Number())) + `": `,
+			presence: fd.HasPresence(),
+			oneof:    fd.ContainingOneof() != nil,
+			declared: fd.HasDefault() && fd.ContainingOneof() == nil,
+			singular: !fd.IsList() && !fd.IsMap(),
+			write:    fieldWriterOf(fd),
+		}
+		p.fields[i].unpopulated = unpopulatedKindOf(&p.fields[i])
+		if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+			p.fields[i].oneofKey = `"` + jsonCamelCase(string(od.Name())) + `": `
+			p.fields[i].oneofProtoKey = `"` + string(od.Name()) + `": `
+		}
+	}
+	p.byNumber = p.fields
+	if !slices.IsSortedFunc(p.fields, compareFieldNumbers) {
+		p.byNumber = slices.SortedFunc(slices.Values(p.fields), compareFieldNumbers)
+	}
+	return p
+}
+
+// fieldWriter writes the value of a field
+type fieldWriter func(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error
+
+// fieldWriterOf returns the writer of values of fd
+func fieldWriterOf(fd protoreflect.FieldDescriptor) fieldWriter {
+	switch {
+	case fd.IsList():
+		return func(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+			return e.marshalList(fd, v.List())
+		}
+	case fd.IsMap():
+		return func(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+			return e.marshalMap(fd, v.Map())
+		}
+	}
+	return (*encoder).marshalSingular
+}
+
+// valueWriter writes a singular value of fd, once masked and transformed
+type valueWriter func(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error
+
+// valueWriters holds the writer of each kind of value, indexed by kind
+var valueWriters [protoreflect.Sint64Kind + 1]valueWriter
+
+func init() {
+	// Assigned in init, since writeMessage refers back to the table
+	// through marshalMessage
+	for kind, write := range map[protoreflect.Kind]valueWriter{
+		protoreflect.BoolKind:     writeBool,
+		protoreflect.Int32Kind:    writeInt32,
+		protoreflect.Sint32Kind:   writeInt32,
+		protoreflect.Sfixed32Kind: writeInt32,
+		protoreflect.Int64Kind:    writeInt64,
+		protoreflect.Sint64Kind:   writeInt64,
+		protoreflect.Sfixed64Kind: writeInt64,
+		protoreflect.Uint32Kind:   writeUint32,
+		protoreflect.Fixed32Kind:  writeUint32,
+		protoreflect.Uint64Kind:   writeUint64,
+		protoreflect.Fixed64Kind:  writeUint64,
+		protoreflect.FloatKind:    writeFloat,
+		protoreflect.DoubleKind:   writeFloat,
+		protoreflect.StringKind:   writeString,
+		protoreflect.BytesKind:    writeBytes,
+		protoreflect.EnumKind:     writeEnum,
+		protoreflect.MessageKind:  writeMessage,
+		protoreflect.GroupKind:    writeMessage,
+	} {
+		valueWriters[kind] = write
+	}
+}
+
+// valueWriterOf returns the writer of values of kind, or nil for an
+// unknown kind
+func valueWriterOf(kind protoreflect.Kind) valueWriter {
+	if int(kind) < len(valueWriters) {
+		return valueWriters[kind]
+	}
+	return nil
+}
+
+// compareFieldNumbers orders field plans by field number
+func compareFieldNumbers(a, b fieldPlan) int {
+	return cmp.Compare(a.fd.Number(), b.fd.Number())
+}
+
+// fields returns the fields of plan in the order they are written
+func (e *encoder) fields(plan *messagePlan) []fieldPlan {
+	if e.opts.Stable {
+		return plan.byNumber
+	}
+	return plan.fields
+}
+
+// writeKey writes the object key of field f with a single write, dropping
+// the space after the colon unless in Multiline or Indent mode
+func (e *encoder) writeKey(f *fieldPlan) {
+	e.writePlannedKey(e.plannedKey(f))
+}
+
+// plannedKey returns the planned object key of field f for the options
+func (e *encoder) plannedKey(f *fieldPlan) string {
+	switch {
+	case e.opts.UseFieldNumbers:
+		return f.numKey
+	case e.opts.UseProtoNames:
+		return f.protoKey
+	}
+	return f.jsonKey
+}
+
+// writeOneofKey writes the object key of the oneof containing field f like
+// writeKey
+func (e *encoder) writeOneofKey(f *fieldPlan) {
+	e.writePlannedKey(e.plannedOneofKey(f))
+}
+
+// plannedOneofKey returns the planned object key of the oneof containing
+// field f. Oneofs have no numbers, so their proto names are used with
+// UseFieldNumbers.
+func (e *encoder) plannedOneofKey(f *fieldPlan) string {
+	if e.opts.UseProtoNames || e.opts.UseFieldNumbers {
+		return f.oneofProtoKey
+	}
+	return f.oneofKey
+}
+
+// writePlannedKey writes key, a quoted key followed by ": ", dropping the
+// space unless in Multiline or Indent mode
+func (e *encoder) writePlannedKey(key string) {
+	if !e.opts.Multiline && e.opts.Indent == "" {
+		key = key[:len(key)-1]
+	}
+	e.w.WriteString(key)
+}
+
+// enumNames maps the numbers of an enum type to their names, quoted as JSON
+// strings. Numbers with several names map to the first one declared, like
+// EnumValueDescriptors.ByNumber.
+type enumNames struct {
+	min   protoreflect.EnumNumber
+	dense []string                           // Indexed by number - min, if numbers are dense
+	names map[protoreflect.EnumNumber]string // Otherwise
+}
+
+// enums caches the names of each registered enum descriptor, like plans
+var enums sync.Map // map[protoreflect.EnumDescriptor]*enumNames
+
+// enumNamesOf returns the names of ed, building them on first use
+func (e *encoder) enumNamesOf(ed protoreflect.EnumDescriptor) *enumNames {
+	if n, ok := enums.Load(ed); ok {
+		return n.(*enumNames)
+	}
+	if n, ok := e.local.enums[ed]; ok {
+		return n
+	}
+	if registered(ed) {
+		n, _ := enums.LoadOrStore(ed, newEnumNames(ed))
+		return n.(*enumNames)
+	}
+	if e.local.enums == nil || len(e.local.enums) >= maxLocalPlans {
+		e.local.enums = make(map[protoreflect.EnumDescriptor]*enumNames)
+	}
+	n := newEnumNames(ed)
+	e.local.enums[ed] = n
+	return n
+}
+
+// newEnumNames builds the names of ed
+func newEnumNames(ed protoreflect.EnumDescriptor) *enumNames {
+	values := ed.Values()
+	if values.Len() == 0 {
+		return &enumNames{}
+	}
+	lo, hi := values.Get(0).Number(), values.Get(0).Number()
+	for i := 1; i < values.Len(); i++ {
+		lo, hi = min(lo, values.Get(i).Number()), max(hi, values.Get(i).Number())
+	}
+
+	n := &enumNames{min: lo}
+	// Numbers are usually consecutive from zero; use a map for sparse ones
+	if span := int64(hi) - int64(lo) + 1; span <= 2*int64(values.Len())+8 {
+		n.dense = make([]string, span)
+	} else {
+		n.names = make(map[protoreflect.EnumNumber]string, values.Len())
+	}
+	for i := 0; i < values.Len(); i++ {
+		v := values.Get(i)
+		if _, ok := n.get(v.Number()); ok {
+			continue
+		}
+		name := `"` + string(v.Name()) + `"`
+		if n.dense != nil {
+			n.dense[v.Number()-lo] = name
+		} else {
+			n.names[v.Number()] = name
+		}
+	}
+	return n
+}
+
+// get returns the quoted name of num, if it is declared
+func (n *enumNames) get(num protoreflect.EnumNumber) (string, bool) {
+	if n.dense != nil {
+		if i := int64(num) - int64(n.min); i >= 0 && i < int64(len(n.dense)) && n.dense[i] != "" {
+			return n.dense[i], true
+		}
+		return "", false
+	}
+	name, ok := n.names[num]
+	return name, ok
+}
+
+// enumName returns the quoted name of num in enum type ed, if it is
+// declared. The names of the last enum type are kept by the encoder, since
+// values of one type often follow each other.
+func (e *encoder) enumName(ed protoreflect.EnumDescriptor, num protoreflect.EnumNumber) (string, bool) {
+	if e.enumDesc != ed {
+		e.enumDesc, e.enums = ed, e.enumNamesOf(ed)
+	}
+	return e.enums.get(num)
+}
@@ -6,18 +6,27 @@ package protojson
 import (
 	"bufio"
 	"bytes"
+	"cmp"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"math"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 // MarshalOptions configures the marshaling behavior.
@@ -31,6 +40,9 @@ type MarshalOptions struct {
 
 	// Resolver is used for looking up types when expanding google.protobuf.Any
 	// messages. If nil, this defaults to using protoregistry.GlobalTypes.
+	// Extensions set on the embedded message are looked up through Resolver
+	// if it also implements protoregistry.ExtensionTypeResolver, such as a
+	// *protoregistry.Types, and through protoregistry.GlobalTypes otherwise.
 	Resolver interface {
 		FindMessageByName(message protoreflect.FullName) (protoreflect.MessageType, error)
 		FindMessageByURL(url string) (protoreflect.MessageType, error)
@@ -52,6 +64,13 @@ type MarshalOptions struct {
 	// UseEnumNumbers emits enum values as numbers instead of strings.
 	UseEnumNumbers bool
 
+	// UseFieldNumbers keys fields by their field numbers instead of their
+	// names, e.g. {"1":"hello","2":42}, including extensions, for smaller
+	// payloads on internal channels where both sides share the schema.
+	// Map keys and the members of well-known types are not affected. The
+	// output is read back with UnmarshalFieldNumbers.
+	UseFieldNumbers bool
+
 	// EmitUnpopulated specifies whether to emit unpopulated fields. It does not
 	// emit unpopulated oneof fields or unpopulated extension fields.
 	// The JSON value emitted for unpopulated fields are as follows:
@@ -68,14 +87,54 @@ type MarshalOptions struct {
 	//  ╚═══════╧════════════════════════════╝
 	EmitUnpopulated bool
 
-	// EmitDefaultValues specifies whether to emit default-valued fields.
-	// It is an alias for EmitUnpopulated for backward compatibility.
-	// Deprecated: Use EmitUnpopulated instead.
+	// EmitDefaultValues specifies whether to emit default-valued primitive
+	// fields, empty lists, and empty maps. The fields affected are as
+	// follows:
+	//  ╔═══════╤════════════════════════════════════════╗
+	//  ║ JSON  │ Protobuf field                         ║
+	//  ╠═══════╪════════════════════════════════════════╣
+	//  ║ false │ non-optional scalar boolean fields     ║
+	//  ║ 0     │ non-optional scalar numeric fields     ║
+	//  ║ ""    │ non-optional scalar string/byte fields ║
+	//  ║ []    │ empty repeated fields                  ║
+	//  ║ {}    │ empty map fields                       ║
+	//  ╚═══════╧════════════════════════════════════════╝
+	//
+	// Behaves similarly to EmitUnpopulated, but does not emit "null"-value
+	// fields, i.e. presence-sensing fields that are omitted will remain
+	// omitted to preserve presence-sensing. For messages whose fields all
+	// lack presence, the two options write the same output.
+	// EmitUnpopulated takes precedence over EmitDefaultValues.
 	EmitDefaultValues bool
 
+	// EmitUnpopulatedKinds selects kinds of unpopulated fields that are
+	// written as with EmitUnpopulated, e.g. UnpopulatedLists|UnpopulatedMaps
+	// to write empty lists and maps without zero scalars. It adds to the
+	// kinds selected by EmitUnpopulated and EmitDefaultValues.
+	EmitUnpopulatedKinds UnpopulatedKind
+
+	// EmitUnpopulatedPaths writes the unpopulated fields at the given
+	// dotted paths of protobuf field names, and those below them, as with
+	// EmitUnpopulated, in the syntax of IncludePaths, e.g. "settings" to
+	// write every unpopulated field of the settings message.
+	EmitUnpopulatedPaths []string
+
+	// OmitUnpopulatedPaths leaves out the unpopulated fields at the given
+	// paths, and those below them, even if selected by other options. It
+	// takes precedence over EmitUnpopulatedPaths.
+	OmitUnpopulatedPaths []string
+
+	// EmitDeclaredDefaults writes unset fields that declare an explicit
+	// default value, such as proto2 fields with [default = 10], as that
+	// default instead of leaving them out, for consumers that do not have
+	// the schema to apply the defaults themselves. It takes precedence
+	// over the null EmitUnpopulated writes for them, and over
+	// OmitUnpopulatedPaths.
+	EmitDeclaredDefaults bool
+
 	// FieldMaskFunc is called for each field during marshaling to determine
 	// if the field value should be masked. If it returns true, the field value
-	// will be replaced with "***" in the JSON output.
+	// will be replaced with MaskString ("***" by default) in the JSON output.
 	//
 	// The function receives the FieldDescriptor which can be used to check:
 	// - Field name: fd.Name() or fd.JSONName()
@@ -88,79 +147,709 @@ type MarshalOptions struct {
 	// - Field naming patterns (e.g., fields containing "password", "token")
 	// - Any other criteria based on the field descriptor
 	//
+	// For repeated fields, every element is masked, e.g. each of a list of
+	// recovery codes. For map values, fd is the map field, so masking a map
+	// field masks all of its values.
+	//
 	// If FieldMaskFunc is nil, no masking is performed.
 	FieldMaskFunc func(fd protoreflect.FieldDescriptor) bool
+
+	// MaskDebugRedact specifies whether fields whose options set
+	// debug_redact = true are masked, as if FieldMaskFunc returned true
+	// for them.
+	MaskDebugRedact bool
+
+	// Redactors scan the values of string fields, including list elements
+	// and map values, and replace each match with MaskString, or with its
+	// digest in MaskHash mode. They apply in order, on top of the field
+	// masking of FieldMaskFunc; see PIIRedactors for built-in ones.
+	Redactors []Redactor
+
+	// MaskAuditFunc is called after each message is encoded with a report
+	// of the values masked in it, including when there were none, so that
+	// compliance tooling can verify that every record was redacted.
+	MaskAuditFunc func(m proto.Message, report MaskReport)
+
+	// MaskKeyPatterns masks the values of map entries whose key matches any
+	// of the patterns, e.g. "*token*" to mask the values of a metadata map
+	// holding credentials. Patterns use the syntax of path.Match and are
+	// compared case-insensitively.
+	MaskKeyPatterns []string
+
+	// MaskMapKeys specifies whether the keys of masked map entries are
+	// masked along with their values. Keys are replaced by their digest in
+	// MaskHash mode, and otherwise by MaskString followed by the position
+	// of the entry, e.g. "***0", so that they stay unique.
+	MaskMapKeys bool
+
+	// MaskString replaces the values of masked string and bytes fields,
+	// e.g. "[REDACTED]". It defaults to "***".
+	MaskString string
+
+	// MaskMode selects how masked string and bytes values are replaced.
+	// The default writes MaskString.
+	MaskMode MaskMode
+
+	// MaskPolicy selects how masked values of kinds other than string and
+	// bytes are written. By default they are written unchanged.
+	MaskPolicy MaskPolicy
+
+	// MaskHashSalt is prepended to masked values before they are hashed
+	// with SHA-256 in MaskHash mode.
+	MaskHashSalt []byte
+
+	// MaskHashFunc replaces the salted SHA-256 digest in MaskHash mode. It
+	// receives the raw value and returns its digest.
+	MaskHashFunc func(value []byte) []byte
+
+	// TruncateLength limits string and bytes values to the given number of
+	// bytes, e.g. to keep debug logs of messages with large blobs bounded.
+	// Longer values are cut, at a character boundary for strings, and a
+	// marker like "...(+1234 bytes)" giving the number of omitted bytes is
+	// appended. Truncated bytes values no longer decode as bytes; with
+	// BytesArray the marker is written as a final string element. Zero
+	// means no limit.
+	TruncateLength int
+
+	// MaxListElements limits repeated fields and google.protobuf.ListValue
+	// values to their first elements, e.g. to keep debug dumps of huge
+	// messages readable. The omitted elements are replaced by a final
+	// string element like "...(+9950 items)". Zero means no limit.
+	MaxListElements int
+
+	// MaxMapEntries limits map fields and google.protobuf.Struct values to
+	// their first entries, in the order they are written. The omitted
+	// entries are replaced by a final member like "...(+9950 entries)"
+	// with a null value. Zero means no limit.
+	MaxMapEntries int
+
+	// FieldMaskPathFunc is like FieldMaskFunc, but also receives the path
+	// from the marshaled message to the value, e.g. to mask "password" only
+	// under "credentials". The path ends with the step for fd, followed by
+	// the index or key when the value is a list element or map value.
+	// A value is masked if either function returns true.
+	FieldMaskPathFunc func(path FieldPath, fd protoreflect.FieldDescriptor) bool
+
+	// FieldMaskValueFunc is called for each field value, including list
+	// elements and map values, so that masking can depend on the value,
+	// e.g. to keep the domain of an email address. If it reports masked,
+	// the replacement is written instead of v; it must hold the same kind
+	// of value as v, or be invalid to write null, and encoding fails
+	// otherwise. To write arbitrary JSON in place of a value, use
+	// RawJSONFunc. FieldMaskFunc and FieldMaskPathFunc take precedence for
+	// string and bytes fields.
+	FieldMaskValueFunc func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (replacement protoreflect.Value, masked bool)
+
+	// RawJSONFunc is called for each field before it is written. If it
+	// reports ok, raw is written verbatim as the field's value instead of
+	// encoding v, e.g. for a bytes field that already holds JSON or a
+	// cached sub-document. raw must be a valid JSON value; it replaces the
+	// whole field, bypassing masking and formatting options.
+	RawJSONFunc func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (raw []byte, ok bool)
+
+	// TransformValue is called for each singular value, including list
+	// elements and map values, before it is masked and written, e.g. to
+	// convert units or normalize strings without cloning the message. The
+	// returned value is written instead of v; it must hold the same kind of
+	// value as v, or be invalid to write null. For map values, fd is the
+	// descriptor of the map entry's value field. An error aborts encoding.
+	TransformValue func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, error)
+
+	// AllowInvalidUTF8 replaces each invalid UTF-8 byte in strings with the
+	// Unicode replacement character U+FFFD instead of returning an error
+	// wrapping ErrInvalidUTF8.
+	AllowInvalidUTF8 bool
+
+	// UnorderedMaps writes map entries and google.protobuf.Struct fields in
+	// Go map iteration order instead of sorting them by key. This avoids
+	// collecting and sorting the keys of every map, at the cost of output
+	// that differs between runs.
+	UnorderedMaps bool
+
+	// TimestampPrecision forces the number of fractional second digits,
+	// from 1 to 9, written for google.protobuf.Timestamp values. Excess
+	// precision is truncated. If zero, fractional seconds are written with
+	// 0, 3, 6 or 9 digits as needed, like the standard package.
+	TimestampPrecision int
+
+	// FloatDecimalPoint specifies whether float and double values that are
+	// whole numbers are written with a trailing ".0", e.g. 42.0 rather than
+	// 42, so schemaless consumers do not infer an integer type.
+	FloatDecimalPoint bool
+
+	// NonFinitePolicy selects how NaN and infinite float and double values
+	// are written. The default writes the strings the protobuf JSON mapping
+	// specifies.
+	NonFinitePolicy NonFinitePolicy
+
+	// Int64AsNumber specifies whether 64-bit integer fields are written as
+	// JSON numbers instead of strings. Map keys are always strings.
+	Int64AsNumber bool
+
+	// EscapeLineSeparators specifies whether U+2028 LINE SEPARATOR and
+	// U+2029 PARAGRAPH SEPARATOR in strings are escaped as \u2028 and
+	// \u2029, so the output can be embedded in JavaScript source such as
+	// <script> blocks.
+	EscapeLineSeparators bool
+
+	// ASCIIOnly specifies whether all non-ASCII characters in strings are
+	// escaped as \uXXXX, with surrogate pairs for characters outside the
+	// Basic Multilingual Plane, so the output is pure ASCII.
+	ASCIIOnly bool
+
+	// FloatFormat selects how finite float and double values are written.
+	// The default matches the standard package.
+	FloatFormat FloatFormat
+
+	// TimestampFormat selects how google.protobuf.Timestamp values are
+	// written. The default is an RFC 3339 string; the Unix formats write
+	// numbers, or strings if TimestampEpochAsString is set.
+	TimestampFormat TimestampFormat
+
+	// TimestampEpochAsString specifies whether Unix TimestampFormat values
+	// are quoted, e.g. for consumers that cannot represent large integers.
+	TimestampEpochAsString bool
+
+	// DurationFormat selects how google.protobuf.Duration values are
+	// written. The default matches the standard package.
+	DurationFormat DurationFormat
+
+	// BytesEncoding selects how bytes fields are written. The default is
+	// standard base64 like the standard package.
+	BytesEncoding BytesEncoding
+
+	// UnresolvedAny selects how google.protobuf.Any messages are handled
+	// when their type cannot be resolved or their payload cannot be
+	// unmarshaled. The default returns an error like the standard package.
+	UnresolvedAny UnresolvedAnyPolicy
+
+	// Formatters maps full names of message types to custom formatters
+	// that write them instead of objects of their fields, e.g. to give an
+	// application's own "well-known" types a compact form. They take
+	// precedence over the built-in forms of the well-known types. With
+	// Indent or Multiline set, their output is re-indented to match.
+	Formatters map[protoreflect.FullName]Formatter
+
+	// GoogleTypes specifies whether common google.type messages are written
+	// in friendly forms: google.type.Date as an ISO 8601 date such as
+	// "2024-05-01", TimeOfDay as "13:45:30.5", LatLng as "37.42,-122.08" and
+	// Color as a hex color such as "#ff8000".
+	GoogleTypes bool
+
+	// MoneyFormat selects how google.type.Money is written. The default is
+	// an object of its fields.
+	MoneyFormat MoneyFormat
+
+	// EnumAsObject specifies whether enum values are written as objects
+	// carrying both representations, e.g. {"name":"STATUS_ACTIVE","number":1}.
+	// It takes precedence over UseEnumNumbers.
+	EnumAsObject bool
+
+	// WrapperAsObject specifies whether values of the wrapper types, such
+	// as google.protobuf.Int32Value, are written as objects of their value
+	// field, e.g. {"value":42} rather than 42, so that downstream systems
+	// without field presence can tell an unset wrapper from a zero one.
+	// The standard package does not accept this form when unmarshaling.
+	WrapperAsObject bool
+
+	// UnknownEnum selects how enum values without a declared name are
+	// written when UseEnumNumbers is not set. The default writes the number.
+	UnknownEnum UnknownEnumPolicy
+
+	// UnknownEnumName is the name written for unknown enum values with
+	// UnknownEnumPlaceholder. If empty, the number is written instead.
+	UnknownEnumName string
+
+	// EmitUnknownFields specifies whether fields that were not recognized
+	// when the message was parsed are written. Unknown fields that resolve
+	// to an extension through Resolver are written like set extensions; the
+	// rest are written as a base64 string of their wire encoding under
+	// UnknownFieldsKey.
+	EmitUnknownFields bool
+
+	// UnknownFieldsKey is the member name used for the wire encoding of
+	// unknown fields. It defaults to "_unknown".
+	UnknownFieldsKey string
+
+	// WrapOneofs writes the set field of each oneof wrapped in an object
+	// keyed by the name of the oneof, e.g. {"result":{"error":"..."}}
+	// rather than {"error":"..."}, so consumers can tell which case is set
+	// without knowing the names of all cases. The oneof name is written in
+	// lowerCamelCase unless UseProtoNames is set. The output can no longer
+	// be read by standard protobuf JSON parsers. The synthetic oneofs of
+	// proto3 optional fields are not wrapped.
+	WrapOneofs bool
+
+	// EmitTypeName selects the messages that are written with a member
+	// holding the full name of their type, e.g. "@type":"shop.v1.Order",
+	// so polymorphic consumers can dispatch on it without wrapping the
+	// messages in google.protobuf.Any. The member comes first in objects
+	// of message fields; messages written in other forms, such as
+	// well-known types, are unchanged. The default writes no type names.
+	EmitTypeName TypeNameScope
+
+	// TypeNameKey is the member name used for type names written with
+	// EmitTypeName. It defaults to "@type".
+	TypeNameKey string
+
+	// IncludePaths restricts the output to the fields at the given dotted
+	// paths of protobuf field names and their ancestors, e.g. "user.name".
+	// List elements and map values are addressed by index or key, and a
+	// "*" segment matches any field, index or key, e.g. "users.*.name".
+	// A path selects the whole subtree below it. If empty, all fields are
+	// included.
+	IncludePaths []string
+
+	// ExcludePaths leaves out the fields at the given paths, in the syntax
+	// of IncludePaths, along with their subtrees, e.g. "users.*.metadata".
+	// Exclusion takes precedence over inclusion.
+	ExcludePaths []string
+
+	// FieldMask restricts the output to the masked fields, like a partial
+	// response honoring a read_mask. Paths through repeated fields apply to
+	// every element, and the segment after a map field is a map key or "*".
+	// A nil or empty mask selects all fields. It combines with IncludePaths
+	// and ExcludePaths.
+	FieldMask *fieldmaskpb.FieldMask
+
+	// MaxOutputBytes limits the size of the JSON output of a single message.
+	// If the output would exceed the limit, encoding is aborted with an error
+	// wrapping ErrOutputTooLarge. Output written before the limit was hit may
+	// already have reached the underlying writer. Zero means no limit.
+	MaxOutputBytes int
+
+	// TruncateOutput makes output that would exceed MaxOutputBytes fit the
+	// limit instead of failing, e.g. for log lines of bounded length. The
+	// message is encoded in full, then values are elided, largest and
+	// deepest first, and a "_truncated": true member is added to the
+	// top-level object, or a "...(truncated)" element to a top-level array,
+	// so that the result is still valid JSON. Indent and Multiline are
+	// ignored. Encoding still fails with ErrOutputTooLarge if the top-level
+	// value is neither an object nor an array, or the limit is too small
+	// for one holding only the marker.
+	TruncateOutput bool
+
+	// Cache, if set, memoizes the JSON encoding of messages of the types it
+	// was created for, wherever they appear. See MessageCache.
+	Cache *MessageCache
+
+	// Canonical writes output canonicalized per RFC 8785, the JSON
+	// Canonicalization Scheme (JCS), so that documents can be hashed and
+	// signed the same way across languages: object members are sorted by
+	// the UTF-16 code units of their keys, numbers are formatted like
+	// ECMAScript, strings are escaped minimally and there is no whitespace.
+	// Indent, Multiline, ASCIIOnly and EscapeLineSeparators are ignored.
+	// Numbers are IEEE 754 doubles in JCS, so integers written as numbers,
+	// e.g. with Int64AsNumber, lose precision beyond 2^53.
+	Canonical bool
+
+	// Stable guarantees byte-identical output for equal messages across
+	// process runs and versions of this package, for content-addressed
+	// storage: fields are written in field number order, so that reordering
+	// declarations in a .proto file does not change the output, map
+	// entries and google.protobuf.Struct fields are sorted by key even if
+	// UnorderedMaps is set, and extensions are sorted by full name. The
+	// encoder always walks messages with protoreflect rather than calling
+	// generated MarshalProtoJSON methods, which follow declaration order.
+	Stable bool
+
+	// Flatten writes a single flat object mapping the dotted path of each
+	// scalar value to the value, e.g. "user.profile.address.city": "Tokyo",
+	// for logging and metrics backends that index flat documents better
+	// than nested ones. Path segments are the keys that would otherwise be
+	// written, and list elements are addressed by index, e.g. "tags.0".
+	// Keys containing dots are not escaped. Empty objects and lists are
+	// kept as values. The message must be written as a JSON object, so
+	// well-known types such as google.protobuf.Timestamp cannot be
+	// flattened at the top level.
+	Flatten bool
+
+	// Hooks are called while messages are encoded, for instrumentation.
+	// Messages are not taken from Cache while any hook is set.
+	Hooks Hooks
+
+	// Comments, if set, annotates fields with comments, e.g. the leading
+	// comments of their .proto declarations loaded with
+	// CommentsFromDescriptorSet, writing JSONC (JSON with comments) for
+	// annotated example configurations. Comments are written as // lines
+	// before each field in Multiline or Indent mode and as /* */ blocks
+	// otherwise. The output is not valid JSON, so Comments is ignored with
+	// Canonical, Flatten and TruncateOutput, by encoders of other formats
+	// such as CSVEncoder, and where JSON is required, such as by
+	// JSONWrapper, LogValue, Codec, JSONColumn, MarshalDiff and
+	// MarshalMergePatch.
+	Comments FieldComments
 }
 
+// MaskMode selects how masked string and bytes values are marshaled.
+type MaskMode int
+
+const (
+	// MaskReplace writes MarshalOptions.MaskString.
+	MaskReplace MaskMode = iota
+	// MaskHash writes the lowercase hex digest of the value, so equal
+	// values stay joinable across records without being readable.
+	MaskHash
+)
+
+// MaskPolicy selects how masked values of kinds other than string and bytes
+// are marshaled.
+type MaskPolicy int
+
+const (
+	// MaskUnchanged writes the value as if it were not masked.
+	MaskUnchanged MaskPolicy = iota
+	// MaskZero writes 0 for numbers, false for bools, MarshalOptions.MaskString
+	// for enums and {} for messages.
+	MaskZero
+	// MaskNull writes null.
+	MaskNull
+	// MaskOmit leaves out the field, or the list element or map entry
+	// holding the value. Where a value cannot be left out, such as in
+	// diffs, null is written.
+	MaskOmit
+)
+
+// MoneyFormat selects how google.type.Money messages are marshaled.
+type MoneyFormat int
+
+const (
+	// MoneyObject writes an object of the message fields, e.g.
+	// {"currencyCode":"USD","units":"12","nanos":340000000}.
+	MoneyObject MoneyFormat = iota
+	// MoneyString writes the decimal amount and currency code, e.g.
+	// "12.34 USD".
+	MoneyString
+)
+
+// UnresolvedAnyPolicy selects how google.protobuf.Any messages with an
+// unresolvable type or invalid payload are marshaled.
+type UnresolvedAnyPolicy int
+
+const (
+	// UnresolvedAnyError returns an error.
+	UnresolvedAnyError UnresolvedAnyPolicy = iota
+	// UnresolvedAnyBase64 writes the raw payload as base64 under "value".
+	UnresolvedAnyBase64
+	// UnresolvedAnySkip writes only "@type" and drops the payload.
+	UnresolvedAnySkip
+)
+
+// FloatFormat selects how finite float and double values are written.
+type FloatFormat int
+
+const (
+	// FloatFormatStandard matches the standard package: plain decimal
+	// notation, switching to exponent form below 1e-6 and from 1e21.
+	FloatFormatStandard FloatFormat = iota
+	// FloatFormatShortest uses strconv's 'g' format, which switches to
+	// exponent form whenever it is shorter.
+	FloatFormatShortest
+)
+
+// TimestampFormat selects how google.protobuf.Timestamp values are marshaled.
+type TimestampFormat int
+
+const (
+	// TimestampRFC3339 writes an RFC 3339 string like the standard package.
+	TimestampRFC3339 TimestampFormat = iota
+	// TimestampUnixSeconds writes seconds since the Unix epoch, with a
+	// fraction for sub-second values.
+	TimestampUnixSeconds
+	// TimestampUnixMillis writes whole milliseconds since the Unix epoch.
+	TimestampUnixMillis
+	// TimestampUnixMicros writes whole microseconds since the Unix epoch.
+	TimestampUnixMicros
+	// TimestampUnixNanos writes nanoseconds since the Unix epoch.
+	TimestampUnixNanos
+)
+
+// DurationFormat selects how google.protobuf.Duration values are marshaled.
+type DurationFormat int
+
+const (
+	// DurationSeconds writes a string of seconds with an "s" suffix, e.g.
+	// "5400s", like the standard package.
+	DurationSeconds DurationFormat = iota
+	// DurationSecondsNumber writes seconds as a number, e.g. 5400.5.
+	DurationSecondsNumber
+	// DurationNanos writes whole nanoseconds as a number.
+	DurationNanos
+	// DurationISO8601 writes an ISO 8601 duration string of hours, minutes
+	// and seconds, e.g. "PT1H30M". Negative durations have a leading "-".
+	DurationISO8601
+)
+
+// BytesEncoding selects how bytes values are marshaled.
+type BytesEncoding int
+
+const (
+	// BytesBase64 writes standard base64 with padding, like the standard
+	// package.
+	BytesBase64 BytesEncoding = iota
+	// BytesBase64URL writes URL-safe base64 with padding.
+	BytesBase64URL
+	// BytesHex writes lowercase hexadecimal.
+	BytesHex
+	// BytesArray writes a JSON array of byte values, e.g. [1,255].
+	BytesArray
+)
+
+// UnknownEnumPolicy selects how enum values without a declared name are
+// marshaled when enums are written by name.
+type UnknownEnumPolicy int
+
+const (
+	// UnknownEnumNumber writes the number, like the standard package.
+	UnknownEnumNumber UnknownEnumPolicy = iota
+	// UnknownEnumError returns an error.
+	UnknownEnumError
+	// UnknownEnumPlaceholder writes MarshalOptions.UnknownEnumName, or the
+	// number if it is empty.
+	UnknownEnumPlaceholder
+	// UnknownEnumOmit leaves out the field, or the list element or map
+	// entry holding the value.
+	UnknownEnumOmit
+)
+
+// NonFinitePolicy selects how NaN and infinite float and double values are
+// marshaled.
+type NonFinitePolicy int
+
+const (
+	// NonFiniteString writes "NaN", "Infinity" and "-Infinity" strings as
+	// the protobuf JSON mapping specifies.
+	NonFiniteString NonFinitePolicy = iota
+	// NonFiniteNull writes null.
+	NonFiniteNull
+	// NonFiniteZero writes 0, or 0.0 if FloatDecimalPoint is set.
+	NonFiniteZero
+	// NonFiniteError returns an error wrapping ErrNonFiniteFloat.
+	NonFiniteError
+)
+
+// ErrNonFiniteFloat is returned, wrapped, for NaN and infinite values when
+// MarshalOptions.NonFinitePolicy is NonFiniteError.
+var ErrNonFiniteFloat = errors.New("non-finite float")
+
+// ErrInvalidUTF8 is returned, wrapped, when a string contains invalid UTF-8
+// and MarshalOptions.AllowInvalidUTF8 is not set.
+var ErrInvalidUTF8 = errors.New("invalid UTF-8 in string")
+
 // Marshal writes the given proto.Message in JSON format using default options.
 // Do not depend on the output being stable. It may change over time across
 // different versions of the program.
 func Marshal(m proto.Message) ([]byte, error) {
-	var buf bytes.Buffer
-	enc := NewEncoder(&buf)
-	if err := enc.Encode(m); err != nil {
+	return MarshalOptions{}.MarshalAppend(nil, m)
+}
+
+// MarshalAppend appends the JSON format encoding of m to dst using default
+// options, returning the result. It allows callers to reuse buffers across
+// calls.
+func MarshalAppend(dst []byte, m proto.Message) ([]byte, error) {
+	return MarshalOptions{}.MarshalAppend(dst, m)
+}
+
+// Marshal writes the given proto.Message in JSON format using options in o.
+// Messages generated by the legacy github.com/golang/protobuf package are
+// passed through protoadapt.MessageV2Of.
+func (o MarshalOptions) Marshal(m proto.Message) ([]byte, error) {
+	return o.MarshalAppend(nil, m)
+}
+
+// MarshalAppend appends the JSON format encoding of m to dst using options
+// in o, returning the result. If an error is returned, dst is returned
+// unmodified.
+func (o MarshalOptions) MarshalAppend(dst []byte, m proto.Message) ([]byte, error) {
+	s := marshalPool.Get().(*marshalState)
+	defer s.release()
+
+	s.enc.SetOptions(o)
+	if err := s.enc.Encode(m); err != nil {
+		return dst, err
+	}
+	return append(dst, s.buf.Bytes()...), nil
+}
+
+// MarshalList writes the given messages as a JSON array using default
+// options.
+func MarshalList(msgs []proto.Message) ([]byte, error) {
+	return MarshalOptions{}.MarshalList(msgs)
+}
+
+// MarshalList writes the given messages as a JSON array using options in o.
+func (o MarshalOptions) MarshalList(msgs []proto.Message) ([]byte, error) {
+	s := marshalPool.Get().(*marshalState)
+	defer s.release()
+
+	s.enc.SetOptions(o)
+	if err := s.enc.EncodeList(msgs); err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+	return append([]byte(nil), s.buf.Bytes()...), nil
+}
+
+// maxPooledBufferSize is the largest output buffer kept in marshalPool.
+// Larger buffers are dropped so that a single huge message does not pin
+// memory for the lifetime of the pool.
+const maxPooledBufferSize = 64 << 10
+
+// marshalState holds the reusable buffers for a single Marshal call.
+type marshalState struct {
+	buf bytes.Buffer
+	enc *Encoder
+}
+
+var marshalPool = sync.Pool{
+	New: func() any {
+		s := &marshalState{}
+		s.enc = NewEncoder(&s.buf)
+		return s
+	},
+}
+
+// release resets s and returns it to marshalPool
+func (s *marshalState) release() {
+	if s.buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	s.buf.Reset()
+	s.enc.Reset(&s.buf)
+	// Drop references to caller-provided resolvers and callbacks, keeping
+	// the plans of types built at run time
+	s.enc.opts = MarshalOptions{}
+	s.enc.enc = encoder{local: s.enc.enc.local}
+	marshalPool.Put(s)
+}
+
+// writer is the set of methods the encoder needs from its output.
+// It is satisfied by *bufio.Writer, *bytes.Buffer and *strings.Builder.
+type writer interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
 }
 
 // encoder is the internal JSON encoder
 type encoder struct {
-	w     *bufio.Writer
+	w     writer
 	opts  MarshalOptions
 	depth int
-	buf   [64]byte // Scratch buffer for number formatting
+	buf   [64]byte     // Scratch buffer for number formatting
+	limit *limitWriter // Non-nil when MaxOutputBytes is set
+
+	enumDesc protoreflect.EnumDescriptor // Enum type last looked up by enumName
+	enums    *enumNames                  // Names of enumDesc
+	local    localPlans                  // Plans of types built at run time
+
+	flusher *flushWriter // Non-nil when SetFlushThreshold is set
+	filter  *pathFilter  // Non-nil when IncludePaths or ExcludePaths is set
+	path    FieldPath    // Path of the value being written, kept if trackPath
+
+	trackPath bool // Whether path is kept, for path filters, FieldMaskPathFunc, MaskAuditFunc and unpopulated paths
+
+	emitKinds   UnpopulatedKind   // Kinds of unpopulated fields written
+	unpopulated *unpopulatedPaths // Non-nil when EmitUnpopulatedPaths or OmitUnpopulatedPaths is set
+
+	hooks bool         // Whether any of Hooks is set
+	count *countWriter // Counts the output for OnMessageEnd, non-nil when it is set
+
+	audit  bool       // Whether masking is recorded for MaskAuditFunc
+	report MaskReport // Masking recorded while writing the current message
+	masked int        // Number of values masked in the current message
+
+	root bool // Whether the top-level message is yet to be written, for EmitTypeName
+
+	cacheOptions int // Identifier of opts in opts.Cache, 0 if not yet looked up or -1 if they cannot be cached
+
+	gw        Writer // Passed to generated MarshalProtoJSON methods
+	generated int8   // Whether generated methods are used: 0 if not yet known, 1 if so, -1 if not
 }
 
 // marshalMessage marshals a protobuf message to JSON
 func (e *encoder) marshalMessage(m protoreflect.Message) error {
-	msgDesc := m.Descriptor()
-
-	// Handle well-known types
-	if msgDesc.FullName() == "google.protobuf.Timestamp" {
-		return e.marshalTimestamp(m)
+	plan := e.planOf(m.Descriptor())
+	if e.hooks {
+		return e.marshalHooked(m, plan)
 	}
-	if msgDesc.FullName() == "google.protobuf.Duration" {
-		return e.marshalDuration(m)
+	if c := e.opts.Cache; c != nil && c.names[plan.name] && e.cacheable() {
+		return e.marshalCached(c, m, plan)
 	}
-	if msgDesc.FullName() == "google.protobuf.Struct" {
-		return e.marshalStruct(m)
-	}
-	if msgDesc.FullName() == "google.protobuf.Value" {
-		return e.marshalValue(m)
-	}
-	if msgDesc.FullName() == "google.protobuf.ListValue" {
-		return e.marshalListValue(m)
+	return e.marshalPlanned(m, plan)
+}
+
+// marshalPlanned marshals a message of the type described by plan
+func (e *encoder) marshalPlanned(m protoreflect.Message, plan *messagePlan) error {
+	typeNamed := e.opts.EmitTypeName != TypeNameNone && e.typeNamed()
+	if f, ok := e.opts.Formatters[plan.name]; ok {
+		return e.marshalFormatted(f, m)
 	}
-	if msgDesc.FullName() == "google.protobuf.Any" {
-		return e.marshalAny(m)
+	if plan.wellKnown != nil {
+		return plan.wellKnown(e, m)
 	}
-	if msgDesc.FullName() == "google.protobuf.Empty" {
-		e.w.WriteString("{}")
-		return nil
+	if f := e.googleTypeFormatter(plan.name); f != nil {
+		return f(e, m)
 	}
-
-	// Handle wrapper types
-	if e.isWrapperType(msgDesc.FullName()) {
-		return e.marshalWrapper(m)
+	if g, ok := m.Interface().(Marshaler); ok && e.useGenerated() {
+		return g.MarshalProtoJSON(&e.gw)
 	}
 
 	e.w.WriteByte('{')
 	e.depth++
 
-	fields := m.Descriptor().Fields()
 	first := true
+	if typeNamed {
+		if err := e.writeTypeName(plan.name); err != nil {
+			return err
+		}
+		first = false
+	}
+	first, err := e.marshalFields(m, plan, first)
+	if err != nil {
+		return err
+	}
+
+	e.depth--
+	if !first {
+		e.writeIndent()
+	}
+	e.w.WriteByte('}')
 
-	for i := 0; i < fields.Len(); i++ {
-		fd := fields.Get(i)
+	return nil
+}
 
-		// Skip unpopulated fields
-		// For optional/oneof fields: skip if not present
-		// For regular proto3 fields: skip unless EmitUnpopulated is set
+// marshalFields writes the populated fields of m as object members. first
+// reports whether no member has been written to the enclosing object yet;
+// the updated value is returned.
+func (e *encoder) marshalFields(m protoreflect.Message, plan *messagePlan, first bool) (bool, error) {
+	fields := e.fields(plan)
+	for i := range fields {
+		f := &fields[i]
+		fd := f.fd
+
+		// Skip unpopulated fields, unless selected by EmitUnpopulated and
+		// related options. Fields with presence are written as null, others
+		// as their default value; oneof fields are always skipped.
+		unset := false
 		if !m.Has(fd) {
-			if fd.HasPresence() || !e.opts.EmitUnpopulated {
+			// m.Get returns the declared default of an unset field
+			declared := f.declared && e.opts.EmitDeclaredDefaults
+			if !declared && !e.emitUnpopulated(f) {
 				continue
 			}
+			unset = f.presence && !declared
+		}
+		if f.singular && e.omitEnum(fd, m.Get(fd)) {
+			continue
+		}
+		if e.trackPath && e.enterPath(fieldStep(fd)) {
+			continue
+		}
+		if f.singular && e.omitMasked(fd) {
+			if e.trackPath {
+				e.leavePath()
+			}
+			continue
 		}
 
 		if !first {
@@ -169,39 +858,114 @@ func (e *encoder) marshalMessage(m protoreflect.Message) error {
 		first = false
 
 		e.writeIndent()
+		if e.opts.Comments != nil {
+			e.writeComment(fd)
+		}
 
-		// Write field name
-		name := e.fieldName(fd)
-		e.w.WriteByte('"')
-		e.w.WriteString(name)
-		e.w.WriteString(`":`)
-
-		// Add space after colon in Multiline or Indent mode
-		if e.opts.Multiline || e.opts.Indent != "" {
-			e.w.WriteByte(' ')
+		wrapped := e.opts.WrapOneofs && f.oneofKey != ""
+		if wrapped {
+			e.writeOneofKey(f)
+			e.w.WriteByte('{')
+			e.depth++
+			e.writeIndent()
+		}
+		e.writeKey(f)
+		if e.hooks && e.opts.Hooks.OnField != nil {
+			e.opts.Hooks.OnField(fd)
 		}
 
 		// Write field value
-		if err := e.marshalField(fd, m.Get(fd)); err != nil {
-			return err
+		if unset {
+			e.w.WriteString("null")
+		} else if err := e.marshalFieldWith(f.write, fd, m.Get(fd)); err != nil {
+			return first, err
+		}
+		if wrapped {
+			e.depth--
+			e.writeIndent()
+			e.w.WriteByte('}')
+		}
+		if err := e.checkLimit(); err != nil {
+			return first, err
+		}
+		if e.trackPath {
+			e.leavePath()
 		}
 	}
 
-	e.depth--
-	if !first {
+	if plan.extensions {
+		var err error
+		if first, err = e.marshalExtensions(m, first); err != nil {
+			return first, err
+		}
+	}
+	if e.opts.EmitUnknownFields && len(m.GetUnknown()) > 0 {
+		return e.marshalUnknown(m, first)
+	}
+	return first, nil
+}
+
+// marshalExtensions writes the populated extension fields of m, ordered by
+// full name like the standard package
+func (e *encoder) marshalExtensions(m protoreflect.Message, first bool) (bool, error) {
+	for _, fd := range sortedExtensions(m) {
+		if e.trackPath && e.enterPath(fieldStep(fd)) {
+			continue
+		}
+		if !fd.IsList() && e.omitMasked(fd) {
+			if e.trackPath {
+				e.leavePath()
+			}
+			continue
+		}
+		if !first {
+			e.writeComma()
+		}
+		first = false
+
 		e.writeIndent()
+		if e.opts.UseFieldNumbers {
+			e.w.WriteByte('"')
+			e.w.Write(strconv.AppendInt(e.buf[:0], int64(fd.Number()), 10))
+			e.w.WriteByte('"')
+		} else {
+			e.w.WriteString(`"[`)
+			e.w.WriteString(string(fd.FullName()))
+			e.w.WriteString(`]"`)
+		}
+		e.writeColon()
+		if e.hooks && e.opts.Hooks.OnField != nil {
+			e.opts.Hooks.OnField(fd)
+		}
+
+		if err := e.marshalField(fd, m.Get(fd)); err != nil {
+			return first, err
+		}
+		if err := e.checkLimit(); err != nil {
+			return first, err
+		}
+		if e.trackPath {
+			e.leavePath()
+		}
 	}
-	e.w.WriteByte('}')
 
-	return nil
+	return first, nil
 }
 
-// fieldName returns the JSON field name for a field descriptor
-func (e *encoder) fieldName(fd protoreflect.FieldDescriptor) string {
-	if e.opts.UseProtoNames {
-		return string(fd.Name())
-	}
-	return fd.JSONName()
+// sortedExtensions returns the populated extension fields of m, ordered by
+// full name
+func sortedExtensions(m protoreflect.Message) []protoreflect.FieldDescriptor {
+	var exts []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if fd.IsExtension() {
+			exts = append(exts, fd)
+		}
+		return true
+	})
+	slices.SortFunc(exts, func(a, b protoreflect.FieldDescriptor) int {
+		return strings.Compare(string(a.FullName()), string(b.FullName()))
+	})
+	return exts
 }
 
 // writeIndent writes indentation based on current depth
@@ -210,10 +974,13 @@ func (e *encoder) writeComma() {
 	// Standard library does not add space after comma
 }
 
+// writeColon writes the name separator, followed by a space in Multiline
+// or Indent mode
 func (e *encoder) writeColon() {
 	e.w.WriteByte(':')
-	// Always add one space after colon
-	e.w.WriteByte(' ')
+	if e.opts.Multiline || e.opts.Indent != "" {
+		e.w.WriteByte(' ')
+	}
 }
 
 func (e *encoder) writeIndent() {
@@ -233,248 +1000,694 @@ func (e *encoder) writeIndent() {
 
 // marshalField marshals a field value
 func (e *encoder) marshalField(fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
-	if fd.IsList() {
-		return e.marshalList(fd, v.List())
+	return e.marshalFieldWith(fieldWriterOf(fd), fd, v)
+}
+
+// marshalFieldWith marshals a field value with write, the writer of fd
+func (e *encoder) marshalFieldWith(write fieldWriter, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	if e.opts.RawJSONFunc != nil {
+		if raw, ok := e.opts.RawJSONFunc(fd, v); ok {
+			return e.writeRawJSON(fd, raw)
+		}
 	}
-	if fd.IsMap() {
-		return e.marshalMap(fd, v.Map())
+	return write(e, fd, v)
+}
+
+// writeRawJSON writes raw JSON returned by RawJSONFunc for fd
+func (e *encoder) writeRawJSON(fd protoreflect.FieldDescriptor, raw []byte) error {
+	if !json.Valid(raw) {
+		return fmt.Errorf("field %s: RawJSONFunc returned invalid JSON", fd.FullName())
 	}
-	return e.marshalSingular(fd, v)
+	e.w.Write(bytes.TrimSpace(raw))
+	return nil
 }
 
 // marshalSingular marshals a singular field value
 func (e *encoder) marshalSingular(fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
-	// Check if this field should be masked
-	if e.opts.FieldMaskFunc != nil && e.opts.FieldMaskFunc(fd) {
-		// Mask string and bytes fields with "***"
+	return e.marshalSingularValue(fd, v, e.maskField(fd))
+}
+
+// marshalSingularValue marshals a singular value of fd, replacing it if
+// masked
+func (e *encoder) marshalSingularValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, masked bool) error {
+	if e.opts.TransformValue != nil {
+		var err error
+		if v, err = e.opts.TransformValue(fd, v); err != nil {
+			return fmt.Errorf("field %s: %w", fd.FullName(), err)
+		}
+		if !v.IsValid() {
+			e.w.WriteString("null")
+			return nil
+		}
+	}
+	if masked {
+		// Mask string and bytes fields with MaskString
 		kind := fd.Kind()
 		if kind == protoreflect.StringKind || kind == protoreflect.BytesKind {
-			e.w.WriteString(`"***"`)
-			return nil
+			e.auditMask()
+			return e.writeMask(fd, v)
 		}
-		// For other types, fall through to normal processing
-		// (user may have set mask condition for non-string/bytes fields)
+		if e.opts.MaskPolicy != MaskUnchanged {
+			e.auditMask()
+			return e.writeMaskPolicy(fd)
+		}
+		// Otherwise fall through to normal processing
 	}
-
-	switch fd.Kind() {
-	case protoreflect.BoolKind:
-		if v.Bool() {
-			e.w.WriteString("true")
-		} else {
-			e.w.WriteString("false")
+	if e.opts.FieldMaskValueFunc != nil {
+		var err error
+		if v, masked, err = e.maskValue(fd, v); err != nil {
+			return err
 		}
-	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
-		b := strconv.AppendInt(e.buf[:0], v.Int(), 10)
-		e.w.Write(b)
-	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
-		e.w.WriteByte('"')
-		b := strconv.AppendInt(e.buf[:0], v.Int(), 10)
-		e.w.Write(b)
-		e.w.WriteByte('"')
-	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
-		b := strconv.AppendUint(e.buf[:0], v.Uint(), 10)
-		e.w.Write(b)
-	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
-		e.w.WriteByte('"')
-		b := strconv.AppendUint(e.buf[:0], v.Uint(), 10)
-		e.w.Write(b)
-		e.w.WriteByte('"')
-	case protoreflect.FloatKind:
-		e.marshalFloat32(float32(v.Float()))
-	case protoreflect.DoubleKind:
-		e.marshalFloat64(v.Float())
-	case protoreflect.StringKind:
-		e.marshalString(v.String())
-	case protoreflect.BytesKind:
-		e.w.WriteByte('"')
-		encoder := base64.NewEncoder(base64.StdEncoding, e.w)
-		encoder.Write(v.Bytes())
-		encoder.Close()
-		e.w.WriteByte('"')
-	case protoreflect.EnumKind:
-		if e.opts.UseEnumNumbers {
-			b := strconv.AppendInt(e.buf[:0], int64(v.Enum()), 10)
-			e.w.Write(b)
-		} else {
-			enumVal := fd.Enum().Values().ByNumber(v.Enum())
-			if enumVal == nil {
-				b := strconv.AppendInt(e.buf[:0], int64(v.Enum()), 10)
-				e.w.Write(b)
-			} else {
-				e.w.WriteByte('"')
-				e.w.WriteString(string(enumVal.Name()))
-				e.w.WriteByte('"')
-			}
+		if masked && !v.IsValid() {
+			e.w.WriteString("null")
+			return nil
 		}
-	case protoreflect.MessageKind, protoreflect.GroupKind:
-		return e.marshalMessage(v.Message())
-	default:
-		return fmt.Errorf("unknown field kind: %v", fd.Kind())
 	}
-	return nil
+
+	if write := valueWriterOf(fd.Kind()); write != nil {
+		return write(e, fd, v)
+	}
+	return fmt.Errorf("unknown field kind: %v", fd.Kind())
 }
 
-// marshalFloat32 marshals a float32 value
-func (e *encoder) marshalFloat32(f float32) {
-	switch {
-	case math.IsNaN(float64(f)):
-		e.w.WriteString(`"NaN"`)
-	case math.IsInf(float64(f), 1):
-		e.w.WriteString(`"Infinity"`)
-	case math.IsInf(float64(f), -1):
-		e.w.WriteString(`"-Infinity"`)
-	default:
-		b := strconv.AppendFloat(e.buf[:0], float64(f), 'g', -1, 32)
-		e.w.Write(b)
+// writeBool writes a bool value
+func writeBool(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	if v.Bool() {
+		e.w.WriteString("true")
+	} else {
+		e.w.WriteString("false")
 	}
+	return nil
 }
 
-// marshalFloat64 marshals a float64 value
-func (e *encoder) marshalFloat64(f float64) {
-	switch {
-	case math.IsNaN(f):
-		e.w.WriteString(`"NaN"`)
-	case math.IsInf(f, 1):
-		e.w.WriteString(`"Infinity"`)
-	case math.IsInf(f, -1):
-		e.w.WriteString(`"-Infinity"`)
-	default:
-		b := strconv.AppendFloat(e.buf[:0], f, 'g', -1, 64)
+// writeInt32 writes a 32-bit signed integer value
+func writeInt32(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	e.w.Write(strconv.AppendInt(e.buf[:0], v.Int(), 10))
+	return nil
+}
+
+// writeInt64 writes a 64-bit signed integer value, quoted unless
+// Int64AsNumber is set
+func writeInt64(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	b := strconv.AppendInt(e.buf[:0], v.Int(), 10)
+	if e.opts.Int64AsNumber {
 		e.w.Write(b)
+		return nil
 	}
+	e.w.WriteByte('"')
+	e.w.Write(b)
+	e.w.WriteByte('"')
+	return nil
 }
 
-// marshalString marshals a string value with proper escaping
-func (e *encoder) marshalString(s string) {
-	e.w.WriteByte('"')
+// writeUint32 writes a 32-bit unsigned integer value
+func writeUint32(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	e.w.Write(strconv.AppendUint(e.buf[:0], v.Uint(), 10))
+	return nil
+}
 
-	// Fast path: check if escaping is needed
-	needsEscape := false
-	for i := 0; i < len(s); i++ {
-		c := s[i]
-		if c < 0x20 || c == '"' || c == '\\' {
-			needsEscape = true
-			break
-		}
+// writeUint64 writes a 64-bit unsigned integer value, quoted unless
+// Int64AsNumber is set
+func writeUint64(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	b := strconv.AppendUint(e.buf[:0], v.Uint(), 10)
+	if e.opts.Int64AsNumber {
+		e.w.Write(b)
+		return nil
 	}
+	e.w.WriteByte('"')
+	e.w.Write(b)
+	e.w.WriteByte('"')
+	return nil
+}
 
-	if !needsEscape {
-		e.w.WriteString(s)
-		e.w.WriteByte('"')
-		return
+// writeFloat writes a float or double value
+func writeFloat(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	bitSize := 64
+	if fd.Kind() == protoreflect.FloatKind {
+		bitSize = 32
 	}
-
-	// Slow path: write with escaping, chunking between special characters
-	start := 0
-	for i := 0; i < len(s); i++ {
-		c := s[i]
-		var escape string
-
-		switch c {
-		case '"':
-			escape = `\"`
-		case '\\':
-			escape = `\\`
-		case '\n':
-			escape = `\n`
-		case '\r':
-			escape = `\r`
-		case '\t':
-			escape = `\t`
-		case '\b':
-			escape = `\b`
-		case '\f':
-			escape = `\f`
-		default:
-			if c < 0x20 {
-				escape = fmt.Sprintf(`\u%04x`, c)
-			} else {
-				continue
-			}
-		}
-
-		// Write chunk before escape
-		if i > start {
-			e.w.WriteString(s[start:i])
-		}
-		e.w.WriteString(escape)
-		start = i + 1
+	if err := e.marshalFloat(v.Float(), bitSize); err != nil {
+		return fmt.Errorf("field %s: %w", fd.FullName(), err)
 	}
+	return nil
+}
 
-	// Write remaining chunk
-	if start < len(s) {
-		e.w.WriteString(s[start:])
+// writeString writes a string value, redacted and truncated as configured
+func writeString(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	s := v.String()
+	if len(e.opts.Redactors) > 0 {
+		s = e.redact(s)
+	}
+	if n := e.opts.TruncateLength; n > 0 && len(s) > n {
+		s = truncateString(s, n)
 	}
+	if err := e.marshalString(s); err != nil {
+		return fmt.Errorf("field %s: %w", fd.FullName(), err)
+	}
+	return nil
+}
 
-	e.w.WriteByte('"')
+// writeBytes writes a bytes value, truncated as configured
+func writeBytes(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	b, marker := v.Bytes(), ""
+	if n := e.opts.TruncateLength; n > 0 && len(b) > n {
+		b, marker = b[:n], truncateMarker(len(b)-n)
+	}
+	e.marshalBytes(b, marker)
+	return nil
 }
 
-// marshalList marshals a repeated field
-func (e *encoder) marshalList(fd protoreflect.FieldDescriptor, list protoreflect.List) error {
-	e.w.WriteByte('[')
-	for i := 0; i < list.Len(); i++ {
-		if i > 0 {
-			e.writeComma()
-		}
-		if err := e.marshalSingular(fd, list.Get(i)); err != nil {
+// writeEnum writes an enum value by name, number or as an object
+func writeEnum(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	// The JSON mapping writes NullValue as null, whatever the number
+	if fd.Enum().FullName() == "google.protobuf.NullValue" {
+		e.w.WriteString("null")
+		return nil
+	}
+	if e.opts.EnumAsObject {
+		return e.marshalEnumObject(fd, v.Enum())
+	}
+	if !e.opts.UseEnumNumbers {
+		ok, err := e.marshalEnumName(fd, v.Enum())
+		if ok || err != nil {
 			return err
 		}
 	}
-	e.w.WriteByte(']')
+	e.w.Write(strconv.AppendInt(e.buf[:0], int64(v.Enum()), 10))
 	return nil
 }
 
-// marshalMap marshals a map field
-func (e *encoder) marshalMap(fd protoreflect.FieldDescriptor, m protoreflect.Map) error {
-	e.w.WriteByte('{')
-
-	// Get key and value field descriptors once
-	keyFd := fd.MapKey()
-	valFd := fd.MapValue()
-
-	// Sort keys for deterministic output
-	// Pre-allocate with capacity to avoid reallocation
-	keys := make([]protoreflect.MapKey, 0, m.Len())
-	m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
-		keys = append(keys, k)
-		return true
-	})
-
-	slices.SortFunc(keys, func(a, b protoreflect.MapKey) int {
-		return strings.Compare(a.String(), b.String())
-	})
+// writeMessage writes a message value
+func writeMessage(e *encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	return e.marshalMessage(v.Message())
+}
 
-	// Check key type once
-	isStringKey := keyFd.Kind() == protoreflect.StringKind
+// marshalEnumName writes the name of enum value n, or the UnknownEnum
+// placeholder for numbers without a declared name. It reports false if
+// nothing was written because the number should be written instead.
+func (e *encoder) marshalEnumName(fd protoreflect.FieldDescriptor, n protoreflect.EnumNumber) (bool, error) {
+	if name, ok := e.enumName(fd.Enum(), n); ok {
+		e.w.WriteString(name)
+		return true, nil
+	}
 
-	for i, k := range keys {
-		if i > 0 {
-			e.writeComma()
+	switch e.opts.UnknownEnum {
+	case UnknownEnumError:
+		return false, fmt.Errorf("field %s: unknown enum value %d", fd.FullName(), n)
+	case UnknownEnumPlaceholder:
+		if e.opts.UnknownEnumName == "" {
+			return false, nil
+		}
+		if err := e.marshalString(e.opts.UnknownEnumName); err != nil {
+			return false, fmt.Errorf("field %s: %w", fd.FullName(), err)
 		}
+		return true, nil
+	}
+	return false, nil
+}
 
-		// Marshal key
-		if isStringKey {
-			e.marshalString(k.String())
-		} else {
+// marshalEnumObject writes enum value n as {"name": ..., "number": ...}.
+// The name is left out for numbers without a declared name unless a
+// placeholder is configured.
+func (e *encoder) marshalEnumObject(fd protoreflect.FieldDescriptor, n protoreflect.EnumNumber) error {
+	e.w.WriteByte('{')
+	e.depth++
+
+	_, declared := e.enumName(fd.Enum(), n)
+	switch {
+	case declared,
+		e.opts.UnknownEnum == UnknownEnumError,
+		e.opts.UnknownEnum == UnknownEnumPlaceholder && e.opts.UnknownEnumName != "":
+		e.writeIndent()
+		e.w.WriteString(`"name"`)
+		e.writeColon()
+		if _, err := e.marshalEnumName(fd, n); err != nil {
+			return err
+		}
+		e.writeComma()
+	}
+
+	e.writeIndent()
+	e.w.WriteString(`"number"`)
+	e.writeColon()
+	e.w.Write(strconv.AppendInt(e.buf[:0], int64(n), 10))
+
+	e.depth--
+	e.writeIndent()
+	e.w.WriteByte('}')
+	return nil
+}
+
+// marshalBytes marshals a bytes value in the configured BytesEncoding,
+// followed by marker if it is not empty
+func (e *encoder) marshalBytes(b []byte, marker string) {
+	switch e.opts.BytesEncoding {
+	case BytesHex:
+		e.w.WriteByte('"')
+		for len(b) > 0 {
+			n := min(len(b), len(e.buf)/2)
+			hex.Encode(e.buf[:], b[:n])
+			e.w.Write(e.buf[:2*n])
+			b = b[n:]
+		}
+		e.w.WriteString(marker)
+		e.w.WriteByte('"')
+	case BytesArray:
+		e.w.WriteByte('[')
+		for i, c := range b {
+			if i > 0 {
+				e.w.WriteByte(',')
+			}
+			e.w.Write(strconv.AppendUint(e.buf[:0], uint64(c), 10))
+		}
+		if marker != "" {
+			if len(b) > 0 {
+				e.w.WriteByte(',')
+			}
 			e.w.WriteByte('"')
-			e.w.WriteString(k.String())
+			e.w.WriteString(marker)
 			e.w.WriteByte('"')
 		}
+		e.w.WriteByte(']')
+	default:
+		enc := base64.StdEncoding
+		if e.opts.BytesEncoding == BytesBase64URL {
+			enc = base64.URLEncoding
+		}
+		e.w.WriteByte('"')
+		e.writeBase64(enc, b)
+		e.w.WriteString(marker)
+		e.w.WriteByte('"')
+	}
+}
+
+// base64Chunk is the number of bytes encoded at a time by writeBase64. Its
+// 64-byte encoding fills the scratch buffer, and as a multiple of 3 it needs
+// no padding except after the last chunk.
+const base64Chunk = 48
+
+// writeBase64 writes b encoded with enc, through the scratch buffer
+func (e *encoder) writeBase64(enc *base64.Encoding, b []byte) {
+	for len(b) > 0 {
+		n := min(len(b), base64Chunk)
+		dst := e.buf[:enc.EncodedLen(n)]
+		enc.Encode(dst, b[:n])
+		e.w.Write(dst)
+		b = b[n:]
+	}
+}
+
+// marshalFloat marshals a float or double value, writing NaN and infinities
+// according to NonFinitePolicy
+func (e *encoder) marshalFloat(f float64, bitSize int) error {
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		e.writeFloat(f, bitSize)
+		return nil
+	}
+
+	switch e.opts.NonFinitePolicy {
+	case NonFiniteNull:
+		e.w.WriteString("null")
+	case NonFiniteZero:
+		e.writeFloat(0, bitSize)
+	case NonFiniteError:
+		return fmt.Errorf("%w: %v", ErrNonFiniteFloat, f)
+	default:
+		switch {
+		case math.IsNaN(f):
+			e.w.WriteString(`"NaN"`)
+		case f > 0:
+			e.w.WriteString(`"Infinity"`)
+		default:
+			e.w.WriteString(`"-Infinity"`)
+		}
+	}
+	return nil
+}
+
+// writeFloat writes a finite float in the configured FloatFormat
+func (e *encoder) writeFloat(f float64, bitSize int) {
+	if e.opts.FloatFormat == FloatFormatShortest {
+		e.writeFloatBytes(strconv.AppendFloat(e.buf[:0], f, 'g', -1, bitSize))
+		return
+	}
+
+	// Like the standard package (and encoding/json), use exponent form only
+	// for magnitudes below 1e-6 or from 1e21, and drop a leading zero from
+	// negative exponents
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bitSize == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bitSize == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b := strconv.AppendFloat(e.buf[:0], f, format, -1, bitSize)
+	if format == 'e' {
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	e.writeFloatBytes(b)
+}
+
+// writeFloatBytes writes a formatted float, appending ".0" to whole numbers
+// when FloatDecimalPoint is set
+func (e *encoder) writeFloatBytes(b []byte) {
+	e.w.Write(b)
+	if e.opts.FloatDecimalPoint && bytes.IndexAny(b, ".eE") < 0 {
+		e.w.WriteString(".0")
+	}
+}
+
+// marshalString marshals a string value with proper escaping.
+// Invalid UTF-8 results in ErrInvalidUTF8 unless AllowInvalidUTF8 is set,
+// in which case each invalid byte is replaced with U+FFFD.
+func (e *encoder) marshalString(s string) error {
+	e.w.WriteByte('"')
+
+	// Fast path: check if escaping or UTF-8 validation is needed. Non-ASCII
+	// text is written as is unless it is invalid or has runes to escape.
+	i := escapeIndex(s, true)
+	if i == len(s) ||
+		(!e.opts.EscapeLineSeparators && !e.opts.ASCIIOnly && s[i] >= utf8.RuneSelf &&
+			escapeIndex(s[i:], false) == len(s)-i && utf8.ValidString(s[i:])) {
+		e.w.WriteString(s)
+		e.w.WriteByte('"')
+		return nil
+	}
+
+	// Slow path: write with escaping, chunking between special characters
+	start := 0
+	for i < len(s) {
+		c := s[i]
+		var escape string
+		size := 1
+
+		if c >= utf8.RuneSelf {
+			var r rune
+			r, size = utf8.DecodeRuneInString(s[i:])
+			switch {
+			case r == utf8.RuneError && size == 1:
+				if !e.opts.AllowInvalidUTF8 {
+					return ErrInvalidUTF8
+				}
+				escape = "\uFFFD"
+				if e.opts.ASCIIOnly {
+					escape = `\ufffd`
+				}
+			case r == '\u2028' && e.opts.EscapeLineSeparators:
+				escape = `\u2028`
+			case r == '\u2029' && e.opts.EscapeLineSeparators:
+				escape = `\u2029`
+			case e.opts.ASCIIOnly:
+				if i > start {
+					e.w.WriteString(s[start:i])
+				}
+				e.w.Write(appendRuneEscape(e.buf[:0], r))
+				i += size
+				start = i
+				continue
+			default:
+				i += size
+				continue
+			}
+		} else {
+			switch c {
+			case '"':
+				escape = `\"`
+			case '\\':
+				escape = `\\`
+			case '\n':
+				escape = `\n`
+			case '\r':
+				escape = `\r`
+			case '\t':
+				escape = `\t`
+			case '\b':
+				escape = `\b`
+			case '\f':
+				escape = `\f`
+			default:
+				if c >= 0x20 {
+					i++
+					i += escapeIndex(s[i:], true)
+					continue
+				}
+				if i > start {
+					e.w.WriteString(s[start:i])
+				}
+				e.w.Write(appendRuneEscape(e.buf[:0], rune(c)))
+				i++
+				start = i
+				continue
+			}
+		}
 
-		e.w.WriteByte(':')
+		// Write chunk before escape
+		if i > start {
+			e.w.WriteString(s[start:i])
+		}
+		e.w.WriteString(escape)
+		i += size
+		start = i
+	}
 
-		// Marshal value
-		if err := e.marshalSingular(valFd, m.Get(k)); err != nil {
+	// Write remaining chunk
+	if start < len(s) {
+		e.w.WriteString(s[start:])
+	}
+
+	e.w.WriteByte('"')
+	return nil
+}
+
+// hexDigits are the lowercase hexadecimal digits
+const hexDigits = "0123456789abcdef"
+
+// appendRuneEscape appends r as a \uXXXX escape, using a UTF-16 surrogate
+// pair for runes outside the Basic Multilingual Plane
+func appendRuneEscape(b []byte, r rune) []byte {
+	if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+		b = appendRuneEscape(b, r1)
+		r = r2
+	}
+	return append(b, '\\', 'u',
+		hexDigits[r>>12&0xf], hexDigits[r>>8&0xf], hexDigits[r>>4&0xf], hexDigits[r&0xf])
+}
+
+// marshalList marshals a repeated field
+func (e *encoder) marshalList(fd protoreflect.FieldDescriptor, list protoreflect.List) error {
+	e.w.WriteByte('[')
+	e.depth++
+	// FieldMaskFunc applies to every element alike, so ask it once
+	listMasked := e.maskDescriptor(fd)
+	n := 0
+	for i := 0; i < list.Len(); i++ {
+		if n == e.opts.MaxListElements && n > 0 {
+			e.writeOmitted(list.Len()-i, "items", false)
+			break
+		}
+		v := list.Get(i)
+		if e.omitEnum(fd, v) {
+			continue
+		}
+		if e.trackPath && e.enterPath(indexStep(i)) {
+			continue
+		}
+		masked := listMasked || e.opts.FieldMaskPathFunc != nil && e.opts.FieldMaskPathFunc(e.path, fd)
+		if masked && e.maskOmits(fd) {
+			e.auditMask()
+			if e.trackPath {
+				e.leavePath()
+			}
+			continue
+		}
+		if n > 0 {
+			e.writeComma()
+		}
+		n++
+		e.writeIndent()
+		if err := e.marshalSingularValue(fd, v, masked); err != nil {
 			return err
 		}
+		if err := e.checkLimit(); err != nil {
+			return err
+		}
+		if e.trackPath {
+			e.leavePath()
+		}
+	}
+	e.depth--
+	if n > 0 {
+		e.writeIndent()
+	}
+	e.w.WriteByte(']')
+	return nil
+}
+
+// omitEnum reports whether v is an enum value without a declared name that
+// UnknownEnumOmit drops from the output
+func (e *encoder) omitEnum(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+	if e.opts.UnknownEnum != UnknownEnumOmit || (e.opts.UseEnumNumbers && !e.opts.EnumAsObject) || fd.Kind() != protoreflect.EnumKind {
+		return false
+	}
+	_, declared := e.enumName(fd.Enum(), v.Enum())
+	return !declared
+}
+
+// marshalMap marshals a map field
+func (e *encoder) marshalMap(fd protoreflect.FieldDescriptor, m protoreflect.Map) error {
+	e.w.WriteByte('{')
+	e.depth++
+
+	var err error
+	n := 0
+	if e.opts.UnorderedMaps {
+		// Write entries in map iteration order, skipping the key sort
+		seen := 0
+		m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			if n == e.opts.MaxMapEntries && n > 0 {
+				e.writeOmitted(m.Len()-seen, "entries", true)
+				return false
+			}
+			seen++
+			var written bool
+			if written, err = e.marshalMapEntry(fd, n, k, v); written {
+				n++
+			}
+			return err == nil
+		})
+	} else {
+		// Sort keys for deterministic output
+		// Pre-allocate with capacity to avoid reallocation
+		keys := make([]protoreflect.MapKey, 0, m.Len())
+		m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			keys = append(keys, k)
+			return true
+		})
+
+		slices.SortFunc(keys, mapKeyCompare(fd.MapKey().Kind()))
+
+		for i, k := range keys {
+			if n == e.opts.MaxMapEntries && n > 0 {
+				e.writeOmitted(len(keys)-i, "entries", true)
+				break
+			}
+			var written bool
+			if written, err = e.marshalMapEntry(fd, n, k, m.Get(k)); err != nil {
+				break
+			}
+			if written {
+				n++
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	e.depth--
+	if n > 0 {
+		e.writeIndent()
 	}
 
 	e.w.WriteByte('}')
 	return nil
 }
 
+// marshalMapEntry marshals an entry of a map field, preceded by a comma
+// unless it is the first one written. It reports whether the entry was
+// written rather than left out by UnknownEnumOmit, path filters or MaskOmit.
+func (e *encoder) marshalMapEntry(fd protoreflect.FieldDescriptor, i int, k protoreflect.MapKey, v protoreflect.Value) (bool, error) {
+	if e.omitEnum(fd.MapValue(), v) {
+		return false, nil
+	}
+	if e.trackPath {
+		if e.enterPath(keyStep(k)) {
+			return false, nil
+		}
+		defer e.leavePath()
+	}
+	masked, err := e.maskMapEntry(fd, k)
+	if err != nil {
+		return false, err
+	}
+	if masked && e.maskOmits(fd.MapValue()) {
+		e.auditMask()
+		return false, nil
+	}
+
+	if i > 0 {
+		e.writeComma()
+	}
+	e.writeIndent()
+	if masked && e.opts.MaskMapKeys {
+		err = e.writeMaskedKey(i, k)
+	} else {
+		err = e.writeMapKey(fd, k)
+	}
+	if err != nil {
+		return true, err
+	}
+	e.writeColon()
+
+	// Marshal value
+	if err := e.marshalSingularValue(fd.MapValue(), v, masked); err != nil {
+		return true, err
+	}
+	return true, e.checkLimit()
+}
+
+// writeMapKey writes the key of a map field entry as a JSON string
+func (e *encoder) writeMapKey(fd protoreflect.FieldDescriptor, k protoreflect.MapKey) error {
+	if fd.MapKey().Kind() == protoreflect.StringKind {
+		if err := e.marshalString(k.String()); err != nil {
+			return fmt.Errorf("map key of field %s: %w", fd.FullName(), err)
+		}
+		return nil
+	}
+	e.w.WriteByte('"')
+	e.w.WriteString(k.String())
+	e.w.WriteByte('"')
+	return nil
+}
+
+// mapKeyCompare returns a comparison function ordering map keys of the given
+// kind the way the standard package does: bool keys false before true,
+// integer keys by numeric value and string keys lexically.
+func mapKeyCompare(kind protoreflect.Kind) func(a, b protoreflect.MapKey) int {
+	switch kind {
+	case protoreflect.BoolKind:
+		return func(a, b protoreflect.MapKey) int {
+			switch {
+			case a.Bool() == b.Bool():
+				return 0
+			case !a.Bool():
+				return -1
+			}
+			return 1
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return func(a, b protoreflect.MapKey) int {
+			return cmp.Compare(a.Int(), b.Int())
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return func(a, b protoreflect.MapKey) int {
+			return cmp.Compare(a.Uint(), b.Uint())
+		}
+	}
+	return func(a, b protoreflect.MapKey) int {
+		return strings.Compare(a.String(), b.String())
+	}
+}
+
 // isWrapperType checks if the given type is a wrapper type
-func (e *encoder) isWrapperType(name protoreflect.FullName) bool {
+func isWrapperType(name protoreflect.FullName) bool {
 	switch name {
 	case "google.protobuf.StringValue",
 		"google.protobuf.Int32Value",
@@ -496,73 +1709,309 @@ func (e *encoder) marshalWrapper(m protoreflect.Message) error {
 	if fd == nil {
 		return fmt.Errorf("wrapper type missing value field")
 	}
-	return e.marshalSingular(fd, m.Get(fd))
+	if !e.opts.WrapperAsObject {
+		return e.marshalSingular(fd, m.Get(fd))
+	}
+
+	e.w.WriteByte('{')
+	e.depth++
+	e.writeIndent()
+	e.w.WriteString(`"value"`)
+	e.writeColon()
+	if err := e.marshalSingular(fd, m.Get(fd)); err != nil {
+		return err
+	}
+	e.depth--
+	e.writeIndent()
+	e.w.WriteByte('}')
+	return nil
 }
 
-// marshalTimestamp marshals google.protobuf.Timestamp
-func (e *encoder) marshalTimestamp(m protoreflect.Message) error {
+// Valid range of google.protobuf.Timestamp seconds:
+// 0001-01-01T00:00:00Z to 9999-12-31T23:59:59Z
+const (
+	minTimestampSeconds = -62135596800
+	maxTimestampSeconds = 253402300799
+)
+
+// timestampFields returns the seconds and nanos of the
+// google.protobuf.Timestamp m, checking that they are in range
+func timestampFields(m protoreflect.Message) (int64, int64, error) {
 	seconds := m.Get(m.Descriptor().Fields().ByName("seconds")).Int()
 	nanos := m.Get(m.Descriptor().Fields().ByName("nanos")).Int()
 
-	// Convert to time.Time
-	t := time.Unix(seconds, nanos).UTC()
+	if seconds < minTimestampSeconds || seconds > maxTimestampSeconds {
+		return 0, 0, fmt.Errorf("%s: seconds out of range %v", m.Descriptor().FullName(), seconds)
+	}
+	if nanos < 0 || nanos >= 1e9 {
+		return 0, 0, fmt.Errorf("%s: nanos out of range %v", m.Descriptor().FullName(), nanos)
+	}
+	return seconds, nanos, nil
+}
 
-	// Format in RFC 3339 nano format
-	e.w.WriteByte('"')
-	formatted := t.Format("2006-01-02T15:04:05")
+// marshalTimestamp marshals google.protobuf.Timestamp
+func (e *encoder) marshalTimestamp(m protoreflect.Message) error {
+	seconds, nanos, err := timestampFields(m)
+	if err != nil {
+		return err
+	}
+
+	if e.opts.TimestampFormat != TimestampRFC3339 {
+		e.marshalEpoch(seconds, nanos)
+		return nil
+	}
 
-	e.w.WriteString(formatted)
+	// Format in RFC 3339 nano format
+	b := append(e.buf[:0], '"')
+	b = time.Unix(seconds, 0).UTC().AppendFormat(b, "2006-01-02T15:04:05")
 
-	// Add fractional seconds if nanos > 0
-	if nanos > 0 {
-		fracStr := fmt.Sprintf(".%09d", nanos)
-		// Trim trailing zeros
-		fracStr = strings.TrimRight(fracStr, "0")
-		e.w.WriteString(fracStr)
+	digits := e.opts.TimestampPrecision
+	if digits <= 0 || digits > 9 {
+		digits = fractionDigits(nanos)
+	}
+	if digits > 0 {
+		b = append(b, '.')
+		b = appendZeroPadded(b, nanos, 9)[:len(b)+digits]
 	}
 
-	e.w.WriteByte('Z')
-	e.w.WriteByte('"')
+	b = append(b, 'Z', '"')
+	e.w.Write(b)
 	return nil
 }
 
-// marshalDuration marshals google.protobuf.Duration
-func (e *encoder) marshalDuration(m protoreflect.Message) error {
+// marshalEpoch writes a Timestamp as a number of TimestampFormat units since
+// the Unix epoch. Sub-unit precision is truncated, except for seconds, which
+// keep a fraction like RFC 3339 output.
+func (e *encoder) marshalEpoch(seconds, nanos int64) {
+	// Split into sign and magnitude so that values beyond the int64 range
+	// of nanoseconds (after the year 2262) can be written
+	neg := seconds < 0
+	secs, frac := seconds, nanos
+	if neg {
+		secs = -seconds
+		if nanos > 0 {
+			secs--
+			frac = 1e9 - nanos
+		}
+	}
+
+	b := e.buf[:0]
+	if e.opts.TimestampEpochAsString {
+		b = append(b, '"')
+	}
+	if neg {
+		b = append(b, '-')
+	}
+
+	switch e.opts.TimestampFormat {
+	case TimestampUnixMillis, TimestampUnixMicros, TimestampUnixNanos:
+		digits := 9
+		switch e.opts.TimestampFormat {
+		case TimestampUnixMillis:
+			digits = 3
+		case TimestampUnixMicros:
+			digits = 6
+		}
+		for i := digits; i < 9; i++ {
+			frac /= 10
+		}
+		if secs == 0 {
+			if frac == 0 && neg {
+				b = b[:len(b)-1] // avoid "-0"
+			}
+			b = strconv.AppendInt(b, frac, 10)
+			break
+		}
+		b = strconv.AppendInt(b, secs, 10)
+		b = appendZeroPadded(b, frac, digits)
+	default:
+		b = strconv.AppendInt(b, secs, 10)
+		digits := e.opts.TimestampPrecision
+		if digits <= 0 || digits > 9 {
+			digits = fractionDigits(frac)
+		}
+		if digits > 0 {
+			b = append(b, '.')
+			b = appendZeroPadded(b, frac, 9)[:len(b)+digits]
+		}
+	}
+
+	if e.opts.TimestampEpochAsString {
+		b = append(b, '"')
+	}
+	e.w.Write(b)
+}
+
+// appendZeroPadded appends n left-padded with zeros to width digits
+func appendZeroPadded(b []byte, n int64, width int) []byte {
+	for i, p := 1, int64(10); i < width; i, p = i+1, p*10 {
+		if n < p {
+			b = append(b, '0')
+		}
+	}
+	return strconv.AppendInt(b, n, 10)
+}
+
+// fractionDigits returns the number of fractional second digits the
+// standard package uses for nanos: none, or 3, 6 or 9 digits
+func fractionDigits(nanos int64) int {
+	switch {
+	case nanos == 0:
+		return 0
+	case nanos%1e6 == 0:
+		return 3
+	case nanos%1e3 == 0:
+		return 6
+	}
+	return 9
+}
+
+// maxDurationSeconds is the largest magnitude of google.protobuf.Duration
+// seconds, approximately 10,000 years
+const maxDurationSeconds = 315576000000
+
+// durationFields returns the seconds and nanos of the
+// google.protobuf.Duration m, checking that they are in range
+func durationFields(m protoreflect.Message) (int64, int64, error) {
 	seconds := m.Get(m.Descriptor().Fields().ByName("seconds")).Int()
 	nanos := m.Get(m.Descriptor().Fields().ByName("nanos")).Int()
 
-	e.w.WriteByte('"')
-	e.w.WriteString(strconv.FormatInt(seconds, 10))
+	if seconds < -maxDurationSeconds || seconds > maxDurationSeconds {
+		return 0, 0, fmt.Errorf("%s: seconds out of range %v", m.Descriptor().FullName(), seconds)
+	}
+	if nanos <= -1e9 || nanos >= 1e9 {
+		return 0, 0, fmt.Errorf("%s: nanos out of range %v", m.Descriptor().FullName(), nanos)
+	}
+	if (seconds > 0 && nanos < 0) || (seconds < 0 && nanos > 0) {
+		return 0, 0, fmt.Errorf("%s: signs of seconds and nanos do not match", m.Descriptor().FullName())
+	}
+	return seconds, nanos, nil
+}
 
-	if nanos != 0 {
-		fracStr := fmt.Sprintf(".%09d", nanos)
-		// Trim trailing zeros
-		fracStr = strings.TrimRight(fracStr, "0")
-		e.w.WriteString(fracStr)
+// marshalDuration marshals google.protobuf.Duration
+func (e *encoder) marshalDuration(m protoreflect.Message) error {
+	seconds, nanos, err := durationFields(m)
+	if err != nil {
+		return err
 	}
 
-	e.w.WriteByte('s')
-	e.w.WriteByte('"')
+	// The sign is written once for both parts, so that e.g. seconds 0 and
+	// nanos -500000000 is written as "-0.500s"
+	neg := seconds < 0 || nanos < 0
+	if neg {
+		seconds, nanos = -seconds, -nanos
+	}
+
+	b := e.buf[:0]
+	switch e.opts.DurationFormat {
+	case DurationSecondsNumber:
+		if neg {
+			b = append(b, '-')
+		}
+		b = appendSeconds(b, seconds, nanos)
+	case DurationNanos:
+		if neg {
+			b = append(b, '-')
+		}
+		if seconds == 0 {
+			b = strconv.AppendInt(b, nanos, 10)
+		} else {
+			b = strconv.AppendInt(b, seconds, 10)
+			b = appendZeroPadded(b, nanos, 9)
+		}
+	case DurationISO8601:
+		b = append(b, '"')
+		if neg {
+			b = append(b, '-')
+		}
+		b = append(b, "PT"...)
+		if h := seconds / 3600; h > 0 {
+			b = strconv.AppendInt(b, h, 10)
+			b = append(b, 'H')
+		}
+		if m := seconds % 3600 / 60; m > 0 {
+			b = strconv.AppendInt(b, m, 10)
+			b = append(b, 'M')
+		}
+		if s := seconds % 60; s > 0 || nanos > 0 || seconds == 0 {
+			b = appendSeconds(b, s, nanos)
+			b = append(b, 'S')
+		}
+		b = append(b, '"')
+	default:
+		b = append(b, '"')
+		if neg {
+			b = append(b, '-')
+		}
+		b = appendSeconds(b, seconds, nanos)
+		b = append(b, 's', '"')
+	}
+	e.w.Write(b)
 	return nil
 }
 
+// appendSeconds appends seconds with a fraction of 0, 3, 6 or 9 digits for
+// nanos, like the standard package's Duration output
+func appendSeconds(b []byte, seconds, nanos int64) []byte {
+	b = strconv.AppendInt(b, seconds, 10)
+	if digits := fractionDigits(nanos); digits > 0 {
+		b = append(b, '.')
+		b = appendZeroPadded(b, nanos, 9)[:len(b)+digits]
+	}
+	return b
+}
+
 // marshalStruct marshals google.protobuf.Struct
 func (e *encoder) marshalStruct(m protoreflect.Message) error {
 	fields := m.Get(m.Descriptor().Fields().ByName("fields")).Map()
 
 	e.w.WriteByte('{')
+	e.depth++
 	first := true
-	fields.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+	var err error
+	n := 0
+	write := func(k protoreflect.MapKey, v protoreflect.Value) bool {
+		if n == e.opts.MaxMapEntries && n > 0 {
+			e.writeOmitted(fields.Len()-n, "entries", true)
+			return false
+		}
+		n++
 		if !first {
 			e.writeComma()
 		}
 		first = false
+		e.writeIndent()
 
-		e.marshalString(k.String())
+		if err = e.marshalString(k.String()); err != nil {
+			return false
+		}
 		e.writeColon()
-		e.marshalValue(v.Message())
-		return true
-	})
+		err = e.marshalValue(v.Message())
+		return err == nil
+	}
+	if e.opts.UnorderedMaps {
+		fields.Range(write)
+	} else {
+		// Sort keys like map keys, as the standard package does
+		keys := make([]protoreflect.MapKey, 0, fields.Len())
+		fields.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+			keys = append(keys, k)
+			return true
+		})
+		slices.SortFunc(keys, mapKeyCompare(protoreflect.StringKind))
+		for _, k := range keys {
+			if !write(k, fields.Get(k)) {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	e.depth--
+	if !first {
+		e.writeIndent()
+	}
 	e.w.WriteByte('}')
 	return nil
 }
@@ -579,9 +2028,9 @@ func (e *encoder) marshalValue(m protoreflect.Message) error {
 	case "null_value":
 		e.w.WriteString("null")
 	case "number_value":
-		e.marshalFloat64(m.Get(od).Float())
+		return e.marshalFloat(m.Get(od).Float(), 64)
 	case "string_value":
-		e.marshalString(m.Get(od).String())
+		return e.marshalString(m.Get(od).String())
 	case "bool_value":
 		if m.Get(od).Bool() {
 			e.w.WriteString("true")
@@ -601,109 +2050,509 @@ func (e *encoder) marshalListValue(m protoreflect.Message) error {
 	values := m.Get(m.Descriptor().Fields().ByName("values")).List()
 
 	e.w.WriteByte('[')
+	e.depth++
 	for i := 0; i < values.Len(); i++ {
+		if i == e.opts.MaxListElements && i > 0 {
+			e.writeOmitted(values.Len()-i, "items", false)
+			break
+		}
 		if i > 0 {
 			e.writeComma()
 		}
-		e.marshalValue(values.Get(i).Message())
+		e.writeIndent()
+		if err := e.marshalValue(values.Get(i).Message()); err != nil {
+			return err
+		}
+	}
+	e.depth--
+	if values.Len() > 0 {
+		e.writeIndent()
 	}
 	e.w.WriteByte(']')
 	return nil
 }
 
-// marshalAny marshals google.protobuf.Any
+// marshalAny marshals google.protobuf.Any. The embedded message's fields
+// are written next to "@type", except for well-known types whose JSON
+// representation is written under "value".
 func (e *encoder) marshalAny(m protoreflect.Message) error {
 	typeURL := m.Get(m.Descriptor().Fields().ByName("type_url")).String()
 	value := m.Get(m.Descriptor().Fields().ByName("value")).Bytes()
 
+	if typeURL == "" && len(value) == 0 {
+		e.w.WriteString("{}")
+		return nil
+	}
+
 	e.w.WriteByte('{')
-	e.marshalString("@type")
-	e.w.WriteString(": ")
-	e.marshalString(typeURL)
-
-	if len(value) > 0 {
-		// Try to unmarshal and re-marshal the embedded message
-		// For now, we'll just include the type_url
-		// A full implementation would need to resolve the type and unmarshal
-		resolver := e.opts.Resolver
-		if resolver == nil {
-			resolver = protoregistry.GlobalTypes
-		}
-
-		// Extract message name from type URL
-		messageName := protoreflect.FullName(typeURL)
-		if i := strings.LastIndexByte(typeURL, '/'); i >= 0 {
-			messageName = protoreflect.FullName(typeURL[i+1:])
-		}
-
-		if mt, err := resolver.FindMessageByName(messageName); err == nil {
-			msg := mt.New()
-			if err := proto.Unmarshal(value, msg.Interface()); err == nil {
-				// Marshal the embedded message fields
-				fields := msg.Descriptor().Fields()
-				for i := 0; i < fields.Len(); i++ {
-					fd := fields.Get(i)
-					if !msg.Has(fd) {
-						if fd.HasPresence() || !e.opts.EmitUnpopulated {
-							continue
-						}
-					}
-
-					e.w.WriteString(", ")
-					name := e.fieldName(fd)
-					e.marshalString(name)
-					e.w.WriteString(`: `)
-					e.marshalField(fd, msg.Get(fd))
-				}
-			}
+	e.depth++
+	e.writeIndent()
+	e.w.WriteString(`"@type"`)
+	e.writeColon()
+	if err := e.marshalString(typeURL); err != nil {
+		return err
+	}
+
+	msg, err := unmarshalAny(e.resolver(), typeURL, value)
+	if err != nil {
+		switch e.opts.UnresolvedAny {
+		case UnresolvedAnyBase64:
+			e.writeComma()
+			e.writeIndent()
+			e.w.WriteString(`"value"`)
+			e.writeColon()
+			e.w.WriteByte('"')
+			e.writeBase64(base64.StdEncoding, value)
+			e.w.WriteByte('"')
+		case UnresolvedAnySkip:
+		default:
+			return err
 		}
+	} else if e.formatted(msg.Descriptor().FullName()) {
+		e.writeComma()
+		e.writeIndent()
+		e.w.WriteString(`"value"`)
+		e.writeColon()
+		if err := e.marshalMessage(msg); err != nil {
+			return err
+		}
+	} else if _, err := e.marshalFields(msg, e.planOf(msg.Descriptor()), false); err != nil {
+		return err
 	}
 
+	e.depth--
+	e.writeIndent()
 	e.w.WriteByte('}')
 	return nil
 }
 
+// typeResolver resolves the types of Any messages and their extensions
+type typeResolver interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+}
+
+// messageResolver resolves messages through a MarshalOptions.Resolver
+// without extension lookups, and extensions through the global registry
+type messageResolver struct {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+}
+
+// resolverOf returns r as a typeResolver, defaulting to the global
+// registry for r if nil, or for extensions if r does not look them up
+func resolverOf(r protoregistry.MessageTypeResolver) typeResolver {
+	switch r := r.(type) {
+	case nil:
+		return protoregistry.GlobalTypes
+	case typeResolver:
+		return r
+	}
+	return messageResolver{r, protoregistry.GlobalTypes}
+}
+
+// unmarshalAny resolves typeURL and unmarshals value into a new message of
+// that type
+func unmarshalAny(resolver typeResolver, typeURL string, value []byte) (protoreflect.Message, error) {
+	mt, err := resolver.FindMessageByURL(typeURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %q: %w", typeURL, err)
+	}
+	msg := mt.New()
+	opts := proto.UnmarshalOptions{AllowPartial: true, Resolver: resolver}
+	if err := opts.Unmarshal(value, msg.Interface()); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal %q: %w", typeURL, err)
+	}
+	return msg, nil
+}
+
 // Encoder writes protocol buffer messages to an output stream in JSON format.
 type Encoder struct {
-	bw   *bufio.Writer
+	out  io.Writer // Writer passed to NewEncoder or Reset
+	w    writer
+	bw   *bufio.Writer // Non-nil when the encoder buffers output itself
+	size int           // Size of bw, or 0 for the bufio default
 	opts MarshalOptions
+	enc  encoder // Reused across Encode calls to avoid allocation
+
+	newline  bool // Terminate each encoded message with '\n'
+	mark     int  // Length of the *bytes.Buffer written to, before the call
+	inArray  bool // Between BeginArray and EndArray
+	arrayLen int  // Number of elements written to the current array
+
+	limit   limitWriter // Enforces MaxOutputBytes
+	flusher flushWriter // Flushes every SetFlushThreshold bytes
+	hasher  hashWriter  // Feeds the output to the SetHash hash
+
+	pos     int          // Position of the output before the call, for Stats
+	count   countWriter  // Counts the output for Stats if its length cannot be read
+	flushed flushCounter // Counts the output flushed from bw, for Stats
+	offsets countWriter  // Counts the output for OnMessageEnd
+	stats   encoderStats // Counters returned by Stats
 }
 
 // NewEncoder returns a new encoder that writes to w using default options.
+//
+// If w is already buffered, i.e. a *bytes.Buffer, a *strings.Builder or a
+// writer with a Flush method such as *bufio.Writer, the encoder writes to
+// it directly. Otherwise output is buffered internally and flushed to w at
+// the end of each Encode call. A failed Encode call leaves no output
+// behind, except for what was already flushed with SetFlushThreshold and
+// in a *strings.Builder or a writer with a Flush method.
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{
-		bw:   bufio.NewWriter(w),
-		opts: MarshalOptions{},
-	}
+	return NewEncoderWithOptions(w, MarshalOptions{})
 }
 
 // NewEncoderWithOptions returns a new encoder that writes to w using the
 // provided MarshalOptions.
 func NewEncoderWithOptions(w io.Writer, opts MarshalOptions) *Encoder {
-	return &Encoder{
-		bw:   bufio.NewWriter(w),
-		opts: opts,
+	e := &Encoder{opts: opts}
+	e.Reset(w)
+	return e
+}
+
+// NewEncoderSize returns a new encoder that writes to w using default
+// options, buffering output in a buffer of at least size bytes when w is
+// not already buffered. A larger buffer reduces the number of writes to w
+// for large messages; a smaller one saves memory when many encoders are
+// live. A size of 0 or less selects the bufio default of 4096 bytes.
+func NewEncoderSize(w io.Writer, size int) *Encoder {
+	e := &Encoder{size: size}
+	e.Reset(w)
+	return e
+}
+
+// bufferedWriter reports whether w can be written to directly without
+// an intermediate bufio.Writer.
+func bufferedWriter(w io.Writer) (writer, bool) {
+	switch w := w.(type) {
+	case *bytes.Buffer:
+		return w, true
+	case *strings.Builder:
+		return w, true
+	case interface {
+		writer
+		Flush() error
+	}:
+		return w, true
 	}
+	return nil, false
 }
 
 // Encode writes the JSON encoding of m to the stream.
-// It does not write a newline after the JSON encoding.
+// It does not write a newline after the JSON encoding unless enabled with
+// SetWriteNewline. Messages generated by the legacy
+// github.com/golang/protobuf package are passed through
+// protoadapt.MessageV2Of.
 func (e *Encoder) Encode(m proto.Message) error {
-	opts := e.opts
-	if opts.EmitDefaultValues {
-		opts.EmitUnpopulated = true
+	return e.EncodeReflect(m.ProtoReflect())
+}
+
+// EncodeReflect writes the JSON encoding of m to the stream like Encode.
+// It accepts messages known only through protoreflect, such as messages
+// of types built from descriptors at run time with dynamicpb.
+func (e *Encoder) EncodeReflect(m protoreflect.Message) error {
+	// Check required fields before writing anything, so that an incomplete
+	// message produces no output
+	if !e.opts.AllowPartial {
+		if err := proto.CheckInitialized(m.Interface()); err != nil {
+			return e.fail(err)
+		}
+	}
+
+	e.prepare()
+
+	if e.inArray {
+		if e.arrayLen > 0 {
+			e.enc.writeComma()
+		}
+		e.arrayLen++
+		e.enc.depth = 1
+		e.enc.writeIndent()
+	}
+
+	e.enc.root = true
+	var err error
+	if e.opts.Canonical || e.opts.TruncateOutput || e.opts.Flatten {
+		err = e.enc.writeRoot(func() error { return e.enc.marshalMessage(m) })
+	} else {
+		err = e.enc.marshalMessage(m)
+	}
+	if err == nil {
+		err = e.enc.checkLimit()
+	}
+	if err != nil {
+		e.abort()
+		return e.fail(err)
+	}
+	e.reportMasks(m.Interface())
+	// Inside an array the newline is written after the closing bracket
+	if e.newline && !e.inArray {
+		e.w.WriteByte('\n')
 	}
 
-	enc := &encoder{
-		w:    e.bw,
-		opts: opts,
+	if err := e.flush(); err != nil {
+		return e.fail(err)
 	}
+	e.encoded()
+	return nil
+}
+
+// BeginArray starts a JSON array. Messages passed to subsequent Encode
+// calls are written as comma-separated elements of the array until
+// EndArray is called. Each element is flushed as it is encoded, so large
+// result sets can be streamed as a single JSON array.
+//
+// A message that fails to encode is left out of the array, and later
+// messages are still written as its elements, unless part of its output
+// remains as described for NewEncoder. The array is then abandoned:
+// EndArray returns an error and the encoder must be Reset.
+func (e *Encoder) BeginArray() error {
+	if e.inArray {
+		return errors.New("array already started")
+	}
+	e.inArray = true
+	e.arrayLen = 0
+	e.pos = e.position()
+	e.w.WriteByte('[')
+	defer e.collect()
+	return e.flush()
+}
 
-	if err := enc.marshalMessage(m.ProtoReflect()); err != nil {
+// EndArray closes the JSON array started by BeginArray.
+func (e *Encoder) EndArray() error {
+	if !e.inArray {
+		return errors.New("no array to end")
+	}
+	e.inArray = false
+	e.pos = e.position()
+	if e.arrayLen > 0 {
+		e.prepare()
+		e.enc.writeIndent()
+	}
+	e.w.WriteByte(']')
+	if e.newline {
+		e.w.WriteByte('\n')
+	}
+	defer e.collect()
+	return e.flush()
+}
+
+// EncodeList writes msgs to the stream as a single JSON array, formatted
+// according to the configured options.
+//
+// If a message fails to encode, the array is abandoned and the encoder
+// writes later values outside of it. A caller's *bytes.Buffer is truncated
+// to where the array began; output already flushed to other writers
+// remains.
+func (e *Encoder) EncodeList(msgs []proto.Message) error {
+	b, direct := e.out.(*bytes.Buffer)
+	direct = direct && e.w == writer(b)
+	var mark int
+	if direct {
+		mark = b.Len()
+	}
+	if err := e.BeginArray(); err != nil {
+		return err
+	}
+	if err := e.EncodeSeq(slices.Values(msgs)); err != nil {
+		e.inArray = false
+		e.arrayLen = 0
+		if direct {
+			b.Truncate(mark)
+		}
 		return err
 	}
+	return e.EndArray()
+}
+
+// EncodeSeq writes each message yielded by seq to the stream as if by
+// Encode, stopping at the first error. Between BeginArray and EndArray the
+// messages become elements of the array; with SetWriteNewline(true) they
+// are written as newline-delimited JSON.
+func (e *Encoder) EncodeSeq(seq iter.Seq[proto.Message]) error {
+	for m := range seq {
+		if err := e.Encode(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepare resets the internal encoder for writing a new top-level value
+func (e *Encoder) prepare() {
+	opts := e.opts
+	if opts.Stable {
+		opts.UnorderedMaps = false
+	}
+	if opts.TruncateOutput && opts.MaxOutputBytes > 0 {
+		opts.Indent, opts.Multiline = "", false
+	}
+	if opts.Canonical {
+		opts.Indent, opts.Multiline = "", false
+		opts.ASCIIOnly, opts.EscapeLineSeparators = false, false
+	}
+	if opts.Canonical || opts.Flatten || opts.TruncateOutput && opts.MaxOutputBytes > 0 {
+		opts.Comments = nil
+	}
+
+	e.enc.w = e.w
+	e.enc.opts = opts
+	e.pos = e.position()
+	if b, ok := e.out.(*bytes.Buffer); ok {
+		e.mark = b.Len()
+	}
+	e.flusher.flushed = false
+	e.enc.depth = 0
+	e.enc.root = false
+	e.enc.limit = nil
+	e.enc.filter = nil
+	e.enc.path = e.enc.path[:0]
+	mask := opts.FieldMask.GetPaths()
+	if len(opts.IncludePaths) > 0 || len(opts.ExcludePaths) > 0 || len(mask) > 0 {
+		e.enc.filter = newPathFilter(opts.IncludePaths, opts.ExcludePaths, mask)
+	}
+	e.enc.emitKinds = emitKinds(&opts)
+	e.enc.unpopulated = nil
+	if len(opts.EmitUnpopulatedPaths) > 0 || len(opts.OmitUnpopulatedPaths) > 0 {
+		e.enc.unpopulated = &unpopulatedPaths{
+			emit: splitPaths(opts.EmitUnpopulatedPaths),
+			omit: splitPaths(opts.OmitUnpopulatedPaths),
+		}
+	}
+	e.enc.hooks = opts.Hooks.enabled()
+	e.enc.audit = opts.MaskAuditFunc != nil
+	e.enc.report = MaskReport{}
+	e.enc.masked = 0
+	e.enc.cacheOptions = 0
+	e.enc.gw.e = &e.enc
+	e.enc.generated = 0
+	e.enc.trackPath = e.enc.filter != nil || opts.FieldMaskPathFunc != nil || e.enc.audit || e.enc.unpopulated != nil
+	e.enc.flusher = nil
+	if e.flusher.threshold > 0 {
+		e.flusher.reset(e)
+		e.enc.w = &e.flusher
+		e.enc.flusher = &e.flusher
+	}
+	if opts.MaxOutputBytes > 0 && !opts.TruncateOutput {
+		e.limit.reset(e.enc.w, opts.MaxOutputBytes)
+		e.enc.w = &e.limit
+		e.enc.limit = &e.limit
+	}
+	e.enc.count = nil
+	if opts.Hooks.OnMessageEnd != nil {
+		e.offsets = countWriter{w: e.enc.w}
+		e.enc.w = &e.offsets
+		e.enc.count = &e.offsets
+	}
+}
+
+// discard drops the output of a failed call, so that a partially encoded
+// message is neither flushed by a later call nor left in a caller's
+// *bytes.Buffer. It reports whether all of the output was dropped, which
+// is not the case when part of it was already flushed, or when writing
+// directly to another kind of buffered writer.
+func (e *Encoder) discard() bool {
+	if b, ok := e.out.(*bytes.Buffer); ok && e.w == writer(b) {
+		b.Truncate(e.mark)
+		return true
+	}
+	if e.w != writer(e.bw) {
+		return false
+	}
+	e.bw.Reset(&e.flushed)
+	return !e.flusher.flushed
+}
+
+// abort drops the output of a failed Encode call. Inside an array the
+// message is left out if none of its output remains; otherwise the array
+// is abandoned, as its output is no longer valid JSON.
+func (e *Encoder) abort() {
+	if e.discard() {
+		if e.inArray {
+			e.arrayLen--
+		}
+		return
+	}
+	e.inArray = false
+	e.arrayLen = 0
+}
+
+// flush writes any buffered output to the underlying writer
+func (e *Encoder) flush() error {
+	if e.bw != nil {
+		return e.bw.Flush()
+	}
+	return nil
+}
+
+// Reset discards any unflushed output and rebinds the encoder to write to w,
+// keeping its options and internal buffer. This allows an Encoder to be
+// reused, e.g. from a sync.Pool, without reallocating its buffer.
+func (e *Encoder) Reset(w io.Writer) {
+	e.out = w
+	e.inArray = false
+	e.arrayLen = 0
+	e.bind()
+}
+
+// bind points the encoder at e.out, directly if it is already buffered and
+// output is not hashed, or else through the encoder's own buffer. Stats
+// read the position of the output, so only buffered writers whose length
+// cannot be read are written through a counter.
+func (e *Encoder) bind() {
+	if bw, ok := bufferedWriter(e.out); ok && e.hasher.h == nil {
+		switch bw.(type) {
+		case *bytes.Buffer, *strings.Builder:
+			e.w = bw
+		default:
+			e.count = countWriter{w: bw}
+			e.w = &e.count
+		}
+		if e.bw != nil {
+			// Keep the buffer for a later Reset, but drop the old writer
+			e.bw.Reset(nil)
+		}
+		return
+	}
+	e.flushed = flushCounter{w: e.sink()}
+	if e.bw == nil {
+		e.bw = bufio.NewWriterSize(&e.flushed, e.size)
+	} else {
+		e.bw.Reset(&e.flushed)
+	}
+	e.w = e.bw
+}
+
+// position returns the number of bytes written to the output so far
+func (e *Encoder) position() int {
+	switch w := e.w.(type) {
+	case *bytes.Buffer:
+		return w.Len()
+	case *strings.Builder:
+		return w.Len()
+	case *bufio.Writer:
+		return e.flushed.n + w.Buffered()
+	}
+	return e.count.n
+}
+
+// SetWriteNewline sets whether each Encode call terminates its output with a
+// newline, producing newline-delimited JSON (NDJSON). Multiline output is
+// still terminated by a single newline after the closing brace.
+func (e *Encoder) SetWriteNewline(v bool) {
+	e.newline = v
+}
 
-	return e.bw.Flush()
+// SetFlushThreshold makes the encoder flush its output to the underlying
+// writer whenever n bytes have been written since the last flush, instead
+// of only at the end of each Encode call. If the underlying writer has a
+// Flush method, such as a *bufio.Writer or an http.ResponseWriter, it is
+// flushed as well. This bounds the memory held for large messages and lets
+// the receiver start reading early, at the cost of partial output reaching
+// the writer when encoding fails. A value of 0 disables periodic flushing.
+func (e *Encoder) SetFlushThreshold(n int) {
+	e.flusher.threshold = max(n, 0)
 }
 
 // SetOptions updates the MarshalOptions used by the encoder.
@@ -2,13 +2,26 @@ package protojson_test
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/wreulicke/protojson"
 	pb_basic "github.com/wreulicke/protojson/gen"
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 // TestFieldMask tests the Field MaskFunc functionality
@@ -272,3 +285,1265 @@ func TestFieldMaskByKind(t *testing.T) {
 		})
 	}
 }
+
+// TestMarshalAppend tests appending encoded messages to an existing buffer
+func TestMarshalAppend(t *testing.T) {
+	msg := &pb_basic.BasicTypes{
+		StringField: "test",
+		Int32Field:  42,
+	}
+
+	buf := make([]byte, 0, 256)
+	buf = append(buf, "prefix:"...)
+	got, err := protojson.MarshalAppend(buf, msg)
+	if err != nil {
+		t.Fatalf("MarshalAppend() error = %v", err)
+	}
+	want := `prefix:{"stringField":"test","int32Field":42}`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("MarshalAppend() mismatch (-want +got):\n%s", diff)
+	}
+	if &got[0] != &buf[:1][0] {
+		t.Errorf("MarshalAppend() did not reuse the destination buffer")
+	}
+
+	opts := protojson.MarshalOptions{UseProtoNames: true}
+	got, err = opts.MarshalAppend(got[:0], msg)
+	if err != nil {
+		t.Fatalf("MarshalOptions.MarshalAppend() error = %v", err)
+	}
+	want = `{"string_field":"test","int32_field":42}`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("MarshalOptions.MarshalAppend() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestMarshalAppendAllocs tests that MarshalAppend does not allocate when
+// the destination buffer is large enough
+func TestMarshalAppendAllocs(t *testing.T) {
+	msg := &pb_basic.BasicTypes{
+		StringField: "hello",
+		Int32Field:  42,
+		Int64Field:  9223372036854775807,
+		BoolField:   true,
+		DoubleField: 2.718281828,
+	}
+
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	buf := make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		var err error
+		buf, err = protojson.MarshalAppend(buf[:0], msg)
+		if err != nil {
+			t.Fatalf("MarshalAppend() error = %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("MarshalAppend() allocs = %v, want 0", allocs)
+	}
+}
+
+// TestMarshalAppendAllocsValues tests that control characters, timestamps,
+// durations and bytes are written without allocating
+func TestMarshalAppendAllocsValues(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+	}{
+		{
+			name: "ControlCharacters",
+			msg:  &pb_basic.BasicTypes{StringField: "a\x00b\x01c\x1fd\ne"},
+		},
+		{
+			name: "Timestamp",
+			msg:  timestamppb.New(time.Date(2024, 1, 15, 10, 30, 0, 123456789, time.UTC)),
+		},
+		{
+			name: "Duration",
+			msg:  durationpb.New(-1500 * time.Millisecond),
+		},
+		{
+			name: "Bytes",
+			msg:  &pb_basic.BasicTypes{BytesField: bytes.Repeat([]byte{0xfb, 0xff}, 200)},
+		},
+	}
+
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := make([]byte, 0, 1024)
+			allocs := testing.AllocsPerRun(100, func() {
+				var err error
+				buf, err = protojson.MarshalAppend(buf[:0], tt.msg)
+				if err != nil {
+					t.Fatalf("MarshalAppend() error = %v", err)
+				}
+			})
+			if allocs != 0 {
+				t.Errorf("MarshalAppend() allocs = %v, want 0", allocs)
+			}
+		})
+	}
+}
+
+// TestInvalidUTF8 tests UTF-8 validation of string values
+func TestInvalidUTF8(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     proto.Message
+		opts    protojson.MarshalOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "ValidMultibyte",
+			msg:  &pb_basic.BasicTypes{StringField: "héllo, 世界 🌍"},
+			want: `{"stringField":"héllo, 世界 🌍"}`,
+		},
+		{
+			name:    "InvalidStringField",
+			msg:     &pb_basic.BasicTypes{StringField: "bad\xffbyte"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidMapKey",
+			msg:     &pb_basic.MapFields{StringMap: map[string]string{"\xc3": "v"}},
+			wantErr: true,
+		},
+		{
+			name:    "TruncatedSequence",
+			msg:     &pb_basic.BasicTypes{StringField: "\xe4\xb8"},
+			wantErr: true,
+		},
+		{
+			name: "AllowInvalidUTF8",
+			msg:  &pb_basic.BasicTypes{StringField: "bad\xffbyte\n"},
+			opts: protojson.MarshalOptions{AllowInvalidUTF8: true},
+			want: "{\"stringField\":\"bad�byte\\n\"}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if tt.wantErr {
+				if !errors.Is(err, protojson.ErrInvalidUTF8) {
+					t.Fatalf("Marshal() error = %v, want ErrInvalidUTF8", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestUnorderedMaps tests that unordered map output carries the same
+// entries as sorted output
+func TestUnorderedMaps(t *testing.T) {
+	msg := &pb_basic.MapFields{
+		StringMap: map[string]string{"a": "1", "b": "2", "c": "3"},
+		IntKeyMap: map[int32]string{1: "one", 2: "two", 10: "ten"},
+		MessageMap: map[string]*pb_basic.Value{
+			"x": {Data: "d", Count: 1},
+		},
+	}
+
+	sorted, err := protojson.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	unordered, err := protojson.MarshalOptions{UnorderedMaps: true}.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var want, got map[string]any
+	if err := json.Unmarshal(sorted, &want); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if err := json.Unmarshal(unordered, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v: %s", err, unordered)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("UnorderedMaps output mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestTimestampPrecision tests fixed fractional second precision and
+// validation of google.protobuf.Timestamp values
+func TestTimestampPrecision(t *testing.T) {
+	tests := []struct {
+		name      string
+		ts        *timestamppb.Timestamp
+		precision int
+		want      string
+		wantErr   bool
+	}{
+		{
+			name: "Automatic",
+			ts:   &timestamppb.Timestamp{Seconds: 1609459200, Nanos: 120000000},
+			want: `{"timestamp":"2021-01-01T00:00:00.120Z"}`,
+		},
+		{
+			name:      "FixedMillis",
+			ts:        &timestamppb.Timestamp{Seconds: 1609459200},
+			precision: 3,
+			want:      `{"timestamp":"2021-01-01T00:00:00.000Z"}`,
+		},
+		{
+			name:      "FixedMillisTruncates",
+			ts:        &timestamppb.Timestamp{Seconds: 1609459200, Nanos: 999999999},
+			precision: 3,
+			want:      `{"timestamp":"2021-01-01T00:00:00.999Z"}`,
+		},
+		{
+			name:      "FixedNanos",
+			ts:        &timestamppb.Timestamp{Seconds: 1609459200, Nanos: 120000000},
+			precision: 9,
+			want:      `{"timestamp":"2021-01-01T00:00:00.120000000Z"}`,
+		},
+		{
+			name:    "NegativeNanos",
+			ts:      &timestamppb.Timestamp{Seconds: 1609459200, Nanos: -1},
+			wantErr: true,
+		},
+		{
+			name:    "SecondsOutOfRange",
+			ts:      &timestamppb.Timestamp{Seconds: 253402300800},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := protojson.MarshalOptions{TimestampPrecision: tt.precision}
+			got, err := opts.Marshal(&pb_basic.WellKnownTypes{Timestamp: tt.ts})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Marshal() expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestInvalidDuration tests validation of google.protobuf.Duration values
+func TestInvalidDuration(t *testing.T) {
+	tests := []struct {
+		name string
+		d    *durationpb.Duration
+	}{
+		{name: "SecondsTooLarge", d: &durationpb.Duration{Seconds: 315576000001}},
+		{name: "SecondsTooSmall", d: &durationpb.Duration{Seconds: -315576000001}},
+		{name: "NanosOutOfRange", d: &durationpb.Duration{Nanos: 1000000000}},
+		{name: "MismatchedSigns", d: &durationpb.Duration{Seconds: 1, Nanos: -1}},
+		{name: "MismatchedSignsNegativeSeconds", d: &durationpb.Duration{Seconds: -1, Nanos: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protojson.Marshal(&pb_basic.WellKnownTypes{Duration: tt.d})
+			if err == nil {
+				t.Fatalf("Marshal() expected error, got %s", got)
+			}
+		})
+	}
+}
+
+// TestUnresolvedAny tests each policy for Any payloads that cannot be resolved
+func TestUnresolvedAny(t *testing.T) {
+	msg := &pb_basic.WellKnownTypes{
+		Any: &anypb.Any{TypeUrl: "type.googleapis.com/unknown.Message", Value: []byte{0x08, 0x01}},
+	}
+
+	tests := []struct {
+		name    string
+		policy  protojson.UnresolvedAnyPolicy
+		want    string
+		wantErr bool
+	}{
+		{name: "Error", policy: protojson.UnresolvedAnyError, wantErr: true},
+		{
+			name:   "Base64",
+			policy: protojson.UnresolvedAnyBase64,
+			want:   `{"any":{"@type":"type.googleapis.com/unknown.Message","value":"CAE="}}`,
+		},
+		{
+			name:   "Skip",
+			policy: protojson.UnresolvedAnySkip,
+			want:   `{"any":{"@type":"type.googleapis.com/unknown.Message"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protojson.MarshalOptions{UnresolvedAny: tt.policy}.Marshal(msg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Marshal() expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestFloatFormat tests the float formatting modes
+func TestFloatFormat(t *testing.T) {
+	msg := &pb_basic.BasicTypes{
+		FloatField:  1e20,
+		DoubleField: 1e-7,
+	}
+
+	tests := []struct {
+		name   string
+		format protojson.FloatFormat
+		want   string
+	}{
+		{
+			name:   "Standard",
+			format: protojson.FloatFormatStandard,
+			want:   `{"floatField":100000000000000000000,"doubleField":1e-7}`,
+		},
+		{
+			name:   "Shortest",
+			format: protojson.FloatFormatShortest,
+			want:   `{"floatField":1e+20,"doubleField":1e-07}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protojson.MarshalOptions{FloatFormat: tt.format}.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestInt64AsNumber tests writing 64-bit integers as JSON numbers
+func TestInt64AsNumber(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Default",
+			msg:  &pb_basic.BasicTypes{Int64Field: -1, Uint64Field: 18446744073709551615},
+			want: `{"int64Field":"-1","uint64Field":"18446744073709551615"}`,
+		},
+		{
+			name: "Scalars",
+			msg: &pb_basic.BasicTypes{
+				Int64Field:    -1,
+				Uint64Field:   18446744073709551615,
+				Sint64Field:   -2,
+				Fixed64Field:  3,
+				Sfixed64Field: -4,
+			},
+			opts: protojson.MarshalOptions{Int64AsNumber: true},
+			want: `{"int64Field":-1,"uint64Field":18446744073709551615,"sint64Field":-2,"fixed64Field":3,"sfixed64Field":-4}`,
+		},
+		{
+			name: "Wrappers",
+			msg: &pb_basic.WrapperTypes{
+				Int64Value:  wrapperspb.Int64(-5),
+				Uint64Value: wrapperspb.UInt64(6),
+			},
+			opts: protojson.MarshalOptions{Int64AsNumber: true},
+			want: `{"int64Value":-5,"uint64Value":6}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestNonFinitePolicy tests each policy for NaN and infinite floats
+func TestNonFinitePolicy(t *testing.T) {
+	msg := &pb_basic.RepeatedFields{
+		Doubles: []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1.5},
+	}
+
+	tests := []struct {
+		name    string
+		policy  protojson.NonFinitePolicy
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "String",
+			policy: protojson.NonFiniteString,
+			want:   `{"doubles":["NaN","Infinity","-Infinity",1.5]}`,
+		},
+		{
+			name:   "Null",
+			policy: protojson.NonFiniteNull,
+			want:   `{"doubles":[null,null,null,1.5]}`,
+		},
+		{
+			name:   "Zero",
+			policy: protojson.NonFiniteZero,
+			want:   `{"doubles":[0,0,0,1.5]}`,
+		},
+		{
+			name:    "Error",
+			policy:  protojson.NonFiniteError,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protojson.MarshalOptions{NonFinitePolicy: tt.policy}.Marshal(msg)
+			if tt.wantErr {
+				if !errors.Is(err, protojson.ErrNonFiniteFloat) {
+					t.Fatalf("Marshal() error = %v, want ErrNonFiniteFloat", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestFloatDecimalPoint tests writing whole-number floats with a decimal point
+func TestFloatDecimalPoint(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Default",
+			msg:  &pb_basic.RepeatedFields{Doubles: []float64{42, 1.5}},
+			want: `{"doubles":[42,1.5]}`,
+		},
+		{
+			name: "WholeNumbers",
+			msg:  &pb_basic.RepeatedFields{Doubles: []float64{42, -3, 0, 1.5, 1e21}},
+			opts: protojson.MarshalOptions{FloatDecimalPoint: true},
+			want: `{"doubles":[42.0,-3.0,0.0,1.5,1e+21]}`,
+		},
+		{
+			name: "Float",
+			msg:  &pb_basic.BasicTypes{FloatField: 2},
+			opts: protojson.MarshalOptions{FloatDecimalPoint: true},
+			want: `{"floatField":2.0}`,
+		},
+		{
+			name: "Shortest",
+			msg:  &pb_basic.RepeatedFields{Doubles: []float64{100, 1e21}},
+			opts: protojson.MarshalOptions{FloatDecimalPoint: true, FloatFormat: protojson.FloatFormatShortest},
+			want: `{"doubles":[100.0,1e+21]}`,
+		},
+		{
+			name: "NonFiniteZero",
+			msg:  &pb_basic.RepeatedFields{Doubles: []float64{math.NaN(), math.Inf(-1)}},
+			opts: protojson.MarshalOptions{FloatDecimalPoint: true, NonFinitePolicy: protojson.NonFiniteZero},
+			want: `{"doubles":[0.0,0.0]}`,
+		},
+		{
+			name: "StructNumber",
+			msg: &pb_basic.WellKnownTypes{
+				Value: structpb.NewNumberValue(7),
+			},
+			opts: protojson.MarshalOptions{FloatDecimalPoint: true},
+			want: `{"value":7.0}`,
+		},
+		{
+			name: "IntegersUnchanged",
+			msg:  &pb_basic.BasicTypes{Int32Field: 5},
+			opts: protojson.MarshalOptions{FloatDecimalPoint: true},
+			want: `{"int32Field":5}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestTimestampFormat tests the Unix epoch Timestamp formats
+func TestTimestampFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		ts   *timestamppb.Timestamp
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "RFC3339",
+			ts:   &timestamppb.Timestamp{Seconds: 1700000000, Nanos: 123456789},
+			want: `"2023-11-14T22:13:20.123456789Z"`,
+		},
+		{
+			name: "Seconds",
+			ts:   &timestamppb.Timestamp{Seconds: 1700000000},
+			opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixSeconds},
+			want: `1700000000`,
+		},
+		{
+			name: "SecondsFraction",
+			ts:   &timestamppb.Timestamp{Seconds: 1700000000, Nanos: 500000000},
+			opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixSeconds},
+			want: `1700000000.500`,
+		},
+		{
+			name: "SecondsPrecision",
+			ts:   &timestamppb.Timestamp{Seconds: 1700000000, Nanos: 123456789},
+			opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixSeconds, TimestampPrecision: 2},
+			want: `1700000000.12`,
+		},
+		{
+			name: "Millis",
+			ts:   &timestamppb.Timestamp{Seconds: 1700000000, Nanos: 123456789},
+			opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixMillis},
+			want: `1700000000123`,
+		},
+		{
+			name: "Micros",
+			ts:   &timestamppb.Timestamp{Seconds: 1700000000, Nanos: 1000},
+			opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixMicros},
+			want: `1700000000000001`,
+		},
+		{
+			name: "Nanos",
+			ts:   &timestamppb.Timestamp{Seconds: 1700000000, Nanos: 5},
+			opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixNanos},
+			want: `1700000000000000005`,
+		},
+		{
+			name: "NanosBeyondInt64",
+			ts:   &timestamppb.Timestamp{Seconds: 253402300799, Nanos: 999999999},
+			opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixNanos},
+			want: `253402300799999999999`,
+		},
+		{
+			name: "SubSecondMillis",
+			ts:   &timestamppb.Timestamp{Nanos: 7000000},
+			opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixMillis},
+			want: `7`,
+		},
+		{
+			name: "BeforeEpochMillis",
+			ts:   &timestamppb.Timestamp{Seconds: -2, Nanos: 500000000},
+			opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixMillis},
+			want: `-1500`,
+		},
+		{
+			name: "BeforeEpochSeconds",
+			ts:   &timestamppb.Timestamp{Seconds: -1, Nanos: 750000000},
+			opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixSeconds},
+			want: `-0.250`,
+		},
+		{
+			name: "BeforeEpochTruncatedToZero",
+			ts:   &timestamppb.Timestamp{Seconds: -1, Nanos: 999999999},
+			opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixMillis},
+			want: `0`,
+		},
+		{
+			name: "AsString",
+			ts:   &timestamppb.Timestamp{Seconds: 1700000000, Nanos: 123000000},
+			opts: protojson.MarshalOptions{TimestampFormat: protojson.TimestampUnixMillis, TimestampEpochAsString: true},
+			want: `"1700000000123"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(&pb_basic.WellKnownTypes{Timestamp: tt.ts})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			want := `{"timestamp":` + tt.want + `}`
+			if diff := cmp.Diff(want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestDurationFormat tests the alternative Duration formats
+func TestDurationFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		d      *durationpb.Duration
+		format protojson.DurationFormat
+		want   string
+	}{
+		{name: "Seconds", d: &durationpb.Duration{Seconds: 5400}, want: `"5400s"`},
+		{name: "SecondsNumber", d: &durationpb.Duration{Seconds: 5400}, format: protojson.DurationSecondsNumber, want: `5400`},
+		{name: "SecondsNumberFraction", d: &durationpb.Duration{Seconds: 1, Nanos: 500000000}, format: protojson.DurationSecondsNumber, want: `1.500`},
+		{name: "SecondsNumberNegative", d: &durationpb.Duration{Nanos: -1000}, format: protojson.DurationSecondsNumber, want: `-0.000001`},
+		{name: "Nanos", d: &durationpb.Duration{Seconds: 2, Nanos: 5}, format: protojson.DurationNanos, want: `2000000005`},
+		{name: "NanosSubSecond", d: &durationpb.Duration{Nanos: -250}, format: protojson.DurationNanos, want: `-250`},
+		{name: "NanosMax", d: &durationpb.Duration{Seconds: 315576000000, Nanos: 999999999}, format: protojson.DurationNanos, want: `315576000000999999999`},
+		{name: "ISO8601", d: &durationpb.Duration{Seconds: 5400}, format: protojson.DurationISO8601, want: `"PT1H30M"`},
+		{name: "ISO8601Seconds", d: &durationpb.Duration{Seconds: 3725, Nanos: 500000000}, format: protojson.DurationISO8601, want: `"PT1H2M5.500S"`},
+		{name: "ISO8601Fraction", d: &durationpb.Duration{Nanos: 1500}, format: protojson.DurationISO8601, want: `"PT0.000001500S"`},
+		{name: "ISO8601Zero", d: &durationpb.Duration{}, format: protojson.DurationISO8601, want: `"PT0S"`},
+		{name: "ISO8601Negative", d: &durationpb.Duration{Seconds: -90}, format: protojson.DurationISO8601, want: `"-PT1M30S"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := protojson.MarshalOptions{DurationFormat: tt.format}
+			got, err := opts.Marshal(&pb_basic.WellKnownTypes{Duration: tt.d})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			want := `{"duration":` + tt.want + `}`
+			if diff := cmp.Diff(want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestBytesEncoding tests the bytes field encodings
+func TestBytesEncoding(t *testing.T) {
+	data := []byte{0x00, 0xfb, 0xff, 0x10}
+	long := bytes.Repeat([]byte{0xab}, 100)
+
+	tests := []struct {
+		name     string
+		msg      proto.Message
+		encoding protojson.BytesEncoding
+		want     string
+	}{
+		{
+			name: "Base64",
+			msg:  &pb_basic.BasicTypes{BytesField: data},
+			want: `{"bytesField":"APv/EA=="}`,
+		},
+		{
+			name:     "Base64URL",
+			msg:      &pb_basic.BasicTypes{BytesField: data},
+			encoding: protojson.BytesBase64URL,
+			want:     `{"bytesField":"APv_EA=="}`,
+		},
+		{
+			name:     "Hex",
+			msg:      &pb_basic.BasicTypes{BytesField: data},
+			encoding: protojson.BytesHex,
+			want:     `{"bytesField":"00fbff10"}`,
+		},
+		{
+			name:     "HexLong",
+			msg:      &pb_basic.BasicTypes{BytesField: long},
+			encoding: protojson.BytesHex,
+			want:     `{"bytesField":"` + strings.Repeat("ab", 100) + `"}`,
+		},
+		{
+			name:     "Array",
+			msg:      &pb_basic.BasicTypes{BytesField: data},
+			encoding: protojson.BytesArray,
+			want:     `{"bytesField":[0,251,255,16]}`,
+		},
+		{
+			name:     "Wrapper",
+			msg:      &pb_basic.WrapperTypes{BytesValue: wrapperspb.Bytes(data)},
+			encoding: protojson.BytesHex,
+			want:     `{"bytesValue":"00fbff10"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protojson.MarshalOptions{BytesEncoding: tt.encoding}.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestBytesLengths tests base64 output for lengths around the sizes of the
+// chunks the encoder writes
+func TestBytesLengths(t *testing.T) {
+	for n := 0; n <= 200; n++ {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(i*7 + n)
+		}
+		msg := &pb_basic.BasicTypes{BytesField: b}
+
+		for _, opts := range []protojson.MarshalOptions{{}, {BytesEncoding: protojson.BytesBase64URL}} {
+			got, err := opts.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			enc := base64.StdEncoding
+			if opts.BytesEncoding == protojson.BytesBase64URL {
+				enc = base64.URLEncoding
+			}
+			want := `{"bytesField":"` + enc.EncodeToString(b) + `"}`
+			if n == 0 {
+				want = `{}`
+			}
+			if diff := cmp.Diff(want, string(got)); diff != "" {
+				t.Errorf("Marshal() of %d bytes mismatch (-want +got):\n%s", n, diff)
+			}
+		}
+	}
+}
+
+// TestEscapeLineSeparators tests escaping U+2028 and U+2029 in strings
+func TestEscapeLineSeparators(t *testing.T) {
+	msg := &pb_basic.BasicTypes{StringField: "a\u2028b\u2029c é"}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Default",
+			want: "{\"stringField\":\"a\u2028b\u2029c é\"}",
+		},
+		{
+			name: "Escaped",
+			opts: protojson.MarshalOptions{EscapeLineSeparators: true},
+			want: `{"stringField":"a\u2028b\u2029c é"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+			var decoded map[string]string
+			if err := json.Unmarshal(got, &decoded); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if decoded["stringField"] != msg.StringField {
+				t.Errorf("decoded stringField = %q, want %q", decoded["stringField"], msg.StringField)
+			}
+		})
+	}
+}
+
+// TestASCIIOnly tests escaping all non-ASCII characters
+func TestASCIIOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Default",
+			msg:  &pb_basic.BasicTypes{StringField: "caf\u00e9"},
+			want: "{\"stringField\":\"caf\u00e9\"}",
+		},
+		{
+			name: "BMP",
+			msg:  &pb_basic.BasicTypes{StringField: "caf\u00e9 \u65e5\u672c"},
+			opts: protojson.MarshalOptions{ASCIIOnly: true},
+			want: `{"stringField":"caf\u00e9 \u65e5\u672c"}`,
+		},
+		{
+			name: "SurrogatePair",
+			msg:  &pb_basic.BasicTypes{StringField: "a\U0001F600b"},
+			opts: protojson.MarshalOptions{ASCIIOnly: true},
+			want: `{"stringField":"a\ud83d\ude00b"}`,
+		},
+		{
+			name: "WithEscapes",
+			msg:  &pb_basic.BasicTypes{StringField: "\"\u00e9\"\n"},
+			opts: protojson.MarshalOptions{ASCIIOnly: true},
+			want: `{"stringField":"\"\u00e9\"\n"}`,
+		},
+		{
+			name: "InvalidUTF8",
+			msg:  &pb_basic.BasicTypes{StringField: "a\xffb"},
+			opts: protojson.MarshalOptions{ASCIIOnly: true, AllowInvalidUTF8: true},
+			want: `{"stringField":"a\ufffdb"}`,
+		},
+		{
+			name: "MapKeys",
+			msg:  &pb_basic.MapFields{StringMap: map[string]string{"\u043a\u043b\u044e\u0447": "\u0437\u043d\u0430\u0447\u0435\u043d\u0438\u0435"}},
+			opts: protojson.MarshalOptions{ASCIIOnly: true},
+			want: `{"stringMap":{"\u043a\u043b\u044e\u0447":"\u0437\u043d\u0430\u0447\u0435\u043d\u0438\u0435"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestUnknownEnum tests each policy for enum values without a declared name
+func TestUnknownEnum(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     proto.Message
+		opts    protojson.MarshalOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Number",
+			msg:  &pb_basic.EnumFields{Status: pb_basic.Status(99), Priority: pb_basic.Priority_PRIORITY_HIGH},
+			want: `{"status":99,"priority":"PRIORITY_HIGH"}`,
+		},
+		{
+			name:    "Error",
+			msg:     &pb_basic.EnumFields{Status: pb_basic.Status(99)},
+			opts:    protojson.MarshalOptions{UnknownEnum: protojson.UnknownEnumError},
+			wantErr: true,
+		},
+		{
+			name: "ErrorIgnoredWithEnumNumbers",
+			msg:  &pb_basic.EnumFields{Status: pb_basic.Status(99)},
+			opts: protojson.MarshalOptions{UnknownEnum: protojson.UnknownEnumError, UseEnumNumbers: true},
+			want: `{"status":99}`,
+		},
+		{
+			name: "Placeholder",
+			msg:  &pb_basic.RepeatedEnums{Statuses: []pb_basic.Status{pb_basic.Status_STATUS_ACTIVE, pb_basic.Status(99)}},
+			opts: protojson.MarshalOptions{UnknownEnum: protojson.UnknownEnumPlaceholder, UnknownEnumName: "UNKNOWN"},
+			want: `{"statuses":["STATUS_ACTIVE","UNKNOWN"]}`,
+		},
+		{
+			name: "PlaceholderWithoutName",
+			msg:  &pb_basic.RepeatedEnums{Statuses: []pb_basic.Status{pb_basic.Status_STATUS_ACTIVE, pb_basic.Status(99)}},
+			opts: protojson.MarshalOptions{UnknownEnum: protojson.UnknownEnumPlaceholder},
+			want: `{"statuses":["STATUS_ACTIVE",99]}`,
+		},
+		{
+			name: "OmitField",
+			msg:  &pb_basic.EnumFields{Status: pb_basic.Status(99), Priority: pb_basic.Priority_PRIORITY_HIGH},
+			opts: protojson.MarshalOptions{UnknownEnum: protojson.UnknownEnumOmit},
+			want: `{"priority":"PRIORITY_HIGH"}`,
+		},
+		{
+			name: "OmitListElements",
+			msg:  &pb_basic.RepeatedEnums{Statuses: []pb_basic.Status{pb_basic.Status(99), pb_basic.Status_STATUS_ACTIVE, pb_basic.Status(98)}},
+			opts: protojson.MarshalOptions{UnknownEnum: protojson.UnknownEnumOmit},
+			want: `{"statuses":["STATUS_ACTIVE"]}`,
+		},
+		{
+			name: "OmitMapEntries",
+			msg: &pb_basic.RepeatedEnums{StatusMap: map[string]pb_basic.Status{
+				"a": pb_basic.Status(99),
+				"b": pb_basic.Status_STATUS_PENDING,
+			}},
+			opts: protojson.MarshalOptions{UnknownEnum: protojson.UnknownEnumOmit},
+			want: `{"statusMap":{"b":"STATUS_PENDING"}}`,
+		},
+		{
+			name: "OmitAllElements_Multiline",
+			msg:  &pb_basic.RepeatedEnums{Statuses: []pb_basic.Status{pb_basic.Status(99)}},
+			opts: protojson.MarshalOptions{UnknownEnum: protojson.UnknownEnumOmit, Multiline: true},
+			want: "{\n  \"statuses\": []\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Marshal() expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestEnumAsObject tests writing enums as name and number objects
+func TestEnumAsObject(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Singular",
+			msg:  &pb_basic.EnumFields{Status: pb_basic.Status_STATUS_ACTIVE},
+			opts: protojson.MarshalOptions{EnumAsObject: true},
+			want: `{"status":{"name":"STATUS_ACTIVE","number":1}}`,
+		},
+		{
+			name: "Repeated",
+			msg:  &pb_basic.RepeatedEnums{Priorities: []pb_basic.Priority{pb_basic.Priority_PRIORITY_LOW, pb_basic.Priority_PRIORITY_HIGH}},
+			opts: protojson.MarshalOptions{EnumAsObject: true, UseEnumNumbers: true},
+			want: `{"priorities":[{"name":"PRIORITY_LOW","number":1},{"name":"PRIORITY_HIGH","number":3}]}`,
+		},
+		{
+			name: "Unknown",
+			msg:  &pb_basic.EnumFields{Status: pb_basic.Status(99)},
+			opts: protojson.MarshalOptions{EnumAsObject: true},
+			want: `{"status":{"number":99}}`,
+		},
+		{
+			name: "UnknownPlaceholder",
+			msg:  &pb_basic.EnumFields{Status: pb_basic.Status(99)},
+			opts: protojson.MarshalOptions{EnumAsObject: true, UnknownEnum: protojson.UnknownEnumPlaceholder, UnknownEnumName: "UNKNOWN"},
+			want: `{"status":{"name":"UNKNOWN","number":99}}`,
+		},
+		{
+			name: "UnknownPlaceholderWithoutName",
+			msg:  &pb_basic.EnumFields{Status: pb_basic.Status(99)},
+			opts: protojson.MarshalOptions{EnumAsObject: true, UnknownEnum: protojson.UnknownEnumPlaceholder},
+			want: `{"status":{"number":99}}`,
+		},
+		{
+			name: "UnknownOmitted",
+			msg:  &pb_basic.EnumFields{Status: pb_basic.Status(99)},
+			opts: protojson.MarshalOptions{EnumAsObject: true, UseEnumNumbers: true, UnknownEnum: protojson.UnknownEnumOmit},
+			want: `{}`,
+		},
+		{
+			name: "Multiline",
+			msg:  &pb_basic.EnumFields{Status: pb_basic.Status_STATUS_ACTIVE},
+			opts: protojson.MarshalOptions{EnumAsObject: true, Multiline: true},
+			want: "{\n  \"status\": {\n    \"name\": \"STATUS_ACTIVE\",\n    \"number\": 1\n  }\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestWrapperAsObject tests writing wrapper types as objects of their value
+func TestWrapperAsObject(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Zero",
+			msg:  &pb_basic.WrapperTypes{Int32Value: wrapperspb.Int32(0), StringValue: wrapperspb.String("")},
+			want: `{"stringValue":{"value":""},"int32Value":{"value":0}}`,
+		},
+		{
+			name: "Values",
+			msg:  &pb_basic.WrapperTypes{Int64Value: wrapperspb.Int64(7), BytesValue: wrapperspb.Bytes([]byte("b"))},
+			want: `{"int64Value":{"value":"7"},"bytesValue":{"value":"Yg=="}}`,
+		},
+		{
+			name: "TopLevel",
+			msg:  wrapperspb.Bool(true),
+			want: `{"value":true}`,
+		},
+		{
+			name: "Unset",
+			msg:  &pb_basic.WrapperTypes{StringValue: wrapperspb.String("")},
+			opts: protojson.MarshalOptions{EmitUnpopulated: true},
+			want: `{"stringValue":{"value":""},"int32Value":null,"int64Value":null,"uint32Value":null,"uint64Value":null,` +
+				`"boolValue":null,"floatValue":null,"doubleValue":null,"bytesValue":null}`,
+		},
+		{
+			name: "Multiline",
+			msg:  &pb_basic.WrapperTypes{DoubleValue: wrapperspb.Double(1.5)},
+			opts: protojson.MarshalOptions{Multiline: true},
+			want: "{\n  \"doubleValue\": {\n    \"value\": 1.5\n  }\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.WrapperAsObject = true
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestTransformValue tests transforming values before they are written
+func TestTransformValue(t *testing.T) {
+	normalize := func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, error) {
+		if fd.Kind() == protoreflect.StringKind {
+			return protoreflect.ValueOfString(strings.ToLower(strings.TrimSpace(v.String()))), nil
+		}
+		return v, nil
+	}
+
+	tests := []struct {
+		name    string
+		msg     proto.Message
+		opts    protojson.MarshalOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Normalize",
+			msg: &pb_basic.User{
+				Name:        "  Alice ",
+				Permissions: []string{"READ", " Write"},
+				Metadata:    map[string]string{"Region": " EU"},
+			},
+			opts: protojson.MarshalOptions{TransformValue: normalize},
+			want: `{"name":"alice","permissions":["read","write"],"metadata":{"Region":"eu"}}`,
+		},
+		{
+			name: "UnitConversion",
+			msg:  &pb_basic.BasicTypes{Int32Field: 3, DoubleField: 1.5},
+			opts: protojson.MarshalOptions{
+				TransformValue: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, error) {
+					if fd.Name() == "int32_field" {
+						return protoreflect.ValueOfInt32(int32(v.Int()) * 1000), nil
+					}
+					return v, nil
+				},
+			},
+			want: `{"int32Field":3000,"doubleField":1.5}`,
+		},
+		{
+			name: "Null",
+			msg:  &pb_basic.BasicTypes{StringField: "s", Int32Field: 1},
+			opts: protojson.MarshalOptions{
+				TransformValue: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, error) {
+					if fd.Kind() == protoreflect.StringKind {
+						return protoreflect.Value{}, nil
+					}
+					return v, nil
+				},
+			},
+			want: `{"stringField":null,"int32Field":1}`,
+		},
+		{
+			name: "BeforeMasking",
+			msg:  &pb_basic.BasicTypes{StringField: " ABC "},
+			opts: protojson.MarshalOptions{
+				TransformValue: normalize,
+				FieldMaskFunc:  func(fd protoreflect.FieldDescriptor) bool { return true },
+				MaskMode:       protojson.MaskHash,
+				MaskHashFunc:   func(b []byte) []byte { return b },
+			},
+			want: `{"stringField":"616263"}`,
+		},
+		{
+			name: "Error",
+			msg:  &pb_basic.BasicTypes{StringField: "s"},
+			opts: protojson.MarshalOptions{
+				TransformValue: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, error) {
+					return v, errors.New("boom")
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Marshal() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestRawJSONFunc tests writing precomputed JSON for selected fields
+func TestRawJSONFunc(t *testing.T) {
+	rawBytes := func(fd protoreflect.FieldDescriptor, v protoreflect.Value) ([]byte, bool) {
+		if fd.Kind() == protoreflect.BytesKind {
+			return v.Bytes(), true
+		}
+		return nil, false
+	}
+
+	tests := []struct {
+		name    string
+		msg     proto.Message
+		opts    protojson.MarshalOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "BytesHoldingJSON",
+			msg:  &pb_basic.BasicTypes{StringField: "s", BytesField: []byte(` {"a":[1,2]} `)},
+			opts: protojson.MarshalOptions{RawJSONFunc: rawBytes},
+			want: `{"stringField":"s","bytesField":{"a":[1,2]}}`,
+		},
+		{
+			name: "CachedSubDocument",
+			msg:  &pb_basic.User{Name: "alice", Profile: &pb_basic.Profile{Bio: "hi"}},
+			opts: protojson.MarshalOptions{
+				RawJSONFunc: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) ([]byte, bool) {
+					if fd.Name() == "profile" {
+						return []byte(`{"cached":true}`), true
+					}
+					return nil, false
+				},
+			},
+			want: `{"name":"alice","profile":{"cached":true}}`,
+		},
+		{
+			name: "WholeRepeatedField",
+			msg:  &pb_basic.User{Permissions: []string{"read"}},
+			opts: protojson.MarshalOptions{
+				RawJSONFunc: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) ([]byte, bool) {
+					return []byte(`"all"`), fd.IsList()
+				},
+			},
+			want: `{"permissions":"all"}`,
+		},
+		{
+			name:    "InvalidJSON",
+			msg:     &pb_basic.BasicTypes{BytesField: []byte(`{"a":`)},
+			opts:    protojson.MarshalOptions{RawJSONFunc: rawBytes},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Marshal() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestConcurrentMarshal tests marshaling messages of the same types from
+// several goroutines at once, including their first use
+func TestConcurrentMarshal(t *testing.T) {
+	msgs := []proto.Message{
+		&pb_basic.User{Id: "1", Name: "alice", Metadata: map[string]string{"k": "v"}},
+		&pb_basic.BasicTypes{StringField: "s", Int64Field: 1},
+		&pb_basic.WellKnownTypes{Duration: durationpb.New(time.Second)},
+	}
+	want := make([]string, len(msgs))
+	for i, m := range msgs {
+		b, err := stdMarshal(stdprotojson.MarshalOptions{}, m)
+		if err != nil {
+			t.Fatalf("stdMarshal() error = %v", err)
+		}
+		want[i] = string(b)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, m := range msgs {
+				got, err := protojson.Marshal(m)
+				if err != nil {
+					t.Errorf("Marshal() error = %v", err)
+					return
+				}
+				if diff := cmp.Diff(want[i], string(got)); diff != "" {
+					t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
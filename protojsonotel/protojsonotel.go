@@ -0,0 +1,165 @@
+// Package protojsonotel records OpenTelemetry spans and metrics for
+// encoding and decoding protobuf messages with
+// github.com/wreulicke/protojson, so that serialization hotspots show up in
+// traces and dashboards.
+package protojsonotel
+
+import (
+	"context"
+	"time"
+
+	"github.com/wreulicke/protojson"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ScopeName is the instrumentation scope of the tracer and meter.
+const ScopeName = "github.com/wreulicke/protojson/protojsonotel"
+
+// Attribute keys set on spans and metrics.
+const (
+	// MessageTypeKey is the full name of the message type.
+	MessageTypeKey = attribute.Key("protojson.message.type")
+
+	// SizeKey is the size of the JSON encoding in bytes, set on spans.
+	SizeKey = attribute.Key("protojson.size")
+)
+
+// Options configures an Instrumentation.
+type Options struct {
+	// TracerProvider creates the tracer. If nil, the global provider is
+	// used.
+	TracerProvider trace.TracerProvider
+
+	// MeterProvider creates the meter. If nil, the global provider is used.
+	MeterProvider metric.MeterProvider
+
+	// DisableSpans turns off recording spans.
+	DisableSpans bool
+
+	// DisableMetrics turns off recording metrics.
+	DisableMetrics bool
+}
+
+// Instrumentation encodes and decodes messages, recording a span and the
+// duration and size of the encoding for each call. Spans are named
+// "protojson.Encode" and "protojson.Decode"; the metrics are the
+// histograms protojson.encode.duration, protojson.encode.size,
+// protojson.decode.duration and protojson.decode.size, with the
+// MessageTypeKey attribute. Durations of failed calls are recorded, sizes
+// are not.
+//
+// An Instrumentation is safe for concurrent use.
+type Instrumentation struct {
+	tracer trace.Tracer
+	encode instruments
+	decode instruments
+}
+
+// instruments are the histograms of one operation
+type instruments struct {
+	duration metric.Float64Histogram
+	size     metric.Int64Histogram
+}
+
+// New returns an Instrumentation configured by opts.
+func New(opts Options) (*Instrumentation, error) {
+	tp := opts.TracerProvider
+	if opts.DisableSpans {
+		tp = tracenoop.NewTracerProvider()
+	} else if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	mp := opts.MeterProvider
+	if opts.DisableMetrics {
+		mp = metricnoop.NewMeterProvider()
+	} else if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+
+	in := &Instrumentation{tracer: tp.Tracer(ScopeName)}
+	meter := mp.Meter(ScopeName)
+	var err error
+	if in.encode, err = newInstruments(meter, "encode"); err != nil {
+		return nil, err
+	}
+	if in.decode, err = newInstruments(meter, "decode"); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// newInstruments creates the histograms of the named operation
+func newInstruments(meter metric.Meter, op string) (instruments, error) {
+	duration, err := meter.Float64Histogram("protojson."+op+".duration",
+		metric.WithDescription("Duration of protojson "+op+" calls."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return instruments{}, err
+	}
+	size, err := meter.Int64Histogram("protojson."+op+".size",
+		metric.WithDescription("Size of the JSON encoding of messages in protojson "+op+" calls."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return instruments{}, err
+	}
+	return instruments{duration: duration, size: size}, nil
+}
+
+// Marshal returns the JSON encoding of m using opts, recording it as a
+// child of the span in ctx.
+func (in *Instrumentation) Marshal(ctx context.Context, m proto.Message, opts protojson.MarshalOptions) ([]byte, error) {
+	var b []byte
+	err := in.record(ctx, "protojson.Encode", in.encode, m, func() (int, error) {
+		var err error
+		b, err = opts.Marshal(m)
+		return len(b), err
+	})
+	return b, err
+}
+
+// Encode writes m to enc, recording it as a child of the span in ctx. The
+// size is taken from the stats of enc.
+func (in *Instrumentation) Encode(ctx context.Context, enc *protojson.Encoder, m proto.Message) error {
+	return in.record(ctx, "protojson.Encode", in.encode, m, func() (int, error) {
+		before := enc.Stats().Bytes
+		err := enc.Encode(m)
+		return int(enc.Stats().Bytes - before), err
+	})
+}
+
+// Unmarshal decodes data into m using opts, recording it as a child of the
+// span in ctx.
+func (in *Instrumentation) Unmarshal(ctx context.Context, data []byte, m proto.Message, opts stdprotojson.UnmarshalOptions) error {
+	return in.record(ctx, "protojson.Decode", in.decode, m, func() (int, error) {
+		return len(data), opts.Unmarshal(data, m)
+	})
+}
+
+// record calls f in a span named name, recording its duration and the size
+// it returns
+func (in *Instrumentation) record(ctx context.Context, name string, inst instruments, m proto.Message, f func() (int, error)) error {
+	typeName := MessageTypeKey.String(string(m.ProtoReflect().Descriptor().FullName()))
+	ctx, span := in.tracer.Start(ctx, name, trace.WithAttributes(typeName))
+	defer span.End()
+
+	start := time.Now()
+	n, err := f()
+	attrs := metric.WithAttributes(typeName)
+	inst.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	inst.size.Record(ctx, int64(n), attrs)
+	span.SetAttributes(SizeKey.Int(n))
+	return nil
+}
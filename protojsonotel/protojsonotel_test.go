@@ -0,0 +1,161 @@
+package protojsonotel_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"github.com/wreulicke/protojson/protojsonotel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+)
+
+// newInstrumentation returns an Instrumentation recording to spans and
+// reader
+func newInstrumentation(t *testing.T, opts protojsonotel.Options) (*protojsonotel.Instrumentation, *tracetest.SpanRecorder, *sdkmetric.ManualReader) {
+	t.Helper()
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	opts.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	opts.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	in, err := protojsonotel.New(opts)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return in, spans, reader
+}
+
+// histograms returns the sample counts and sums of the histograms
+// collected by reader, by name and message type
+func histograms(t *testing.T, reader *sdkmetric.ManualReader) map[string][2]float64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	got := make(map[string][2]float64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Histogram[int64]:
+				for _, p := range data.DataPoints {
+					typeName, _ := p.Attributes.Value(protojsonotel.MessageTypeKey)
+					got[m.Name+" "+typeName.AsString()] = [2]float64{float64(p.Count), float64(p.Sum)}
+				}
+			case metricdata.Histogram[float64]:
+				for _, p := range data.DataPoints {
+					typeName, _ := p.Attributes.Value(protojsonotel.MessageTypeKey)
+					// Durations vary, so only the count is compared
+					got[m.Name+" "+typeName.AsString()] = [2]float64{float64(p.Count), 0}
+				}
+			}
+		}
+	}
+	return got
+}
+
+// TestInstrumentation tests the spans and metrics recorded for encoding
+// and decoding
+func TestInstrumentation(t *testing.T) {
+	in, spans, reader := newInstrumentation(t, protojsonotel.Options{})
+	ctx := context.Background()
+	msg := &pb_basic.Inner{Name: "b"}
+
+	b, err := in.Marshal(ctx, msg, protojson.MarshalOptions{})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := in.Encode(ctx, protojson.NewEncoder(&buf), msg); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if err := in.Unmarshal(ctx, b, &pb_basic.Inner{}, stdprotojson.UnmarshalOptions{}); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	type span struct {
+		Name  string
+		Attrs []attribute.KeyValue
+	}
+	attrs := []attribute.KeyValue{
+		protojsonotel.MessageTypeKey.String("test.nested.Inner"),
+		protojsonotel.SizeKey.Int(len(b)),
+	}
+	want := []span{{"protojson.Encode", attrs}, {"protojson.Encode", attrs}, {"protojson.Decode", attrs}}
+	var got []span
+	for _, s := range spans.Ended() {
+		got = append(got, span{s.Name(), s.Attributes()})
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b attribute.Value) bool { return a == b })); diff != "" {
+		t.Errorf("spans mismatch (-want +got):\n%s", diff)
+	}
+
+	wantMetrics := map[string][2]float64{
+		"protojson.encode.duration test.nested.Inner": {2, 0},
+		"protojson.encode.size test.nested.Inner":     {2, float64(2 * len(b))},
+		"protojson.decode.duration test.nested.Inner": {1, 0},
+		"protojson.decode.size test.nested.Inner":     {1, float64(len(b))},
+	}
+	if diff := cmp.Diff(wantMetrics, histograms(t, reader)); diff != "" {
+		t.Errorf("metrics mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestInstrumentationError tests that failed calls are recorded as span
+// errors without a size
+func TestInstrumentationError(t *testing.T) {
+	in, spans, reader := newInstrumentation(t, protojsonotel.Options{})
+	_, err := in.Marshal(context.Background(), &pb_basic.Inner{Name: "\xff"}, protojson.MarshalOptions{})
+	if !errors.Is(err, protojson.ErrInvalidUTF8) {
+		t.Fatalf("Marshal() error = %v, want ErrInvalidUTF8", err)
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got %d spans, want 1", len(ended))
+	}
+	if got := ended[0].Status().Code; got != codes.Error {
+		t.Errorf("span status = %v, want Error", got)
+	}
+	want := map[string][2]float64{"protojson.encode.duration test.nested.Inner": {1, 0}}
+	if diff := cmp.Diff(want, histograms(t, reader)); diff != "" {
+		t.Errorf("metrics mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestInstrumentationDisabled tests turning off spans and metrics
+func TestInstrumentationDisabled(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        protojsonotel.Options
+		wantSpans   int
+		wantMetrics int
+	}{
+		{name: "Spans", opts: protojsonotel.Options{DisableSpans: true}, wantMetrics: 2},
+		{name: "Metrics", opts: protojsonotel.Options{DisableMetrics: true}, wantSpans: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, spans, reader := newInstrumentation(t, tt.opts)
+			if _, err := in.Marshal(context.Background(), &pb_basic.Inner{Name: "b"}, protojson.MarshalOptions{}); err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if got := len(spans.Ended()); got != tt.wantSpans {
+				t.Errorf("got %d spans, want %d", got, tt.wantSpans)
+			}
+			if got := len(histograms(t, reader)); got != tt.wantMetrics {
+				t.Errorf("got %d metrics, want %d", got, tt.wantMetrics)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Package protojsonprom exposes Prometheus metrics of encoding protobuf
+// messages with github.com/wreulicke/protojson, for fleet-wide visibility
+// of the cost of JSON serialization.
+package protojsonprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wreulicke/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// MessageLabel is the label holding the full name of the message type.
+const MessageLabel = "message"
+
+// Options configures a Collector.
+type Options struct {
+	// Namespace is prepended to the metric names, e.g. "myapp" for
+	// myapp_protojson_encode_duration_seconds.
+	Namespace string
+
+	// DurationBuckets are the buckets of the duration histogram in
+	// seconds. If nil, exponential buckets from 10µs to about 2.6s are
+	// used.
+	DurationBuckets []float64
+
+	// SizeBuckets are the buckets of the size histogram in bytes. If nil,
+	// exponential buckets from 64B to 16MiB are used.
+	SizeBuckets []float64
+}
+
+// Collector is a prometheus.Collector of the histograms
+// protojson_encode_duration_seconds and protojson_encode_size_bytes,
+// labeled by message type. Messages are observed when encoded with its
+// Marshal and Encode methods, or reported with Observe.
+//
+// A Collector is safe for concurrent use.
+type Collector struct {
+	duration *prometheus.HistogramVec
+	size     *prometheus.HistogramVec
+}
+
+// NewCollector returns a Collector configured by opts.
+func NewCollector(opts Options) *Collector {
+	durationBuckets := opts.DurationBuckets
+	if durationBuckets == nil {
+		durationBuckets = prometheus.ExponentialBuckets(10e-6, 4, 10)
+	}
+	sizeBuckets := opts.SizeBuckets
+	if sizeBuckets == nil {
+		sizeBuckets = prometheus.ExponentialBuckets(64, 4, 10)
+	}
+	return &Collector{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: "protojson",
+			Name:      "encode_duration_seconds",
+			Help:      "Duration of encoding protobuf messages as JSON.",
+			Buckets:   durationBuckets,
+		}, []string{MessageLabel}),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: "protojson",
+			Name:      "encode_size_bytes",
+			Help:      "Size of the JSON encoding of protobuf messages.",
+			Buckets:   sizeBuckets,
+		}, []string{MessageLabel}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.duration.Describe(ch)
+	c.size.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.duration.Collect(ch)
+	c.size.Collect(ch)
+}
+
+// Observe records the encoding of a message of the named type that took d
+// and wrote n bytes.
+func (c *Collector) Observe(name protoreflect.FullName, d time.Duration, n int) {
+	c.duration.WithLabelValues(string(name)).Observe(d.Seconds())
+	c.size.WithLabelValues(string(name)).Observe(float64(n))
+}
+
+// Marshal returns the JSON encoding of m using opts, observing it if it
+// succeeds.
+func (c *Collector) Marshal(m proto.Message, opts protojson.MarshalOptions) ([]byte, error) {
+	start := time.Now()
+	b, err := opts.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	c.Observe(m.ProtoReflect().Descriptor().FullName(), time.Since(start), len(b))
+	return b, nil
+}
+
+// Encode writes m to enc, observing it if it succeeds. The size is taken
+// from the stats of enc, so Encode may be called concurrently for
+// different encoders, but not for the same one.
+func (c *Collector) Encode(enc *protojson.Encoder, m proto.Message) error {
+	start := time.Now()
+	before := enc.Stats().Bytes
+	if err := enc.Encode(m); err != nil {
+		return err
+	}
+	c.Observe(m.ProtoReflect().Descriptor().FullName(), time.Since(start), int(enc.Stats().Bytes-before))
+	return nil
+}
@@ -0,0 +1,88 @@
+package protojsonprom_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"github.com/wreulicke/protojson/protojsonprom"
+)
+
+// histogram is the sample count and sum of a histogram for one message type
+type histogram struct {
+	Count uint64
+	Sum   float64
+}
+
+// gather returns the histograms collected from c, by metric name and
+// message type. Durations vary, so their sums are left out.
+func gather(t *testing.T, c *protojsonprom.Collector) map[string]histogram {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	got := make(map[string]histogram)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			h := histogram{Count: m.GetHistogram().GetSampleCount()}
+			if f.GetName() != "protojson_encode_duration_seconds" {
+				h.Sum = m.GetHistogram().GetSampleSum()
+			}
+			got[f.GetName()+" "+m.GetLabel()[0].GetValue()] = h
+		}
+	}
+	return got
+}
+
+// TestCollector tests the histograms of messages encoded through a
+// collector
+func TestCollector(t *testing.T) {
+	c := protojsonprom.NewCollector(protojsonprom.Options{})
+	var buf bytes.Buffer
+	enc := protojson.NewEncoder(&buf)
+
+	nested := &pb_basic.Nested{Id: "a", Inner: &pb_basic.Inner{Name: "b"}}
+	for range 2 {
+		if err := c.Encode(enc, nested); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+	if err := c.Encode(enc, &pb_basic.Inner{Name: "\xff"}); err == nil {
+		t.Fatal("Encode() of invalid UTF-8 succeeded, want error")
+	}
+	b, err := c.Marshal(&pb_basic.Inner{Name: "b"}, protojson.MarshalOptions{})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := map[string]histogram{
+		"protojson_encode_duration_seconds test.nested.Nested": {Count: 2},
+		"protojson_encode_size_bytes test.nested.Nested":       {Count: 2, Sum: float64(buf.Len())},
+		"protojson_encode_duration_seconds test.nested.Inner":  {Count: 1},
+		"protojson_encode_size_bytes test.nested.Inner":        {Count: 1, Sum: float64(len(b))},
+	}
+	if diff := cmp.Diff(want, gather(t, c)); diff != "" {
+		t.Errorf("histograms mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestCollectorNamespace tests prefixing the metric names and observing
+// messages directly
+func TestCollectorNamespace(t *testing.T) {
+	c := protojsonprom.NewCollector(protojsonprom.Options{Namespace: "app"})
+	c.Observe("test.nested.Inner", time.Millisecond, 10)
+	want := map[string]histogram{
+		"app_protojson_encode_duration_seconds test.nested.Inner": {Count: 1, Sum: 0.001},
+		"app_protojson_encode_size_bytes test.nested.Inner":       {Count: 1, Sum: 10},
+	}
+	if diff := cmp.Diff(want, gather(t, c)); diff != "" {
+		t.Errorf("histograms mismatch (-want +got):\n%s", diff)
+	}
+}
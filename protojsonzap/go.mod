@@ -0,0 +1,14 @@
+module github.com/wreulicke/protojson/protojsonzap
+
+go 1.25.1
+
+require (
+	github.com/google/go-cmp v0.7.0
+	github.com/wreulicke/protojson v0.0.0-00010101000000-000000000000
+	go.uber.org/zap v1.27.0
+	google.golang.org/protobuf v1.36.11
+)
+
+require go.uber.org/multierr v1.10.0 // indirect
+
+replace github.com/wreulicke/protojson => ../
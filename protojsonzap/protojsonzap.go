@@ -0,0 +1,22 @@
+// Package protojsonzap logs protobuf messages with go.uber.org/zap using
+// github.com/wreulicke/protojson.
+package protojsonzap
+
+import (
+	"github.com/wreulicke/protojson"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// Object returns a zap.Field that logs m under key as a JSON object
+// written by protojson with opts, e.g. with FieldMaskFunc masking sensitive
+// fields, instead of zap's reflection-based encoding of the generated
+// struct:
+//
+//	logger.Info("created user", protojsonzap.Object("user", user, opts))
+//
+// The message is only marshaled when an entry holding the field is
+// written. If marshaling fails, zap logs the error under key+"Error".
+func Object(key string, m proto.Message, opts protojson.MarshalOptions) zap.Field {
+	return zap.Reflect(key, protojson.JSONWrapper(m, opts))
+}
@@ -0,0 +1,71 @@
+package protojsonzap_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"github.com/wreulicke/protojson/protojsonzap"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// newLogger returns a logger writing JSON entries without timestamps to buf
+func newLogger(buf *bytes.Buffer) *zap.Logger {
+	cfg := zapcore.EncoderConfig{
+		MessageKey:     "msg",
+		LevelKey:       "level",
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeDuration: zapcore.StringDurationEncoder,
+	}
+	return zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(cfg), zapcore.AddSync(buf), zap.InfoLevel))
+}
+
+// TestObject tests lazy marshaling of messages logged with zap
+func TestObject(t *testing.T) {
+	calls := 0
+	opts := protojson.MarshalOptions{
+		FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "email" },
+		TransformValue: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, error) {
+			calls++
+			return v, nil
+		},
+	}
+	msg := &pb_basic.User{Name: "alice", Email: "alice@example.com"}
+
+	var buf bytes.Buffer
+	logger := newLogger(&buf)
+
+	logger.Debug("skipped", protojsonzap.Object("user", msg, opts))
+	if calls != 0 {
+		t.Errorf("message marshaled %d times for a disabled entry, want 0", calls)
+	}
+
+	logger.Info("created", protojsonzap.Object("user", msg, opts))
+	want := `{"level":"info","msg":"created","user":{"name":"alice","email":"***"}}` + "\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("log mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestObjectError tests that marshaling errors are logged in place of the
+// message
+func TestObjectError(t *testing.T) {
+	opts := protojson.MarshalOptions{
+		TransformValue: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, error) {
+			return v, errors.New("boom")
+		},
+	}
+
+	var buf bytes.Buffer
+	newLogger(&buf).Info("created", protojsonzap.Object("user", &pb_basic.User{Name: "alice"}, opts))
+	if got := buf.String(); !strings.Contains(got, `"userError":`) || !strings.Contains(got, "boom") {
+		t.Errorf("log = %s, want the marshaling error under userError", got)
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/wreulicke/protojson/protojsonzerolog
+
+go 1.25.1
+
+require (
+	github.com/google/go-cmp v0.7.0
+	github.com/rs/zerolog v1.35.1
+	github.com/wreulicke/protojson v0.0.0-00010101000000-000000000000
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	github.com/mattn/go-colorable v0.1.14 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+)
+
+replace github.com/wreulicke/protojson => ../
@@ -0,0 +1,64 @@
+// Package protojsonzerolog logs protobuf messages with github.com/rs/zerolog
+// using github.com/wreulicke/protojson.
+package protojsonzerolog
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/wreulicke/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxPooledBufferSize is the largest output buffer kept in encoderPool.
+const maxPooledBufferSize = 64 << 10
+
+// pooledEncoder is an encoder reused across log events.
+type pooledEncoder struct {
+	buf bytes.Buffer
+	enc *protojson.Encoder
+}
+
+var encoderPool = sync.Pool{
+	New: func() any {
+		p := &pooledEncoder{}
+		p.enc = protojson.NewEncoder(&p.buf)
+		return p
+	},
+}
+
+// RawJSON adds m to e under key as a JSON object written by protojson with
+// opts, e.g. with FieldMaskFunc masking sensitive fields:
+//
+//	protojsonzerolog.RawJSON(logger.Info(), "user", user, opts).Msg("created user")
+//
+// The message is encoded with a pooled encoder straight into the bytes
+// copied into the event, and not at all if e is disabled. If marshaling
+// fails, the error is added under key+"Error" instead.
+func RawJSON(e *zerolog.Event, key string, m proto.Message, opts protojson.MarshalOptions) *zerolog.Event {
+	if !e.Enabled() {
+		return e
+	}
+
+	p := encoderPool.Get().(*pooledEncoder)
+	defer p.release()
+
+	p.enc.SetOptions(opts)
+	if err := p.enc.Encode(m); err != nil {
+		return e.AnErr(key+"Error", err)
+	}
+	return e.RawJSON(key, p.buf.Bytes())
+}
+
+// release resets p and returns it to encoderPool
+func (p *pooledEncoder) release() {
+	if p.buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	p.buf.Reset()
+	p.enc.Reset(&p.buf)
+	// Drop references to caller-provided resolvers and callbacks
+	p.enc.SetOptions(protojson.MarshalOptions{})
+	encoderPool.Put(p)
+}
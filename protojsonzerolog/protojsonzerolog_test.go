@@ -0,0 +1,61 @@
+package protojsonzerolog_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rs/zerolog"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"github.com/wreulicke/protojson/protojsonzerolog"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TestRawJSON tests writing messages into zerolog events
+func TestRawJSON(t *testing.T) {
+	calls := 0
+	opts := protojson.MarshalOptions{
+		FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "email" },
+		TransformValue: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, error) {
+			calls++
+			return v, nil
+		},
+	}
+	msg := &pb_basic.User{Name: "alice", Email: "alice@example.com"}
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).Level(zerolog.InfoLevel)
+
+	protojsonzerolog.RawJSON(logger.Debug(), "user", msg, opts).Msg("skipped")
+	if calls != 0 {
+		t.Errorf("message marshaled %d times for a disabled event, want 0", calls)
+	}
+
+	for range 2 {
+		protojsonzerolog.RawJSON(logger.Info(), "user", msg, opts).Msg("created")
+	}
+	line := `{"level":"info","user":{"name":"alice","email":"***"},"message":"created"}` + "\n"
+	if diff := cmp.Diff(line+line, buf.String()); diff != "" {
+		t.Errorf("log mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestRawJSONError tests that marshaling errors are logged in place of the
+// message
+func TestRawJSONError(t *testing.T) {
+	opts := protojson.MarshalOptions{
+		TransformValue: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, error) {
+			return v, errors.New("boom")
+		},
+	}
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	protojsonzerolog.RawJSON(logger.Info(), "user", &pb_basic.User{Name: "alice"}, opts).Msg("created")
+	if got := buf.String(); !strings.Contains(got, `"userError":`) || !strings.Contains(got, "boom") || strings.Contains(got, `"user":`) {
+		t.Errorf("log = %s, want only the marshaling error under userError", got)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package protojson_test
+
+// raceEnabled reports whether the race detector is on, whose
+// instrumentation allocates
+const raceEnabled = true
@@ -0,0 +1,129 @@
+package protojson
+
+import (
+	"encoding/hex"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// Redactor finds sensitive content in string values, returning the start
+// and end offsets of each match like regexp.Regexp.FindAllStringIndex,
+// which *regexp.Regexp implements.
+type Redactor interface {
+	FindAllStringIndex(s string, n int) [][]int
+}
+
+var (
+	// RedactEmails matches email addresses.
+	RedactEmails Redactor = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+	// RedactPhoneNumbers matches ten digit phone numbers in the usual
+	// groupings, e.g. "(555) 123-4567", with an optional "+" country code.
+	RedactPhoneNumbers Redactor = regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]?\d{4}\b`)
+
+	// RedactCreditCards matches payment card numbers of 13 to 19 digits,
+	// optionally grouped by spaces or dashes, that pass the Luhn check.
+	RedactCreditCards Redactor = luhnRedactor{regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)}
+
+	// RedactIPAddresses matches IPv4 and IPv6 addresses, along with a port
+	// if one follows.
+	RedactIPAddresses Redactor = ipRedactor{regexp.MustCompile(`[0-9A-Fa-f:.]*[.:][0-9A-Fa-f:.]*`)}
+)
+
+// PIIRedactors holds all built-in redactors, ordered so that card numbers
+// are matched before phone numbers.
+var PIIRedactors = []Redactor{RedactEmails, RedactCreditCards, RedactPhoneNumbers, RedactIPAddresses}
+
+// luhnRedactor keeps the matches of a regexp whose digits pass the Luhn check
+type luhnRedactor struct {
+	re *regexp.Regexp
+}
+
+func (r luhnRedactor) FindAllStringIndex(s string, n int) [][]int {
+	return filterMatches(s, r.re.FindAllStringIndex(s, n), luhnValid)
+}
+
+// luhnValid reports whether the digits of s pass the Luhn check
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// ipRedactor keeps the matches of a regexp that parse as an IP address
+type ipRedactor struct {
+	re *regexp.Regexp
+}
+
+func (r ipRedactor) FindAllStringIndex(s string, n int) [][]int {
+	locs := r.re.FindAllStringIndex(s, n)
+	for _, loc := range locs {
+		// Leave out trailing periods, e.g. one ending a sentence
+		for loc[1] > loc[0] && s[loc[1]-1] == '.' {
+			loc[1]--
+		}
+	}
+	return filterMatches(s, locs, func(m string) bool {
+		if _, err := netip.ParseAddr(m); err == nil {
+			return true
+		}
+		_, err := netip.ParseAddrPort(m)
+		return err == nil
+	})
+}
+
+// filterMatches keeps the matches in locs whose text satisfies keep
+func filterMatches(s string, locs [][]int, keep func(string) bool) [][]int {
+	n := 0
+	for _, loc := range locs {
+		if keep(s[loc[0]:loc[1]]) {
+			locs[n] = loc
+			n++
+		}
+	}
+	return locs[:n]
+}
+
+// redact replaces the content of s matched by Redactors with MaskString,
+// or with its digest in MaskHash mode
+func (e *encoder) redact(s string) string {
+	for _, r := range e.opts.Redactors {
+		locs := r.FindAllStringIndex(s, -1)
+		if len(locs) == 0 {
+			continue
+		}
+		if e.audit {
+			e.report.Redactions += len(locs)
+		}
+		var b strings.Builder
+		last := 0
+		for _, loc := range locs {
+			b.WriteString(s[last:loc[0]])
+			if e.opts.MaskMode == MaskHash {
+				b.WriteString(hex.EncodeToString(e.maskDigest([]byte(s[loc[0]:loc[1]]))))
+			} else if e.opts.MaskString != "" {
+				b.WriteString(e.opts.MaskString)
+			} else {
+				b.WriteString(defaultMaskString)
+			}
+			last = loc[1]
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+	return s
+}
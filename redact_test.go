@@ -0,0 +1,117 @@
+package protojson_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TestRedactors tests content-based redaction of string values
+func TestRedactors(t *testing.T) {
+	tests := []struct {
+		name      string
+		redactors []protojson.Redactor
+		opts      protojson.MarshalOptions
+		input     string
+		want      string
+	}{
+		{
+			name:      "Email",
+			redactors: []protojson.Redactor{protojson.RedactEmails},
+			input:     "contact alice@example.com or bob.smith+tag@mail.example.org",
+			want:      "contact *** or ***",
+		},
+		{
+			name:      "Phone",
+			redactors: []protojson.Redactor{protojson.RedactPhoneNumbers},
+			input:     "call (555) 123-4567 or +1 555.123.4567",
+			want:      "call *** or ***",
+		},
+		{
+			name:      "CreditCard",
+			redactors: []protojson.Redactor{protojson.RedactCreditCards},
+			input:     "card 4111 1111 1111 1111, ref 4111 1111 1111 1112",
+			want:      "card ***, ref 4111 1111 1111 1112",
+		},
+		{
+			name:      "IPAddress",
+			redactors: []protojson.Redactor{protojson.RedactIPAddresses},
+			input:     "from 10.0.0.1, 192.168.1.20:8080 and fe80::1 at 12:30:45 on host 10.1.2.3.",
+			want:      "from ***, *** and *** at 12:30:45 on host ***.",
+		},
+		{
+			name:      "AllPII",
+			redactors: protojson.PIIRedactors,
+			input:     "alice@example.com paid with 5500-0000-0000-0004 from 203.0.113.9, phone 555-123-4567",
+			want:      "*** paid with *** from ***, phone ***",
+		},
+		{
+			name:      "Custom",
+			redactors: []protojson.Redactor{regexp.MustCompile(`sk_[a-z0-9]+`)},
+			opts:      protojson.MarshalOptions{MaskString: "[REDACTED]"},
+			input:     "key sk_live123 leaked",
+			want:      "key [REDACTED] leaked",
+		},
+		{
+			name:      "Hash",
+			redactors: []protojson.Redactor{protojson.RedactEmails},
+			opts: protojson.MarshalOptions{
+				MaskMode:     protojson.MaskHash,
+				MaskHashFunc: func(b []byte) []byte { return []byte{byte(len(b))} },
+			},
+			input: "to a@b.io",
+			want:  "to 06",
+		},
+		{
+			name:      "NoMatch",
+			redactors: protojson.PIIRedactors,
+			input:     "version 1.5 released at 09:00",
+			want:      "version 1.5 released at 09:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Redactors = tt.redactors
+			got, err := opts.Marshal(&pb_basic.BasicTypes{StringField: tt.input})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			want, err := protojson.Marshal(&pb_basic.BasicTypes{StringField: tt.want})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(string(want), string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestRedactorsWithFieldMask tests redaction combined with field masking
+func TestRedactorsWithFieldMask(t *testing.T) {
+	msg := &pb_basic.User{
+		Name:        "alice",
+		Email:       "alice@example.com",
+		Permissions: []string{"owner alice@example.com"},
+		Metadata:    map[string]string{"contact": "bob@example.com"},
+	}
+	opts := protojson.MarshalOptions{
+		Redactors:     []protojson.Redactor{protojson.RedactEmails},
+		FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "email" },
+		MaskString:    "[PII]",
+	}
+	got, err := opts.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"name":"alice","email":"[PII]","permissions":["owner [PII]"],"metadata":{"contact":"[PII]"}}`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+	}
+}
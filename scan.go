@@ -0,0 +1,50 @@
+package protojson
+
+import "math/bits"
+
+// Masks for scanning strings eight bytes at a time
+const (
+	lsbMask   = 0x0101010101010101 // Lowest bit of each byte
+	msbMask   = 0x8080808080808080 // Highest bit of each byte
+	quoteMask = lsbMask * '"'
+	slashMask = lsbMask * '\\'
+	spaceMask = lsbMask * ' '
+)
+
+// escapeIndex returns the index of the first byte of s that must be escaped
+// in a JSON string, i.e. a control character, '"' or '\\', or len(s) if there
+// is none. If nonASCII is set, bytes of multi-byte UTF-8 sequences are
+// reported as well.
+//
+// Eight bytes are tested at a time: for each byte, the highest bit of the
+// result is set if the byte is less than ' ', equal to '"' or '\\', or, with
+// nonASCII, not ASCII. Borrows only carry past a byte that is reported, so
+// the lowest bit set marks the first such byte.
+func escapeIndex(s string, nonASCII bool) int {
+	var high uint64
+	if nonASCII {
+		high = msbMask
+	}
+	i := 0
+	for ; i+8 <= len(s); i += 8 {
+		_ = s[i+7]
+		x := uint64(s[i]) | uint64(s[i+1])<<8 | uint64(s[i+2])<<16 | uint64(s[i+3])<<24 |
+			uint64(s[i+4])<<32 | uint64(s[i+5])<<40 | uint64(s[i+6])<<48 | uint64(s[i+7])<<56
+		m := (x - spaceMask) &^ x
+		q := x ^ quoteMask
+		m |= (q - lsbMask) &^ q
+		b := x ^ slashMask
+		m |= (b - lsbMask) &^ b
+		m &= msbMask
+		m |= x & high
+		if m != 0 {
+			return i + bits.TrailingZeros64(m)/8
+		}
+	}
+	for ; i < len(s); i++ {
+		if c := s[i]; c < ' ' || c == '"' || c == '\\' || (nonASCII && c >= 0x80) {
+			return i
+		}
+	}
+	return i
+}
@@ -0,0 +1,52 @@
+package protojson_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+)
+
+// TestStringEscapePositions tests that characters needing escaping are
+// found at every offset of strings longer than the scanned word size
+func TestStringEscapePositions(t *testing.T) {
+	specials := []string{"\"", "\\", "\n", "\x00", "\x1f", " ", "!", "#", "[", "]", "\x7f", "\u00e9", "\u2028", "\U0001F30D"}
+
+	for _, special := range specials {
+		for n := 0; n <= 24; n++ {
+			for pos := 0; pos <= n; pos++ {
+				s := strings.Repeat("a", pos) + special + strings.Repeat("b", n-pos)
+				msg := &pb_basic.BasicTypes{StringField: s}
+
+				want, err := stdMarshal(stdprotojson.MarshalOptions{}, msg)
+				if err != nil {
+					t.Fatalf("standard protojson.Marshal failed: %v", err)
+				}
+				got, err := protojson.Marshal(msg)
+				if err != nil {
+					t.Fatalf("Marshal(%q) error = %v", s, err)
+				}
+				if diff := cmp.Diff(string(want), string(got)); diff != "" {
+					t.Errorf("Marshal(%q) mismatch (-want +got):\n%s", s, diff)
+				}
+			}
+		}
+	}
+}
+
+// TestStringInvalidUTF8Positions tests that invalid UTF-8 is detected at
+// every offset, also after valid multi-byte runes
+func TestStringInvalidUTF8Positions(t *testing.T) {
+	for _, prefix := range []string{"", "\u00e9"} {
+		for n := 0; n <= 24; n++ {
+			s := prefix + strings.Repeat("a", n) + "\xff" + strings.Repeat("b", 8)
+			_, err := protojson.Marshal(&pb_basic.BasicTypes{StringField: s})
+			if err == nil {
+				t.Errorf("Marshal(%q) succeeded, want error", s)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package protojson
+
+import (
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Size returns the length in bytes of the JSON encoding of m using options
+// in opts, without keeping the output. It encodes m into a counter, so the
+// result is exact and can be used to allocate a destination buffer or to
+// enforce a quota before marshaling. MaxOutputBytes is ignored, and
+// neither Hooks nor MaskAuditFunc are called. If m cannot be encoded, Size
+// returns the error Marshal would.
+func Size(m proto.Message, opts MarshalOptions) (int, error) {
+	s := sizePool.Get().(*sizeState)
+	defer s.release()
+
+	opts.MaxOutputBytes = 0
+	opts.MaskAuditFunc = nil
+	opts.Hooks = Hooks{}
+	s.enc.SetOptions(opts)
+	if err := s.enc.Encode(m); err != nil {
+		return 0, err
+	}
+	return s.n.n, nil
+}
+
+// byteCounter is a writer that counts the bytes written to it. Its Flush
+// method lets the encoder write to it without a bufio.Writer.
+type byteCounter struct {
+	n int
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += len(p)
+	return len(p), nil
+}
+
+func (c *byteCounter) WriteByte(byte) error {
+	c.n++
+	return nil
+}
+
+func (c *byteCounter) WriteString(s string) (int, error) {
+	c.n += len(s)
+	return len(s), nil
+}
+
+func (c *byteCounter) Flush() error {
+	return nil
+}
+
+// sizeState holds the reusable encoder for a single Size call
+type sizeState struct {
+	n   byteCounter
+	enc *Encoder
+}
+
+var sizePool = sync.Pool{
+	New: func() any {
+		s := &sizeState{}
+		s.enc = NewEncoder(&s.n)
+		return s
+	},
+}
+
+// release resets s and returns it to sizePool
+func (s *sizeState) release() {
+	s.n.n = 0
+	// Drop references to caller-provided resolvers and callbacks, keeping
+	// the plans of types built at run time
+	s.enc.opts = MarshalOptions{}
+	s.enc.enc = encoder{local: s.enc.enc.local}
+	sizePool.Put(s)
+}
@@ -0,0 +1,95 @@
+package protojson_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TestSize tests that Size returns the length of the Marshal output
+func TestSize(t *testing.T) {
+	msgs := []proto.Message{
+		&pb_basic.BasicTypes{},
+		&pb_basic.BasicTypes{StringField: "h\u00e9llo\n\"world\"", Int64Field: 1 << 40, BytesField: []byte("binary")},
+		&pb_basic.RepeatedFields{Strings: []string{"a", "b"}, Numbers: []int32{1, 2, 3}},
+		&pb_basic.MapFields{StringMap: map[string]string{"k1": "v1", "k2": "v2"}},
+		timestamppb.New(time.Date(2024, 1, 15, 10, 30, 0, 123000000, time.UTC)),
+	}
+	options := []protojson.MarshalOptions{
+		{},
+		{Indent: "  ", UseProtoNames: true},
+		{EmitUnpopulated: true, UseEnumNumbers: true},
+		{ASCIIOnly: true, BytesEncoding: protojson.BytesBase64URL},
+		{FieldMaskFunc: func(protoreflect.FieldDescriptor) bool { return true }},
+	}
+
+	for _, opts := range options {
+		for _, msg := range msgs {
+			data, err := opts.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			got, err := protojson.Size(msg, opts)
+			if err != nil {
+				t.Fatalf("Size() error = %v", err)
+			}
+			if got != len(data) {
+				t.Errorf("Size(%v) = %d, want %d", msg, got, len(data))
+			}
+		}
+	}
+}
+
+// TestSizeIgnoresMaxOutputBytes tests that Size reports the full length
+// of messages larger than MaxOutputBytes, and an error for invalid messages
+func TestSizeIgnoresMaxOutputBytes(t *testing.T) {
+	msg := &pb_basic.BasicTypes{StringField: "a long enough string"}
+	want, err := protojson.Size(msg, protojson.MarshalOptions{})
+	if err != nil {
+		t.Fatalf("Size() error = %v", err)
+	}
+	got, err := protojson.Size(msg, protojson.MarshalOptions{MaxOutputBytes: 8})
+	if err != nil {
+		t.Fatalf("Size() with MaxOutputBytes error = %v", err)
+	}
+	if got != want {
+		t.Errorf("Size() with MaxOutputBytes = %d, want %d", got, want)
+	}
+
+	invalid := &pb_basic.BasicTypes{StringField: "bad\xff"}
+	if _, err := protojson.Size(invalid, protojson.MarshalOptions{}); !errors.Is(err, protojson.ErrInvalidUTF8) {
+		t.Errorf("Size() of invalid UTF-8 error = %v, want ErrInvalidUTF8", err)
+	}
+}
+
+// TestSizeSkipsCallbacks tests that Size does not call hooks or the mask
+// audit function, whose calls would be mistaken for encoded output
+func TestSizeSkipsCallbacks(t *testing.T) {
+	calls := 0
+	opts := protojson.MarshalOptions{
+		FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "string_field" },
+		MaskAuditFunc: func(proto.Message, protojson.MaskReport) { calls++ },
+		Hooks: protojson.Hooks{
+			OnMessageStart: func(protoreflect.MessageDescriptor) { calls++ },
+			OnMessageEnd:   func(protoreflect.MessageDescriptor, int) { calls++ },
+			OnField:        func(protoreflect.FieldDescriptor) { calls++ },
+		},
+	}
+	msg := &pb_basic.BasicTypes{StringField: "secret", Int32Field: 1}
+	got, err := protojson.Size(msg, opts)
+	if err != nil {
+		t.Fatalf("Size() error = %v", err)
+	}
+	if want := len(`{"stringField":"***","int32Field":1}`); got != want {
+		t.Errorf("Size() = %d, want %d", got, want)
+	}
+	if calls != 0 {
+		t.Errorf("Size() made %d callback calls, want 0", calls)
+	}
+}
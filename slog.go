@@ -0,0 +1,36 @@
+package protojson
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// LogValue returns a slog.LogValuer that marshals m with opts, e.g. with
+// FieldMaskFunc masking sensitive fields, only when a log record holding
+// it is actually handled:
+//
+//	logger.Info("created user", "user", protojson.LogValue(user, opts))
+//
+// slog.JSONHandler embeds the message as a JSON object, while other
+// handlers see its JSON text. If marshaling fails, the value is the error.
+func LogValue(m proto.Message, opts MarshalOptions) slog.LogValuer {
+	return logValuer{m: m, opts: opts}
+}
+
+// logValuer marshals a message lazily for slog
+type logValuer struct {
+	m    proto.Message
+	opts MarshalOptions
+}
+
+func (v logValuer) LogValue() slog.Value {
+	opts := v.opts
+	opts.Comments = nil
+	b, err := opts.Marshal(v.m)
+	if err != nil {
+		return slog.AnyValue(err)
+	}
+	return slog.AnyValue(json.RawMessage(b))
+}
@@ -0,0 +1,69 @@
+package protojson_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TestLogValue tests lazy marshaling of messages logged with slog
+func TestLogValue(t *testing.T) {
+	calls := 0
+	opts := protojson.MarshalOptions{
+		FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "email" },
+		TransformValue: func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (protoreflect.Value, error) {
+			calls++
+			return v, nil
+		},
+	}
+	msg := &pb_basic.User{Name: "alice", Email: "alice@example.com"}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	logger.Debug("skipped", "user", protojson.LogValue(msg, opts))
+	if calls != 0 {
+		t.Errorf("message marshaled %d times for a disabled record, want 0", calls)
+	}
+
+	logger.InfoContext(context.Background(), "created", "user", protojson.LogValue(msg, opts))
+	want := `{"level":"INFO","msg":"created","user":{"name":"alice","email":"***"}}` + "\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("log mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestLogValueText tests LogValue with a text handler and marshaling errors
+func TestLogValueText(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == slog.LevelKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	logger.Info("ok", "user", protojson.LogValue(&pb_basic.User{Name: "bob"}, protojson.MarshalOptions{}))
+	logger.Info("bad", "value", protojson.LogValue(&pb_basic.BasicTypes{StringField: "\xff"}, protojson.MarshalOptions{}))
+
+	want := `msg=ok user="{\"name\":\"bob\"}"` + "\n" +
+		`msg=bad value="field test.basic.BasicTypes.string_field: invalid UTF-8 in string"` + "\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("log mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,76 @@
+package protojson
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// JSONColumn stores a message in a JSON or JSONB database column. It
+// implements driver.Valuer and sql.Scanner, so it can be passed to Exec and
+// Scan directly:
+//
+//	col := protojson.JSONColumn[*pb.User]{Message: user, Options: opts}
+//	_, err := db.Exec("INSERT INTO users (doc) VALUES ($1)", col)
+//
+//	var got protojson.JSONColumn[*pb.User]
+//	err = db.QueryRow("SELECT doc FROM users").Scan(&got)
+//
+// A nil Message is stored and loaded as SQL NULL.
+type JSONColumn[T proto.Message] struct {
+	// Message is the stored message, or nil for NULL.
+	Message T
+
+	// Options are the options the message is marshaled with. Scanning
+	// discards unknown fields, so rows written by newer schemas can still
+	// be read, and resolves types through Options.Resolver.
+	Options MarshalOptions
+}
+
+// Value returns the JSON encoding of c.Message as a string, so that drivers
+// send it as text rather than binary data, or nil if c.Message is nil.
+func (c JSONColumn[T]) Value() (driver.Value, error) {
+	if any(c.Message) == nil || !c.Message.ProtoReflect().IsValid() {
+		return nil, nil
+	}
+	opts := c.Options
+	opts.Comments = nil
+	b, err := opts.Marshal(c.Message)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan sets c.Message to a new message parsed from src, which must be a
+// string, a []byte or nil for NULL. If T is an interface type, c.Message
+// must already hold a message of the stored type.
+func (c *JSONColumn[T]) Scan(src any) error {
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		var zero T
+		c.Message = zero
+		return nil
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONColumn", src)
+	}
+
+	if any(c.Message) == nil {
+		return errors.New("cannot scan into JSONColumn of an interface type without a message")
+	}
+	m := c.Message.ProtoReflect().Type().New().Interface().(T)
+	opts := stdprotojson.UnmarshalOptions{DiscardUnknown: true, Resolver: resolverOf(c.Options.Resolver)}
+	if err := opts.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("scan into %T: %w", m, err)
+	}
+	c.Message = m
+	return nil
+}
@@ -0,0 +1,154 @@
+package protojson_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+var (
+	_ driver.Valuer = protojson.JSONColumn[*pb_basic.User]{}
+	_ sql.Scanner   = (*protojson.JSONColumn[*pb_basic.User])(nil)
+)
+
+// TestJSONColumnValue tests storing messages in JSON columns
+func TestJSONColumnValue(t *testing.T) {
+	tests := []struct {
+		name string
+		col  protojson.JSONColumn[proto.Message]
+		want driver.Value
+	}{
+		{
+			name: "nil interface",
+			col:  protojson.JSONColumn[proto.Message]{},
+			want: nil,
+		},
+		{
+			name: "nil message",
+			col:  protojson.JSONColumn[proto.Message]{Message: (*pb_basic.User)(nil)},
+			want: nil,
+		},
+		{
+			name: "message",
+			col:  protojson.JSONColumn[proto.Message]{Message: &pb_basic.User{Id: "1", Name: "alice"}},
+			want: `{"id":"1","name":"alice"}`,
+		},
+		{
+			name: "options",
+			col: protojson.JSONColumn[proto.Message]{
+				Message: &pb_basic.User{Name: "alice", Email: "alice@example.com"},
+				Options: protojson.MarshalOptions{
+					FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "email" },
+				},
+			},
+			want: `{"name":"alice","email":"***"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.col.Value()
+			if err != nil {
+				t.Fatalf("Value() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Value() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestJSONColumnScan tests loading messages from JSON columns
+func TestJSONColumnScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     any
+		want    *pb_basic.User
+		wantErr bool
+	}{
+		{
+			name: "null",
+			src:  nil,
+			want: nil,
+		},
+		{
+			name: "bytes",
+			src:  []byte(`{"id":"1","name":"alice"}`),
+			want: &pb_basic.User{Id: "1", Name: "alice"},
+		},
+		{
+			name: "string",
+			src:  `{"name":"alice"}`,
+			want: &pb_basic.User{Name: "alice"},
+		},
+		{
+			name: "unknown fields",
+			src:  `{"name":"alice","nickname":"al"}`,
+			want: &pb_basic.User{Name: "alice"},
+		},
+		{
+			name:    "invalid JSON",
+			src:     `{"name":`,
+			wantErr: true,
+		},
+		{
+			name:    "unsupported type",
+			src:     int64(1),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			col := protojson.JSONColumn[*pb_basic.User]{Message: &pb_basic.User{Name: "previous"}}
+			err := col.Scan(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, col.Message, protocmp.Transform()); diff != "" {
+				t.Errorf("Scan() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestJSONColumnRoundTrip tests scanning the value of a column, including
+// into an interface-typed column holding a message
+func TestJSONColumnRoundTrip(t *testing.T) {
+	msg := &pb_basic.User{Id: "1", Name: "alice", Email: "alice@example.com"}
+	v, err := protojson.JSONColumn[*pb_basic.User]{Message: msg}.Value()
+	if err != nil {
+		t.Fatalf("Value() error = %v", err)
+	}
+
+	var typed protojson.JSONColumn[*pb_basic.User]
+	if err := typed.Scan(v); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if diff := cmp.Diff(msg, typed.Message, protocmp.Transform()); diff != "" {
+		t.Errorf("Scan() mismatch (-want +got):\n%s", diff)
+	}
+
+	dynamic := protojson.JSONColumn[proto.Message]{Message: &pb_basic.User{}}
+	if err := dynamic.Scan(v); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if diff := cmp.Diff(proto.Message(msg), dynamic.Message, protocmp.Transform()); diff != "" {
+		t.Errorf("Scan() mismatch (-want +got):\n%s", diff)
+	}
+
+	var empty protojson.JSONColumn[proto.Message]
+	if err := empty.Scan(v); err == nil {
+		t.Error("Scan() into an interface-typed column without a message succeeded, want error")
+	}
+}
@@ -0,0 +1,114 @@
+package protojson_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// TestStable tests that Stable output does not depend on map iteration or
+// declaration order
+func TestStable(t *testing.T) {
+	// Fields declared out of field number order
+	int32Type := descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum()
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("runtime/stable.proto"),
+		Package: proto.String("test.runtime"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Reordered"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("third"), Number: proto.Int32(3), Type: int32Type, Label: optional, JsonName: proto.String("third")},
+				{Name: proto.String("first"), Number: proto.Int32(1), Type: int32Type, Label: optional, JsonName: proto.String("first")},
+				{Name: proto.String("second"), Number: proto.Int32(2), Type: int32Type, Label: optional, JsonName: proto.String("second")},
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile() error = %v", err)
+	}
+	md := fd.Messages().ByName("Reordered")
+	reordered := dynamicpb.NewMessage(md)
+	for i, name := range []protoreflect.Name{"first", "second", "third"} {
+		reordered.Set(md.Fields().ByName(name), protoreflect.ValueOfInt32(int32(i+1)))
+	}
+
+	structMsg := mustStruct(t, map[string]any{"b": 1, "a": 2, "d": 3, "c": 4, "e": 5})
+	mapMsg := &pb_basic.MapFields{StringMap: map[string]string{"b": "1", "a": "2", "d": "3", "c": "4", "e": "5"}}
+
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "DeclarationOrder",
+			msg:  reordered,
+			want: `{"third":3,"first":1,"second":2}`,
+		},
+		{
+			name: "FieldNumberOrder",
+			msg:  reordered,
+			opts: protojson.MarshalOptions{Stable: true},
+			want: `{"first":1,"second":2,"third":3}`,
+		},
+		{
+			name: "StructKeys",
+			msg:  structMsg,
+			opts: protojson.MarshalOptions{Stable: true, UnorderedMaps: true},
+			want: `{"a":2,"b":1,"c":4,"d":3,"e":5}`,
+		},
+		{
+			name: "MapKeys",
+			msg:  mapMsg,
+			opts: protojson.MarshalOptions{Stable: true, UnorderedMaps: true},
+			want: `{"stringMap":{"a":"2","b":"1","c":"4","d":"3","e":"5"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Repeat to catch map iteration order leaking into the output
+			for range 20 {
+				got, err := tt.opts.Marshal(tt.msg)
+				if err != nil {
+					t.Fatalf("Marshal() error = %v", err)
+				}
+				if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+					t.Fatalf("Marshal() mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+// TestStructKeysSorted tests that google.protobuf.Struct fields are sorted
+// by key like the standard package does
+func TestStructKeysSorted(t *testing.T) {
+	msg := mustStruct(t, map[string]any{"b": 1, "a": 2, "d": 3, "c": 4, "e": 5})
+	want, err := stdMarshal(stdprotojson.MarshalOptions{}, msg)
+	if err != nil {
+		t.Fatalf("standard protojson.Marshal failed: %v", err)
+	}
+	for range 20 {
+		got, err := protojson.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if diff := cmp.Diff(string(want), string(got)); diff != "" {
+			t.Fatalf("Marshal() mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
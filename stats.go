@@ -0,0 +1,84 @@
+package protojson
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// EncoderStats holds the counters of an Encoder, for exporting
+// serialization throughput as metrics.
+type EncoderStats struct {
+	// Bytes is the number of bytes of output written, including array
+	// brackets and newlines, but not output of calls that failed.
+	Bytes int64
+
+	// Messages is the number of messages encoded without error.
+	Messages int64
+
+	// MaskedFields is the number of values masked in those messages, as
+	// reported to MaskAuditFunc. Messages taken from a MessageCache are
+	// not masked again and do not count.
+	MaskedFields int64
+
+	// Errors is the number of Encode calls that failed.
+	Errors int64
+}
+
+// encoderStats holds the counters of an Encoder, read while it is used
+type encoderStats struct {
+	bytes    atomic.Int64
+	messages atomic.Int64
+	masked   atomic.Int64
+	errors   atomic.Int64
+}
+
+// Stats returns the counters of e since it was created or since the last
+// call to ResetStats. It is safe to call concurrently with the use of e.
+func (e *Encoder) Stats() EncoderStats {
+	return EncoderStats{
+		Bytes:        e.stats.bytes.Load(),
+		Messages:     e.stats.messages.Load(),
+		MaskedFields: e.stats.masked.Load(),
+		Errors:       e.stats.errors.Load(),
+	}
+}
+
+// ResetStats sets the counters of e to zero. It is safe to call
+// concurrently with the use of e.
+func (e *Encoder) ResetStats() {
+	e.stats.bytes.Store(0)
+	e.stats.messages.Store(0)
+	e.stats.masked.Store(0)
+	e.stats.errors.Store(0)
+}
+
+// collect adds the output written since the start of the call to the
+// stats
+func (e *Encoder) collect() {
+	e.stats.bytes.Add(int64(e.position() - e.pos))
+}
+
+// encoded records a message encoded without error
+func (e *Encoder) encoded() {
+	e.collect()
+	e.stats.messages.Add(1)
+	e.stats.masked.Add(int64(e.enc.masked))
+}
+
+// fail records a failed call, whose output is not counted, and returns err
+func (e *Encoder) fail(err error) error {
+	e.stats.errors.Add(1)
+	return err
+}
+
+// flushCounter counts the bytes the encoder's own buffer flushes to w
+type flushCounter struct {
+	w io.Writer
+	n int
+}
+
+func (c *flushCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
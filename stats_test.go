@@ -0,0 +1,143 @@
+package protojson_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestEncoderStats tests the counters of an Encoder across calls
+func TestEncoderStats(t *testing.T) {
+	var buf bytes.Buffer
+	enc := protojson.NewEncoderWithOptions(&buf, protojson.MarshalOptions{MaskDebugRedact: true})
+	enc.SetWriteNewline(true)
+
+	msg := &pb_basic.RedactedFields{Username: "u", Password: "p", Pin: 1234}
+	for range 2 {
+		if err := enc.Encode(msg); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+	want := protojson.EncoderStats{Bytes: int64(buf.Len()), Messages: 2, MaskedFields: 2}
+	if diff := cmp.Diff(want, enc.Stats()); diff != "" {
+		t.Errorf("Stats() mismatch (-want +got):\n%s", diff)
+	}
+
+	// A failed call counts as an error, without its output
+	err := enc.Encode(&pb_basic.RedactedFields{Username: "\xff"})
+	if !errors.Is(err, protojson.ErrInvalidUTF8) {
+		t.Fatalf("Encode() error = %v, want ErrInvalidUTF8", err)
+	}
+	want.Errors = 1
+	if diff := cmp.Diff(want, enc.Stats()); diff != "" {
+		t.Errorf("Stats() after error mismatch (-want +got):\n%s", diff)
+	}
+
+	enc.ResetStats()
+	if diff := cmp.Diff(protojson.EncoderStats{}, enc.Stats()); diff != "" {
+		t.Errorf("Stats() after ResetStats mismatch (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := enc.EncodeList([]proto.Message{msg, &pb_basic.RedactedFields{}}); err != nil {
+		t.Fatalf("EncodeList() error = %v", err)
+	}
+	want = protojson.EncoderStats{Bytes: int64(buf.Len()), Messages: 2, MaskedFields: 1}
+	if diff := cmp.Diff(want, enc.Stats()); diff != "" {
+		t.Errorf("Stats() after EncodeList mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestEncoderStatsUnbuffered tests that output buffered by the encoder
+// itself is counted once flushed
+func TestEncoderStatsUnbuffered(t *testing.T) {
+	var out writeCounter
+	enc := protojson.NewEncoder(&out)
+	if err := enc.Encode(&pb_basic.Inner{Name: "b"}); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	want := protojson.EncoderStats{Bytes: int64(out.buf.Len()), Messages: 1}
+	if diff := cmp.Diff(want, enc.Stats()); diff != "" {
+		t.Errorf("Stats() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestEncoderStatsConcurrent tests reading and resetting the counters while
+// the encoder is in use
+func TestEncoderStatsConcurrent(t *testing.T) {
+	var buf bytes.Buffer
+	enc := protojson.NewEncoder(&buf)
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for range 100 {
+			enc.Stats()
+			enc.ResetStats()
+		}
+	})
+	for range 100 {
+		if err := enc.Encode(&pb_basic.Inner{Name: "b"}); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+// TestEncoderStatsWriters tests that output is counted for each kind of
+// writer, without the output of failed calls
+func TestEncoderStatsWriters(t *testing.T) {
+	msg := &pb_basic.Inner{Name: "b"}
+	bad := &pb_basic.Inner{Name: "\xff"}
+	want := protojson.EncoderStats{Bytes: int64(len(`{"name":"b"}`)), Messages: 1, Errors: 1}
+
+	var sb strings.Builder
+	var buf bytes.Buffer
+	tests := []struct {
+		name string
+		w    io.Writer
+	}{
+		{name: "Builder", w: &sb},
+		{name: "BufioWriter", w: bufio.NewWriter(&buf)},
+		{name: "Unbuffered", w: &writeCounter{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := protojson.NewEncoder(tt.w)
+			if err := enc.Encode(msg); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if err := enc.Encode(bad); err == nil {
+				t.Fatal("Encode() of invalid UTF-8 succeeded, want error")
+			}
+			if diff := cmp.Diff(want, enc.Stats()); diff != "" {
+				t.Errorf("Stats() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// A failed call after Reset to a *bytes.Buffer leaves no output behind
+	buf.Reset()
+	enc := protojson.NewEncoder(&writeCounter{})
+	enc.Reset(&buf)
+	if err := enc.Encode(msg); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if err := enc.Encode(bad); err == nil {
+		t.Fatal("Encode() of invalid UTF-8 succeeded, want error")
+	}
+	if diff := cmp.Diff(`{"name":"b"}`, buf.String()); diff != "" {
+		t.Errorf("Encode() after Reset mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, enc.Stats()); diff != "" {
+		t.Errorf("Stats() after Reset mismatch (-want +got):\n%s", diff)
+	}
+}
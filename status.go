@@ -0,0 +1,133 @@
+package protojson
+
+import (
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// codeNames are the names of the google.rpc.Code values, indexed by number
+var codeNames = [...]string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+// MarshalStatus writes a google.rpc.Status message, such as the proto of a
+// gRPC status, in the error shape of Google APIs using default options.
+func MarshalStatus(st proto.Message) ([]byte, error) {
+	return MarshalOptions{}.MarshalStatus(st)
+}
+
+// MarshalStatus writes a google.rpc.Status message in the error shape of
+// Google APIs using options in o:
+//
+//	{"error":{"code":5,"message":"...","status":"NOT_FOUND","details":[...]}}
+//
+// status is the name of the google.rpc.Code and is left out for unknown
+// codes. The details are expanded like google.protobuf.Any fields, with
+// their types resolved through Resolver.
+func (o MarshalOptions) MarshalStatus(st proto.Message) ([]byte, error) {
+	m := st.ProtoReflect()
+	if name := m.Descriptor().FullName(); name != "google.rpc.Status" {
+		return nil, fmt.Errorf("%s is not google.rpc.Status", name)
+	}
+
+	s := marshalPool.Get().(*marshalState)
+	defer s.release()
+
+	s.enc.SetOptions(o)
+	s.enc.prepare()
+	if err := s.enc.enc.marshalStatus(m); err != nil {
+		return nil, err
+	}
+	if err := s.enc.enc.checkLimit(); err != nil {
+		return nil, err
+	}
+	s.enc.reportMasks(st)
+	if err := s.enc.flush(); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), s.buf.Bytes()...), nil
+}
+
+// marshalStatus writes m, a google.rpc.Status, wrapped in an "error" object
+func (e *encoder) marshalStatus(m protoreflect.Message) error {
+	fields := m.Descriptor().Fields()
+	code := m.Get(fields.ByName("code")).Int()
+	details := m.Get(fields.ByName("details")).List()
+
+	e.w.WriteByte('{')
+	e.depth++
+	e.writeIndent()
+	e.w.WriteString(`"error"`)
+	e.writeColon()
+	e.w.WriteByte('{')
+	e.depth++
+
+	e.writeIndent()
+	e.w.WriteString(`"code"`)
+	e.writeColon()
+	e.w.Write(strconv.AppendInt(e.buf[:0], code, 10))
+
+	e.writeComma()
+	e.writeIndent()
+	e.w.WriteString(`"message"`)
+	e.writeColon()
+	if err := e.marshalString(m.Get(fields.ByName("message")).String()); err != nil {
+		return fmt.Errorf("google.rpc.Status.message: %w", err)
+	}
+
+	if code >= 0 && code < int64(len(codeNames)) {
+		e.writeComma()
+		e.writeIndent()
+		e.w.WriteString(`"status"`)
+		e.writeColon()
+		e.w.WriteString(`"` + codeNames[code] + `"`)
+	}
+
+	if details.Len() > 0 {
+		e.writeComma()
+		e.writeIndent()
+		e.w.WriteString(`"details"`)
+		e.writeColon()
+		e.w.WriteByte('[')
+		e.depth++
+		for i := 0; i < details.Len(); i++ {
+			if i > 0 {
+				e.writeComma()
+			}
+			e.writeIndent()
+			if err := e.marshalMessage(details.Get(i).Message()); err != nil {
+				return err
+			}
+		}
+		e.depth--
+		e.writeIndent()
+		e.w.WriteByte(']')
+	}
+
+	e.depth--
+	e.writeIndent()
+	e.w.WriteByte('}')
+	e.depth--
+	e.writeIndent()
+	e.w.WriteByte('}')
+	return nil
+}
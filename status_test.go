@@ -0,0 +1,126 @@
+package protojson_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// newStatus returns a dynamic google.rpc.Status message
+func newStatus(t *testing.T, code int32, message string, details ...*anypb.Any) proto.Message {
+	t.Helper()
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("google/rpc/status.proto"),
+		Package:    proto.String("google.rpc"),
+		Dependency: []string{"google/protobuf/any.proto"},
+		Syntax:     proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Status"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("code"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), JsonName: proto.String("code")},
+				{Name: proto.String("message"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), JsonName: proto.String("message")},
+				{Name: proto.String("details"), Number: proto.Int32(3), Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(), TypeName: proto.String(".google.protobuf.Any"), JsonName: proto.String("details")},
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile() error = %v", err)
+	}
+	md := fd.Messages().ByName("Status")
+	st := dynamicpb.NewMessage(md)
+	st.Set(md.Fields().ByName("code"), protoreflect.ValueOfInt32(code))
+	st.Set(md.Fields().ByName("message"), protoreflect.ValueOfString(message))
+	list := st.Mutable(md.Fields().ByName("details")).List()
+	for _, d := range details {
+		list.Append(protoreflect.ValueOfMessage(d.ProtoReflect()))
+	}
+	return st
+}
+
+// TestMarshalStatus tests writing google.rpc.Status in the Google API error
+// shape
+func TestMarshalStatus(t *testing.T) {
+	detail := mustAny(t, &pb_basic.BasicTypes{StringField: "field", Int32Field: 3})
+
+	tests := []struct {
+		name    string
+		st      proto.Message
+		opts    protojson.MarshalOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Basic",
+			st:   newStatus(t, 5, "user not found"),
+			want: `{"error":{"code":5,"message":"user not found","status":"NOT_FOUND"}}`,
+		},
+		{
+			name: "Details",
+			st:   newStatus(t, 3, "bad request", detail),
+			want: `{"error":{"code":3,"message":"bad request","status":"INVALID_ARGUMENT","details":[{"@type":"type.googleapis.com/test.basic.BasicTypes","stringField":"field","int32Field":3}]}}`,
+		},
+		{
+			name: "UnknownCode",
+			st:   newStatus(t, 42, "custom"),
+			want: `{"error":{"code":42,"message":"custom"}}`,
+		},
+		{
+			name: "Indent",
+			st:   newStatus(t, 5, "gone", detail),
+			opts: protojson.MarshalOptions{Indent: "  "},
+			want: `{
+  "error": {
+    "code": 5,
+    "message": "gone",
+    "status": "NOT_FOUND",
+    "details": [
+      {
+        "@type": "type.googleapis.com/test.basic.BasicTypes",
+        "stringField": "field",
+        "int32Field": 3
+      }
+    ]
+  }
+}`,
+		},
+		{
+			name:    "UnresolvableDetail",
+			st:      newStatus(t, 2, "oops", detail),
+			opts:    protojson.MarshalOptions{Resolver: new(protoregistry.Types)},
+			wantErr: true,
+		},
+		{
+			name:    "NotStatus",
+			st:      &pb_basic.BasicTypes{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.MarshalStatus(tt.st)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("MarshalStatus() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MarshalStatus() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("MarshalStatus() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,542 @@
+package protojson
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TextEncoder writes messages in the protobuf text format, as read by
+// prototext, with the same options as the JSON encoder where they apply:
+// masking, path filters, Redactors, TruncateLength, TransformValue,
+// MaxOutputBytes and MaskAuditFunc work exactly as they do for JSON, so
+// that tools can switch formats without changing how data is redacted.
+// Fields are named by their text names and written as "name: value",
+// messages as "name: {...}", repeated fields as one entry per element and
+// map fields as entries with key and value fields. google.protobuf.Any is
+// expanded as [type_url]: {...} if its type resolves. Output is on a single
+// line unless Indent or Multiline is set. Masked values that JSON would
+// write as null are left out, since text has no null. Options that only
+// change how values are formatted in JSON, such as UseProtoNames or
+// Int64AsNumber, are ignored, and MaskMapKeys only applies to string keys.
+type TextEncoder struct {
+	enc *Encoder
+}
+
+// NewTextEncoder returns a new text format encoder that writes to w using
+// the provided MarshalOptions.
+func NewTextEncoder(w io.Writer, opts MarshalOptions) *TextEncoder {
+	return &TextEncoder{enc: NewEncoderWithOptions(w, textOptions(opts))}
+}
+
+// Encode writes m in text format followed by a newline, so that messages
+// written to a stream are separated.
+func (e *TextEncoder) Encode(m proto.Message) error {
+	return e.enc.encodeText(m.ProtoReflect(), true)
+}
+
+// MarshalText returns the text format encoding of m using options in o.
+// Single-line output has no trailing newline. See TextEncoder.
+func (o MarshalOptions) MarshalText(m proto.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := NewEncoderWithOptions(&buf, textOptions(o))
+	if err := enc.encodeText(m.ProtoReflect(), false); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// textOptions returns o without the options that post-process JSON output
+func textOptions(o MarshalOptions) MarshalOptions {
+	o.Canonical = false
+	o.Flatten = false
+	o.TruncateOutput = false
+	return o
+}
+
+// encodeText writes m in text format, followed by a newline if newline is
+// set or the output is multiline
+func (e *Encoder) encodeText(m protoreflect.Message, newline bool) error {
+	if !e.opts.AllowPartial {
+		if err := proto.CheckInitialized(m.Interface()); err != nil {
+			return e.fail(err)
+		}
+	}
+
+	e.prepare()
+	err := e.enc.marshalTextFields(m)
+	if err == nil && newline && !e.enc.textMultiline() {
+		e.enc.w.WriteByte('\n')
+	}
+	if err == nil {
+		err = e.enc.checkLimit()
+	}
+	if err != nil {
+		e.discard()
+		return e.fail(err)
+	}
+	e.reportMasks(m.Interface())
+	if err := e.flush(); err != nil {
+		return e.fail(err)
+	}
+	e.encoded()
+	return nil
+}
+
+// textMultiline reports whether text output has one field per line
+func (e *encoder) textMultiline() bool {
+	return e.opts.Indent != "" || e.opts.Multiline
+}
+
+// beginTextField writes what precedes a field: the indentation in
+// multiline output, or a space after an earlier field otherwise
+func (e *encoder) beginTextField(first bool) {
+	if !e.textMultiline() {
+		if !first {
+			e.w.WriteByte(' ')
+		}
+		return
+	}
+	indent := e.opts.Indent
+	if indent == "" {
+		indent = "  "
+	}
+	for i := 0; i < e.depth; i++ {
+		e.w.WriteString(indent)
+	}
+}
+
+// endTextField writes what follows a field
+func (e *encoder) endTextField() {
+	if e.textMultiline() {
+		e.w.WriteByte('\n')
+	}
+}
+
+// marshalTextFields writes the populated fields of m in text format
+func (e *encoder) marshalTextFields(m protoreflect.Message) error {
+	if m.Descriptor().FullName() == "google.protobuf.Any" {
+		if ok, err := e.marshalTextAny(m); ok || err != nil {
+			return err
+		}
+	}
+
+	first := true
+	plan := e.planOf(m.Descriptor())
+	fields := e.fields(plan)
+	for i := range fields {
+		fd := fields[i].fd
+		if !m.Has(fd) {
+			continue
+		}
+		var err error
+		if first, err = e.marshalTextField(fd, m.Get(fd), first); err != nil {
+			return err
+		}
+	}
+
+	if plan.extensions {
+		var exts []protoreflect.FieldDescriptor
+		m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+			if fd.IsExtension() {
+				exts = append(exts, fd)
+			}
+			return true
+		})
+		slices.SortFunc(exts, func(a, b protoreflect.FieldDescriptor) int {
+			return strings.Compare(string(a.FullName()), string(b.FullName()))
+		})
+		for _, fd := range exts {
+			var err error
+			if first, err = e.marshalTextField(fd, m.Get(fd), first); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// marshalTextField writes the entries for field fd with value v. first
+// reports whether no field has been written to the enclosing message yet;
+// the updated value is returned.
+func (e *encoder) marshalTextField(fd protoreflect.FieldDescriptor, v protoreflect.Value, first bool) (bool, error) {
+	if e.trackPath {
+		if e.enterPath(fieldStep(fd)) {
+			return first, nil
+		}
+		defer e.leavePath()
+	}
+
+	var err error
+	switch {
+	case fd.IsList():
+		list := v.List()
+		listMasked := e.maskDescriptor(fd)
+		for i := 0; i < list.Len() && err == nil; i++ {
+			if e.trackPath && e.enterPath(indexStep(i)) {
+				continue
+			}
+			masked := listMasked || e.opts.FieldMaskPathFunc != nil && e.opts.FieldMaskPathFunc(e.path, fd)
+			first, err = e.marshalTextEntry(fd, list.Get(i), masked, first)
+			if e.trackPath {
+				e.leavePath()
+			}
+		}
+	case fd.IsMap():
+		first, err = e.marshalTextMap(fd, v.Map(), first)
+	default:
+		first, err = e.marshalTextEntry(fd, v, e.maskField(fd), first)
+	}
+	if err != nil {
+		return first, err
+	}
+	return first, e.checkLimit()
+}
+
+// marshalTextEntry writes a singular value of fd as "name: value", unless
+// it is left out
+func (e *encoder) marshalTextEntry(fd protoreflect.FieldDescriptor, v protoreflect.Value, masked, first bool) (bool, error) {
+	v, ok, err := e.textValue(fd, v, masked)
+	if err != nil || !ok {
+		return first, err
+	}
+	e.beginTextField(first)
+	e.writeTextName(fd)
+	e.writeColon()
+	if err := e.writeTextValue(fd, v); err != nil {
+		return false, err
+	}
+	e.endTextField()
+	return false, nil
+}
+
+// writeTextName writes the name of fd in text format
+func (e *encoder) writeTextName(fd protoreflect.FieldDescriptor) {
+	if fd.IsExtension() {
+		e.w.WriteByte('[')
+		e.w.WriteString(string(fd.FullName()))
+		e.w.WriteByte(']')
+		return
+	}
+	e.w.WriteString(fd.TextName())
+}
+
+// textValue returns the value of fd to write in place of v, applying
+// TransformValue, masking and FieldMaskValueFunc. It reports false if the
+// value is left out. A masked message zeroed by MaskZero is returned as
+// an invalid value, written as an empty message.
+func (e *encoder) textValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, masked bool) (protoreflect.Value, bool, error) {
+	if e.opts.TransformValue != nil {
+		var err error
+		if v, err = e.opts.TransformValue(fd, v); err != nil {
+			return v, false, fmt.Errorf("field %s: %w", fd.FullName(), err)
+		}
+		if !v.IsValid() {
+			return v, false, nil
+		}
+	}
+	if masked {
+		switch kind := fd.Kind(); {
+		case kind == protoreflect.StringKind || kind == protoreflect.BytesKind:
+			e.auditMask()
+			mask := e.textMask(fd, v)
+			if kind == protoreflect.BytesKind {
+				return protoreflect.ValueOfBytes([]byte(mask)), true, nil
+			}
+			return protoreflect.ValueOfString(mask), true, nil
+		case e.opts.MaskPolicy == MaskZero:
+			e.auditMask()
+			return textZero(fd), true, nil
+		case e.opts.MaskPolicy != MaskUnchanged:
+			e.auditMask()
+			return v, false, nil
+		}
+	}
+	if e.opts.FieldMaskValueFunc != nil {
+		replacement, masked, err := e.maskValue(fd, v)
+		if err != nil {
+			return v, false, err
+		}
+		if masked {
+			return replacement, replacement.IsValid(), nil
+		}
+	}
+	return v, true, nil
+}
+
+// textMask returns the replacement for a masked string or bytes value
+func (e *encoder) textMask(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	if e.opts.MaskMode == MaskHash {
+		var b []byte
+		for _, c := range e.maskDigest(maskBytes(fd, v)) {
+			b = append(b, hexDigits[c>>4], hexDigits[c&0xf])
+		}
+		return string(b)
+	}
+	if e.opts.MaskString == "" {
+		return defaultMaskString
+	}
+	return e.opts.MaskString
+}
+
+// textZero returns the zero value of fd for MaskZero
+func textZero(fd protoreflect.FieldDescriptor) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(false)
+	case protoreflect.EnumKind:
+		return protoreflect.ValueOfEnum(0)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(0)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(0)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(0)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(0)
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(0)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(0)
+	}
+	return protoreflect.Value{}
+}
+
+// writeTextValue writes a singular value of fd in text format
+func (e *encoder) writeTextValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		e.w.WriteString(strconv.FormatBool(v.Bool()))
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		e.w.Write(strconv.AppendInt(e.buf[:0], v.Int(), 10))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		e.w.Write(strconv.AppendUint(e.buf[:0], v.Uint(), 10))
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		bitSize := 64
+		if fd.Kind() == protoreflect.FloatKind {
+			bitSize = 32
+		}
+		e.w.Write(appendTextFloat(e.buf[:0], v.Float(), bitSize))
+	case protoreflect.StringKind:
+		s := v.String()
+		if len(e.opts.Redactors) > 0 {
+			s = e.redact(s)
+		}
+		if n := e.opts.TruncateLength; n > 0 && len(s) > n {
+			s = truncateString(s, n)
+		}
+		if !e.opts.AllowInvalidUTF8 && !utf8.ValidString(s) {
+			return fmt.Errorf("field %s: %w", fd.FullName(), ErrInvalidUTF8)
+		}
+		e.writeTextString(s, true)
+	case protoreflect.BytesKind:
+		b := v.Bytes()
+		if n := e.opts.TruncateLength; n > 0 && len(b) > n {
+			b = append(b[:n:n], truncateMarker(len(b)-n)...)
+		}
+		e.writeTextString(string(b), false)
+	case protoreflect.EnumKind:
+		n := v.Enum()
+		if ev := fd.Enum().Values().ByNumber(n); ev != nil && !e.opts.UseEnumNumbers {
+			e.w.WriteString(string(ev.Name()))
+		} else {
+			e.w.Write(strconv.AppendInt(e.buf[:0], int64(n), 10))
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		e.w.WriteByte('{')
+		if !v.IsValid() {
+			e.w.WriteByte('}')
+			return nil
+		}
+		return e.marshalTextMessage(v.Message())
+	default:
+		return fmt.Errorf("unknown field kind: %v", fd.Kind())
+	}
+	return nil
+}
+
+// marshalTextMessage writes the fields of m and the closing brace of a
+// message value whose opening brace has been written
+func (e *encoder) marshalTextMessage(m protoreflect.Message) error {
+	e.endTextField()
+	e.depth++
+	if err := e.marshalTextFields(m); err != nil {
+		return err
+	}
+	e.depth--
+	e.beginTextField(true)
+	e.w.WriteByte('}')
+	return nil
+}
+
+// writeTextString writes s as a quoted text format string. Printable ASCII
+// characters are written as is and other bytes as octal escapes, except
+// in strings, where UTF-8 sequences are written as is and invalid bytes
+// are replaced with U+FFFD.
+func (e *encoder) writeTextString(s string, isString bool) {
+	e.w.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			e.w.WriteByte('\\')
+			e.w.WriteByte(c)
+		case c == '\n':
+			e.w.WriteString(`\n`)
+		case c == '\r':
+			e.w.WriteString(`\r`)
+		case c == '\t':
+			e.w.WriteString(`\t`)
+		case c >= 0x20 && c < 0x7f:
+			e.w.WriteByte(c)
+		case c >= utf8.RuneSelf && isString:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				e.w.WriteString("\uFFFD")
+			} else {
+				e.w.WriteString(s[i : i+size])
+			}
+			i += size
+			continue
+		default:
+			e.w.Write(append(e.buf[:0], '\\', '0'+c>>6, '0'+c>>3&7, '0'+c&7))
+		}
+		i++
+	}
+	e.w.WriteByte('"')
+}
+
+// appendTextFloat appends f in text format, with inf, -inf and nan for
+// non-finite values
+func appendTextFloat(b []byte, f float64, bitSize int) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(b, "nan"...)
+	case math.IsInf(f, 1):
+		return append(b, "inf"...)
+	case math.IsInf(f, -1):
+		return append(b, "-inf"...)
+	}
+	return strconv.AppendFloat(b, f, 'g', -1, bitSize)
+}
+
+// marshalTextMap writes the entries of map field fd, each as a message
+// with key and value fields
+func (e *encoder) marshalTextMap(fd protoreflect.FieldDescriptor, m protoreflect.Map, first bool) (bool, error) {
+	keys := make([]protoreflect.MapKey, 0, m.Len())
+	m.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	if !e.opts.UnorderedMaps {
+		slices.SortFunc(keys, mapKeyCompare(fd.MapKey().Kind()))
+	}
+
+	n := 0
+	for _, k := range keys {
+		if e.trackPath && e.enterPath(keyStep(k)) {
+			continue
+		}
+		written, err := e.marshalTextMapEntry(fd, n, k, m.Get(k), first)
+		if e.trackPath {
+			e.leavePath()
+		}
+		if err != nil {
+			return first, err
+		}
+		if written {
+			first = false
+			n++
+		}
+	}
+	return first, nil
+}
+
+// marshalTextMapEntry writes the i-th written entry of map field fd. It
+// reports whether the entry was written rather than left out.
+func (e *encoder) marshalTextMapEntry(fd protoreflect.FieldDescriptor, i int, k protoreflect.MapKey, v protoreflect.Value, first bool) (bool, error) {
+	masked, err := e.maskMapEntry(fd, k)
+	if err != nil {
+		return false, err
+	}
+	vd := fd.MapValue()
+	v, ok, err := e.textValue(vd, v, masked)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	e.beginTextField(first)
+	e.writeTextName(fd)
+	e.writeColon()
+	e.w.WriteByte('{')
+	e.endTextField()
+	e.depth++
+
+	kd := fd.MapKey()
+	e.beginTextField(true)
+	e.w.WriteString("key")
+	e.writeColon()
+	if masked && e.opts.MaskMapKeys && kd.Kind() == protoreflect.StringKind {
+		// Like writeMaskedKey, hashes replace keys by their own digest
+		key := e.textMask(kd, k.Value())
+		if e.opts.MaskMode != MaskHash {
+			key += strconv.Itoa(i)
+		}
+		e.writeTextString(key, true)
+	} else if err := e.writeTextValue(kd, k.Value()); err != nil {
+		return true, err
+	}
+	e.endTextField()
+
+	e.beginTextField(false)
+	e.w.WriteString("value")
+	e.writeColon()
+	if err := e.writeTextValue(vd, v); err != nil {
+		return true, err
+	}
+	e.endTextField()
+
+	e.depth--
+	e.beginTextField(true)
+	e.w.WriteByte('}')
+	e.endTextField()
+	return true, nil
+}
+
+// marshalTextAny writes a google.protobuf.Any whose type resolves in
+// expanded form, as [type_url]: {...}. It reports false if the type does
+// not resolve, so that the fields of the Any are written instead.
+func (e *encoder) marshalTextAny(m protoreflect.Message) (bool, error) {
+	fields := m.Descriptor().Fields()
+	typeURL := m.Get(fields.ByName("type_url")).String()
+	value := m.Get(fields.ByName("value")).Bytes()
+	if typeURL == "" {
+		return false, nil
+	}
+	msg, err := unmarshalAny(e.resolver(), typeURL, value)
+	if err != nil {
+		return false, nil
+	}
+
+	e.beginTextField(true)
+	e.w.WriteByte('[')
+	e.w.WriteString(typeURL)
+	e.w.WriteByte(']')
+	e.writeColon()
+	e.w.WriteByte('{')
+	if err := e.marshalTextMessage(msg); err != nil {
+		return true, err
+	}
+	e.endTextField()
+	return true, nil
+}
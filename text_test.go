@@ -0,0 +1,165 @@
+package protojson_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// TestMarshalText tests the text format encoding of messages
+func TestMarshalText(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Scalars",
+			msg: &pb_basic.BasicTypes{
+				StringField: "a\"\n\u00e9",
+				Int64Field:  -5,
+				Uint64Field: math.MaxUint64,
+				BoolField:   true,
+				FloatField:  float32(math.Inf(-1)),
+				DoubleField: 0.25,
+				BytesField:  []byte{'x', 0, 0xff},
+			},
+			want: `string_field:"a\"\n` + "\u00e9" + `" int64_field:-5 uint64_field:18446744073709551615 ` +
+				`bool_field:true float_field:-inf double_field:0.25 bytes_field:"x\000\377"`,
+		},
+		{
+			name: "Nested",
+			msg: &pb_basic.User{
+				Id:          "u1",
+				Role:        pb_basic.Role_ROLE_ADMIN,
+				Permissions: []string{"r", "w"},
+				Profile:     &pb_basic.Profile{Address: &pb_basic.Address{City: "Tokyo"}},
+			},
+			want: `id:"u1" role:ROLE_ADMIN permissions:"r" permissions:"w" profile:{address:{city:"Tokyo"}}`,
+		},
+		{
+			name: "Maps",
+			msg:  &pb_basic.MapFields{StringMap: map[string]string{"b": "2", "a": "1"}, IntKeyMap: map[int32]string{3: "c"}},
+			want: `string_map:{key:"a" value:"1"} string_map:{key:"b" value:"2"} int_key_map:{key:3 value:"c"}`,
+		},
+		{
+			name: "Multiline",
+			msg:  &pb_basic.User{Id: "u1", Profile: &pb_basic.Profile{Bio: "b"}, Metadata: map[string]string{"k": "v"}},
+			opts: protojson.MarshalOptions{Indent: "  "},
+			want: "id: \"u1\"\nprofile: {\n  bio: \"b\"\n}\nmetadata: {\n  key: \"k\"\n  value: \"v\"\n}\n",
+		},
+		{
+			name: "Masked",
+			msg:  &pb_basic.RedactedFields{Username: "u", Password: "p", Pin: 1234},
+			opts: protojson.MarshalOptions{MaskDebugRedact: true, MaskPolicy: protojson.MaskNull},
+			want: `username:"u" password:"***"`,
+		},
+		{
+			name: "MaskZero",
+			msg:  &pb_basic.RedactedFields{Pin: 1234},
+			opts: protojson.MarshalOptions{MaskDebugRedact: true, MaskPolicy: protojson.MaskZero},
+			want: `pin:0`,
+		},
+		{
+			name: "MaskedMapKeys",
+			msg:  &pb_basic.MapFields{StringMap: map[string]string{"token": "t", "name": "n"}},
+			opts: protojson.MarshalOptions{MaskKeyPatterns: []string{"tok*"}, MaskMapKeys: true, MaskString: "#"},
+			want: `string_map:{key:"name" value:"n"} string_map:{key:"#1" value:"#"}`,
+		},
+		{
+			name: "ExcludePaths",
+			msg:  &pb_basic.User{Id: "u1", Email: "e", Profile: &pb_basic.Profile{Bio: "b", AvatarUrl: "a"}},
+			opts: protojson.MarshalOptions{ExcludePaths: []string{"email", "profile.bio"}},
+			want: `id:"u1" profile:{avatar_url:"a"}`,
+		},
+		{
+			name: "Any",
+			msg:  &pb_basic.WellKnownTypes{Any: mustAny(t, &pb_basic.BasicTypes{Int32Field: 1})},
+			want: `any:{[type.googleapis.com/test.basic.BasicTypes]:{int32_field:1}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.MarshalText(tt.msg)
+			if err != nil {
+				t.Fatalf("MarshalText() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("MarshalText() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMarshalTextRoundTrip tests that prototext reads the output back into
+// the original message
+func TestMarshalTextRoundTrip(t *testing.T) {
+	msgs := []proto.Message{
+		&pb_basic.BasicTypes{
+			StringField: "tab\tquote\" \u2028",
+			Sint32Field: -7,
+			FloatField:  -0.5,
+			DoubleField: 1e300,
+			BytesField:  []byte("\x00\x01binary\\"),
+		},
+		&pb_basic.MapFields{
+			BoolMap:    map[string]bool{"t": true},
+			MessageMap: map[string]*pb_basic.Value{"m": {Data: "d", Count: 2}},
+		},
+		&pb_basic.WellKnownTypes{Any: mustAny(t, &pb_basic.User{Id: "u"})},
+	}
+	for _, opts := range []protojson.MarshalOptions{{}, {Multiline: true}} {
+		for _, msg := range msgs {
+			data, err := opts.MarshalText(msg)
+			if err != nil {
+				t.Fatalf("MarshalText() error = %v", err)
+			}
+			got := msg.ProtoReflect().New().Interface()
+			if err := prototext.Unmarshal(data, got); err != nil {
+				t.Fatalf("prototext.Unmarshal(%s) error = %v", data, err)
+			}
+			if diff := cmp.Diff(msg, got, protocmp.Transform()); diff != "" {
+				t.Errorf("round trip of %s mismatch (-want +got):\n%s", data, diff)
+			}
+		}
+	}
+}
+
+// TestTextEncoder tests writing messages to a stream and the masking hooks
+// of the JSON encoder
+func TestTextEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	var audited []string
+	enc := protojson.NewTextEncoder(&buf, protojson.MarshalOptions{
+		FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "email" },
+		MaskAuditFunc: func(m proto.Message, report protojson.MaskReport) {
+			audited = append(audited, report.Paths...)
+		},
+	})
+	for _, id := range []string{"1", "2"} {
+		if err := enc.Encode(&pb_basic.User{Id: id, Email: "e"}); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+	if _, err := (protojson.MarshalOptions{}).MarshalText(&pb_basic.User{Name: "bad\xff"}); err == nil {
+		t.Error("MarshalText() of invalid UTF-8 succeeded, want error")
+	}
+
+	want := "id:\"1\" email:\"***\"\nid:\"2\" email:\"***\"\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("Encode() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"email", "email"}, audited); diff != "" {
+		t.Errorf("MaskAuditFunc paths mismatch (-want +got):\n%s", diff)
+	}
+}
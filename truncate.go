@@ -0,0 +1,282 @@
+package protojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+// truncateString cuts s to at most n bytes at a character boundary and
+// appends the truncation marker
+func truncateString(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + truncateMarker(len(s)-n)
+}
+
+// truncateMarker returns the marker for omitted trailing bytes
+func truncateMarker(omitted int) string {
+	return "...(+" + strconv.Itoa(omitted) + " bytes)"
+}
+
+// omittedMarker returns the marker for omitted trailing list elements or
+// map entries
+func omittedMarker(omitted int, unit string) string {
+	return "...(+" + strconv.Itoa(omitted) + " " + unit + ")"
+}
+
+// writeOmitted writes the marker for omitted trailing list elements or map
+// entries, after a comma: as a string element, or as a member with a null
+// value if member is set
+func (e *encoder) writeOmitted(omitted int, unit string, member bool) {
+	e.writeComma()
+	e.writeIndent()
+	e.w.WriteByte('"')
+	e.w.WriteString(omittedMarker(omitted, unit))
+	e.w.WriteByte('"')
+	if member {
+		e.writeColon()
+		e.w.WriteString("null")
+	}
+}
+
+// truncatedKey is the member added to objects cut down to MaxOutputBytes
+// by TruncateOutput
+const truncatedKey = `"_truncated":true`
+
+// truncatedElement is the element added to arrays cut down to
+// MaxOutputBytes by TruncateOutput
+const truncatedElement = `"...(truncated)"`
+
+// writeRoot runs write, which writes a top-level value, post-processing
+// its output for Flatten, Canonical and TruncateOutput
+func (e *encoder) writeRoot(write func() error) error {
+	if e.opts.Flatten {
+		inner := write
+		write = func() error { return e.writeFlat(inner) }
+	}
+	if e.opts.Canonical {
+		inner := write
+		write = func() error { return e.writeCanonical(inner) }
+	}
+	if e.opts.TruncateOutput && e.opts.MaxOutputBytes > 0 {
+		inner := write
+		write = func() error { return e.writeTruncated(inner) }
+	}
+	return write()
+}
+
+// writeTruncated runs write with its output captured, then writes the
+// output cut down to MaxOutputBytes by eliding values
+func (e *encoder) writeTruncated(write func() error) error {
+	var buf bytes.Buffer
+	w := e.redirect(&buf)
+	err := write()
+	e.redirect(w)
+	if err != nil {
+		return err
+	}
+
+	max := e.opts.MaxOutputBytes
+	if buf.Len() <= max {
+		e.w.Write(buf.Bytes())
+		return nil
+	}
+
+	root, err := parseJSON(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	if root.size <= max {
+		root.write(e.w, false)
+		return nil
+	}
+
+	// The marker follows a comma unless all entries are elided
+	marker := truncatedKey
+	if root.kind == '[' {
+		marker = truncatedElement
+	}
+	if root.kind == 0 || max < len("{}")+len(marker) {
+		return fmt.Errorf("%w: cannot truncate to MaxOutputBytes limit of %d bytes", ErrOutputTooLarge, max)
+	}
+	root.shrink(root.size - (max - len(marker) - 1))
+
+	root.write(e.w, true)
+	return nil
+}
+
+// jsonNode is a value in compact JSON output, parsed for TruncateOutput
+type jsonNode struct {
+	kind    byte   // '{', '[' or 0 for other values
+	raw     []byte // Encoding of other values
+	entries []jsonEntry
+	live    int // Number of entries not removed
+	size    int // Length of the encoding, without removed entries
+}
+
+// jsonEntry is an object member or array element of a jsonNode
+type jsonEntry struct {
+	key     []byte // Quoted key of object members
+	val     *jsonNode
+	removed bool
+}
+
+// cost returns the length of the encoding of the entry within its
+// container, including the separating comma unless it is the only one
+func (n *jsonNode) cost(ent *jsonEntry) int {
+	c := ent.val.size
+	if n.kind == '{' {
+		c += len(ent.key) + 1
+	}
+	if n.live > 1 {
+		c++
+	}
+	return c
+}
+
+// parseJSON parses the JSON value b, which may hold whitespace, e.g. from
+// a Formatter or RawJSONFunc
+func parseJSON(b []byte) (*jsonNode, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return nil, fmt.Errorf("parsing JSON output: %w", err)
+	}
+	root, _ := parseNode(buf.Bytes(), 0)
+	return root, nil
+}
+
+// parseNode parses the compact JSON value starting at b[i], returning it
+// and the index after it. b must be valid JSON without whitespace.
+func parseNode(b []byte, i int) (*jsonNode, int) {
+	n := &jsonNode{}
+	switch b[i] {
+	case '{', '[':
+		n.kind = b[i]
+		n.size = 2
+		i++
+		for b[i] != '}' && b[i] != ']' {
+			if b[i] == ',' {
+				i++
+				n.size++
+			}
+			var ent jsonEntry
+			if n.kind == '{' {
+				j := scanString(b, i)
+				ent.key = b[i:j]
+				i = j + 1 // Skip ':'
+				n.size += len(ent.key) + 1
+			}
+			ent.val, i = parseNode(b, i)
+			n.size += ent.val.size
+			n.entries = append(n.entries, ent)
+		}
+		n.live = len(n.entries)
+		return n, i + 1
+	case '"':
+		j := scanString(b, i)
+		n.raw = b[i:j]
+		n.size = len(n.raw)
+		return n, j
+	}
+	j := i
+	for j < len(b) && b[j] != ',' && b[j] != '}' && b[j] != ']' {
+		j++
+	}
+	n.raw = b[i:j]
+	n.size = len(n.raw)
+	return n, j
+}
+
+// scanString returns the index after the JSON string starting at b[i]
+func scanString(b []byte, i int) int {
+	for i++; b[i] != '"'; i++ {
+		if b[i] == '\\' {
+			i++
+		}
+	}
+	return i + 1
+}
+
+// shrink removes entries from container n until its encoding is at least
+// excess bytes shorter, or no entries are left. The largest entries are
+// handled first: those no larger than what remains to be saved are
+// removed, and larger containers are shrunk in turn, so that the deepest
+// and largest values are elided while as much as possible is kept. It
+// returns the number of bytes saved.
+func (n *jsonNode) shrink(excess int) int {
+	order := make([]int, len(n.entries))
+	for i := range order {
+		order[i] = i
+	}
+	// Largest first, and later entries before earlier ones of equal size
+	slices.SortFunc(order, func(a, b int) int {
+		if d := n.entries[b].val.size - n.entries[a].val.size; d != 0 {
+			return d
+		}
+		return b - a
+	})
+
+	saved := 0
+	for _, i := range order {
+		if saved >= excess {
+			break
+		}
+		ent := &n.entries[i]
+		if c := n.cost(ent); c > excess-saved && ent.val.kind != 0 && ent.val.live > 0 {
+			s := ent.val.shrink(excess - saved)
+			n.size -= s
+			saved += s
+			if saved >= excess {
+				break
+			}
+		}
+		c := n.cost(ent)
+		ent.removed = true
+		n.live--
+		n.size -= c
+		saved += c
+	}
+	return saved
+}
+
+// write writes the encoding of n without removed entries, followed by the
+// truncation member of objects or element of arrays if mark is set
+func (n *jsonNode) write(w writer, mark bool) {
+	if n.kind == 0 {
+		w.Write(n.raw)
+		return
+	}
+	w.WriteByte(n.kind)
+	first := true
+	for i := range n.entries {
+		ent := &n.entries[i]
+		if ent.removed {
+			continue
+		}
+		if !first {
+			w.WriteByte(',')
+		}
+		first = false
+		if n.kind == '{' {
+			w.Write(ent.key)
+			w.WriteByte(':')
+		}
+		ent.val.write(w, false)
+	}
+	if mark {
+		if !first {
+			w.WriteByte(',')
+		}
+		if n.kind == '{' {
+			w.WriteString(truncatedKey)
+		} else {
+			w.WriteString(truncatedElement)
+		}
+	}
+	w.WriteByte(n.kind + 2) // '}' or ']'
+}
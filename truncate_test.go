@@ -0,0 +1,334 @@
+package protojson_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/apipb"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// TestTruncateLength tests truncation of long string and bytes values
+func TestTruncateLength(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *pb_basic.BasicTypes
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "ShortValuesUnchanged",
+			msg:  &pb_basic.BasicTypes{StringField: "abc", BytesField: []byte("abc")},
+			opts: protojson.MarshalOptions{TruncateLength: 3},
+			want: `{"stringField":"abc","bytesField":"YWJj"}`,
+		},
+		{
+			name: "String",
+			msg:  &pb_basic.BasicTypes{StringField: "abcdefghij"},
+			opts: protojson.MarshalOptions{TruncateLength: 4},
+			want: `{"stringField":"abcd...(+6 bytes)"}`,
+		},
+		{
+			name: "StringCharacterBoundary",
+			msg:  &pb_basic.BasicTypes{StringField: "a\u00e9\u00e9"},
+			opts: protojson.MarshalOptions{TruncateLength: 2},
+			want: "{\"stringField\":\"a...(+4 bytes)\"}",
+		},
+		{
+			name: "Base64",
+			msg:  &pb_basic.BasicTypes{BytesField: []byte("abcdef")},
+			opts: protojson.MarshalOptions{TruncateLength: 3},
+			want: `{"bytesField":"YWJj...(+3 bytes)"}`,
+		},
+		{
+			name: "Hex",
+			msg:  &pb_basic.BasicTypes{BytesField: []byte{1, 2, 3, 4}},
+			opts: protojson.MarshalOptions{TruncateLength: 2, BytesEncoding: protojson.BytesHex},
+			want: `{"bytesField":"0102...(+2 bytes)"}`,
+		},
+		{
+			name: "Array",
+			msg:  &pb_basic.BasicTypes{BytesField: []byte{1, 2, 3, 4}},
+			opts: protojson.MarshalOptions{TruncateLength: 2, BytesEncoding: protojson.BytesArray},
+			want: `{"bytesField":[1,2,"...(+2 bytes)"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestTruncateOutput tests cutting output down to MaxOutputBytes
+func TestTruncateOutput(t *testing.T) {
+	user := &pb_basic.User{
+		Id:          "1",
+		Name:        "Alice",
+		Permissions: []string{"read", "write", "admin"},
+		Profile: &pb_basic.Profile{
+			Bio:     strings.Repeat("b", 100),
+			Address: &pb_basic.Address{City: "Tokyo", Country: "JP"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		max     int
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Fits",
+			max:  1000,
+			want: `{"id":"1","name":"Alice","permissions":["read","write","admin"],"profile":{"bio":"` + strings.Repeat("b", 100) + `","address":{"city":"Tokyo","country":"JP"}}}`,
+		},
+		{
+			name: "LargestValueElided",
+			max:  140,
+			want: `{"id":"1","name":"Alice","permissions":["read","write","admin"],"profile":{"address":{"city":"Tokyo","country":"JP"}},"_truncated":true}`,
+		},
+		{
+			name: "NestedValuesElided",
+			max:  120,
+			want: `{"id":"1","name":"Alice","permissions":["read","write","admin"],"profile":{"address":{}},"_truncated":true}`,
+		},
+		{
+			name: "LaterElementsElided",
+			max:  66,
+			want: `{"id":"1","name":"Alice","permissions":["read"],"_truncated":true}`,
+		},
+		{
+			name: "OnlyMarker",
+			max:  len(`{"_truncated":true}`),
+			want: `{"_truncated":true}`,
+		},
+		{
+			name:    "TooSmall",
+			max:     10,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := protojson.MarshalOptions{MaxOutputBytes: tt.max, TruncateOutput: true, Indent: "  "}
+			got, err := opts.Marshal(user)
+			if tt.wantErr {
+				if !errors.Is(err, protojson.ErrOutputTooLarge) {
+					t.Fatalf("Marshal() error = %v, want ErrOutputTooLarge", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+			if len(got) > tt.max {
+				t.Errorf("Marshal() wrote %d bytes, want at most %d", len(got), tt.max)
+			}
+		})
+	}
+}
+
+// TestTruncateOutputLimits tests that output is valid JSON within every
+// limit
+func TestTruncateOutputLimits(t *testing.T) {
+	msg := &pb_basic.MapFields{
+		StringMap:  map[string]string{"a": "alpha", "b": strings.Repeat("beta", 10), "c": "\"quoted\""},
+		IntKeyMap:  map[int32]string{1: "one", 2: "two"},
+		MessageMap: map[string]*pb_basic.Value{"v": {Data: "data", Count: 3}},
+	}
+	full, err := protojson.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	for max := len(`{"_truncated":true}`); max <= len(full)+1; max++ {
+		opts := protojson.MarshalOptions{MaxOutputBytes: max, TruncateOutput: true}
+		got, err := opts.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Marshal() with limit %d error = %v", max, err)
+		}
+		if len(got) > max {
+			t.Errorf("Marshal() with limit %d wrote %d bytes", max, len(got))
+		}
+		if !json.Valid(got) {
+			t.Errorf("Marshal() with limit %d wrote invalid JSON %s", max, got)
+		}
+		if max >= len(full) && !bytes.Equal(got, full) {
+			t.Errorf("Marshal() with limit %d = %s, want %s", max, got, full)
+		}
+	}
+}
+
+// TestTruncateOutputValues tests truncating output holding whitespace and
+// top-level values other than objects
+func TestTruncateOutputValues(t *testing.T) {
+	spaced := map[protoreflect.FullName]protojson.Formatter{
+		"google.protobuf.SourceContext": func(protoreflect.Message, protojson.MarshalOptions) ([]byte, error) {
+			return []byte("{ }"), nil
+		},
+	}
+
+	tests := []struct {
+		name    string
+		msg     proto.Message
+		opts    protojson.MarshalOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Whitespace",
+			msg:  &apipb.Api{Name: strings.Repeat("n", 120), SourceContext: &sourcecontextpb.SourceContext{}},
+			opts: protojson.MarshalOptions{MaxOutputBytes: 100, Formatters: spaced},
+			want: `{"sourceContext":{},"_truncated":true}`,
+		},
+		{
+			name: "WhitespaceFits",
+			msg:  &apipb.Api{Name: "n", SourceContext: &sourcecontextpb.SourceContext{}},
+			opts: protojson.MarshalOptions{MaxOutputBytes: 31, Formatters: spaced},
+			want: `{"name":"n","sourceContext":{}}`,
+		},
+		{
+			name: "Array",
+			msg: &structpb.ListValue{Values: []*structpb.Value{
+				structpb.NewStringValue(strings.Repeat("x", 50)),
+				structpb.NewStringValue(strings.Repeat("y", 50)),
+				structpb.NewStringValue("z"),
+			}},
+			opts: protojson.MarshalOptions{MaxOutputBytes: 60},
+			want: `["z","...(truncated)"]`,
+		},
+		{
+			name:    "Scalar",
+			msg:     wrapperspb.String(strings.Repeat("s", 100)),
+			opts:    protojson.MarshalOptions{MaxOutputBytes: 60},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.TruncateOutput = true
+			got, err := tt.opts.Marshal(tt.msg)
+			if tt.wantErr {
+				if !errors.Is(err, protojson.ErrOutputTooLarge) {
+					t.Fatalf("Marshal() error = %v, want ErrOutputTooLarge", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMaxElements tests limiting the number of list elements and map
+// entries written
+func TestMaxElements(t *testing.T) {
+	numbers := make([]int32, 100)
+	for i := range numbers {
+		numbers[i] = int32(i)
+	}
+	stringMap := map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}
+
+	tests := []struct {
+		name string
+		msg  proto.Message
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "List",
+			msg:  &pb_basic.RepeatedFields{Numbers: numbers, Strings: []string{"x"}},
+			opts: protojson.MarshalOptions{MaxListElements: 3},
+			want: `{"strings":["x"],"numbers":[0,1,2,"...(+97 items)"]}`,
+		},
+		{
+			name: "ListIndented",
+			msg:  &pb_basic.RepeatedFields{Numbers: numbers[:3]},
+			opts: protojson.MarshalOptions{MaxListElements: 1, Indent: " "},
+			want: "{\n \"numbers\": [\n  0,\n  \"...(+2 items)\"\n ]\n}",
+		},
+		{
+			name: "Map",
+			msg:  &pb_basic.MapFields{StringMap: stringMap},
+			opts: protojson.MarshalOptions{MaxMapEntries: 2},
+			want: `{"stringMap":{"a":"1","b":"2","...(+2 entries)":null}}`,
+		},
+		{
+			name: "UnorderedMap",
+			msg:  &pb_basic.MapFields{StringMap: map[string]string{"a": "1"}},
+			opts: protojson.MarshalOptions{MaxMapEntries: 1, UnorderedMaps: true},
+			want: `{"stringMap":{"a":"1"}}`,
+		},
+		{
+			name: "Struct",
+			msg:  mustStruct(t, map[string]any{"a": 1, "b": []any{1, 2, 3}, "c": 3}),
+			opts: protojson.MarshalOptions{MaxMapEntries: 2, MaxListElements: 2},
+			want: `{"a":1,"b":[1,2,"...(+1 items)"],"...(+1 entries)":null}`,
+		},
+		{
+			name: "WithinLimits",
+			msg:  &pb_basic.RepeatedFields{Numbers: numbers[:3]},
+			opts: protojson.MarshalOptions{MaxListElements: 3},
+			want: `{"numbers":[0,1,2]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestMaxMapEntriesUnordered tests the marker of maps written in iteration
+// order
+func TestMaxMapEntriesUnordered(t *testing.T) {
+	msg := &pb_basic.MapFields{StringMap: map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}}
+	opts := protojson.MarshalOptions{MaxMapEntries: 3, UnorderedMaps: true}
+	got, err := opts.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var doc struct {
+		StringMap map[string]*string `json:"stringMap"`
+	}
+	if err := json.Unmarshal(got, &doc); err != nil {
+		t.Fatalf("json.Unmarshal(%s) error = %v", got, err)
+	}
+	if len(doc.StringMap) != 4 || doc.StringMap["...(+1 entries)"] != nil {
+		t.Errorf("Marshal() = %s, want 3 entries and a marker", got)
+	}
+}
@@ -0,0 +1,56 @@
+package protojson
+
+import "google.golang.org/protobuf/reflect/protoreflect"
+
+// TypeNameScope selects the messages written with a member holding the
+// full name of their type.
+type TypeNameScope int
+
+const (
+	// TypeNameNone writes no type names.
+	TypeNameNone TypeNameScope = iota
+	// TypeNameTopLevel writes the type name of the top-level message only.
+	TypeNameTopLevel
+	// TypeNameNested writes the type names of messages in fields, list
+	// elements and map values only.
+	TypeNameNested
+	// TypeNameAll writes the type names of all messages.
+	TypeNameAll
+)
+
+// defaultTypeNameKey is the member name used for type names when
+// MarshalOptions.TypeNameKey is empty
+const defaultTypeNameKey = "@type"
+
+// typeNamed reports whether a message written as an object of its fields
+// starts with its type name, consuming the top-level state of e
+func (e *encoder) typeNamed() bool {
+	top := e.root
+	e.root = false
+	switch e.opts.EmitTypeName {
+	case TypeNameTopLevel:
+		return top
+	case TypeNameNested:
+		return !top
+	}
+	return e.opts.EmitTypeName == TypeNameAll
+}
+
+// writeTypeName writes the member holding the type name name as the first
+// member of an object
+func (e *encoder) writeTypeName(name protoreflect.FullName) error {
+	e.writeIndent()
+	if err := e.marshalString(e.typeNameKey()); err != nil {
+		return err
+	}
+	e.writeColon()
+	return e.marshalString(string(name))
+}
+
+// typeNameKey returns the member name of type names
+func (e *encoder) typeNameKey() string {
+	if e.opts.TypeNameKey == "" {
+		return defaultTypeNameKey
+	}
+	return e.opts.TypeNameKey
+}
@@ -0,0 +1,107 @@
+package protojson_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// TestEmitTypeName tests writing the type names of messages
+func TestEmitTypeName(t *testing.T) {
+	nested := &pb_basic.Nested{Id: "a", Inner: &pb_basic.Inner{Name: "b"}}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		msg  proto.Message
+		want string
+	}{
+		{
+			name: "None",
+			msg:  nested,
+			want: `{"id":"a","inner":{"name":"b"}}`,
+		},
+		{
+			name: "TopLevel",
+			opts: protojson.MarshalOptions{EmitTypeName: protojson.TypeNameTopLevel},
+			msg:  nested,
+			want: `{"@type":"test.nested.Nested","id":"a","inner":{"name":"b"}}`,
+		},
+		{
+			name: "Nested",
+			opts: protojson.MarshalOptions{EmitTypeName: protojson.TypeNameNested},
+			msg:  nested,
+			want: `{"id":"a","inner":{"@type":"test.nested.Inner","name":"b"}}`,
+		},
+		{
+			name: "All",
+			opts: protojson.MarshalOptions{EmitTypeName: protojson.TypeNameAll},
+			msg:  nested,
+			want: `{"@type":"test.nested.Nested","id":"a","inner":{"@type":"test.nested.Inner","name":"b"}}`,
+		},
+		{
+			name: "CustomKey",
+			opts: protojson.MarshalOptions{EmitTypeName: protojson.TypeNameTopLevel, TypeNameKey: "kind"},
+			msg:  &pb_basic.Inner{},
+			want: `{"kind":"test.nested.Inner"}`,
+		},
+		{
+			name: "ListAndMap",
+			opts: protojson.MarshalOptions{EmitTypeName: protojson.TypeNameNested},
+			msg: &pb_basic.MapFields{
+				MessageMap: map[string]*pb_basic.Value{"k": {Count: 1}},
+			},
+			want: `{"messageMap":{"k":{"@type":"test.maps.Value","count":1}}}`,
+		},
+		{
+			name: "WellKnownTypesUnchanged",
+			opts: protojson.MarshalOptions{EmitTypeName: protojson.TypeNameAll},
+			msg:  &pb_basic.WellKnownTypes{Duration: durationpb.New(0)},
+			want: `{"@type":"test.wellknown.WellKnownTypes","duration":"0s"}`,
+		},
+		{
+			name: "AnyContentsUnchanged",
+			opts: protojson.MarshalOptions{EmitTypeName: protojson.TypeNameNested},
+			msg:  &pb_basic.WellKnownTypes{Any: mustAny(t, &pb_basic.Inner{Name: "b"})},
+			want: `{"any":{"@type":"type.googleapis.com/test.nested.Inner","name":"b"}}`,
+		},
+		{
+			name: "Indent",
+			opts: protojson.MarshalOptions{EmitTypeName: protojson.TypeNameTopLevel, Indent: " "},
+			msg:  &pb_basic.Inner{Name: "b"},
+			want: "{\n \"@type\": \"test.nested.Inner\",\n \"name\": \"b\"\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestEmitTypeNameList tests that each message of a list is top-level
+func TestEmitTypeNameList(t *testing.T) {
+	opts := protojson.MarshalOptions{EmitTypeName: protojson.TypeNameTopLevel}
+	got, err := opts.MarshalList([]proto.Message{
+		&pb_basic.Inner{Name: "a", Deep: &pb_basic.DeepInner{}},
+		&pb_basic.Value{Data: "b"},
+	})
+	if err != nil {
+		t.Fatalf("MarshalList() error = %v", err)
+	}
+	want := `[{"@type":"test.nested.Inner","name":"a","deep":{}},{"@type":"test.maps.Value","data":"b"}]`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("MarshalList() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,99 @@
+package protojson
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// defaultUnknownFieldsKey is the member name used for unknown fields when
+// MarshalOptions.UnknownFieldsKey is empty
+const defaultUnknownFieldsKey = "_unknown"
+
+// resolver returns the configured Resolver, defaulting to the global registry
+func (e *encoder) resolver() typeResolver {
+	return resolverOf(e.opts.Resolver)
+}
+
+// marshalUnknown writes the unknown fields of m. Fields that resolve to an
+// extension of m are decoded and written like set extensions; the remaining
+// bytes are written base64-encoded under UnknownFieldsKey.
+func (e *encoder) marshalUnknown(m protoreflect.Message, first bool) (bool, error) {
+	ext, rest, err := e.unknownFields(m)
+	if err != nil {
+		return first, err
+	}
+	if ext != nil {
+		if first, err = e.marshalExtensions(ext, first); err != nil {
+			return first, err
+		}
+	}
+
+	if len(rest) > 0 {
+		if !first {
+			e.writeComma()
+		}
+		first = false
+
+		e.writeIndent()
+		if err := e.marshalString(e.unknownFieldsKey()); err != nil {
+			return first, err
+		}
+		e.writeColon()
+		e.w.WriteByte('"')
+		e.writeBase64(base64.StdEncoding, rest)
+		e.w.WriteByte('"')
+	}
+
+	return first, e.checkLimit()
+}
+
+// unknownFields splits the unknown fields of m into a message of its type
+// holding those that resolve to an extension of m, or nil if there are
+// none, and the encoding of the remaining ones
+func (e *encoder) unknownFields(m protoreflect.Message) (protoreflect.Message, []byte, error) {
+	resolver := e.resolver()
+	name := m.Descriptor().FullName()
+
+	var resolved, rest []byte
+	raw := m.GetUnknown()
+	for len(raw) > 0 {
+		num, typ, n := protowire.ConsumeTag(raw)
+		if n < 0 {
+			return nil, nil, fmt.Errorf("unknown fields of %s: %w", name, protowire.ParseError(n))
+		}
+		l := protowire.ConsumeFieldValue(num, typ, raw[n:])
+		if l < 0 {
+			return nil, nil, fmt.Errorf("unknown fields of %s: %w", name, protowire.ParseError(l))
+		}
+		field := raw[:n+l]
+		raw = raw[n+l:]
+
+		if _, err := resolver.FindExtensionByNumber(name, num); err == nil {
+			resolved = append(resolved, field...)
+		} else {
+			rest = append(rest, field...)
+		}
+	}
+
+	if len(resolved) == 0 {
+		return nil, rest, nil
+	}
+	ext := m.Type().New()
+	opts := proto.UnmarshalOptions{AllowPartial: true, Resolver: resolver}
+	if err := opts.Unmarshal(resolved, ext.Interface()); err != nil {
+		return nil, nil, fmt.Errorf("unknown fields of %s: %w", name, err)
+	}
+	return ext, rest, nil
+}
+
+// unknownFieldsKey returns the member name of unknown fields
+func (e *encoder) unknownFieldsKey() string {
+	if e.opts.UnknownFieldsKey == "" {
+		return defaultUnknownFieldsKey
+	}
+	return e.opts.UnknownFieldsKey
+}
@@ -0,0 +1,71 @@
+package protojson_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// TestEmitUnknownFields tests writing unknown fields as extensions and as
+// base64 wire data
+func TestEmitUnknownFields(t *testing.T) {
+	// Field 50 is unknown to Extendable; field 100 is the ext_count extension
+	var raw []byte
+	raw = protowire.AppendTag(raw, 50, protowire.VarintType)
+	raw = protowire.AppendVarint(raw, 1)
+	raw = protowire.AppendTag(raw, 100, protowire.VarintType)
+	raw = protowire.AppendVarint(raw, 3)
+
+	msg := &pb_basic.Extendable{Name: proto.String("name")}
+	msg.ProtoReflect().SetUnknown(raw)
+
+	noExtensions := new(protoregistry.Types)
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{
+			name: "Default",
+			want: `{"name":"name"}`,
+		},
+		{
+			name: "EmitUnknownFields",
+			opts: protojson.MarshalOptions{EmitUnknownFields: true},
+			want: `{"name":"name","[test.proto2.ext_count]":3,"_unknown":"kAMB"}`,
+		},
+		{
+			name: "UnresolvedExtension",
+			opts: protojson.MarshalOptions{EmitUnknownFields: true, Resolver: noExtensions},
+			want: `{"name":"name","_unknown":"kAMBoAYD"}`,
+		},
+		{
+			name: "CustomKey",
+			opts: protojson.MarshalOptions{EmitUnknownFields: true, UnknownFieldsKey: "$raw"},
+			want: `{"name":"name","[test.proto2.ext_count]":3,"$raw":"kAMB"}`,
+		},
+		{
+			name: "Multiline",
+			opts: protojson.MarshalOptions{EmitUnknownFields: true, Multiline: true},
+			want: "{\n  \"name\": \"name\",\n  \"[test.proto2.ext_count]\": 3,\n  \"_unknown\": \"kAMB\"\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package protojson
+
+// UnpopulatedKind is a set of kinds of fields, selecting the unpopulated
+// fields that are written by MarshalOptions.EmitUnpopulatedKinds.
+type UnpopulatedKind int
+
+const (
+	// UnpopulatedScalars selects singular fields without presence, such as
+	// proto3 scalar fields, written as their zero value.
+	UnpopulatedScalars UnpopulatedKind = 1 << iota
+	// UnpopulatedLists selects repeated fields, written as [].
+	UnpopulatedLists
+	// UnpopulatedMaps selects map fields, written as {}.
+	UnpopulatedMaps
+	// UnpopulatedOptional selects fields with explicit presence outside of
+	// oneofs, such as message fields and proto2 optional fields, written
+	// as null.
+	UnpopulatedOptional
+
+	// UnpopulatedAll selects all kinds, like EmitUnpopulated.
+	UnpopulatedAll = UnpopulatedScalars | UnpopulatedLists | UnpopulatedMaps | UnpopulatedOptional
+)
+
+// unpopulatedKindOf returns the kind of the field f of a message plan, or
+// zero for fields in oneofs, which are never written unpopulated
+func unpopulatedKindOf(f *fieldPlan) UnpopulatedKind {
+	switch {
+	case f.oneof:
+		return 0
+	case f.fd.IsList():
+		return UnpopulatedLists
+	case f.fd.IsMap():
+		return UnpopulatedMaps
+	case f.presence:
+		return UnpopulatedOptional
+	}
+	return UnpopulatedScalars
+}
+
+// unpopulatedPaths holds EmitUnpopulatedPaths and OmitUnpopulatedPaths,
+// split into segments
+type unpopulatedPaths struct {
+	emit [][]string
+	omit [][]string
+}
+
+// emitKinds returns the kinds of unpopulated fields written with opts,
+// before per-path overrides
+func emitKinds(opts *MarshalOptions) UnpopulatedKind {
+	kinds := opts.EmitUnpopulatedKinds
+	if opts.EmitUnpopulated {
+		kinds = UnpopulatedAll
+	}
+	if opts.EmitDefaultValues {
+		kinds |= UnpopulatedScalars | UnpopulatedLists | UnpopulatedMaps
+	}
+	return kinds
+}
+
+// emitUnpopulated reports whether the unpopulated field f is written
+func (e *encoder) emitUnpopulated(f *fieldPlan) bool {
+	if f.unpopulated == 0 {
+		return false
+	}
+	if p := e.unpopulated; p != nil {
+		// The path of the field, without the step entering it yet
+		path := append(e.path, PathStep{Field: f.fd, Index: -1, name: string(f.fd.Name())})
+		for _, pattern := range p.omit {
+			if match, _ := matchPath(pattern, path, false); match {
+				return false
+			}
+		}
+		for _, pattern := range p.emit {
+			if match, _ := matchPath(pattern, path, false); match {
+				return true
+			}
+		}
+	}
+	return e.emitKinds&f.unpopulated != 0
+}
@@ -0,0 +1,97 @@
+package protojson_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestEmitUnpopulatedSelective tests writing unpopulated fields selected by
+// kind and path
+func TestEmitUnpopulatedSelective(t *testing.T) {
+	user := &pb_basic.User{Id: "u1", Profile: &pb_basic.Profile{Bio: "b"}}
+
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		msg  proto.Message
+		want string
+	}{
+		{
+			name: "ListsAndMaps",
+			opts: protojson.MarshalOptions{EmitUnpopulatedKinds: protojson.UnpopulatedLists | protojson.UnpopulatedMaps},
+			msg:  user,
+			want: `{"id":"u1","permissions":[],"profile":{"bio":"b","socialLinks":[]},"metadata":{}}`,
+		},
+		{
+			name: "Scalars",
+			opts: protojson.MarshalOptions{EmitUnpopulatedKinds: protojson.UnpopulatedScalars},
+			msg:  user,
+			want: `{"id":"u1","name":"","email":"","role":"ROLE_UNSPECIFIED","profile":{"avatarUrl":"","bio":"b"}}`,
+		},
+		{
+			name: "Optional",
+			opts: protojson.MarshalOptions{EmitUnpopulatedKinds: protojson.UnpopulatedOptional},
+			msg:  user,
+			want: `{"id":"u1","profile":{"bio":"b","address":null}}`,
+		},
+		{
+			name: "AddsToEmitDefaultValues",
+			opts: protojson.MarshalOptions{EmitDefaultValues: true, EmitUnpopulatedKinds: protojson.UnpopulatedOptional},
+			msg:  &pb_basic.Nested{},
+			want: `{"id":"","inner":null}`,
+		},
+		{
+			name: "OneofsNeverWritten",
+			opts: protojson.MarshalOptions{EmitUnpopulatedKinds: protojson.UnpopulatedAll, EmitUnpopulatedPaths: []string{"string_value"}},
+			msg:  &pb_basic.OneOfFields{},
+			want: `{"id":""}`,
+		},
+		{
+			name: "EmitPath",
+			opts: protojson.MarshalOptions{EmitUnpopulatedPaths: []string{"profile"}},
+			msg:  user,
+			want: `{"id":"u1","profile":{"avatarUrl":"","bio":"b","address":null,"socialLinks":[]}}`,
+		},
+		{
+			name: "EmitPathUnsetMessage",
+			opts: protojson.MarshalOptions{EmitUnpopulatedPaths: []string{"profile", "metadata"}},
+			msg:  &pb_basic.User{},
+			want: `{"profile":null,"metadata":{}}`,
+		},
+		{
+			name: "OmitPath",
+			opts: protojson.MarshalOptions{EmitUnpopulated: true, OmitUnpopulatedPaths: []string{"profile.address", "name", "email"}},
+			msg:  user,
+			want: `{"id":"u1","role":"ROLE_UNSPECIFIED","permissions":[],` +
+				`"profile":{"avatarUrl":"","bio":"b","socialLinks":[]},"metadata":{}}`,
+		},
+		{
+			name: "OmitPathPrecedence",
+			opts: protojson.MarshalOptions{EmitUnpopulatedPaths: []string{"profile"}, OmitUnpopulatedPaths: []string{"profile.*"}},
+			msg:  user,
+			want: `{"id":"u1","profile":{"bio":"b"}}`,
+		},
+		{
+			name: "Wildcard",
+			opts: protojson.MarshalOptions{EmitUnpopulatedPaths: []string{"people.*.age"}},
+			msg:  &pb_basic.RepeatedNested{People: []*pb_basic.SimplePerson{{Name: "a"}, {Age: 2}}},
+			want: `{"people":[{"name":"a","age":0},{"age":2}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
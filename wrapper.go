@@ -0,0 +1,68 @@
+package protojson
+
+import (
+	"bytes"
+	"errors"
+
+	stdprotojson "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// JSONWrapper returns m wrapped so that encoding/json marshals it with
+// this package using opts, and unmarshals into it with the standard
+// package, instead of encoding the generated struct field by field. It is
+// meant for messages held in fields of ordinary Go structs:
+//
+//	type Event struct {
+//		Kind string                `json:"kind"`
+//		User *protojson.JSONMessage `json:"user"`
+//	}
+//
+//	data, err := json.Marshal(Event{Kind: "created", User: protojson.JSONWrapper(user, opts)})
+func JSONWrapper(m proto.Message, opts MarshalOptions) *JSONMessage {
+	return &JSONMessage{Message: m, Options: opts}
+}
+
+// JSONMessage is a message that implements json.Marshaler and
+// json.Unmarshaler. See JSONWrapper.
+type JSONMessage struct {
+	// Message is the wrapped message. A nil Message is marshaled as null.
+	Message proto.Message
+
+	// Options are the options Message is marshaled with. Unmarshaling
+	// resolves types through Options.Resolver.
+	Options MarshalOptions
+}
+
+// MarshalJSON returns the JSON encoding of w.Message.
+func (w JSONMessage) MarshalJSON() ([]byte, error) {
+	if w.Message == nil || !w.Message.ProtoReflect().IsValid() {
+		return []byte("null"), nil
+	}
+	opts := w.Options
+	opts.Comments = nil
+	return opts.Marshal(w.Message)
+}
+
+// UnmarshalJSON parses data into w.Message, which must hold a message of
+// the expected type. A typed nil message, such as (*pb.User)(nil), is
+// replaced with a new message of that type. null leaves w.Message
+// unchanged, following the convention of encoding/json.
+func (w *JSONMessage) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if w.Message == nil {
+		return errors.New("cannot unmarshal into JSONMessage without a message")
+	}
+	m := w.Message
+	if !m.ProtoReflect().IsValid() {
+		m = m.ProtoReflect().Type().New().Interface()
+	}
+	opts := stdprotojson.UnmarshalOptions{Resolver: resolverOf(w.Options.Resolver)}
+	if err := opts.Unmarshal(data, m); err != nil {
+		return err
+	}
+	w.Message = m
+	return nil
+}
@@ -0,0 +1,93 @@
+package protojson_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wreulicke/protojson"
+	pb_basic "github.com/wreulicke/protojson/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// wrapperEvent is an ordinary Go struct holding a message
+type wrapperEvent struct {
+	Kind string                 `json:"kind"`
+	User *protojson.JSONMessage `json:"user"`
+}
+
+// TestJSONWrapperMarshal tests messages in structs marshaled with
+// encoding/json
+func TestJSONWrapperMarshal(t *testing.T) {
+	opts := protojson.MarshalOptions{
+		FieldMaskFunc: func(fd protoreflect.FieldDescriptor) bool { return fd.Name() == "email" },
+	}
+
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{
+			name: "struct field",
+			v:    wrapperEvent{Kind: "created", User: protojson.JSONWrapper(&pb_basic.User{Id: "1", Name: "alice", Email: "alice@example.com"}, opts)},
+			want: `{"kind":"created","user":{"id":"1","name":"alice","email":"***"}}`,
+		},
+		{
+			name: "nil wrapper",
+			v:    wrapperEvent{Kind: "deleted"},
+			want: `{"kind":"deleted","user":null}`,
+		},
+		{
+			name: "nil message",
+			v:    wrapperEvent{Kind: "deleted", User: protojson.JSONWrapper((*pb_basic.User)(nil), opts)},
+			want: `{"kind":"deleted","user":null}`,
+		},
+		{
+			name: "value",
+			v:    []protojson.JSONMessage{{Message: &pb_basic.User{Name: "alice"}}},
+			want: `[{"name":"alice"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("json.Marshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestJSONWrapperUnmarshal tests messages in structs unmarshaled with
+// encoding/json
+func TestJSONWrapperUnmarshal(t *testing.T) {
+	data := []byte(`{"kind":"created","user":{"id":"1","name":"alice"}}`)
+	want := &pb_basic.User{Id: "1", Name: "alice"}
+
+	for _, m := range []proto.Message{&pb_basic.User{Name: "previous"}, (*pb_basic.User)(nil)} {
+		ev := wrapperEvent{User: protojson.JSONWrapper(m, protojson.MarshalOptions{})}
+		if err := json.Unmarshal(data, &ev); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		if diff := cmp.Diff(proto.Message(want), ev.User.Message, protocmp.Transform()); diff != "" {
+			t.Errorf("json.Unmarshal() mismatch (-want +got):\n%s", diff)
+		}
+	}
+
+	var ev wrapperEvent
+	if err := json.Unmarshal([]byte(`{"user":null}`), &ev); err != nil || ev.User != nil {
+		t.Errorf("json.Unmarshal(null) = %v, %v, want nil wrapper and no error", ev.User, err)
+	}
+
+	ev = wrapperEvent{User: &protojson.JSONMessage{}}
+	if err := json.Unmarshal(data, &ev); err == nil {
+		t.Error("json.Unmarshal() into a wrapper without a message succeeded, want error")
+	}
+}